@@ -0,0 +1,100 @@
+// app_config composes the app's configuration from independent config files, one per concern:
+// training, server, and kinematics. Each file is of the same form as config.yaml, a kind and
+// its definition:
+//
+//	kind: ServerConfig
+//	def:
+//	  host: localhost
+//	  port: 8080
+package app_config
+
+import (
+	"fmt"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+
+	"github.com/spf13/viper"
+)
+
+// The kinds of config files.
+const (
+	KindTraining   = "TrainingConfig"
+	KindServer     = "ServerConfig"
+	KindKinematics = "KinematicsConfig"
+)
+
+// AppConfig is the app's configuration, composed from one file per kind.
+type AppConfig struct {
+	// Training is the training config, which is required.
+	Training *reinforcement.TrainingConfig
+	// TrainingPath is the path of the training config's file.
+	TrainingPath string
+	// Server is the server config, whose fields are empty if omitted.
+	Server ServerConfig
+	// Kinematics are the kinematics, or nil if omitted. If set, they are also set
+	// on the training config, overriding any kinematics it specifies.
+	Kinematics *grid_world.Kinematics
+}
+
+// ServerConfig describes where the server listens, and how often it publishes view updates
+// to clients; zero publish intervals are set to the server's defaults.
+type ServerConfig struct {
+	Host string `mapstructure:"host"`
+	Port string `mapstructure:"port"`
+	// The interval over which view updates are batched, e.g. 20ms.
+	BatchWindow time.Duration `mapstructure:"batchWindow"`
+	// The min interval between updates sent to each client, e.g. 100ms.
+	PublishResolution time.Duration `mapstructure:"publishResolution"`
+	// The secret required of websocket and control requests as their 'token' query param; empty
+	// disables auth. See server.WithAuthToken.
+	AuthToken string `mapstructure:"authToken"`
+}
+
+// LoadConfig loads and composes the passed config files, which may be in any order, but must
+// include a training config and must not repeat a kind. Each file is read by its own viper
+// instance, such that the files are independent.
+func LoadConfig(paths ...string) (*AppConfig, error) {
+	appConfig := &AppConfig{}
+	loaded := map[string]string{}
+	for _, path := range paths {
+		vp := viper.New()
+		vp.SetConfigFile(path)
+		vp.SetConfigType("yaml")
+		if err := vp.ReadInConfig(); err != nil {
+			return nil, err
+		}
+
+		kind := vp.GetString("kind")
+		if prev, ok := loaded[kind]; ok {
+			return nil, fmt.Errorf("%s is defined by both %s and %s", kind, prev, path)
+		}
+		loaded[kind] = path
+
+		var err error
+		switch kind {
+		case KindTraining:
+			appConfig.Training, err = reinforcement.FromYaml(path)
+			appConfig.TrainingPath = path
+		case KindServer:
+			err = vp.UnmarshalKey("def", &appConfig.Server)
+		case KindKinematics:
+			appConfig.Kinematics = &grid_world.Kinematics{}
+			err = vp.UnmarshalKey("def", appConfig.Kinematics)
+		default:
+			err = fmt.Errorf("unknown kind %q", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if appConfig.Training == nil {
+		return nil, fmt.Errorf("no %s among %v", KindTraining, paths)
+	}
+	if appConfig.Kinematics != nil {
+		appConfig.Training.Kinematics = appConfig.Kinematics
+	}
+	return appConfig, nil
+}
@@ -0,0 +1,107 @@
+package app_config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	trainingYaml = `kind: TrainingConfig
+def:
+  hyperParams:
+  - key: epsilon
+    val: 0.2
+  algorithm:
+    kind: qlearning
+`
+	serverYaml = `kind: ServerConfig
+def:
+  host: localhost
+  port: "9090"
+  publishResolution: 250ms
+  authToken: secret
+`
+	kinematicsYaml = `kind: KinematicsConfig
+def:
+  maxVelocity: 6
+  maxAcceleration: 2
+`
+)
+
+// writeConfig writes the passed yaml to a file in dir, returning its path.
+func writeConfig(dir, name, yaml string) string {
+	path := filepath.Join(dir, name)
+	So(os.WriteFile(path, []byte(yaml), 0o644), ShouldBeNil)
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	Convey("Given training, server, and kinematics config files", t, func() {
+		dir := t.TempDir()
+		training := writeConfig(dir, "training.yaml", trainingYaml)
+		server := writeConfig(dir, "server.yaml", serverYaml)
+		kinematics := writeConfig(dir, "kinematics.yaml", kinematicsYaml)
+
+		Convey("When all are loaded", func() {
+			appConfig, err := LoadConfig(server, kinematics, training)
+
+			Convey("Then each is composed into the app config", func() {
+				So(err, ShouldBeNil)
+				So(appConfig.Training.GetHyperParamOrDefault("epsilon", 0), ShouldEqual, 0.2)
+				So(appConfig.Training.Algorithm.Kind, ShouldEqual, reinforcement.AlgQLearning)
+				So(appConfig.TrainingPath, ShouldEqual, training)
+				So(appConfig.Server, ShouldResemble, ServerConfig{
+					Host:              "localhost",
+					Port:              "9090",
+					PublishResolution: 250 * time.Millisecond,
+					AuthToken:         "secret",
+				})
+				So(*appConfig.Kinematics, ShouldResemble, grid_world.Kinematics{MaxVelocity: 6, MaxAcceleration: 2})
+			})
+
+			Convey("Then the kinematics apply to training", func() {
+				So(appConfig.Training.GetKinematics(), ShouldResemble, *appConfig.Kinematics)
+			})
+		})
+
+		Convey("When only the training config is loaded", func() {
+			appConfig, err := LoadConfig(training)
+
+			Convey("Then the others are empty", func() {
+				So(err, ShouldBeNil)
+				So(appConfig.Server, ShouldResemble, ServerConfig{})
+				So(appConfig.Kinematics, ShouldBeNil)
+				So(appConfig.Training.GetKinematics(), ShouldResemble, grid_world.DefaultKinematics)
+			})
+		})
+
+		Convey("Then a missing training config is an error", func() {
+			_, err := LoadConfig(server, kinematics)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then a repeated kind is an error", func() {
+			other := writeConfig(dir, "other.yaml", serverYaml)
+			_, err := LoadConfig(training, server, other)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then an unknown kind is an error", func() {
+			unknown := writeConfig(dir, "unknown.yaml", "kind: NetworkConfig\ndef:\n  foo: bar\n")
+			_, err := LoadConfig(training, unknown)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then a missing file is an error", func() {
+			_, err := LoadConfig(training, filepath.Join(dir, "missing.yaml"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -3,44 +3,30 @@ package atomic_float
 import (
 	"math"
 	"sync/atomic"
-	"unsafe"
 )
 
-// Notes:
-// - consider gc side effects
-// - consider race conditions
-// This code 'checks out' despite the code-smell of using the unsafe package.
-// But beware the tight guidelines, and minimize critical regions and pointers.
-// For example, no unsafe pointer should be stored for more than a few lines of context,
-// since the gc may move the original variable around, such that the original pointer
-// no longer refers to the variable's location:
-// 	tmp := unintptr(unsafe.Pointer(&x)) + unsafe.Offsetof(x.b)
-// In this code the gc may run, see that &x is no longer referenced, move it,
-// and thus tmp refers to a stale location.
-
 // AtomicFloat64 encapsulates a float64 for non-locking atomic operations.
-// WARNING: THIS CODE NEEDS REVIEW BY A GOLANG EXPERT. DO NOT TRUST THIS CODE FOR PRODUCTION.
 // I came up with this to cheat my way out the problem of locking a very large matrix accessed
 // by a much smaller number of workers. Implementing an atomic float precludes the need for locks.
-// However this was only for a personal enrichment project, and has not be thoroughly evaluated,
-// it merely 'passes the race detector'.
+// The float's bits are stored in an atomic.Uint64, per math.Float64bits, which avoids the
+// unsafe pointer casts of the original implementation (and their gc caveats) entirely.
+// Per atomic.Uint64, an AtomicFloat64 must not be copied after first use.
 type AtomicFloat64 struct {
-	val float64
+	bits atomic.Uint64
 }
 
 // NewAtomicFloat64 encapsulates a float64 for atomic operations.
 func NewAtomicFloat64(val float64) *AtomicFloat64 {
-	return &AtomicFloat64{
-		val: val,
-	}
+	af := &AtomicFloat64{}
+	af.bits.Store(math.Float64bits(val))
+	return af
 }
 
 // Atomically read the float64.
 // This definition is needed to ensure that read values are not stale/dirty local copies,
 // or equivalently stated that the value is synchronized with main memory.
 func (af *AtomicFloat64) AtomicRead() (value float64) {
-	uint_val := atomic.LoadUint64((*uint64)(unsafe.Pointer(&af.val)))
-	return math.Float64frombits(uint_val)
+	return math.Float64frombits(af.bits.Load())
 }
 
 // Atomically add to the float64.
@@ -49,21 +35,32 @@ func (af *AtomicFloat64) AtomicRead() (value float64) {
 // logically incorrect. If the pointee changes while we're operating upon it, it is better
 // for the caller to know and take some other action (drop the update, recalculate, etc).
 func (af *AtomicFloat64) AtomicAdd(addend float64) (newVal float64, succeeded bool) {
-	old := af.AtomicRead()
-	newVal = old + addend
-	succeeded = atomic.CompareAndSwapUint64(
-		(*uint64)(unsafe.Pointer(&af.val)),
-		math.Float64bits(old),
-		math.Float64bits(newVal))
+	old := af.bits.Load()
+	newVal = math.Float64frombits(old) + addend
+	succeeded = af.bits.CompareAndSwap(old, math.Float64bits(newVal))
+	return
+}
+
+// AtomicAddBlocking adds to the float64, retrying until the addition succeeds, and returns
+// the new value. Unlike AtomicAdd, the caller cannot detect intervening changes, hence this
+// is only for callers that genuinely want retry-until-success semantics, such as counters.
+func (af *AtomicFloat64) AtomicAddBlocking(addend float64) (newVal float64) {
+	for succeeded := false; !succeeded; newVal, succeeded = af.AtomicAdd(addend) {
+	}
 	return
 }
 
-// AtomicSet sets the float64, returns true on success.
+// AtomicSet sets the float64, returns true on success. Like AtomicAdd, it fails if the value
+// changes between its read and swap, such that concurrent updaters may detect contention.
+// Deliberate overwrites that must always take effect, such as resets, should use Store.
 func (af *AtomicFloat64) AtomicSet(new_val float64) (succeeded bool) {
-	old := af.AtomicRead()
-	succeeded = atomic.CompareAndSwapUint64(
-		(*uint64)(unsafe.Pointer(&af.val)),
-		math.Float64bits(old),
-		math.Float64bits(new_val))
+	old := af.bits.Load()
+	succeeded = af.bits.CompareAndSwap(old, math.Float64bits(new_val))
 	return
 }
+
+// Store unconditionally sets the float64. Unlike AtomicSet it cannot fail, since it does not
+// compare the previous value, hence it is for deliberate overwrites, e.g. re-initializing values.
+func (af *AtomicFloat64) Store(val float64) {
+	af.bits.Store(math.Float64bits(val))
+}
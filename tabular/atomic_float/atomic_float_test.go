@@ -77,3 +77,87 @@ func TestAtomicAdd(t *testing.T) {
 		})
 	})
 }
+
+func TestAtomicAddBlocking(t *testing.T) {
+	Convey("When multiple writers call AtomicAddBlocking concurrently", t, func() {
+		f64 := NewAtomicFloat64(0.0)
+		num_ops := 3000
+		num_writers := 200
+
+		start := make(chan struct{})
+		wg := sync.WaitGroup{}
+		wg.Add(num_writers)
+		adder := func() {
+			<-start
+			for i := 0; i < num_ops; i++ {
+				f64.AtomicAddBlocking(1.0)
+			}
+			wg.Done()
+		}
+
+		for i := 0; i < num_writers; i++ {
+			go adder()
+		}
+
+		// Wait for goroutines to begin
+		time.Sleep(time.Millisecond * 10)
+		close(start)
+		wg.Wait()
+		So(f64.AtomicRead(), ShouldEqual, float64(num_ops*num_writers))
+	})
+}
+
+func TestStore(t *testing.T) {
+	Convey("Given a float value that writers concurrently increment", t, func() {
+		f64 := NewAtomicFloat64(0.0)
+		done := make(chan struct{})
+		wg := sync.WaitGroup{}
+		num_writers := 8
+		wg.Add(num_writers)
+		for i := 0; i < num_writers; i++ {
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						f64.AtomicAddBlocking(1.0)
+					}
+				}
+			}()
+		}
+		defer func() {
+			close(done)
+			wg.Wait()
+		}()
+
+		Convey("Then every Store takes effect, whereas AtomicSet may report contention", func() {
+			// The writers' increments in the meantime are far below the stored value's magnitude.
+			reset := -1e12
+			for i := 0; i < 1000; i++ {
+				f64.Store(reset)
+				So(f64.AtomicRead(), ShouldBeLessThan, reset/2)
+				f64.Store(0)
+				So(f64.AtomicRead(), ShouldBeGreaterThanOrEqualTo, 0)
+			}
+
+			// AtomicSet only takes effect when it reports success.
+			for i := 0; i < 1000; i++ {
+				f64.Store(0)
+				if f64.AtomicSet(reset) {
+					So(f64.AtomicRead(), ShouldBeLessThan, reset/2)
+				}
+			}
+		})
+	})
+
+	Convey("When Store is called without contention", t, func() {
+		f64 := NewAtomicFloat64(1.5)
+		f64.Store(-2.25)
+
+		Convey("Then the stored value is read", func() {
+			So(f64.AtomicRead(), ShouldEqual, -2.25)
+		})
+	})
+}
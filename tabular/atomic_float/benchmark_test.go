@@ -0,0 +1,55 @@
+package atomic_float
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+// unsafeFloat64 is the original unsafe.Pointer-based implementation, retained for benchmarking.
+type unsafeFloat64 struct {
+	val float64
+}
+
+func (af *unsafeFloat64) AtomicRead() float64 {
+	return math.Float64frombits(atomic.LoadUint64((*uint64)(unsafe.Pointer(&af.val))))
+}
+
+func (af *unsafeFloat64) AtomicAdd(addend float64) (newVal float64, succeeded bool) {
+	old := af.AtomicRead()
+	newVal = old + addend
+	succeeded = atomic.CompareAndSwapUint64(
+		(*uint64)(unsafe.Pointer(&af.val)),
+		math.Float64bits(old),
+		math.Float64bits(newVal))
+	return
+}
+
+// atomicFloat is the common interface of the benchmarked implementations.
+type atomicFloat interface {
+	AtomicRead() float64
+	AtomicAdd(float64) (float64, bool)
+}
+
+// benchmarkContention runs retry-until-success adds and reads concurrently on a single value.
+func benchmarkContention(b *testing.B, af atomicFloat) {
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if i%2 == 0 {
+				_ = af.AtomicRead()
+				continue
+			}
+			for succeeded := false; !succeeded; _, succeeded = af.AtomicAdd(1.0) {
+			}
+		}
+	})
+}
+
+func BenchmarkUnsafeFloat64Contention(b *testing.B) {
+	benchmarkContention(b, &unsafeFloat64{})
+}
+
+func BenchmarkAtomicFloat64Contention(b *testing.B) {
+	benchmarkContention(b, NewAtomicFloat64(0))
+}
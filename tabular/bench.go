@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+)
+
+// benchWorkerCounts returns the powers of two up to NumCPU, and NumCPU itself.
+func benchWorkerCounts() (counts []int) {
+	for n := 1; n < runtime.NumCPU(); n *= 2 {
+		counts = append(counts, n)
+	}
+	return append(counts, runtime.NumCPU())
+}
+
+// benchEstimatorCounts returns the estimator counts to benchmark: the single estimator, and the
+// configured number of sharded estimators, if any, such that the two may be compared.
+func benchEstimatorCounts(config *reinforcement.TrainingConfig) []int {
+	if estimators := config.Algorithm.Estimators; estimators > 1 {
+		return []int{1, estimators}
+	}
+	return []int{1}
+}
+
+// runBenchmark trains on the selected track for the passed number of episodes per worker count,
+// printing the wall time and episode rate of each as a table. Beyond some number of workers the
+// single estimator and the channel coordination dominate, and more workers don't help. If the
+// config specifies multiple estimators, each worker count is also run with the single estimator
+// for comparison. Convergence is compared by the quality of the resulting greedy policies, per
+// reinforcement.EvaluatePolicy.
+func runBenchmark(
+	ctx context.Context,
+	racetrack []string,
+	config *reinforcement.TrainingConfig,
+	episodes int,
+) error {
+	type result struct {
+		workers     int
+		estimators  int
+		elapsed     time.Duration
+		policySteps float64
+		failRate    float64
+	}
+	results := []result{}
+	for _, workers := range benchWorkerCounts() {
+		for _, estimators := range benchEstimatorCounts(config) {
+			benchStates, err := grid_world.ConvertChecked(racetrack, config.GetKinematics())
+			if err != nil {
+				return err
+			}
+			benchConfig := *config
+			benchConfig.Algorithm.Estimators = estimators
+
+			benchCtx, cancel := reinforcement.WithEpisodeBudget(ctx, episodes)
+			start := time.Now()
+			if err := reinforcement.Train(benchCtx, benchStates, &benchConfig, workers, func(context.Context, int) {}); err != nil {
+				cancel()
+				return err
+			}
+			<-benchCtx.Done()
+			elapsed := time.Since(start)
+			cancel()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			policySteps, failRate := reinforcement.EvaluatePolicy(benchStates, &benchConfig)
+			results = append(results, result{workers, estimators, elapsed, policySteps, failRate})
+		}
+	}
+
+	fmt.Printf("\n%d episodes per run\n", episodes)
+	fmt.Printf("%8s %11s %12s %14s %13s %10s\n",
+		"workers", "estimators", "wall time", "episodes/sec", "policy steps", "fail rate")
+	for _, res := range results {
+		fmt.Printf("%8d %11d %12v %14.0f %13.1f %10.2f\n",
+			res.workers, res.estimators, res.elapsed.Round(time.Millisecond),
+			float64(episodes)/res.elapsed.Seconds(), res.policySteps, res.failRate)
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package grid_world
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
 
 	"tabular/atomic_float"
 )
@@ -15,6 +18,40 @@ type State struct {
 	X, Y, VX, VY int
 	CellType     rune
 	Value        *atomic_float.AtomicFloat64
+	// visits counts the visits to the state's x/y position, shared by its velocity states.
+	visits *atomic.Int64
+}
+
+// RecordVisit counts a visit to the state's x/y position, e.g. by an agent during training.
+// States not built by Convert have no position counter, so their visits are not counted.
+func (s *State) RecordVisit() {
+	if s.visits != nil {
+		s.visits.Add(1)
+	}
+}
+
+// Visits returns the number of visits to the state's x/y position at any velocity.
+func (s *State) Visits() int64 {
+	if s.visits == nil {
+		return 0
+	}
+	return s.visits.Load()
+}
+
+// ResetVisits zeroes the visit counts of every position.
+func ResetVisits(states [][][][]State) {
+	VisitXYStates(states, func(velstates [][]State) {
+		if counter := velstates[0][0].visits; counter != nil {
+			counter.Store(0)
+		}
+	})
+}
+
+// ResetValues sets the value of every state to the passed value, e.g. to re-initialize training.
+// Values are stored unconditionally, so the reset takes effect even if values are concurrently
+// updated, though such updates may then overwrite it.
+func ResetValues(states [][][][]State, val float64) {
+	Visit(states, func(s *State) { s.Value.Store(val) })
 }
 
 // Action consists of a velocity increment/decrement and horizontal or vertical direction.
@@ -32,6 +69,13 @@ type Step struct {
 	Successor *State
 	Action    *Action
 	Reward    float64
+	// SuccessorAction is the action actually taken in the successor state, a'.
+	// This is only populated by on-policy methods (SARSA) and is nil for terminal successors.
+	SuccessorAction *Action
+	// Truncated marks the last step of an episode cut off at the max episode length. Its
+	// successor is not terminal, so estimators bootstrap from its value rather than ending there,
+	// except Q-learning, whose target is then the reward alone.
+	Truncated bool
 }
 
 // Episode is a sequence of Steps.
@@ -43,19 +87,178 @@ const (
 	TRACK  = 'o'
 	START  = '-'
 	FINISH = '+'
+	// SAND is traversable like TRACK, but carries a larger step penalty, so agents learn to avoid it.
+	SAND = 'S'
+	// Tiered finish cells are FINISH cells whose rewards are configured per tier, such that
+	// agents may prefer a high-value goal over a nearby low-value one.
+	FINISH_TIER_1 = '1'
+	FINISH_TIER_2 = '2'
+	FINISH_TIER_3 = '3'
+)
+
+// IsFinish reports whether the passed cell type is FINISH or one of the tiered finish cells.
+func IsFinish(cellType rune) bool {
+	switch cellType {
+	case FINISH, FINISH_TIER_1, FINISH_TIER_2, FINISH_TIER_3:
+		return true
+	}
+	return false
+}
+
+// The default velocity and acceleration bounds in the x or y direction; see Kinematics.
+const (
+	MAX_VELOCITY     = 4
+	MAX_ACCELERATION = 1
 )
 
-// Acceleration actions in the x or y direction.
+// Kinematics are the velocity and acceleration bounds of the agent in the x or y direction.
+// Velocity components range over [-MaxVelocity, MaxVelocity], such that the agent may reverse,
+// and actions change each component by an acceleration in [-MaxAcceleration, MaxAcceleration];
+// both inclusive. The velocity dimensions of a state grid are determined by its Kinematics.
+// Positions are clamped to the grid bounds, unless Wrap is set, in which case an agent moving
+// off one edge of the grid reappears at the opposite edge, as on a torus.
+type Kinematics struct {
+	MaxVelocity     int  `mapstructure:"maxVelocity" yaml:"maxVelocity"`
+	MaxAcceleration int  `mapstructure:"maxAcceleration" yaml:"maxAcceleration"`
+	Wrap            bool `mapstructure:"wrap" yaml:"wrap"`
+	// ActionSet restricts the actions to one of the ActionSet constants; empty selects ActionsAll.
+	// It is set per the training config's algorithm, rather than decoded with the bounds.
+	ActionSet string `mapstructure:"-" yaml:"-"`
+}
+
+// The action sets, per Kinematics.ActionSet.
 const (
-	MAX_VELOCITY   = 4
-	MIN_VELOCITY   = 0
-	NUM_VELOCITIES = 5
+	// ActionsAll is every combination of component accelerations, including diagonals.
+	ActionsAll = "all"
+	// ActionsManhattan changes at most one velocity component per step.
+	ActionsManhattan = "manhattan"
+	// ActionsKing changes each velocity component by at most one per step, like a chess king.
+	ActionsKing = "king"
+	// ActionsDiagonal changes both velocity components by the same magnitude, or neither.
+	ActionsDiagonal = "diagonal"
 )
 
+// DefaultKinematics are the bounds of the original problem definition.
+var DefaultKinematics = Kinematics{
+	MaxVelocity:     MAX_VELOCITY,
+	MaxAcceleration: MAX_ACCELERATION,
+}
+
+// ErrInvalidKinematics is returned when a state grid cannot be built from the passed Kinematics.
+var ErrInvalidKinematics = errors.New("invalid kinematics")
+
+func (k Kinematics) MinVelocity() int {
+	return -k.MaxVelocity
+}
+
+// NumVelocities is the number of velocity values of each component, the size of a state grid's velocity dimensions.
+func (k Kinematics) NumVelocities() int {
+	return 2*k.MaxVelocity + 1
+}
+
+func (k Kinematics) MinAcceleration() int {
+	return -k.MaxAcceleration
+}
+
+// NumAccelerations is the number of acceleration values of each component.
+func (k Kinematics) NumAccelerations() int {
+	return 2*k.MaxAcceleration + 1
+}
+
+// NumActions is the number of acceleration actions available in every state, e.g. |(+1, -1, 0)|**2.
+func (k Kinematics) NumActions() int {
+	return k.NumAccelerations() * k.NumAccelerations()
+}
+
+// Actions returns the actions of the action set, each within the acceleration bounds, ordered by
+// Dvx then Dvy. Action-values are indexed over every action regardless, per NumActions, so the
+// action set only restricts which of them agents enumerate and choose among. Actions are
+// enumerated per step, so each action set is computed once and the returned slice is shared
+// by every caller; it must not be modified.
+func (k Kinematics) Actions() []Action {
+	key := actionSetKey{maxAcceleration: k.MaxAcceleration, actionSet: k.ActionSet}
+	if actions, ok := actionSets.Load(key); ok {
+		return actions.([]Action)
+	}
+	actions, _ := actionSets.LoadOrStore(key, k.newActions())
+	return actions.([]Action)
+}
+
+// actionSetKey is what determines an action set: the acceleration bounds and the set's name.
+type actionSetKey struct {
+	maxAcceleration int
+	actionSet       string
+}
+
+// actionSets caches the actions of each actionSetKey; see Actions.
+var actionSets sync.Map
+
+// newActions enumerates the actions of the action set; see Actions.
+func (k Kinematics) newActions() []Action {
+	actions := make([]Action, 0, k.NumActions())
+	for dvx := k.MinAcceleration(); dvx <= k.MaxAcceleration; dvx++ {
+		for dvy := k.MinAcceleration(); dvy <= k.MaxAcceleration; dvy++ {
+			if k.allows(dvx, dvy) {
+				actions = append(actions, Action{Dvx: dvx, Dvy: dvy})
+			}
+		}
+	}
+	return actions
+}
+
+// allows returns whether the action set includes the passed accelerations.
+func (k Kinematics) allows(dvx, dvy int) bool {
+	switch k.ActionSet {
+	case ActionsManhattan:
+		return dvx == 0 || dvy == 0
+	case ActionsKing:
+		return abs(dvx) <= 1 && abs(dvy) <= 1
+	case ActionsDiagonal:
+		return abs(dvx) == abs(dvy)
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// ValidateActionSet returns an error if the passed action set is not one of the ActionSet constants.
+func ValidateActionSet(actionSet string) error {
+	switch actionSet {
+	case "", ActionsAll, ActionsManhattan, ActionsKing, ActionsDiagonal:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown action set %q, expected one of %q, %q, %q, %q",
+		ErrInvalidKinematics, actionSet, ActionsAll, ActionsManhattan, ActionsKing, ActionsDiagonal)
+}
+
+// validate returns an error if the bounds are not positive or the action set is unknown.
+func (k Kinematics) validate() error {
+	if k.MaxVelocity < 1 || k.MaxAcceleration < 1 {
+		return fmt.Errorf("%w: max velocity %d and max acceleration %d must be positive",
+			ErrInvalidKinematics, k.MaxVelocity, k.MaxAcceleration)
+	}
+	return ValidateActionSet(k.ActionSet)
+}
+
+// VelIndex returns the index of the passed velocity value within the velocity dimensions of
+// the passed state grid, [x][y][VelIndex(states, vx)][VelIndex(states, vy)]. The velocity
+// dimensions are symmetric about zero, so the index is derived from their size, whatever
+// Kinematics the grid was built with. Never index states by raw velocity values.
+func VelIndex[T any](states [][][][]T, v int) int {
+	return v + (len(states[0][0])-1)/2
+}
+
 // Rewards
 const (
 	COLLISION_REWARD = -5
 	STEP_REWARD      = -1
+	SAND_REWARD      = -3
+	FINISH_REWARD    = 0
 )
 
 // The classical track and a smaller debug track for development.
@@ -108,15 +311,39 @@ var (
 	}
 )
 
+// ErrInvalidTrack is returned when a track cannot be converted to a state grid.
+var ErrInvalidTrack = errors.New("invalid track")
+
 // Converts a tack input string array to an actual state grid of positions and velocities.
 // The orientation is such that the bottom/left most position of the track (when printed in a console) is (0,0).
 // This gives awkward reverse-iteration displaying, but makes sense for the problem dynamics: +1 velocity yields +1 position in some array.
 // Note that this is just an (X x Y x VX x VY) size matrix and would be implemented as such in Python.
-// Note there is no error checking on the input track, nor error returned.
-// Returns: multidim state slice, whose indices are [x][y][vx][vy].
-func Convert(track []string) (states [][][][]State) {
+// The velocity dimensions of each cell span the velocities of the passed Kinematics.
+// Convert panics if the track or kinematics are invalid; see ConvertChecked.
+// Returns: multidim state slice, whose indices are [x][y][VelIndex(states, vx)][VelIndex(states, vy)].
+func Convert(track []string, kinematics Kinematics) (states [][][][]State) {
+	var err error
+	if states, err = ConvertChecked(track, kinematics); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// ConvertChecked validates the track and converts it to a state grid per Convert.
+// The track must be rectangular and non-empty, e.g. per PadTrack, contain only WALL, TRACK, SAND, START,
+// and FINISH or tiered finish cells, and contain at least one START and one finish cell. The returned
+// error wraps ErrInvalidTrack or ErrInvalidKinematics and describes the first problem found.
+func ConvertChecked(track []string, kinematics Kinematics) (states [][][][]State, err error) {
+	if err = validateTrack(track); err != nil {
+		return
+	}
+	if err = kinematics.validate(); err != nil {
+		return
+	}
+
 	width := len(track[0])
 	height := len(track)
+	numVelocities := kinematics.NumVelocities()
 
 	states = make([][][][]State, 0, width)
 	// Build cells from left to right...
@@ -124,20 +351,22 @@ func Convert(track []string) (states [][][][]State) {
 		states = append(states, make([][][]State, 0, height))
 		// And bottom to top...
 		for y := 0; y < height; y++ {
-			states[x] = append(states[x], make([][]State, 0, MAX_VELOCITY+1))
+			states[x] = append(states[x], make([][]State, 0, numVelocities))
 			// Select cells bottom up, so the grid has a logical progression where positive x/y velocities are right/up, from (0,0).
 			cell_type := rune(track[height-y-1][x])
+			visits := &atomic.Int64{}
 			// Augment the track cell with x/y velocity values per each state
-			for vx := 0; vx < MAX_VELOCITY+1; vx++ {
-				states[x][y] = append(states[x][y], make([]State, 0, MAX_VELOCITY+1)) // +1 since zero is included as a velocity.
-				for vy := 0; vy < MAX_VELOCITY+1; vy++ {
+			for vx := 0; vx < numVelocities; vx++ {
+				states[x][y] = append(states[x][y], make([]State, 0, numVelocities))
+				for vy := 0; vy < numVelocities; vy++ {
 					state := State{
 						X:        x,
 						Y:        y,
-						VX:       vx,
-						VY:       vy,
+						VX:       vx + kinematics.MinVelocity(),
+						VY:       vy + kinematics.MinVelocity(),
 						CellType: cell_type,
 						Value:    atomic_float.NewAtomicFloat64(0.0),
+						visits:   visits,
 					}
 					states[x][y][vx] = append(states[x][y][vx], state)
 				}
@@ -145,7 +374,41 @@ func Convert(track []string) (states [][][][]State) {
 		}
 	}
 
-	return states
+	return states, nil
+}
+
+// validateTrack returns an error describing the first problem with the track, if any.
+func validateTrack(track []string) error {
+	if len(track) == 0 || len(track[0]) == 0 {
+		return fmt.Errorf("%w: zero-length track", ErrInvalidTrack)
+	}
+
+	numStarts, numFinishes := 0, 0
+	width := len(track[0])
+	for row, line := range track {
+		if len(line) != width {
+			return fmt.Errorf("%w: row %d has length %d, expected %d", ErrInvalidTrack, row, len(line), width)
+		}
+		for col, cell := range line {
+			switch cell {
+			case START:
+				numStarts++
+			case FINISH, FINISH_TIER_1, FINISH_TIER_2, FINISH_TIER_3:
+				numFinishes++
+			case WALL, TRACK, SAND:
+			default:
+				return fmt.Errorf("%w: unknown cell type %q at row %d, column %d", ErrInvalidTrack, cell, row, col)
+			}
+		}
+	}
+
+	if numStarts == 0 {
+		return fmt.Errorf("%w: no START (%q) cell", ErrInvalidTrack, START)
+	}
+	if numFinishes == 0 {
+		return fmt.Errorf("%w: no FINISH (%q) cell", ErrInvalidTrack, FINISH)
+	}
+	return nil
 }
 
 // A 'live' state is one for which displaying the policy is relevant information,
@@ -166,7 +429,7 @@ func ShowPolicy(states [][][][]State) {
 		for x := range states {
 			if isLive(&states[x][y][0][0]) {
 				maxState := MaxVelState(states[x][y])
-				dir := putMaxDir(maxState)
+				dir := MaxDir(maxState)
 				fmt.Printf("%c %d,%d  ", dir, maxState.VX, maxState.VY)
 			} else {
 				fmt.Printf("-      ")
@@ -205,10 +468,9 @@ func ShowMaxValues(states [][][][]State) {
 		fmt.Print(" ")
 		for x := range states {
 			velstates := states[x][y]
-			state := MaxVelState(velstates)
-			val := state.Value.AtomicRead()
+			_, val := MaxVelStateValue(velstates)
 			fmt.Printf("%.2f ", val)
-			//fmt.Printf("%.2f%c ", state.value, putMaxDir(state))
+			//fmt.Printf("%.2f%c ", state.value, MaxDir(state))
 			total += val
 		}
 		fmt.Println("")
@@ -223,17 +485,7 @@ func ShowAvgValues(states [][][][]State) {
 	for _, y := range Rev(len(states[0])) {
 		fmt.Print(" ")
 		for x := range states {
-			velstates := states[x][y]
-			avg := 0.0
-			n := 0.0
-			for i := 0; i < len(velstates); i++ {
-				// From 1, since states for which both velocity components are zero or negative are excluded by problem def.
-				for j := 1; j < len(velstates[i]); j++ {
-					avg += velstates[i][j].Value.AtomicRead()
-					n++
-				}
-			}
-			avg /= n
+			avg := avgVelStateValue(states, x, y)
 			fmt.Printf("%.2f ", avg)
 			total += avg
 		}
@@ -242,6 +494,24 @@ func ShowAvgValues(states [][][][]State) {
 	fmt.Printf("Total: %.2f\n", total)
 }
 
+// avgVelStateValue returns the mean value of the velocity substates of the passed position.
+func avgVelStateValue(states [][][][]State, x, y int) float64 {
+	velstates := states[x][y]
+	zero := VelIndex(states, 0)
+	sum, n := 0.0, 0
+	for i := range velstates {
+		for j := range velstates[i] {
+			// Skip the substate whose velocity components are both zero, which is excluded by problem def.
+			if i == zero && j == zero {
+				continue
+			}
+			sum += velstates[i][j].Value.AtomicRead()
+			n++
+		}
+	}
+	return sum / float64(n)
+}
+
 /*
 // Purely for debugging: print the entire state structs.
 func show_all(states [][][][]State, fn func(s *State) string) {
@@ -259,31 +529,49 @@ func show_all(states [][][][]State, fn func(s *State) string) {
 }
 */
 
-// Returns a printable run for the max direction value in some x/y grid position.
-// This is hyper simplified for console based display.
-// Note only > and ^ are possible via the problem definition, since velocity components are constrained to positive values.
-func putMaxDir(state *State) rune {
-	if state.VX > state.VY {
-		return '>'
+// MaxDir returns a printable rune for the max direction value in some x/y grid position.
+// This is hyper simplified for console based display: the direction of the dominant velocity component.
+func MaxDir(state *State) rune {
+	absVX, absVY := state.VX, state.VY
+	if absVX < 0 {
+		absVX = -absVX
 	}
-	if state.VX < state.VY {
-		return '^'
+	if absVY < 0 {
+		absVY = -absVY
 	}
 
+	switch {
+	case absVX > absVY && state.VX > 0:
+		return '>'
+	case absVX > absVY:
+		return '<'
+	case absVY > absVX && state.VY > 0:
+		return '^'
+	case absVY > absVX:
+		return 'v'
+	}
 	return '='
 }
 
 // Returns the max-valued velocity state from the subset of velocity states, a clumsy operation purely for viewing.
 func MaxVelState(vel_states [][]State) (maxState *State) {
+	maxState, _ = MaxVelStateValue(vel_states)
+	return
+}
+
+// MaxVelStateValue returns the max-valued velocity state per MaxVelState, and the value by which it
+// was selected. Training may update the state after it is selected, so readers that present both
+// the state and its value must use the returned value rather than reading it again.
+func MaxVelStateValue(vel_states [][]State) (maxState *State, maxVal float64) {
 	// Get the max value from the state subset of velocities
 	maxState = &State{
 		Value: atomic_float.NewAtomicFloat64(-math.MaxFloat64),
 	}
-	maxVal := maxState.Value.AtomicRead()
+	maxVal = maxState.Value.AtomicRead()
 
 	for vx := range vel_states {
 		for vy := range vel_states[vx] {
-			if vx == 0 && vy == 0 {
+			if vel_states[vx][vy].VX == 0 && vel_states[vx][vy].VY == 0 {
 				// Skip states whose velocity components are both zero, which are excluded by problem def.
 				continue
 			}
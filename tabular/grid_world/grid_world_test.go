@@ -0,0 +1,398 @@
+package grid_world
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVelocityIndices(t *testing.T) {
+	Convey("Given converted states", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		maxV := DefaultKinematics.MaxVelocity
+
+		Convey("VelIndex maps every velocity into the velocity dimensions", func() {
+			So(VelIndex(states, -maxV), ShouldEqual, 0)
+			So(VelIndex(states, maxV), ShouldEqual, DefaultKinematics.NumVelocities()-1)
+		})
+
+		Convey("Each state is indexed by its velocity values via VelIndex", func() {
+			So(len(states[0][0]), ShouldEqual, DefaultKinematics.NumVelocities())
+			for vx := -maxV; vx <= maxV; vx++ {
+				for vy := -maxV; vy <= maxV; vy++ {
+					state := states[1][2][VelIndex(states, vx)][VelIndex(states, vy)]
+					So(state.VX, ShouldEqual, vx)
+					So(state.VY, ShouldEqual, vy)
+				}
+			}
+		})
+
+		Convey("MaxVelState excludes the zero velocity state", func() {
+			Visit(states, func(s *State) { s.Value.AtomicSet(-1) })
+			states[1][2][VelIndex(states, 0)][VelIndex(states, 0)].Value.AtomicSet(100)
+			maxState := MaxVelState(states[1][2])
+			So(maxState.VX == 0 && maxState.VY == 0, ShouldBeFalse)
+		})
+	})
+}
+
+func TestKinematics(t *testing.T) {
+	Convey("Given kinematics with a max velocity of 6", t, func() {
+		kinematics := Kinematics{MaxVelocity: 6, MaxAcceleration: 2}
+		states := Convert(DebugTrack, kinematics)
+
+		Convey("Every cell fans out over 13 x 13 velocity states", func() {
+			So(kinematics.NumVelocities(), ShouldEqual, 13)
+			Visit(states, func(s *State) {
+				So(len(states[s.X][s.Y]), ShouldEqual, 13)
+				So(len(states[s.X][s.Y][0]), ShouldEqual, 13)
+			})
+		})
+
+		Convey("The velocity states span [-6, 6] and are indexed via VelIndex", func() {
+			cell := states[1][2]
+			So(cell[0][0].VX, ShouldEqual, -6)
+			So(cell[0][0].VY, ShouldEqual, -6)
+			So(cell[12][12].VX, ShouldEqual, 6)
+			So(cell[12][12].VY, ShouldEqual, 6)
+			for v := -6; v <= 6; v++ {
+				So(cell[VelIndex(states, v)][VelIndex(states, -v)].VX, ShouldEqual, v)
+				So(cell[VelIndex(states, v)][VelIndex(states, -v)].VY, ShouldEqual, -v)
+			}
+		})
+
+		Convey("The action count follows the acceleration bound", func() {
+			So(kinematics.NumAccelerations(), ShouldEqual, 5)
+			So(kinematics.NumActions(), ShouldEqual, 25)
+		})
+	})
+
+	Convey("Non-positive bounds are rejected", t, func() {
+		_, err := ConvertChecked(DebugTrack, Kinematics{MaxVelocity: 0, MaxAcceleration: 1})
+		So(errors.Is(err, ErrInvalidKinematics), ShouldBeTrue)
+		_, err = ConvertChecked(DebugTrack, Kinematics{MaxVelocity: 4, MaxAcceleration: 0})
+		So(errors.Is(err, ErrInvalidKinematics), ShouldBeTrue)
+	})
+
+	Convey("Given each action set", t, func() {
+		cases := []struct {
+			actionSet string
+			// The sizes of the action set with a max acceleration of 1 and of 2.
+			unit, double int
+			allows       func(dvx, dvy int) bool
+		}{
+			{"", 9, 25, func(dvx, dvy int) bool { return true }},
+			{ActionsAll, 9, 25, func(dvx, dvy int) bool { return true }},
+			{ActionsManhattan, 5, 9, func(dvx, dvy int) bool { return dvx == 0 || dvy == 0 }},
+			{ActionsKing, 9, 9, func(dvx, dvy int) bool { return abs(dvx) <= 1 && abs(dvy) <= 1 }},
+			{ActionsDiagonal, 5, 9, func(dvx, dvy int) bool { return abs(dvx) == abs(dvy) }},
+		}
+		for _, tc := range cases {
+			unit := Kinematics{MaxVelocity: 4, MaxAcceleration: 1, ActionSet: tc.actionSet}
+			double := Kinematics{MaxVelocity: 4, MaxAcceleration: 2, ActionSet: tc.actionSet}
+
+			Convey(fmt.Sprintf("Then the %q action set has %d and %d actions", tc.actionSet, tc.unit, tc.double), func() {
+				So(len(unit.Actions()), ShouldEqual, tc.unit)
+				So(len(double.Actions()), ShouldEqual, tc.double)
+				So(unit.validate(), ShouldBeNil)
+			})
+
+			Convey(fmt.Sprintf("Then the %q actions are computed once and shared", tc.actionSet), func() {
+				So(&unit.Actions()[0], ShouldEqual, &unit.Actions()[0])
+				wrapped := unit
+				wrapped.MaxVelocity, wrapped.Wrap = 7, true
+				So(&wrapped.Actions()[0], ShouldEqual, &unit.Actions()[0])
+				So(&double.Actions()[0], ShouldNotEqual, &unit.Actions()[0])
+			})
+
+			Convey(fmt.Sprintf("Then the %q actions are distinct, bounded, and allowed", tc.actionSet), func() {
+				seen := map[Action]bool{}
+				for _, action := range double.Actions() {
+					So(seen[action], ShouldBeFalse)
+					seen[action] = true
+					So(abs(action.Dvx), ShouldBeLessThanOrEqualTo, 2)
+					So(abs(action.Dvy), ShouldBeLessThanOrEqualTo, 2)
+					So(tc.allows(action.Dvx, action.Dvy), ShouldBeTrue)
+				}
+			})
+		}
+
+		Convey("Then unknown action sets are rejected", func() {
+			_, err := ConvertChecked(DebugTrack, Kinematics{MaxVelocity: 4, MaxAcceleration: 1, ActionSet: "rook"})
+			So(errors.Is(err, ErrInvalidKinematics), ShouldBeTrue)
+		})
+	})
+}
+
+func TestVisits(t *testing.T) {
+	Convey("Given converted states", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		slow := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		fast := &states[1][1][VelIndex(states, 2)][VelIndex(states, -1)]
+		other := &states[1][2][VelIndex(states, 1)][VelIndex(states, 0)]
+
+		Convey("When states are visited", func() {
+			slow.RecordVisit()
+			fast.RecordVisit()
+			other.RecordVisit()
+
+			Convey("Then visits are counted per position, across velocities", func() {
+				So(slow.Visits(), ShouldEqual, 2)
+				So(fast.Visits(), ShouldEqual, 2)
+				So(other.Visits(), ShouldEqual, 1)
+				So(states[2][2][0][0].Visits(), ShouldEqual, 0)
+			})
+
+			Convey("Then ResetVisits zeroes every position", func() {
+				ResetVisits(states)
+				Visit(states, func(s *State) { So(s.Visits(), ShouldEqual, 0) })
+			})
+		})
+
+		Convey("Then states not built by Convert are not counted", func() {
+			state := &State{}
+			state.RecordVisit()
+			So(state.Visits(), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestResetValues(t *testing.T) {
+	Convey("Given states with distinct values", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		Visit(states, func(s *State) { s.Value.Store(float64(s.X*100 + s.Y*10 + s.VX)) })
+
+		Convey("Then ResetValues sets every state's value", func() {
+			ResetValues(states, -5)
+			Visit(states, func(s *State) { So(s.Value.AtomicRead(), ShouldEqual, -5) })
+		})
+	})
+}
+
+func TestMaxVelStateValue(t *testing.T) {
+	Convey("Given a cell whose zero velocity state has the highest value", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		velstates := states[1][1]
+		Visit(states, func(s *State) { s.Value.Store(-10) })
+		velstates[VelIndex(states, 0)][VelIndex(states, 0)].Value.Store(100)
+		best := &velstates[VelIndex(states, 1)][VelIndex(states, -1)]
+		best.Value.Store(5)
+
+		Convey("Then the max non-zero velocity state is returned with the value by which it was selected", func() {
+			state, val := MaxVelStateValue(velstates)
+			So(state, ShouldEqual, best)
+			So(val, ShouldEqual, 5)
+			So(MaxVelState(velstates), ShouldEqual, best)
+		})
+
+		Convey("Then the average excludes only the zero velocity state", func() {
+			n := float64(DefaultKinematics.NumVelocities()*DefaultKinematics.NumVelocities() - 1)
+			So(avgVelStateValue(states, 1, 1), ShouldAlmostEqual, (5-10*(n-1))/n)
+		})
+	})
+}
+
+func TestSaveLoadValues(t *testing.T) {
+	Convey("Given states with values saved to a file", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		Visit(states, func(s *State) { s.Value.Store(float64(s.X*1000+s.Y*100+s.VX*10) + float64(s.VY)/10) })
+		path := filepath.Join(t.TempDir(), "values.json")
+		So(SaveValues(states, path), ShouldBeNil)
+
+		Convey("Then loading them into a grid of the same track restores every value", func() {
+			loaded := Convert(DebugTrack, DefaultKinematics)
+			So(LoadValues(loaded, path), ShouldBeNil)
+			So(SnapshotValues(loaded), ShouldResemble, SnapshotValues(states))
+		})
+
+		Convey("Then loading them into a grid of other dimensions fails", func() {
+			So(LoadValues(Convert(FullTrack, DefaultKinematics), path), ShouldNotBeNil)
+			So(LoadValues(Convert(DebugTrack, Kinematics{MaxVelocity: 2, MaxAcceleration: 1}), path), ShouldNotBeNil)
+		})
+
+		Convey("Then loading them into a grid of another track of the same size fails, loading nothing", func() {
+			track := append([]string{}, DebugTrack...)
+			track[3] = "WoooWW"
+			other := Convert(track, DefaultKinematics)
+			err := LoadValues(other, path)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "cell")
+			So(SnapshotValues(other), ShouldResemble, SnapshotValues(Convert(track, DefaultKinematics)))
+		})
+
+		Convey("When an entry midway through the file is out of range", func() {
+			saved := savedValues{}
+			data, err := os.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(json.Unmarshal(data, &saved), ShouldBeNil)
+			saved.Values[len(saved.Values)/2].X = saved.Width
+			data, err = json.Marshal(&saved)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(path, data, 0o644), ShouldBeNil)
+
+			Convey("Then loading fails, and leaves the grid unchanged", func() {
+				loaded := Convert(DebugTrack, DefaultKinematics)
+				err := LoadValues(loaded, path)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "out of range")
+				So(SnapshotValues(loaded), ShouldResemble, SnapshotValues(Convert(DebugTrack, DefaultKinematics)))
+			})
+		})
+	})
+}
+
+func TestExportValuesCSV(t *testing.T) {
+	Convey("Given converted states with values", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		Visit(states, func(s *State) { s.Value.AtomicSet(float64(s.X) + float64(s.VY)/10) })
+
+		Convey("When exported", func() {
+			buf := &bytes.Buffer{}
+			So(ExportValuesCSV(states, buf), ShouldBeNil)
+			rows, err := csv.NewReader(buf).ReadAll()
+			So(err, ShouldBeNil)
+
+			Convey("Then there is a header and a row per state", func() {
+				width, height, numVelocities := getDims(states)
+				So(rows[0], ShouldResemble, []string{"x", "y", "vx", "vy", "cellType", "value"})
+				So(len(rows)-1, ShouldEqual, width*height*numVelocities*numVelocities)
+			})
+
+			Convey("Then each row's value and cell type are those of its state", func() {
+				for _, row := range rows[1:] {
+					x, _ := strconv.Atoi(row[0])
+					y, _ := strconv.Atoi(row[1])
+					vx, _ := strconv.Atoi(row[2])
+					vy, _ := strconv.Atoi(row[3])
+					state := &states[x][y][VelIndex(states, vx)][VelIndex(states, vy)]
+					So(row[4], ShouldEqual, string(state.CellType))
+					So(row[5], ShouldEqual, strconv.FormatFloat(state.Value.AtomicRead(), 'g', -1, 64))
+				}
+			})
+		})
+
+		Convey("When a snapshot is exported after the values change", func() {
+			snapshot := SnapshotValues(states)
+			Visit(states, func(s *State) { s.Value.AtomicSet(-1) })
+			buf := &bytes.Buffer{}
+			So(ExportSnapshotCSV(states, snapshot, buf), ShouldBeNil)
+			rows, err := csv.NewReader(buf).ReadAll()
+			So(err, ShouldBeNil)
+
+			Convey("Then each row's value is that of the snapshot", func() {
+				for _, row := range rows[1:] {
+					x, _ := strconv.Atoi(row[0])
+					vy, _ := strconv.Atoi(row[3])
+					So(row[5], ShouldEqual, strconv.FormatFloat(float64(x)+float64(vy)/10, 'g', -1, 64))
+				}
+			})
+		})
+	})
+}
+
+func TestValidateTrack(t *testing.T) {
+	Convey("Given invalid tracks", t, func() {
+		cases := []struct {
+			name  string
+			track []string
+			cause string
+		}{
+			{"an empty track", []string{}, "zero-length"},
+			{"a track of empty rows", []string{""}, "zero-length"},
+			{"an unknown cell rune", []string{"W-o?+W"}, "unknown cell type"},
+			{"no START cell", []string{"Wooo+W"}, "no START"},
+			{"no finish cell", []string{"W-oooW"}, "no FINISH"},
+		}
+		for _, tc := range cases {
+			Convey(fmt.Sprintf("Then %s is rejected as an invalid track", tc.name), func() {
+				states, err := ConvertChecked(tc.track, DefaultKinematics)
+				So(states, ShouldBeNil)
+				So(errors.Is(err, ErrInvalidTrack), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, tc.cause)
+			})
+		}
+	})
+
+	Convey("Given a track with a start and a tiered finish", t, func() {
+		Convey("Then it is valid", func() {
+			_, err := ConvertChecked([]string{"W-oo" + string(FINISH_TIER_1) + "W"}, DefaultKinematics)
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestTrackShapes(t *testing.T) {
+	Convey("Given a single row track", t, func() {
+		states := Convert([]string{"-oo+"}, DefaultKinematics)
+
+		Convey("Then it converts to a grid one cell high", func() {
+			So(len(states), ShouldEqual, 4)
+			So(len(states[0]), ShouldEqual, 1)
+			So(states[0][0][0][0].CellType, ShouldEqual, START)
+			So(states[3][0][0][0].CellType, ShouldEqual, FINISH)
+		})
+	})
+
+	Convey("Given a single column track", t, func() {
+		states := Convert([]string{"+", "o", "-"}, DefaultKinematics)
+
+		Convey("Then it converts to a grid one cell wide, bottom up", func() {
+			So(len(states), ShouldEqual, 1)
+			So(len(states[0]), ShouldEqual, 3)
+			So(states[0][0][0][0].CellType, ShouldEqual, START)
+			So(states[0][2][0][0].CellType, ShouldEqual, FINISH)
+		})
+	})
+
+	Convey("Given a track with sand", t, func() {
+		states, err := ConvertChecked([]string{"-SS+"}, DefaultKinematics)
+
+		Convey("Then the sand cells convert, and are live", func() {
+			So(err, ShouldBeNil)
+			So(states[1][0][0][0].CellType, ShouldEqual, SAND)
+			So(isLive(&states[1][0][0][0]), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a ragged track", t, func() {
+		track := []string{
+			"WWWW",
+			"Wo+",
+			"W-",
+		}
+
+		Convey("Then it is rejected rather than converted out of bounds", func() {
+			_, err := ConvertChecked(track, DefaultKinematics)
+			So(errors.Is(err, ErrInvalidTrack), ShouldBeTrue)
+		})
+
+		Convey("Then padding makes it rectangular, with walls", func() {
+			padded := PadTrack(track)
+			So(padded, ShouldResemble, []string{"WWWW", "Wo+W", "W-WW"})
+			So(track[1], ShouldEqual, "Wo+")
+			states, err := ConvertChecked(padded, DefaultKinematics)
+			So(err, ShouldBeNil)
+			So(states[3][0][0][0].CellType, ShouldEqual, WALL)
+		})
+
+		Convey("When loaded from a file", func() {
+			path := filepath.Join(t.TempDir(), "track.txt")
+			So(os.WriteFile(path, []byte(strings.Join(track, "\n")+"\n"), 0o644), ShouldBeNil)
+			loaded, err := LoadTrack(path)
+
+			Convey("Then it is padded", func() {
+				So(err, ShouldBeNil)
+				So(loaded, ShouldResemble, PadTrack(track))
+			})
+		})
+	})
+}
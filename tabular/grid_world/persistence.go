@@ -0,0 +1,180 @@
+package grid_world
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// savedValues is the serialized form of a state grid's values, for checkpointing and offline
+// analysis. The dimensions are stored so that loading can verify the target grid matches.
+type savedValues struct {
+	Width         int          `json:"width"`
+	Height        int          `json:"height"`
+	NumVelocities int          `json:"numVelocities"`
+	Values        []savedValue `json:"values"`
+}
+
+// savedValue is a single state's value, keyed by (x,y,vx,vy), and the type of its cell, by
+// which loading verifies the target grid is of the same track. The cell type is omitted by
+// files saved before it was recorded, whose tracks are then unchecked.
+type savedValue struct {
+	X        int     `json:"x"`
+	Y        int     `json:"y"`
+	VX       int     `json:"vx"`
+	VY       int     `json:"vy"`
+	CellType string  `json:"cellType,omitempty"`
+	Value    float64 `json:"value"`
+}
+
+// getDims returns the x, y, and velocity dimensions of the passed state grid.
+func getDims(states [][][][]State) (width, height, numVelocities int) {
+	width = len(states)
+	if width > 0 {
+		height = len(states[0])
+		if height > 0 {
+			numVelocities = len(states[0][0])
+		}
+	}
+	return
+}
+
+// SaveValues writes every state's value to the passed path as json, keyed by (x,y,vx,vy).
+func SaveValues(states [][][][]State, path string) (err error) {
+	saved := savedValues{}
+	saved.Width, saved.Height, saved.NumVelocities = getDims(states)
+	Visit(states, func(s *State) {
+		saved.Values = append(saved.Values, savedValue{
+			X:        s.X,
+			Y:        s.Y,
+			VX:       s.VX,
+			VY:       s.VY,
+			CellType: string(s.CellType),
+			Value:    s.Value.AtomicRead(),
+		})
+	})
+
+	var f *os.File
+	if f, err = os.Create(path); err != nil {
+		return fmt.Errorf("save values: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("save values: %w", closeErr)
+		}
+	}()
+
+	if err = json.NewEncoder(f).Encode(&saved); err != nil {
+		err = fmt.Errorf("save values: %w", err)
+	}
+	return
+}
+
+// LoadValues restores state values previously written by SaveValues into the passed states.
+// An error is returned if the saved dimensions or cell types do not match those of the target
+// grid, or any saved state is out of its range, in which case no values are loaded.
+func LoadValues(states [][][][]State, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("load values: %w", err)
+	}
+	defer f.Close()
+
+	saved := savedValues{}
+	if err = json.NewDecoder(f).Decode(&saved); err != nil {
+		return fmt.Errorf("load values from %s: %w", path, err)
+	}
+
+	width, height, numVelocities := getDims(states)
+	if saved.Width != width || saved.Height != height || saved.NumVelocities != numVelocities {
+		return fmt.Errorf(
+			"load values from %s: saved dimensions (%d x %d x %d x %d) do not match grid (%d x %d x %d x %d)",
+			path,
+			saved.Width, saved.Height, saved.NumVelocities, saved.NumVelocities,
+			width, height, numVelocities, numVelocities)
+	}
+
+	// Every entry is checked before any is stored, such that a bad file leaves the grid unchanged.
+	targets := make([]*State, len(saved.Values))
+	for i, sv := range saved.Values {
+		vx, vy := VelIndex(states, sv.VX), VelIndex(states, sv.VY)
+		if sv.X < 0 || sv.X >= width || sv.Y < 0 || sv.Y >= height ||
+			vx < 0 || vx >= numVelocities || vy < 0 || vy >= numVelocities {
+			return fmt.Errorf("load values from %s: state (%d,%d,%d,%d) out of range", path, sv.X, sv.Y, sv.VX, sv.VY)
+		}
+		targets[i] = &states[sv.X][sv.Y][vx][vy]
+		if cellType := string(targets[i].CellType); sv.CellType != "" && sv.CellType != cellType {
+			return fmt.Errorf("load values from %s: saved cell (%d,%d) is %q, not %q", path, sv.X, sv.Y, sv.CellType, cellType)
+		}
+	}
+	for i, sv := range saved.Values {
+		targets[i].Value.Store(sv.Value)
+	}
+
+	return nil
+}
+
+// SnapshotValues returns a copy of the state values, indexed as the states. Each value is read
+// atomically, but values may be updated during the copy, such that the snapshot mixes values from
+// before and after some updates; see reinforcement.Gate.SnapshotValues for a coherent snapshot.
+func SnapshotValues(states [][][][]State) (values [][][][]float64) {
+	values = make([][][][]float64, len(states))
+	for x := range states {
+		values[x] = make([][][]float64, len(states[x]))
+		for y := range states[x] {
+			values[x][y] = make([][]float64, len(states[x][y]))
+			for vx := range states[x][y] {
+				values[x][y][vx] = make([]float64, len(states[x][y][vx]))
+				for vy := range states[x][y][vx] {
+					values[x][y][vx][vy] = states[x][y][vx][vy].Value.AtomicRead()
+				}
+			}
+		}
+	}
+	return
+}
+
+// ExportValuesCSV writes every state's value to the passed writer as csv, one row per (x,y,vx,vy)
+// following a header row, for analysis outside of Go, e.g. with pandas. The values are copied
+// before writing, per SnapshotValues.
+func ExportValuesCSV(states [][][][]State, w io.Writer) error {
+	return ExportSnapshotCSV(states, SnapshotValues(states), w)
+}
+
+// ExportSnapshotCSV writes the passed snapshot of the states' values to the passed writer, as
+// ExportValuesCSV, e.g. such that a coherent snapshot is exported without holding training for
+// the duration of the write.
+func ExportSnapshotCSV(states [][][][]State, values [][][][]float64, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"x", "y", "vx", "vy", "cellType", "value"}); err != nil {
+		return fmt.Errorf("export values: %w", err)
+	}
+
+	for x := range states {
+		for y := range states[x] {
+			for vx := range states[x][y] {
+				for vy := range states[x][y][vx] {
+					s := &states[x][y][vx][vy]
+					if err := cw.Write([]string{
+						strconv.Itoa(s.X),
+						strconv.Itoa(s.Y),
+						strconv.Itoa(s.VX),
+						strconv.Itoa(s.VY),
+						string(s.CellType),
+						strconv.FormatFloat(values[x][y][vx][vy], 'g', -1, 64),
+					}); err != nil {
+						return fmt.Errorf("export values: %w", err)
+					}
+				}
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("export values: %w", err)
+	}
+	return nil
+}
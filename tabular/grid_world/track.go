@@ -0,0 +1,55 @@
+package grid_world
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadTrack reads a track from a plain-text file, one row per line, using the same
+// cell runes as DebugTrack and FullTrack. Trailing whitespace is stripped from each
+// row and trailing blank lines are ignored. Rows shorter than the longest are padded
+// with walls per PadTrack, since Convert requires a rectangular track. An error is
+// returned for empty files.
+func LoadTrack(path string) (track []string, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return nil, fmt.Errorf("load track: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		track = append(track, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("load track %s: %w", path, err)
+	}
+
+	for len(track) > 0 && track[len(track)-1] == "" {
+		track = track[:len(track)-1]
+	}
+	if len(track) == 0 {
+		return nil, fmt.Errorf("load track %s: empty track", path)
+	}
+
+	return PadTrack(track), nil
+}
+
+// PadTrack returns a copy of the passed track whose rows are padded on the right with WALL
+// cells to the width of the longest row, making a ragged track rectangular.
+func PadTrack(track []string) []string {
+	width := 0
+	for _, row := range track {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	padded := make([]string, len(track))
+	for i, row := range track {
+		padded[i] = row + strings.Repeat(string(WALL), width-len(row))
+	}
+	return padded
+}
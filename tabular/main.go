@@ -16,23 +16,55 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
+	"tabular/app_config"
 	"tabular/grid_world"
 	"tabular/reinforcement"
 	"tabular/server"
+	"tabular/tui"
 )
 
 var (
-	stateUpdates chan [][][][]grid_world.State = make(chan [][][][]grid_world.State)
-	states       [][][][]grid_world.State
-	dbg          *bool
-	nworkers     *int
-	host         *string
-	port         *string
-	addr         string
+	stateUpdates   chan [][][][]grid_world.State = make(chan [][][][]grid_world.State)
+	episodeUpdates chan *grid_world.Episode      = make(chan *grid_world.Episode)
+	metrics        chan reinforcement.Metrics    = make(chan reinforcement.Metrics)
+	gate           *reinforcement.Gate           = reinforcement.NewGate()
+	states         [][][][]grid_world.State
 )
 
+// The flags, parsed by parseFlags.
+var (
+	dbg           = flag.Bool("debug", false, "debug mode")
+	nworkers      = flag.Int("nworkers", runtime.NumCPU(), "number of worker training routines; 0 trains alpha-monte-carlo sequentially, for reproducibility")
+	host          = flag.String("host", "", "The host ip")
+	port          = flag.String("port", "8080", "The host port")
+	trackPath     = flag.String("track", "", "path to a track file, one row per line; overrides the built-in tracks")
+	configPaths   = flag.String("config", "./config.yaml", "comma-separated config files: training, and optionally server and kinematics")
+	trainLog      = flag.String("trainlog", "", "path to write a JSON training log to, one object per line; 'console' prints it instead")
+	exportPath    = flag.String("export", "", "path to write the state values to as csv when training completes")
+	benchEpisodes = flag.Int("bench", 0, "if positive, benchmark training this many episodes per worker count, then exit")
+	referencePath = flag.String("reference", "", "path to saved state values, e.g. a checkpoint of a converged run, against which to report the value error")
+	tuiMode       = flag.Bool("tui", false, "render training to the terminal rather than serving the web views; q quits")
+	trajectory    = flag.String("trajectory", "", "x,y of the start cell from which the trajectory view shows the greedy policy; defaults to the first start cell")
+)
+
+// envFlags are the environment variables that set flags, e.g. for containerized deployments,
+// by the names of the flags they set.
+var envFlags = map[string]string{
+	"TABULAR_TRACK":   "track",
+	"TABULAR_CONFIG":  "config",
+	"TABULAR_HOST":    "host",
+	"TABULAR_PORT":    "port",
+	"TABULAR_WORKERS": "nworkers",
+}
+
 /*
 Reactive algorithms? Good for dedicated learning pipelines in the cloud...
 - Load new problem instances
@@ -79,61 +111,238 @@ on dirt simple rpi projects: discrete input/output sensors, video streams, etc,
 other small, rapidly developed applications.
 */
 
-// TODO: per 12-factor rules, these should be taken from env or config-map; KISS for now. Also init is bad.
-func init() {
-	dbg = flag.Bool("debug", false, "debug mode")
-	nworkers = flag.Int("nworkers", runtime.NumCPU(), "number of worker training routines")
-	host = flag.String("host", "", "The host ip")
-	port = flag.String("port", "8080", "The host port")
-	addr = *host + ":" + *port
+// parseFlags parses the command line, then sets each flag that was not passed explicitly from
+// its environment variable per envFlags, if set; otherwise the flag keeps its default. Values
+// from the environment are parsed as the flag's would be, and are treated as passed explicitly
+// thereafter, e.g. the host and port override the server config as flags do; see listenAddr.
+func parseFlags() error {
 	flag.Parse()
+
+	passed := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { passed[f.Name] = true })
+	for env, name := range envFlags {
+		val, ok := os.LookupEnv(env)
+		if !ok || passed[name] {
+			continue
+		}
+		if err := flag.Set(name, val); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", env, val, err)
+		}
+	}
+	return nil
 }
 
-func selectTrack() []string {
+func selectTrack() ([]string, error) {
 	// choose or input a track
+	if *trackPath != "" {
+		return grid_world.LoadTrack(*trackPath)
+	}
 	if *dbg {
-		return grid_world.DebugTrack
+		return grid_world.DebugTrack, nil
+	}
+	return grid_world.FullTrack, nil
+}
+
+// trajectorySampler returns the sampler of the trajectory view's greedy rollouts, from the start
+// cell given by the trajectory flag, else from the first start cell, bottom-left first.
+func trajectorySampler(
+	states [][][][]grid_world.State,
+	config *reinforcement.TrainingConfig,
+) (*reinforcement.ExportEpisodeSampler, error) {
+	x, y := -1, -1
+	if *trajectory != "" {
+		if _, err := fmt.Sscanf(*trajectory, "%d,%d", &x, &y); err != nil {
+			return nil, fmt.Errorf("invalid trajectory %q, expected x,y: %w", *trajectory, err)
+		}
+	} else {
+		grid_world.VisitXYStates(states, func(velstates [][]grid_world.State) {
+			if state := &velstates[0][0]; x < 0 && state.CellType == grid_world.START {
+				x, y = state.X, state.Y
+			}
+		})
 	}
-	return grid_world.FullTrack
+	return reinforcement.NewExportEpisodeSampler(states, config, x, y)
 }
 
 func runApp() (err error) {
-	var algConfig *reinforcement.TrainingConfig
-	if algConfig, err = reinforcement.FromYaml("./config.yaml"); err != nil {
+	var appConfig *app_config.AppConfig
+	if appConfig, err = app_config.LoadConfig(strings.Split(*configPaths, ",")...); err != nil {
+		return
+	}
+	algConfig := appConfig.Training
+	if err = reinforcement.ValidateHyperParams(algConfig); err != nil {
 		return
 	}
 
-	appCtx, appCancel := context.WithCancel(context.TODO())
+	// Hyper-parameters may be changed by clients during training, and by edits to the training config.
+	hyperParams := reinforcement.NewHyperParams(algConfig)
+	if err = reinforcement.WatchConfig(appConfig.TrainingPath, func(cfg *reinforcement.TrainingConfig) {
+		if err := hyperParams.Reload(cfg); err != nil {
+			fmt.Println("config reload failed:", err)
+		}
+	}); err != nil {
+		return
+	}
+
+	// Interrupts cancel the app, upon which the server shuts down and closes its clients' websockets.
+	appCtx, appCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer appCancel()
 
-	trainingCtx, _ := algConfig.WithTrainingDeadline(appCtx)
+	var racetrack []string
+	if racetrack, err = selectTrack(); err != nil {
+		return
+	}
+	if *benchEpisodes > 0 {
+		return runBenchmark(appCtx, racetrack, algConfig, *benchEpisodes)
+	}
+	if states, err = grid_world.ConvertChecked(racetrack, algConfig.GetKinematics()); err != nil {
+		return
+	}
 
-	racetrack := selectTrack()
-	states = grid_world.Convert(racetrack)
+	trainOpts := []reinforcement.TrainOption{
+		reinforcement.WithGate(gate),
+		reinforcement.WithHyperParams(hyperParams),
+	}
+	// The metrics and episodes are only consumed by the server's views, and publishing them
+	// blocks training until they are received.
+	if !*tuiMode {
+		var sampler *reinforcement.ExportEpisodeSampler
+		if sampler, err = trajectorySampler(states, algConfig); err != nil {
+			return
+		}
+		trainOpts = append(trainOpts,
+			reinforcement.WithMetrics(metrics, 1000),
+			reinforcement.WithGreedyEpisodeUpdates(episodeUpdates, 1000, sampler))
+	}
+	if *referencePath != "" {
+		reference := grid_world.Convert(racetrack, algConfig.GetKinematics())
+		if err = grid_world.LoadValues(reference, *referencePath); err != nil {
+			return
+		}
+		trainOpts = append(trainOpts, reinforcement.WithReference(reference))
+	}
+	switch *trainLog {
+	case "":
+	case "console":
+		trainOpts = append(trainOpts, reinforcement.WithTrainingLogger(reinforcement.ConsoleLogger{}, 10000))
+	default:
+		var logFile *os.File
+		if logFile, err = os.Create(*trainLog); err != nil {
+			return
+		}
+		defer logFile.Close()
+		trainOpts = append(trainOpts, reinforcement.WithTrainingLogger(reinforcement.NewJSONLogger(logFile), 1000))
+	}
 
-	// Start training
-	reinforcement.Train(
-		trainingCtx,
+	// Start training. Each run, including those restarted by clients, is bounded by the
+	// training deadline and convergence stop of the config.
+	trainer := reinforcement.NewTrainer(
+		appCtx,
 		states,
 		algConfig,
 		*nworkers,
-		exportStates)
+		exportStates,
+		trainOpts...)
+	if err = trainer.Start(); err != nil {
+		return
+	}
+
+	exported := exportOnCompletion(trainer.Done())
+
+	if *tuiMode {
+		err = tui.Run(appCtx, states, stateUpdates, time.Second/4)
+		// Quitting the terminal ui stops training, as does an interrupt.
+		appCancel()
+		if exportErr := <-exported; err == nil {
+			err = exportErr
+		}
+		return
+	}
 
 	// Run server
 	var srv *server.Server
 	if srv, err = server.NewServer(
 		appCtx,
-		addr,
+		listenAddr(appConfig.Server),
 		states,
 		stateUpdates,
+		metrics,
+		episodeUpdates,
+		&trainingController{gate, hyperParams, trainer},
+		server.WithPublishConfig(server.PublishConfig{
+			BatchWindow: appConfig.Server.BatchWindow,
+			Resolution:  appConfig.Server.PublishResolution,
+		}),
+		server.WithAuthToken(appConfig.Server.AuthToken),
 	); err != nil {
 		return
 	}
 
 	err = srv.Serve()
+	// Serve also returns if it fails, e.g. if the port is in use, upon which training is stopped
+	// as upon an interrupt. Training runs are derived from the app's context, hence complete.
+	appCancel()
+	if exportErr := <-exported; err == nil {
+		err = exportErr
+	}
 	return
 }
 
+// exportOnCompletion writes the state values to the export path as csv, if any, once training
+// completes, per the passed chan. The returned chan receives the result.
+func exportOnCompletion(trainingDone <-chan struct{}) <-chan error {
+	exported := make(chan error, 1)
+	go func() {
+		<-trainingDone
+		if *exportPath == "" {
+			exported <- nil
+			return
+		}
+
+		f, err := os.Create(*exportPath)
+		if err == nil {
+			err = grid_world.ExportSnapshotCSV(states, gate.SnapshotValues(states), f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err == nil {
+			fmt.Println("exported state values to", *exportPath)
+		}
+		exported <- err
+	}()
+	return exported
+}
+
+// listenAddr returns the server's listen address per the server config, overridden by the
+// host and port flags when they are passed explicitly or set from the environment. The flags
+// must be parsed; see parseFlags.
+func listenAddr(cfg app_config.ServerConfig) string {
+	addrHost, addrPort := *host, *port
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Host = addrHost
+		case "port":
+			cfg.Port = addrPort
+		}
+	})
+	if cfg.Host != "" {
+		addrHost = cfg.Host
+	}
+	if cfg.Port != "" {
+		addrPort = cfg.Port
+	}
+	return net.JoinHostPort(addrHost, addrPort)
+}
+
+// trainingController exposes training controls to clients of the server.
+type trainingController struct {
+	*reinforcement.Gate
+	*reinforcement.HyperParams
+	*reinforcement.Trainer
+}
+
 // When called during training progress, this blocks and sends the current
 // state values to the server to update views.
 func exportStates(ctx context.Context, episodeCount int) {
@@ -159,6 +368,10 @@ func print_values_async(states [][][][]State, done <-chan struct{}) {
 
 // TODO: use mixedCaps throughout
 func main() {
+	if err := parseFlags(); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 	if err := runApp(); err != nil {
 		fmt.Println(err)
 	}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunAppServeFailure(t *testing.T) {
+	Convey("Given the server's port is already in use", t, func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer listener.Close()
+		_, usedPort, _ := net.SplitHostPort(listener.Addr().String())
+		So(flag.Set("host", "127.0.0.1"), ShouldBeNil)
+		So(flag.Set("port", usedPort), ShouldBeNil)
+		So(flag.Set("debug", "true"), ShouldBeNil)
+		So(flag.Set("nworkers", "1"), ShouldBeNil)
+
+		Convey("When the app is run", func() {
+			returned := make(chan error, 1)
+			go func() { returned <- runApp() }()
+
+			Convey("Then it returns the serve error promptly, rather than training until the deadline", func() {
+				select {
+				case err := <-returned:
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "serve")
+				case <-time.After(15 * time.Second):
+					So("runApp returned", ShouldEqual, "runApp blocked")
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package reinforcement
+
+import (
+	"math"
+	"math/rand"
+
+	"tabular/atomic_float"
+	. "tabular/grid_world"
+)
+
+// ActionValues stores Q(s,a) estimates in parallel with the state grid. The State type only
+// carries a single value, V(s), so Q-values require a fifth dimension indexed by action:
+// [x][y][vx][vy][action]. Like State.Value, each entry is an atomic float, since agents read
+// these while the estimator writes them. The actions are those of the passed Kinematics.
+type ActionValues struct {
+	values     [][][][][]*atomic_float.AtomicFloat64
+	kinematics Kinematics
+}
+
+// NewActionValues allocates an action-value store of the same dimensions as the passed states,
+// with one action per acceleration pair of the passed kinematics.
+func NewActionValues(states [][][][]State, initVal float64, kinematics Kinematics) (qvals *ActionValues) {
+	numActions := kinematics.NumActions()
+	values := make([][][][][]*atomic_float.AtomicFloat64, len(states))
+	for x := range states {
+		values[x] = make([][][][]*atomic_float.AtomicFloat64, len(states[x]))
+		for y := range states[x] {
+			values[x][y] = make([][][]*atomic_float.AtomicFloat64, len(states[x][y]))
+			for vx := range states[x][y] {
+				values[x][y][vx] = make([][]*atomic_float.AtomicFloat64, len(states[x][y][vx]))
+				for vy := range states[x][y][vx] {
+					values[x][y][vx][vy] = make([]*atomic_float.AtomicFloat64, numActions)
+					for a := 0; a < numActions; a++ {
+						values[x][y][vx][vy][a] = atomic_float.NewAtomicFloat64(initVal)
+					}
+				}
+			}
+		}
+	}
+	return &ActionValues{
+		values:     values,
+		kinematics: kinematics,
+	}
+}
+
+// actionIndex maps an action's velocity increments in [-MaxAcceleration, MaxAcceleration]
+// to its index in [0,NumActions).
+func (qvals *ActionValues) actionIndex(action *Action) int {
+	minAcc := qvals.kinematics.MinAcceleration()
+	return (action.Dvx-minAcc)*qvals.kinematics.NumAccelerations() + (action.Dvy - minAcc)
+}
+
+// Get returns Q(s,a) for the passed state and action.
+func (qvals *ActionValues) Get(state *State, action *Action) *atomic_float.AtomicFloat64 {
+	vel_states := qvals.values[state.X][state.Y]
+	return vel_states[VelIndex(qvals.values, state.VX)][VelIndex(qvals.values, state.VY)][qvals.actionIndex(action)]
+}
+
+// MaxAction returns the max-valued action in the passed state and its value, Q(s,a).
+// Actions resulting in both velocity components being zero are excluded, per problem def.
+// The first allowed action is returned if every value is -Inf or NaN, e.g. upon divergence,
+// and the no-op action if no action is allowed, so the returned action is never nil.
+func (qvals *ActionValues) MaxAction(
+	state *State,
+) (action *Action, maxVal float64) {
+	maxVal = -math.MaxFloat64
+	kinematics := qvals.kinematics
+	actions := kinematics.Actions()
+	for i := range actions {
+		candidate := &actions[i]
+		if vx, vy := getNewVelocity(state, candidate, kinematics); vx == 0 && vy == 0 {
+			continue
+		}
+
+		val := qvals.Get(state, candidate).AtomicRead()
+		if action == nil || val > maxVal {
+			maxVal = val
+			action = candidate
+		}
+	}
+	if action == nil {
+		action = &Action{}
+		maxVal = qvals.Get(state, action).AtomicRead()
+	}
+	return
+}
+
+// newPolicyQMax returns an epsilon-greedy policy over the passed action values:
+// with probability epsilon() a random action is taken, otherwise the max-valued action.
+// Random choices are drawn from the rng passed by the calling agent.
+func newPolicyQMax(
+	states [][][][]State,
+	qvals *ActionValues,
+	epsilon func() float64,
+	collide collisionFunc,
+) func(*State, *rand.Rand) (*State, *Action) {
+	return func(state *State, rng *rand.Rand) (target *State, action *Action) {
+		r := rng.Float64()
+		if r <= epsilon() {
+			// Exploration: do something random
+			action = getRandAction(state, rng, qvals.kinematics)
+		} else {
+			// Exploitation: take the max-valued action
+			action, _ = qvals.MaxAction(state)
+		}
+		target = getSuccessor(states, state, action, collide, qvals.kinematics)
+		return target, action
+	}
+}
@@ -0,0 +1,42 @@
+package reinforcement
+
+import (
+	"context"
+)
+
+type budgetKey struct{}
+
+// episodeBudget cancels training once the estimator has processed a fixed number of episodes.
+// It is only used by the (single) estimator, hence is not synchronized.
+type episodeBudget struct {
+	remaining int
+	cancel    context.CancelFunc
+}
+
+// WithEpisodeBudget returns a context that is cancelled once training has processed the passed
+// number of episodes, e.g. for benchmarking a fixed amount of work. Like WithConvergenceStop,
+// training stops on whichever of its stop conditions occurs first.
+func WithEpisodeBudget(ctx context.Context, episodes int) (context.Context, context.CancelFunc) {
+	innerCtx, cancel := context.WithCancel(ctx)
+	budget := &episodeBudget{
+		remaining: episodes,
+		cancel:    cancel,
+	}
+	return context.WithValue(innerCtx, budgetKey{}, budget), cancel
+}
+
+// getEpisodeBudget returns the episode budget of the passed context, or nil if none.
+func getEpisodeBudget(ctx context.Context) *episodeBudget {
+	budget, _ := ctx.Value(budgetKey{}).(*episodeBudget)
+	return budget
+}
+
+func (eb *episodeBudget) Observe(float64) {}
+
+// EndEpisode counts down the budget, cancelling training once it is spent.
+func (eb *episodeBudget) EndEpisode(int) {
+	eb.remaining--
+	if eb.remaining <= 0 {
+		eb.cancel()
+	}
+}
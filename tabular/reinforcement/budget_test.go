@@ -0,0 +1,42 @@
+package reinforcement
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEpisodeBudget(t *testing.T) {
+	Convey("Given training with an episode budget", t, func() {
+		ctx, cancel := WithEpisodeBudget(context.Background(), 100)
+		Reset(cancel)
+
+		var episodes int64
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 2, func(_ context.Context, count int) {
+			atomic.StoreInt64(&episodes, int64(count))
+		})
+
+		Convey("Then training stops once the budget is spent", func() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+				So("training did not stop", ShouldBeEmpty)
+			}
+			So(atomic.LoadInt64(&episodes), ShouldBeGreaterThanOrEqualTo, 100)
+		})
+	})
+
+	Convey("Given a budget that is not yet spent", t, func() {
+		budget := &episodeBudget{remaining: 2, cancel: func() { panic("cancelled early") }}
+
+		Convey("Then episodes count it down without cancelling", func() {
+			budget.EndEpisode(10)
+			So(budget.remaining, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package reinforcement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	. "tabular/grid_world"
+)
+
+// CheckpointConfig describes where and how often state values are saved during training.
+// Checkpointing is disabled when Path is empty or Interval is not positive.
+type CheckpointConfig struct {
+	// Path is the file to which state values are saved, and from which training resumes.
+	Path string `mapstructure:"path" yaml:"path"`
+	// Interval is the number of episodes between checkpoints.
+	Interval int `mapstructure:"interval" yaml:"interval"`
+}
+
+func (cc *CheckpointConfig) enabled() bool {
+	return cc.Path != "" && cc.Interval > 0
+}
+
+// withCheckpoints wraps the passed progress func such that the state values are saved
+// every Interval episodes. A failed checkpoint is logged but does not halt training.
+// Note that only state values are saved, hence this is a partial checkpoint for the
+// Q(s,a) based algorithms.
+func withCheckpoints(
+	states [][][][]State,
+	cc CheckpointConfig,
+	progressFn ProgressFunc,
+) ProgressFunc {
+	if !cc.enabled() {
+		return progressFn
+	}
+
+	return func(ctx context.Context, episodeCount int) {
+		if episodeCount%cc.Interval == 0 {
+			if err := saveCheckpoint(states, cc.Path); err != nil {
+				log.Println("checkpoint failed:", err)
+			}
+		}
+		progressFn(ctx, episodeCount)
+	}
+}
+
+// saveCheckpoint atomically saves the state values to path by writing them to a
+// temp file in the same directory and renaming it, so that a crash mid-write
+// never leaves a corrupt checkpoint.
+func saveCheckpoint(states [][][][]State, path string) (err error) {
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp"); err != nil {
+		return
+	}
+	tmpPath := tmp.Name()
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = SaveValues(states, tmpPath); err != nil {
+		return
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		err = fmt.Errorf("checkpoint rename: %w", err)
+	}
+	return
+}
+
+// loadCheckpoint loads the state values from the checkpoint file if one exists,
+// returning true if the values were restored.
+func loadCheckpoint(states [][][][]State, cc CheckpointConfig) bool {
+	if !cc.enabled() {
+		return false
+	}
+
+	if _, err := os.Stat(cc.Path); errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+
+	if err := LoadValues(states, cc.Path); err != nil {
+		log.Println("checkpoint not loaded:", err)
+		return false
+	}
+	log.Println("resuming from checkpoint", cc.Path)
+	return true
+}
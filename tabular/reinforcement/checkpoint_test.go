@@ -0,0 +1,148 @@
+package reinforcement
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckpointInterval(t *testing.T) {
+	Convey("Given a progress func wrapped with checkpoints every 3 episodes", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		calls := 0
+		progressFn := withCheckpoints(states, CheckpointConfig{Path: path, Interval: 3}, func(context.Context, int) { calls++ })
+
+		Convey("Then the values are saved only upon each interval, and progress is always reported", func() {
+			for count := 1; count <= 7; count++ {
+				Visit(states, func(s *State) { s.Value.Store(float64(count)) })
+				progressFn(context.Background(), count)
+
+				_, err := os.Stat(path)
+				So(err == nil, ShouldEqual, count >= 3)
+				if err == nil {
+					saved := Convert(DebugTrack, DefaultKinematics)
+					So(LoadValues(saved, path), ShouldBeNil)
+					So(saved[1][1][0][0].Value.AtomicRead(), ShouldEqual, float64(count-count%3))
+				}
+			}
+			So(calls, ShouldEqual, 7)
+		})
+	})
+
+	Convey("Given a disabled checkpoint config", t, func() {
+		progressFn := func(context.Context, int) {}
+
+		Convey("Then the progress func is returned unwrapped", func() {
+			wrapped := withCheckpoints(nil, CheckpointConfig{Path: "unused.json"}, progressFn)
+			So(wrapped, ShouldEqual, progressFn)
+		})
+	})
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	Convey("Given states and a checkpoint directory", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		dir := t.TempDir()
+
+		Convey("When a checkpoint is saved", func() {
+			path := filepath.Join(dir, "checkpoint.json")
+			So(saveCheckpoint(states, path), ShouldBeNil)
+
+			Convey("Then only the checkpoint remains, its temp file renamed to it", func() {
+				entries, err := os.ReadDir(dir)
+				So(err, ShouldBeNil)
+				So(len(entries), ShouldEqual, 1)
+				So(entries[0].Name(), ShouldEqual, "checkpoint.json")
+			})
+		})
+
+		Convey("When the checkpoint cannot be renamed into place", func() {
+			// A non-empty directory cannot be replaced by a file.
+			path := filepath.Join(dir, "checkpoint.json")
+			So(os.MkdirAll(filepath.Join(path, "occupied"), 0o755), ShouldBeNil)
+			err := saveCheckpoint(states, path)
+
+			Convey("Then an error is returned, and no partial file remains", func() {
+				So(err, ShouldNotBeNil)
+				matches, globErr := filepath.Glob(filepath.Join(dir, "*.tmp"))
+				So(globErr, ShouldBeNil)
+				So(matches, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	Convey("Given a checkpoint of distinct values", t, func() {
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		checkpointed := Convert(DebugTrack, DefaultKinematics)
+		Visit(checkpointed, func(s *State) { s.Value.Store(float64(s.X*10+s.Y) + 0.5) })
+		So(SaveValues(checkpointed, path), ShouldBeNil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		config := &TrainingConfig{Checkpoint: CheckpointConfig{Path: path, Interval: 1000}}
+		states := Convert(DebugTrack, DefaultKinematics)
+		// The run is paused, such that the values are those with which it began.
+		gate := NewGate()
+		gate.Pause()
+		trainer := NewTrainer(ctx, states, config, 1, func(context.Context, int) {}, WithGate(gate))
+
+		Convey("Then training resumes from the checkpoint", func() {
+			So(trainer.Start(), ShouldBeNil)
+			So(SnapshotValues(states), ShouldResemble, SnapshotValues(checkpointed))
+		})
+
+		Convey("Then a reset ignores the checkpoint", func() {
+			So(trainer.Start(), ShouldBeNil)
+			So(trainer.Reset(), ShouldBeNil)
+			So(SnapshotValues(states), ShouldNotResemble, SnapshotValues(checkpointed))
+		})
+	})
+}
+
+// lockedBuffer is a bytes.Buffer that may be written by the training routines while read by a test.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.Write(p)
+}
+
+func (lb *lockedBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buf.String()
+}
+
+func TestCheckpointFailure(t *testing.T) {
+	Convey("Given a checkpoint path that cannot be written", t, func() {
+		logs := &lockedBuffer{}
+		log.SetOutput(logs)
+		Reset(func() { log.SetOutput(os.Stderr) })
+		path := filepath.Join(t.TempDir(), "missing", "checkpoint.json")
+		config := &TrainingConfig{Checkpoint: CheckpointConfig{Path: path, Interval: 5}}
+
+		Convey("Then each failure is logged, and training completes its budget", func() {
+			// TrainSync returns only once the budget's episodes are processed.
+			ctx, cancel := WithEpisodeBudget(context.Background(), 20)
+			defer cancel()
+			So(TrainSync(ctx, Convert(DebugTrack, DefaultKinematics), config, 1), ShouldBeNil)
+			So(strings.Count(logs.String(), "checkpoint failed"), ShouldBeGreaterThanOrEqualTo, 4)
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package reinforcement
+
+import (
+	. "tabular/grid_world"
+)
+
+// collisionFunc returns the first WALL state with which an agent would collide if starting
+// from the passed state and proceeding for one time step with velocity components vx and vy,
+// or nil if no collision.
+type collisionFunc func(states [][][][]State, start *State, vx, vy int) *State
+
+// Collision checking modes, selected via the collisionMode hyperparameter.
+const (
+	// collisionModeBox checks every cell in the region spanned by start and start + (vx,vy).
+	collisionModeBox = 0
+	// collisionModeSupercover checks only the cells through which the line from start to
+	// start + (vx,vy) passes.
+	collisionModeSupercover = 1
+)
+
+// getCollisionFunc returns the collision checking function selected by the collisionMode
+// hyperparameter, defaulting to the original box-collision checking. For wrapping kinematics,
+// the traversal wraps around the grid edges.
+func getCollisionFunc(config *TrainingConfig) collisionFunc {
+	wrap := config.GetKinematics().Wrap
+	switch int(config.GetHyperParamOrDefault("collisionMode", collisionModeBox)) {
+	case collisionModeSupercover:
+		if wrap {
+			return checkWrappedSupercoverCollision
+		}
+		return checkSupercoverCollision
+	default:
+		if wrap {
+			return checkWrappedTerminalCollision
+		}
+		return checkTerminalCollision
+	}
+}
+
+// checkSupercoverCollision is a less conservative alternative to checkTerminalCollision: rather
+// than checking the entire region spanned by start and start + (vx,vy), this traverses the
+// supercover of the line segment between the two cell centers, e.g. every cell the segment passes
+// through, Bresenham-style. When the segment passes exactly through a cell corner, both cells
+// adjacent to the corner are checked, such that diagonal moves cannot clip through wall corners.
+// Off grid cells are ignored, as in checkTerminalCollision.
+// Returns: the first state with which the agent would collide; nil, if no collision.
+func checkSupercoverCollision(states [][][][]State, start *State, vx, vy int) (state *State) {
+	return checkLineCollision(states, start, vx, vy, false)
+}
+
+// checkWrappedSupercoverCollision is checkSupercoverCollision for wrapping kinematics, whose
+// traversal wraps around the grid edges rather than ignoring off grid cells.
+func checkWrappedSupercoverCollision(states [][][][]State, start *State, vx, vy int) (state *State) {
+	return checkLineCollision(states, start, vx, vy, true)
+}
+
+// checkLineCollision implements checkSupercoverCollision, wrapping off grid cells if wrap is set.
+func checkLineCollision(states [][][][]State, start *State, vx, vy int, wrap bool) (state *State) {
+	max_x := len(states) - 1
+	max_y := len(states[0]) - 1
+
+	supercover(start.X, start.Y, vx, vy, func(x, y int) bool {
+		if wrap {
+			x, y = wrapIndex(x, max_x+1), wrapIndex(y, max_y+1)
+		}
+		// Ignore out of bounds states
+		if x < 0 || x > max_x || y < 0 || y > max_y {
+			return false
+		}
+
+		traversed := &states[x][y][VelIndex(states, vx)][VelIndex(states, vy)]
+		if traversed.CellType == WALL {
+			state = traversed
+			return true
+		}
+		return false
+	})
+	return
+}
+
+// supercover visits, in order, every grid cell through which the line segment from the center
+// of (x0,y0) to the center of (x0+dx,y0+dy) passes, until visit returns true. Per Dedu's supercover
+// variant of Bresenham's algorithm, the error terms are doubled to remain in integer arithmetic.
+func supercover(x0, y0, dx, dy int, visit func(x, y int) bool) {
+	xstep, ystep := 1, 1
+	if dx < 0 {
+		xstep, dx = -1, -dx
+	}
+	if dy < 0 {
+		ystep, dy = -1, -dy
+	}
+	ddx, ddy := 2*dx, 2*dy
+
+	x, y := x0, y0
+	if visit(x, y) {
+		return
+	}
+
+	if ddx >= ddy {
+		// x-major: step x each iteration, and y when the error accumulates past a cell.
+		errPrev, err := dx, dx
+		for i := 0; i < dx; i++ {
+			x += xstep
+			err += ddy
+			if err > ddx {
+				y += ystep
+				err -= ddx
+				switch {
+				case err+errPrev < ddx:
+					// The segment enters the next row before the next column.
+					if visit(x, y-ystep) {
+						return
+					}
+				case err+errPrev > ddx:
+					// The segment enters the next column before the next row.
+					if visit(x-xstep, y) {
+						return
+					}
+				default:
+					// The segment passes exactly through a corner: check both cells.
+					if visit(x, y-ystep) || visit(x-xstep, y) {
+						return
+					}
+				}
+			}
+			if visit(x, y) {
+				return
+			}
+			errPrev = err
+		}
+		return
+	}
+
+	// y-major: symmetric to the above.
+	errPrev, err := dy, dy
+	for i := 0; i < dy; i++ {
+		y += ystep
+		err += ddx
+		if err > ddy {
+			x += xstep
+			err -= ddy
+			switch {
+			case err+errPrev < ddy:
+				if visit(x-xstep, y) {
+					return
+				}
+			case err+errPrev > ddy:
+				if visit(x, y-ystep) {
+					return
+				}
+			default:
+				if visit(x-xstep, y) || visit(x, y-ystep) {
+					return
+				}
+			}
+		}
+		if visit(x, y) {
+			return
+		}
+		errPrev = err
+	}
+}
@@ -0,0 +1,106 @@
+package reinforcement
+
+import (
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCollisionModes(t *testing.T) {
+	Convey("Given a track with a tight diagonal gap", t, func() {
+		// The open cells (0,0),(0,1),(0,2),(1,2),(1,3),(1,4) are exactly those through which
+		// the line from (0,0) to (1,4) passes; the remaining cells in that region are walls.
+		states := Convert([]string{
+			"WW+",
+			"WoW",
+			"WoW",
+			"ooW",
+			"oWW",
+			"-WW",
+		}, DefaultKinematics)
+		start := &states[0][0][0][0]
+
+		Convey("When box collision checking is used", func() {
+			collision := checkTerminalCollision(states, start, 1, 4)
+
+			Convey("Then the walls adjacent to the line are reported as a collision", func() {
+				So(collision, ShouldNotBeNil)
+				So(collision.CellType, ShouldEqual, WALL)
+			})
+		})
+
+		Convey("When supercover collision checking is used", func() {
+			collision := checkSupercoverCollision(states, start, 1, 4)
+
+			Convey("Then the agent passes through the gap", func() {
+				So(collision, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a diagonal move that would clip between two wall corners", t, func() {
+		states := Convert([]string{
+			"W+",
+			"-W",
+		}, DefaultKinematics)
+		start := &states[0][0][0][0]
+
+		Convey("Then both modes report a collision", func() {
+			So(checkTerminalCollision(states, start, 1, 1), ShouldNotBeNil)
+			So(checkSupercoverCollision(states, start, 1, 1), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a move with negative velocity components", t, func() {
+		states := Convert([]string{
+			"Wo+o",
+			"oooo",
+			"oooo",
+			"ooo-",
+		}, DefaultKinematics)
+		start := &states[3][0][0][0]
+
+		Convey("Then both modes check the region behind the start position", func() {
+			for _, collide := range []collisionFunc{checkTerminalCollision, checkSupercoverCollision} {
+				collision := collide(states, start, -3, 3)
+				So(collision, ShouldNotBeNil)
+				So(collision.X, ShouldEqual, 0)
+				So(collision.Y, ShouldEqual, 3)
+			}
+		})
+
+		Convey("Then moves clear of walls do not collide", func() {
+			for _, collide := range []collisionFunc{checkTerminalCollision, checkSupercoverCollision} {
+				So(collide(states, start, -3, 2), ShouldBeNil)
+			}
+		})
+	})
+
+	Convey("When supercover traverses a shallow line", t, func() {
+		visited := [][2]int{}
+		supercover(0, 0, 4, 1, func(x, y int) bool {
+			visited = append(visited, [2]int{x, y})
+			return false
+		})
+
+		Convey("Then every cell the segment passes through is visited in order", func() {
+			So(visited, ShouldResemble, [][2]int{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {3, 1}, {4, 1}})
+		})
+	})
+
+	Convey("When the collision mode hyperparameter is set", t, func() {
+		states := Convert([]string{"WW+", "WoW", "WoW", "ooW", "oWW", "-WW"}, DefaultKinematics)
+		start := &states[0][0][0][0]
+
+		Convey("Then supercover checking is selected", func() {
+			config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "collisionMode", Val: collisionModeSupercover}}}
+			So(getCollisionFunc(config)(states, start, 1, 4), ShouldBeNil)
+		})
+
+		Convey("Then box checking is the default", func() {
+			So(getCollisionFunc(&TrainingConfig{})(states, start, 1, 4), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,144 @@
+package reinforcement
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	. "tabular/grid_world"
+)
+
+// The convergence criteria of ConvergenceConfig.
+const (
+	// ConvergeOnDelta, the default, converges once the max absolute value-delta applied per
+	// episode remains below the threshold.
+	ConvergeOnDelta = "delta"
+	// ConvergeOnPolicy converges once no cell's greedy action changes, per
+	// EvaluatePolicyStability, even if the values still drift; often a cleaner stop signal.
+	ConvergeOnPolicy = "policy"
+)
+
+// ConvergenceConfig describes when learning has plateaued: when the Criterion has held for
+// Window consecutive episodes. Convergence checking is disabled when Window is not positive.
+type ConvergenceConfig struct {
+	// Threshold is the max value-delta of the delta criterion; unused by the policy criterion.
+	Threshold float64 `mapstructure:"threshold" yaml:"threshold"`
+	Window    int     `mapstructure:"window" yaml:"window"`
+	// Criterion is ConvergeOnDelta or ConvergeOnPolicy; empty selects ConvergeOnDelta.
+	Criterion string `mapstructure:"criterion" yaml:"criterion,omitempty"`
+}
+
+// policyChecksPerWindow is the number of times the policy criterion compares the greedy policy
+// per window, since comparing it every episode would dominate training.
+const policyChecksPerWindow = 10
+
+type convergenceKey struct{}
+
+// convergenceMonitor cancels training once its criterion has held across the window. It is
+// only used by the (single) estimator, hence is not synchronized.
+type convergenceMonitor struct {
+	criterion string
+	threshold float64
+	window    int
+	// The number of consecutive episodes for which the criterion held.
+	quiescent int
+	// The max absolute delta of the current episode.
+	maxDelta float64
+	cancel   context.CancelFunc
+
+	// For the policy criterion, the trained states, bound by Train, and the greedy policy of the
+	// last comparison, which occurs every interval episodes.
+	states   [][][][]State
+	config   *TrainingConfig
+	policy   [][]*Action
+	interval int
+	episodes int
+}
+
+// WithConvergenceStop returns a context that is cancelled once training converges,
+// per the convergence config, if one is specified. This is the sibling of
+// WithTrainingDeadline: training stops on whichever occurs first.
+func (cfg *TrainingConfig) WithConvergenceStop(
+	ctx context.Context,
+) (context.Context, error) {
+	cc := cfg.Convergence
+	if cc.Window <= 0 {
+		return ctx, nil
+	}
+	criterion := cc.Criterion
+	switch criterion {
+	case "":
+		criterion = ConvergeOnDelta
+		fallthrough
+	case ConvergeOnDelta:
+		if cc.Threshold <= 0 {
+			return nil, fmt.Errorf("convergence threshold must be positive, got %f", cc.Threshold)
+		}
+	case ConvergeOnPolicy:
+	default:
+		return nil, fmt.Errorf("unknown convergence criterion %q, expected %q or %q",
+			cc.Criterion, ConvergeOnDelta, ConvergeOnPolicy)
+	}
+
+	innerCtx, cancel := context.WithCancel(ctx)
+	monitor := &convergenceMonitor{
+		criterion: criterion,
+		threshold: cc.Threshold,
+		window:    cc.Window,
+		cancel:    cancel,
+		interval:  cc.Window / policyChecksPerWindow,
+	}
+	if monitor.interval < 1 {
+		monitor.interval = 1
+	}
+	return context.WithValue(innerCtx, convergenceKey{}, monitor), nil
+}
+
+// getConvergenceMonitor returns the convergence monitor of the passed context, or nil if none.
+func getConvergenceMonitor(ctx context.Context) *convergenceMonitor {
+	monitor, _ := ctx.Value(convergenceKey{}).(*convergenceMonitor)
+	return monitor
+}
+
+// bind sets the states whose greedy policy the policy criterion compares.
+func (cm *convergenceMonitor) bind(states [][][][]State, config *TrainingConfig) {
+	cm.states, cm.config = states, config
+}
+
+// Observe records a delta applied during the current episode.
+func (cm *convergenceMonitor) Observe(delta float64) {
+	cm.maxDelta = math.Max(cm.maxDelta, math.Abs(delta))
+}
+
+// EndEpisode completes the current episode, cancelling training if the criterion has held
+// across the window.
+func (cm *convergenceMonitor) EndEpisode(_ int) {
+	if cm.criterion == ConvergeOnPolicy {
+		cm.comparePolicy()
+	} else if cm.maxDelta < cm.threshold {
+		cm.quiescent++
+	} else {
+		cm.quiescent = 0
+	}
+	cm.maxDelta = 0
+
+	if cm.quiescent >= cm.window {
+		cm.cancel()
+	}
+}
+
+// comparePolicy compares the greedy policy against that of the last comparison every interval
+// episodes, counting the interval as quiescent if no cell's action changed.
+func (cm *convergenceMonitor) comparePolicy() {
+	cm.episodes++
+	if cm.episodes%cm.interval != 0 || cm.states == nil {
+		return
+	}
+	policy := GreedyActions(cm.states, cm.config)
+	if cm.policy != nil && EvaluatePolicyStability(cm.policy, policy) == 0 {
+		cm.quiescent += cm.interval
+	} else {
+		cm.quiescent = 0
+	}
+	cm.policy = policy
+}
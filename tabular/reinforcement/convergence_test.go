@@ -0,0 +1,58 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConvergenceCriteria(t *testing.T) {
+	Convey("Given convergence configs", t, func() {
+		Convey("Then the delta criterion requires a threshold, and is the default", func() {
+			for _, criterion := range []string{"", ConvergeOnDelta} {
+				config := &TrainingConfig{Convergence: ConvergenceConfig{Window: 10, Criterion: criterion}}
+				_, err := config.WithConvergenceStop(context.Background())
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("Then the policy criterion does not", func() {
+			config := &TrainingConfig{Convergence: ConvergenceConfig{Window: 10, Criterion: ConvergeOnPolicy}}
+			_, err := config.WithConvergenceStop(context.Background())
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Then unknown criteria are rejected", func() {
+			config := &TrainingConfig{Convergence: ConvergenceConfig{Threshold: 1, Window: 10, Criterion: "values"}}
+			_, err := config.WithConvergenceStop(context.Background())
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given sequential training that stops once the greedy policy is stable", t, func() {
+		config := &TrainingConfig{
+			HyperParams: []HyperParameter{{Key: "seed", Val: 42}},
+			Convergence: ConvergenceConfig{Window: 1000, Criterion: ConvergeOnPolicy},
+			// Starting at rest from the START cells only, the greedy policy settles quickly; per random
+			// starts it takes far longer, since more of the cells' substates are trained, whose close
+			// values flip their greedy actions more often.
+			StartVelocity: startVelocityZero,
+		}
+		timeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		Reset(cancel)
+		ctx, err := config.WithConvergenceStop(timeout)
+		So(err, ShouldBeNil)
+
+		states := Convert(DebugTrack, DefaultKinematics)
+		So(TrainSync(ctx, states, config, 0), ShouldBeNil)
+
+		Convey("Then training converges before the timeout", func() {
+			So(timeout.Err(), ShouldBeNil)
+			So(ctx.Err(), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,149 @@
+package reinforcement
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	. "tabular/grid_world"
+)
+
+// WithEpisodeUpdates publishes an agent's episode to the passed chan every interval
+// episodes, e.g. for visualizing trajectories. Publishing never blocks the agents;
+// sampled episodes are dropped if the receiver is not ready.
+func WithEpisodeUpdates(episodes chan<- *Episode, interval int) TrainOption {
+	return func(opts *trainOptions) {
+		opts.samplers = append(opts.samplers, newEpisodeSampler(episodes, interval))
+	}
+}
+
+// WithGreedyEpisodeUpdates publishes a greedy rollout of the passed sampler to the passed chan
+// every interval episodes, rather than an agent's episode, as WithEpisodeUpdates does otherwise.
+func WithGreedyEpisodeUpdates(
+	episodes chan<- *Episode,
+	interval int,
+	sampler *ExportEpisodeSampler,
+) TrainOption {
+	return func(opts *trainOptions) {
+		es := newEpisodeSampler(episodes, interval)
+		es.export = sampler
+		opts.samplers = append(opts.samplers, es)
+	}
+}
+
+// episodeSamplers offers each episode to every sampler; an empty set samples nothing.
+type episodeSamplers []*episodeSampler
+
+func (samplers episodeSamplers) sample(episode *Episode) {
+	for _, sampler := range samplers {
+		sampler.sample(episode)
+	}
+}
+
+// episodeSampler publishes every interval-th episode generated by any agent.
+// A nil sampler samples nothing, and a sampler without a chan only counts episodes.
+type episodeSampler struct {
+	episodes chan<- *Episode
+	interval int64
+	count    int64
+	// If set, publishing blocks the agent until the episode is received or done is closed,
+	// rather than dropping the episode if the receiver is not ready.
+	block bool
+	done  <-chan struct{}
+	// If set, its rollout is published in place of each episode that is due.
+	export *ExportEpisodeSampler
+}
+
+func newEpisodeSampler(episodes chan<- *Episode, interval int) *episodeSampler {
+	if interval <= 0 {
+		interval = 1
+	}
+	return &episodeSampler{
+		episodes: episodes,
+		interval: int64(interval),
+	}
+}
+
+// sample counts the passed episode, publishing it if it is due.
+// The episode must not be modified afterward, as the receiver reads it concurrently.
+func (es *episodeSampler) sample(episode *Episode) {
+	if es == nil {
+		return
+	}
+	if atomic.AddInt64(&es.count, 1)%es.interval != 0 || es.episodes == nil {
+		return
+	}
+	if es.export != nil {
+		episode = es.export.Sample()
+	}
+
+	if es.block {
+		select {
+		case es.episodes <- episode:
+		case <-es.done:
+		}
+		return
+	}
+
+	select {
+	case es.episodes <- episode:
+	default:
+	}
+}
+
+// sampled returns the number of episodes counted by the sampler.
+func (es *episodeSampler) sampled() int {
+	return int(atomic.LoadInt64(&es.count))
+}
+
+// ExportEpisodeSampler rolls out the greedy policy from a fixed start cell, per EvaluatePolicy,
+// such that the sampled episodes show the current policy rather than exploration noise. Whereas
+// successive agent episodes jump between random starts and random actions, its rollouts only
+// change as the policy does, hence settle as it improves.
+type ExportEpisodeSampler struct {
+	states [][][][]State
+	config *TrainingConfig
+	x, y   int
+}
+
+// NewExportEpisodeSampler returns a sampler of greedy rollouts from the start cell at the passed
+// position, or an error if that is not a start cell.
+func NewExportEpisodeSampler(
+	states [][][][]State,
+	config *TrainingConfig,
+	x, y int,
+) (*ExportEpisodeSampler, error) {
+	if x < 0 || x >= len(states) || y < 0 || y >= len(states[x]) {
+		return nil, fmt.Errorf("start cell (%d,%d) is outside of the %dx%d track", x, y, len(states), len(states[0]))
+	}
+	if cellType := states[x][y][0][0].CellType; cellType != START {
+		return nil, fmt.Errorf("cell (%d,%d) is %q, not a start cell %q", x, y, cellType, START)
+	}
+	return &ExportEpisodeSampler{
+		states: states,
+		config: config,
+		x:      x,
+		y:      y,
+	}, nil
+}
+
+// Sample returns the greedy rollout from the start cell's max-valued velocity substate, per
+// MaxVelState, until it reaches a terminal state or loops. The values are only read atomically,
+// so episodes may be sampled during training.
+func (sampler *ExportEpisodeSampler) Sample() *Episode {
+	rewards := sampler.config.GetRewards()
+	episode := Episode{}
+	greedyRollout(
+		sampler.states,
+		MaxVelState(sampler.states[sampler.x][sampler.y]),
+		getCollisionFunc(sampler.config),
+		sampler.config.GetKinematics(),
+		func(state, successor *State, action *Action) {
+			episode = append(episode, Step{
+				State:     state,
+				Successor: successor,
+				Action:    action,
+				Reward:    getReward(successor, rewards),
+			})
+		})
+	return &episode
+}
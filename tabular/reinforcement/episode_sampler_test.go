@@ -0,0 +1,75 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExportEpisodeSampler(t *testing.T) {
+	Convey("Given a policy trained sequentially, with a fixed seed", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 42}}}
+		states := Convert(DebugTrack, DefaultKinematics)
+		ctx, cancel := WithEpisodeBudget(context.Background(), 2000)
+		Reset(cancel)
+		So(TrainSync(ctx, states, config, 0), ShouldBeNil)
+
+		Convey("When a greedy rollout is sampled from a start cell", func() {
+			sampler, err := NewExportEpisodeSampler(states, config, 1, 0)
+			So(err, ShouldBeNil)
+			episode := *sampler.Sample()
+
+			Convey("Then it is a contiguous greedy episode from the start cell's best substate", func() {
+				So(len(episode), ShouldBeGreaterThan, 0)
+				So(episode[0].State, ShouldEqual, MaxVelState(states[1][0]))
+				for i, step := range episode {
+					successor, action := get_max_successor(states, step.State, getCollisionFunc(config), config.GetKinematics())
+					So(step.Successor, ShouldEqual, successor)
+					So(*step.Action, ShouldResemble, *action)
+					So(step.Reward, ShouldEqual, getReward(step.Successor, config.GetRewards()))
+					if i > 0 {
+						So(step.State, ShouldEqual, episode[i-1].Successor)
+					}
+				}
+				So(is_terminal(episode[len(episode)-1].Successor), ShouldBeTrue)
+			})
+
+			Convey("Then resampling the unchanged policy yields the same episode", func() {
+				So(*sampler.Sample(), ShouldResemble, episode)
+			})
+		})
+
+		Convey("Then cells other than start cells are rejected", func() {
+			_, err := NewExportEpisodeSampler(states, config, 1, 1)
+			So(err, ShouldNotBeNil)
+			_, err = NewExportEpisodeSampler(states, config, len(states), 0)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given training that publishes greedy episodes", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 42}}}
+		states := Convert(DebugTrack, DefaultKinematics)
+		sampler, err := NewExportEpisodeSampler(states, config, 2, 0)
+		So(err, ShouldBeNil)
+		episodes := make(chan *Episode, 10)
+		ctx, cancel := WithEpisodeBudget(context.Background(), 100)
+		defer cancel()
+		So(TrainSync(ctx, states, config, 0, WithGreedyEpisodeUpdates(episodes, 10, sampler)), ShouldBeNil)
+		close(episodes)
+
+		Convey("Then every published episode starts from the start cell", func() {
+			n := 0
+			for episode := range episodes {
+				So(len(*episode), ShouldBeGreaterThan, 0)
+				So((*episode)[0].State.X, ShouldEqual, 2)
+				So((*episode)[0].State.Y, ShouldEqual, 0)
+				n++
+			}
+			So(n, ShouldEqual, 10)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package reinforcement
+
+import (
+	"fmt"
+	"math"
+
+	. "tabular/grid_world"
+)
+
+// EvaluatePolicy measures the quality of the greedy policy over the current state values,
+// which are more readily interpreted than the values themselves. The policy is rolled out
+// from every non-zero velocity substate of each start cell, always taking the action leading
+// to the max-valued successor, per get_max_successor. meanSteps is the mean number of steps of
+// the rollouts reaching a finish cell, or 0 if none do. failRate is the fraction of rollouts
+// that instead crash into a wall or loop. Since both the policy and the kinematics are
+// deterministic, a rollout that revisits a state loops forever, so it is stopped there; hence
+// no rollout exceeds the number of states. The values are only read atomically, so the policy
+// may be evaluated during training.
+func EvaluatePolicy(
+	states [][][][]State,
+	config *TrainingConfig,
+) (meanSteps float64, failRate float64) {
+	collide := getCollisionFunc(config)
+	kinematics := config.GetKinematics()
+
+	rollouts, finished, totalSteps := 0, 0, 0
+	Visit(states, func(start *State) {
+		if start.CellType != START || (start.VX == 0 && start.VY == 0) {
+			return
+		}
+		rollouts++
+		if steps, ok := greedyRollout(states, start, collide, kinematics, nil); ok {
+			finished++
+			totalSteps += steps
+		}
+	})
+
+	if finished > 0 {
+		meanSteps = float64(totalSteps) / float64(finished)
+	}
+	if rollouts > 0 {
+		failRate = float64(rollouts-finished) / float64(rollouts)
+	}
+	return
+}
+
+// greedyRollout follows the greedy policy from the passed state, returning the number of steps
+// taken and whether a finish cell was reached, rather than a wall or a loop. Each step is passed
+// to onStep, unless it is nil.
+func greedyRollout(
+	states [][][][]State,
+	start *State,
+	collide collisionFunc,
+	kinematics Kinematics,
+	onStep func(state, successor *State, action *Action),
+) (steps int, finished bool) {
+	visited := map[*State]bool{}
+	state := start
+	for !is_terminal(state) {
+		if visited[state] {
+			return steps, false
+		}
+		visited[state] = true
+		successor, action := get_max_successor(states, state, collide, kinematics)
+		if onStep != nil {
+			onStep(state, successor, action)
+		}
+		state = successor
+		steps++
+	}
+	return steps, IsFinish(state.CellType)
+}
+
+// ValueError returns the root mean square error of the state values against those of the passed
+// reference, e.g. a prior converged run restored via LoadValues, such that the rate at which
+// training approaches it can be measured. Terminal states are excluded, since their values are
+// fixed by the rewards. An error is returned if the grids' dimensions or cell types differ,
+// rather than a misleading error over some overlap of different tracks.
+func ValueError(states, reference [][][][]State) (float64, error) {
+	if err := checkSameGrid(states, reference); err != nil {
+		return 0, err
+	}
+
+	sumSquares, n := 0.0, 0
+	for x := range states {
+		for y := range states[x] {
+			for vx := range states[x][y] {
+				for vy := range states[x][y][vx] {
+					state := &states[x][y][vx][vy]
+					if is_terminal(state) {
+						continue
+					}
+					diff := state.Value.AtomicRead() - reference[x][y][vx][vy].Value.AtomicRead()
+					sumSquares += diff * diff
+					n++
+				}
+			}
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return math.Sqrt(sumSquares / float64(n)), nil
+}
+
+// checkSameGrid returns an error if the passed grids differ in dimensions or cell types.
+func checkSameGrid(states, reference [][][][]State) error {
+	dims := func(grid [][][][]State) (width, height, numVelocities int) {
+		width = len(grid)
+		if width > 0 {
+			height = len(grid[0])
+			if height > 0 {
+				numVelocities = len(grid[0][0])
+			}
+		}
+		return
+	}
+	width, height, numVelocities := dims(states)
+	refWidth, refHeight, refNumVelocities := dims(reference)
+	if width != refWidth || height != refHeight || numVelocities != refNumVelocities {
+		return fmt.Errorf("reference dimensions (%d x %d x %d x %d) do not match grid (%d x %d x %d x %d)",
+			refWidth, refHeight, refNumVelocities, refNumVelocities,
+			width, height, numVelocities, numVelocities)
+	}
+	for x := range states {
+		for y := range states[x] {
+			if cellType, refCellType := states[x][y][0][0].CellType, reference[x][y][0][0].CellType; cellType != refCellType {
+				return fmt.Errorf("reference cell (%d,%d) is %q, not %q", x, y, refCellType, cellType)
+			}
+		}
+	}
+	return nil
+}
+
+// GreedyActions returns the greedy action of each x/y cell, per get_max_successor from the cell's
+// max-valued velocity substate, as shown by the views; nil for walls and finish cells, which have
+// no policy. Like EvaluatePolicy, the values are only read atomically.
+func GreedyActions(
+	states [][][][]State,
+	config *TrainingConfig,
+) [][]*Action {
+	collide := getCollisionFunc(config)
+	kinematics := config.GetKinematics()
+
+	actions := make([][]*Action, len(states))
+	for x := range states {
+		actions[x] = make([]*Action, len(states[x]))
+		for y := range states[x] {
+			if is_terminal(&states[x][y][0][0]) {
+				continue
+			}
+			_, actions[x][y] = get_max_successor(states, MaxVelState(states[x][y]), collide, kinematics)
+		}
+	}
+	return actions
+}
+
+// EvaluatePolicyStability returns the number of cells whose greedy action differs between the
+// passed policies, per GreedyActions, e.g. those of successive sweeps. Once it stays at zero the
+// policy has converged, even if the values still drift. A nil prev counts every cell with an
+// action as changed.
+func EvaluatePolicyStability(prev, cur [][]*Action) (changed int) {
+	for x := range cur {
+		for y, action := range cur[x] {
+			var prevAction *Action
+			if x < len(prev) && y < len(prev[x]) {
+				prevAction = prev[x][y]
+			}
+			switch {
+			case action == nil && prevAction == nil:
+			case action == nil || prevAction == nil || *action != *prevAction:
+				changed++
+			}
+		}
+	}
+	return
+}
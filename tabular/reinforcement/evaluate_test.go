@@ -0,0 +1,153 @@
+package reinforcement
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	Convey("Given a policy trained sequentially, with a fixed seed", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 42}}}
+		untrained := Convert(DebugTrack, DefaultKinematics)
+		initStateVals(untrained, config.GetRewards().Collision)
+		trained := Convert(DebugTrack, DefaultKinematics)
+		ctx, cancel := WithEpisodeBudget(context.Background(), 2000)
+		Reset(cancel)
+		So(TrainSync(ctx, trained, config, 0), ShouldBeNil)
+
+		Convey("Then fewer greedy rollouts fail than those of the untrained policy", func() {
+			_, untrainedFailRate := EvaluatePolicy(untrained, config)
+			meanSteps, failRate := EvaluatePolicy(trained, config)
+			So(failRate, ShouldBeLessThan, untrainedFailRate)
+			So(meanSteps, ShouldBeGreaterThan, 0)
+		})
+	})
+
+	Convey("Given state values that are max at the walls", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		Visit(states, func(s *State) {
+			if s.CellType == WALL {
+				s.Value.AtomicSet(1000)
+			}
+		})
+
+		Convey("Then every greedy rollout fails", func() {
+			meanSteps, failRate := EvaluatePolicy(states, &TrainingConfig{})
+			So(failRate, ShouldEqual, 1)
+			So(meanSteps, ShouldEqual, 0)
+		})
+	})
+
+	Convey("Given a wrapped track without walls, whose finish is avoided", t, func() {
+		kinematics := DefaultKinematics
+		kinematics.Wrap = true
+		states := Convert([]string{"-ooo", "oooo", "oooo", "-oo+"}, kinematics)
+		Visit(states, func(s *State) {
+			if IsFinish(s.CellType) {
+				s.Value.AtomicSet(-1000)
+			}
+		})
+
+		Convey("Then every greedy rollout loops, and is stopped as a failure", func() {
+			meanSteps, failRate := EvaluatePolicy(states, &TrainingConfig{Kinematics: &kinematics})
+			So(failRate, ShouldEqual, 1)
+			So(meanSteps, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestValueError(t *testing.T) {
+	Convey("Given a state grid and a reference of the same track", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		reference := Convert(DebugTrack, DefaultKinematics)
+		initStateVals(states, -5)
+		initStateVals(reference, -5)
+
+		Convey("Then the error is zero for equal values", func() {
+			rms, err := ValueError(states, reference)
+			So(err, ShouldBeNil)
+			So(rms, ShouldEqual, 0)
+		})
+
+		Convey("Then the error is the RMS of the differences of the non-terminal states", func() {
+			Visit(states, func(s *State) {
+				if !is_terminal(s) {
+					s.Value.AtomicAdd(2)
+				} else {
+					s.Value.AtomicAdd(100)
+				}
+			})
+			rms, err := ValueError(states, reference)
+			So(err, ShouldBeNil)
+			So(rms, ShouldAlmostEqual, 2)
+		})
+	})
+
+	Convey("Given a reference of different dimensions", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		reference := Convert(DebugTrack[1:], DefaultKinematics)
+
+		Convey("Then an error is returned rather than a value error", func() {
+			_, err := ValueError(states, reference)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a reference of a different track of the same dimensions", t, func() {
+		track := append([]string{}, DebugTrack...)
+		track[1] = strings.Replace(track[1], "o", "W", 1)
+		states := Convert(DebugTrack, DefaultKinematics)
+		reference := Convert(track, DefaultKinematics)
+
+		Convey("Then an error is returned", func() {
+			_, err := ValueError(states, reference)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestEvaluatePolicyStability(t *testing.T) {
+	Convey("Given the greedy policies of an untrained and a trained grid", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 42}}}
+		untrained := Convert(DebugTrack, DefaultKinematics)
+		initStateVals(untrained, config.GetRewards().Collision)
+		trained := Convert(DebugTrack, DefaultKinematics)
+		ctx, cancel := WithEpisodeBudget(context.Background(), 2000)
+		Reset(cancel)
+		So(TrainSync(ctx, trained, config, 0), ShouldBeNil)
+		before, after := GreedyActions(untrained, config), GreedyActions(trained, config)
+
+		Convey("Then walls and finish cells have no action, and all others do", func() {
+			for x := range trained {
+				for y := range trained[x] {
+					So(after[x][y] == nil, ShouldEqual, is_terminal(&trained[x][y][0][0]))
+				}
+			}
+		})
+
+		Convey("Then a policy is stable against itself", func() {
+			So(EvaluatePolicyStability(after, GreedyActions(trained, config)), ShouldEqual, 0)
+		})
+
+		Convey("Then training changed some cells' actions", func() {
+			So(EvaluatePolicyStability(before, after), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("Then every cell with an action has changed from no policy", func() {
+			cells := 0
+			for x := range after {
+				for y := range after[x] {
+					if after[x][y] != nil {
+						cells++
+					}
+				}
+			}
+			So(EvaluatePolicyStability(nil, after), ShouldEqual, cells)
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package reinforcement
+
+import (
+	"fmt"
+	"math"
+)
+
+// The exploration presets, per TrainingConfig.Exploration.
+const (
+	// ExplorationEpsilonGreedy, the default, explores by taking random actions with probability
+	// epsilon, from pessimistic initial values.
+	ExplorationEpsilonGreedy = "epsilon-greedy"
+	// ExplorationOptimistic explores via optimistic initial values: every state is initialized
+	// above the rewards an agent can attain, and epsilon is annealed toward zero. Even a greedy
+	// agent then prefers unvisited states, since the values of those it has visited are corrected
+	// downward, until the inflated values have been learned away.
+	ExplorationOptimistic = "optimistic"
+)
+
+// optimisticEpsilonDecay is the epsilonDecay of the optimistic preset, which anneals epsilon to
+// under 1% of its initial value within 5000 episodes.
+const optimisticEpsilonDecay = 0.999
+
+// validateExploration returns an error if the exploration preset is unknown.
+func (cfg *TrainingConfig) validateExploration() error {
+	switch cfg.Exploration {
+	case "", ExplorationEpsilonGreedy, ExplorationOptimistic:
+		return nil
+	}
+	return fmt.Errorf("unknown exploration %q, expected %q or %q",
+		cfg.Exploration, ExplorationEpsilonGreedy, ExplorationOptimistic)
+}
+
+// explorationDefault returns the default of the passed hyper-param per the exploration preset,
+// if the preset has one. Explicit hyper-params override the preset's defaults.
+func (cfg *TrainingConfig) explorationDefault(param string) (float64, bool) {
+	if cfg.Exploration != ExplorationOptimistic {
+		return 0, false
+	}
+	switch param {
+	case "initValue":
+		return optimisticInitValue(cfg.GetRewards()), true
+	case "epsilonDecay":
+		return optimisticEpsilonDecay, true
+	case "epsilonMin":
+		return 0, true
+	}
+	return 0, false
+}
+
+// optimisticInitValue returns an initial value above any attainable return: the max finish reward,
+// since every other reward is a cost, plus the range of the rewards, such that it remains
+// optimistic for a few visits.
+func optimisticInitValue(rewards *Rewards) float64 {
+	maxFinish := rewards.Finish
+	for _, reward := range rewards.FinishTiers {
+		maxFinish = math.Max(maxFinish, reward)
+	}
+	return maxFinish + (maxFinish - rewards.Collision)
+}
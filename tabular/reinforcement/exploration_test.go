@@ -0,0 +1,67 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExploration(t *testing.T) {
+	Convey("Given the optimistic exploration preset", t, func() {
+		config := &TrainingConfig{Exploration: ExplorationOptimistic}
+
+		Convey("Then its initial value exceeds every attainable reward, and epsilon anneals to zero", func() {
+			So(config.validateExploration(), ShouldBeNil)
+			So(getInitValue(config), ShouldBeGreaterThan, FINISH_REWARD)
+			So(config.GetHyperParamOrDefault("epsilonDecay", 1), ShouldBeLessThan, 1)
+			So(config.GetHyperParamOrDefault("epsilonMin", 0.5), ShouldEqual, 0)
+		})
+
+		Convey("Then explicit hyper-params override its defaults", func() {
+			config.HyperParams = []HyperParameter{{Key: "initValue", Val: 100}, {Key: "finishReward3", Val: 20}}
+			So(getInitValue(config), ShouldEqual, 100)
+			config.HyperParams = config.HyperParams[1:]
+			So(getInitValue(config), ShouldBeGreaterThan, 20)
+		})
+	})
+
+	Convey("Given an unknown exploration preset", t, func() {
+		config := &TrainingConfig{Exploration: "curious"}
+
+		Convey("Then it is rejected", func() {
+			So(config.validateExploration(), ShouldNotBeNil)
+			So(TrainSync(context.Background(), Convert(DebugTrack, DefaultKinematics), config, 0), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given greedy agents, which never take random actions", t, func() {
+		// visited returns the number of non-terminal states updated by a short run of greedy training,
+		// hence visited, per the passed exploration preset.
+		visited := func(exploration string) (n int) {
+			config := &TrainingConfig{
+				HyperParams: []HyperParameter{{Key: "epsilon", Val: 0}, {Key: "eta", Val: 0.1}, {Key: "seed", Val: 7}},
+				Exploration: exploration,
+			}
+			states := Convert(DebugTrack, DefaultKinematics)
+			ctx, cancel := WithEpisodeBudget(context.Background(), 200)
+			defer cancel()
+			So(TrainSync(ctx, states, config, 0), ShouldBeNil)
+
+			init := getInitValue(config)
+			Visit(states, func(s *State) {
+				if !is_terminal(s) && s.Value.AtomicRead() != init {
+					n++
+				}
+			})
+			return
+		}
+
+		Convey("Then optimistic initial values drive them to visit more states early", func() {
+			optimistic, pessimistic := visited(ExplorationOptimistic), visited(ExplorationEpsilonGreedy)
+			So(optimistic, ShouldBeGreaterThan, pessimistic)
+		})
+	})
+}
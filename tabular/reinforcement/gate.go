@@ -0,0 +1,141 @@
+package reinforcement
+
+import (
+	"sync"
+
+	. "tabular/grid_world"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// Gate pauses and resumes training. While paused the estimator stops draining the agents'
+// episodes, which backpressures the agents, so all training halts until resumed.
+// A nil Gate is never paused, and ignores Pause and Resume.
+type Gate struct {
+	mu sync.Mutex
+	// open is closed while the gate is open, and replaced with an open chan when paused.
+	open   chan struct{}
+	paused bool
+	// applying is read-locked by the estimators while they update the state values, and
+	// write-locked by Hold.
+	applying sync.RWMutex
+}
+
+// NewGate returns an open gate.
+func NewGate() *Gate {
+	open := make(chan struct{})
+	close(open)
+	return &Gate{open: open}
+}
+
+// WithGate pauses and resumes training per the passed gate.
+func WithGate(gate *Gate) TrainOption {
+	return func(opts *trainOptions) {
+		opts.gate = gate
+	}
+}
+
+// Pause halts training until Resume is called. Redundant calls are ignored.
+func (g *Gate) Pause() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.paused = true
+		g.open = make(chan struct{})
+	}
+}
+
+// Resume continues training if paused. Redundant calls are ignored.
+func (g *Gate) Resume() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.paused = false
+		close(g.open)
+	}
+}
+
+// Paused returns whether training is currently paused.
+func (g *Gate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Hold runs fn while the estimators apply no updates to the state values, waiting for those in
+// progress, such that fn observes the values at a single point in training, e.g. to snapshot
+// them coherently. Training blocks meanwhile, hence fn should be brief. Unlike Pause, Hold
+// need not be released. A nil gate runs fn immediately.
+func (g *Gate) Hold(fn func()) {
+	if g == nil {
+		fn()
+		return
+	}
+	g.applying.Lock()
+	defer g.applying.Unlock()
+	fn()
+}
+
+// SnapshotValues returns a copy of the state values, per grid_world.SnapshotValues, read while
+// the gate holds the estimators, hence internally consistent.
+func (g *Gate) SnapshotValues(states [][][][]State) (values [][][][]float64) {
+	g.Hold(func() { values = SnapshotValues(states) })
+	return
+}
+
+// apply runs the passed update of the state values, blocking while the gate holds the
+// estimators. A nil gate runs it immediately. Only the estimators apply updates.
+func (g *Gate) apply(update func()) {
+	if g == nil {
+		update()
+		return
+	}
+	g.applying.RLock()
+	defer g.applying.RUnlock()
+	update()
+}
+
+// wait blocks while the gate is paused, or until done is closed.
+func (g *Gate) wait(done <-chan struct{}) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	open := g.open
+	g.mu.Unlock()
+
+	select {
+	case <-open:
+	case <-done:
+	}
+}
+
+// gated forwards items from the passed chan only while the gate is open.
+func gated[T any](done <-chan struct{}, gate *Gate, input <-chan T) <-chan T {
+	if gate == nil {
+		return input
+	}
+
+	output := make(chan T)
+	go func() {
+		defer close(output)
+		for item := range channerics.OrDone(done, input) {
+			gate.wait(done)
+			select {
+			case output <- item:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,94 @@
+package reinforcement
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGate(t *testing.T) {
+	Convey("Given a gated channel", t, func() {
+		done := make(chan struct{})
+		defer close(done)
+		gate := NewGate()
+		input := make(chan int, 2)
+		output := gated(done, gate, input)
+
+		Convey("Items are forwarded while the gate is open", func() {
+			input <- 1
+			So(<-output, ShouldEqual, 1)
+			So(gate.Paused(), ShouldBeFalse)
+		})
+
+		Convey("Items are held while paused, and forwarded once resumed", func() {
+			gate.Pause()
+			gate.Pause()
+			So(gate.Paused(), ShouldBeTrue)
+			input <- 1
+
+			select {
+			case <-output:
+				t.Fatal("item forwarded while paused")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			gate.Resume()
+			gate.Resume()
+			So(gate.Paused(), ShouldBeFalse)
+			So(<-output, ShouldEqual, 1)
+		})
+	})
+
+	Convey("A nil gate is never paused", t, func() {
+		var gate *Gate
+		input := make(chan int)
+		So(gated(nil, gate, input), ShouldEqual, (<-chan int)(input))
+		So(gate.Paused(), ShouldBeFalse)
+		So(gate.Pause, ShouldNotPanic)
+		So(gate.Paused(), ShouldBeFalse)
+		So(gate.Resume, ShouldNotPanic)
+	})
+}
+
+func TestGateHold(t *testing.T) {
+	for _, kind := range []string{AlgAlphaMonteCarlo, AlgQLearning, AlgSarsa, AlgTDLambda} {
+		Convey(fmt.Sprintf("Given %s training with a gate", kind), t, func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			Reset(cancel)
+			gate := NewGate()
+			states := Convert(DebugTrack, DefaultKinematics)
+			config := &TrainingConfig{Algorithm: AlgorithmConfig{Kind: kind}}
+			Train(ctx, states, config, 2, func(context.Context, int) {}, WithGate(gate))
+			// Await training, per the values updated.
+			initial := SnapshotValues(states)
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if fmt.Sprint(SnapshotValues(states)) != fmt.Sprint(initial) {
+					break
+				}
+			}
+
+			Convey(fmt.Sprintf("Then %s applies no updates while held", kind), func() {
+				var before, after [][][][]float64
+				gate.Hold(func() {
+					before = SnapshotValues(states)
+					time.Sleep(20 * time.Millisecond)
+					after = SnapshotValues(states)
+				})
+				So(after, ShouldResemble, before)
+				So(gate.SnapshotValues(states), ShouldNotResemble, initial)
+			})
+		})
+	}
+
+	Convey("A nil gate holds nothing", t, func() {
+		var gate *Gate
+		held := false
+		gate.Hold(func() { held = true })
+		So(held, ShouldBeTrue)
+	})
+}
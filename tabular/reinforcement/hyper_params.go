@@ -0,0 +1,147 @@
+package reinforcement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"tabular/atomic_float"
+)
+
+// ErrInvalidHyperParam indicates an unknown hyper-parameter or an out-of-range value.
+var ErrInvalidHyperParam = errors.New("invalid hyper-parameter")
+
+// HyperParams are the hyper-parameters which may be changed during training. The policies
+// and estimators read these on every use, so changes take effect without restarting.
+// Schedules, such as epsilon decay, apply to the current values.
+type HyperParams struct {
+	// Epsilon: the agent exploration/exploitation policy param, in [0,1].
+	Epsilon *atomic_float.AtomicFloat64
+	// Eta: the learning rate, in (0,1].
+	Eta *atomic_float.AtomicFloat64
+	// Gamma: the look-ahead parameter, or how much to value future state values, in [0,1];
+	// in [0,1) for bootstrapping algorithms.
+	Gamma *atomic_float.AtomicFloat64
+	// Whether the config's algorithm bootstraps, which restricts gamma; see isBootstrapping.
+	bootstrapping bool
+}
+
+// NewHyperParams returns the initial hyper-parameters per the passed config.
+func NewHyperParams(config *TrainingConfig) *HyperParams {
+	return &HyperParams{
+		Epsilon: atomic_float.NewAtomicFloat64(config.GetHyperParamOrDefault("epsilon", 0.1)),
+		Eta:     atomic_float.NewAtomicFloat64(config.GetHyperParamOrDefault("eta", 0.01)),
+		Gamma:   atomic_float.NewAtomicFloat64(config.GetHyperParamOrDefault("gamma", 0.9)),
+
+		bootstrapping: config.Algorithm.isBootstrapping(),
+	}
+}
+
+// WithHyperParams trains using the passed hyper-parameters, such that the caller may
+// change them during training. By default they are taken from the config.
+func WithHyperParams(hyperParams *HyperParams) TrainOption {
+	return func(opts *trainOptions) {
+		opts.hyperParams = hyperParams
+	}
+}
+
+// SetParam validates and sets the passed hyper-parameter, by key. An error is
+// returned for unknown keys and out-of-range values, in which case nothing is set.
+// As in ValidateHyperParams, gamma must be < 1 if the algorithm is bootstrapping.
+func (hp *HyperParams) SetParam(key string, val float64) error {
+	if math.IsNaN(val) {
+		return fmt.Errorf("%w: %s=%v", ErrInvalidHyperParam, key, val)
+	}
+
+	var param *atomic_float.AtomicFloat64
+	var valid bool
+	switch key {
+	case "epsilon":
+		param, valid = hp.Epsilon, val >= 0 && val <= 1
+	case "eta":
+		param, valid = hp.Eta, val > 0 && val <= 1
+	case "gamma":
+		if hp.bootstrapping && val >= 1 {
+			return fmt.Errorf("%w: gamma=%v must be < 1 for bootstrapping algorithms", ErrInvalidHyperParam, val)
+		}
+		param, valid = hp.Gamma, val >= 0 && val <= 1
+	default:
+		return fmt.Errorf("%w: unknown key %q", ErrInvalidHyperParam, key)
+	}
+	if !valid {
+		return fmt.Errorf("%w: %s=%v out of range", ErrInvalidHyperParam, key, val)
+	}
+
+	param.Store(val)
+	return nil
+}
+
+// hyperParamRanges are the known hyper-parameters of the TrainingConfig, mapped to whether a value is
+// within range. The params that are changeable during training have the same ranges as in SetParam.
+var hyperParamRanges = map[string]func(val float64) bool{
+	"epsilon":         unitInterval,
+	"epsilonDecay":    func(val float64) bool { return val > 0 && val <= 1 },
+	"epsilonMin":      unitInterval,
+	"warmupEpisodes":  nonNegativeInt,
+	"eta":             func(val float64) bool { return val > 0 && val <= 1 },
+	"etaDecay":        nonNegative,
+	"gamma":           unitInterval,
+	"lambda":          unitInterval,
+	"nstep":           nonNegativeInt,
+	"maxEpisodeLen":   positiveInt,
+	"seed":            isInt,
+	"collisionMode":   func(val float64) bool { return val == collisionModeBox || val == collisionModeSupercover },
+	"collisionReward": isFinite,
+	"stepReward":      isFinite,
+	"sandReward":      isFinite,
+	"finishReward":    isFinite,
+	"finishReward1":   isFinite,
+	"finishReward2":   isFinite,
+	"finishReward3":   isFinite,
+	"maxVelocity":     positiveInt,
+	"maxAcceleration": positiveInt,
+	"wrap":            boolean,
+	"noise":           unitInterval,
+	"initValue":       isFinite,
+	"oracleFraction":  unitInterval,
+	"oracleEpisodes":  nonNegativeInt,
+	"sweepThreshold":  nonNegative,
+	"sweepUpdates":    nonNegativeInt,
+}
+
+func isFinite(val float64) bool       { return !math.IsNaN(val) && !math.IsInf(val, 0) }
+func isInt(val float64) bool          { return isFinite(val) && val == math.Trunc(val) }
+func unitInterval(val float64) bool   { return val >= 0 && val <= 1 }
+func nonNegative(val float64) bool    { return isFinite(val) && val >= 0 }
+func nonNegativeInt(val float64) bool { return isInt(val) && val >= 0 }
+func positiveInt(val float64) bool    { return isInt(val) && val > 0 }
+func boolean(val float64) bool        { return val == 0 || val == 1 }
+
+// ValidateHyperParams returns an error listing every hyper-parameter of the passed config whose key
+// is unknown, repeated, or whose value is out of range, or nil if all are valid. Otherwise such
+// params would silently have no effect, since GetHyperParamOrDefault falls back to the defaults.
+// Undiscounted returns, gamma=1, are only valid for alpha-monte-carlo; see isBootstrapping.
+func ValidateHyperParams(cfg *TrainingConfig) error {
+	var problems []string
+	seen := map[string]bool{}
+	for _, param := range cfg.HyperParams {
+		inRange, known := hyperParamRanges[param.Key]
+		switch {
+		case !known:
+			problems = append(problems, fmt.Sprintf("unknown key %q", param.Key))
+		case seen[param.Key]:
+			problems = append(problems, fmt.Sprintf("repeated key %q", param.Key))
+		case !inRange(param.Val):
+			problems = append(problems, fmt.Sprintf("%s=%v out of range", param.Key, param.Val))
+		case param.Key == "gamma" && param.Val >= 1 && cfg.Algorithm.isBootstrapping():
+			problems = append(problems, fmt.Sprintf("gamma=%v must be < 1 for %s", param.Val, cfg.Algorithm.Kind))
+		}
+		seen[param.Key] = true
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidHyperParam, strings.Join(problems, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package reinforcement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSetParam(t *testing.T) {
+	Convey("Given hyper-parameters from an empty config", t, func() {
+		hp := NewHyperParams(&TrainingConfig{})
+
+		Convey("Valid values are set", func() {
+			So(hp.SetParam("epsilon", 0.2), ShouldBeNil)
+			So(hp.SetParam("eta", 1), ShouldBeNil)
+			So(hp.SetParam("gamma", 0), ShouldBeNil)
+			So(hp.Epsilon.AtomicRead(), ShouldEqual, 0.2)
+			So(hp.Eta.AtomicRead(), ShouldEqual, 1)
+			So(hp.Gamma.AtomicRead(), ShouldEqual, 0)
+		})
+
+		Convey("Invalid keys and values are rejected and not set", func() {
+			for _, tc := range []struct {
+				key string
+				val float64
+			}{
+				{"epsilon", -0.1},
+				{"epsilon", 1.1},
+				{"eta", 0},
+				{"gamma", 2},
+				{"gamma", math.NaN()},
+				{"lambda", 0.5},
+			} {
+				err := hp.SetParam(tc.key, tc.val)
+				So(errors.Is(err, ErrInvalidHyperParam), ShouldBeTrue)
+			}
+			So(hp.Epsilon.AtomicRead(), ShouldEqual, 0.1)
+			So(hp.Eta.AtomicRead(), ShouldEqual, 0.01)
+			So(hp.Gamma.AtomicRead(), ShouldEqual, 0.9)
+		})
+
+		Convey("Gamma may be 1 for alpha-monte-carlo", func() {
+			So(hp.SetParam("gamma", 1), ShouldBeNil)
+			So(hp.Gamma.AtomicRead(), ShouldEqual, 1)
+		})
+	})
+
+	for _, kind := range []string{AlgQLearning, AlgSarsa, AlgTDLambda} {
+		Convey(fmt.Sprintf("Given hyper-parameters of %s", kind), t, func() {
+			hp := NewHyperParams(&TrainingConfig{Algorithm: AlgorithmConfig{Kind: kind}})
+
+			Convey("Then gamma below 1 is set, but gamma of 1 is rejected and not set", func() {
+				So(hp.SetParam("gamma", 0.999), ShouldBeNil)
+				err := hp.SetParam("gamma", 1)
+				So(errors.Is(err, ErrInvalidHyperParam), ShouldBeTrue)
+				So(hp.Gamma.AtomicRead(), ShouldEqual, 0.999)
+			})
+		})
+	}
+}
+
+func TestValidateHyperParams(t *testing.T) {
+	Convey("Given known hyper-parameters within range", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "epsilon", Val: 0.1},
+			{Key: "eta", Val: 0.005},
+			{Key: "gamma", Val: 1},
+			{Key: "nstep", Val: 3},
+			{Key: "stepReward", Val: -2},
+		}}
+
+		Convey("Then they are valid", func() {
+			So(ValidateHyperParams(config), ShouldBeNil)
+		})
+	})
+
+	Convey("Given no hyper-parameters", t, func() {
+		Convey("Then the defaults are valid", func() {
+			So(ValidateHyperParams(&TrainingConfig{}), ShouldBeNil)
+		})
+	})
+
+	Convey("Given unknown keys", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "epsilonn", Val: 0.3},
+			{Key: "gamma", Val: 0.9},
+			{Key: "Eta", Val: 0.01},
+		}}
+
+		Convey("Then the error lists each of them", func() {
+			err := ValidateHyperParams(config)
+			So(errors.Is(err, ErrInvalidHyperParam), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, `"epsilonn"`)
+			So(err.Error(), ShouldContainSubstring, `"Eta"`)
+			So(err.Error(), ShouldNotContainSubstring, "gamma")
+		})
+	})
+
+	Convey("Given out of range values", t, func() {
+		for _, param := range []HyperParameter{
+			{Key: "epsilon", Val: 1.5},
+			{Key: "epsilon", Val: -0.1},
+			{Key: "gamma", Val: 1.01},
+			{Key: "eta", Val: 0},
+			{Key: "eta", Val: math.NaN()},
+			{Key: "nstep", Val: 2.5},
+			{Key: "maxVelocity", Val: 0},
+			{Key: "collisionMode", Val: 2},
+			{Key: "finishReward", Val: math.Inf(1)},
+		} {
+			Convey(fmt.Sprintf("Then %s=%v is invalid", param.Key, param.Val), func() {
+				err := ValidateHyperParams(&TrainingConfig{HyperParams: []HyperParameter{param}})
+				So(errors.Is(err, ErrInvalidHyperParam), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, param.Key)
+			})
+		}
+	})
+
+	Convey("Given a repeated key", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "eta", Val: 0.1},
+			{Key: "eta", Val: 0.2},
+		}}
+
+		Convey("Then it is invalid, since only the first would apply", func() {
+			So(errors.Is(ValidateHyperParams(config), ErrInvalidHyperParam), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given gamma at the boundary of discounting", t, func() {
+		for _, tc := range []struct {
+			kind  string
+			gamma float64
+			valid bool
+		}{
+			{kind: "", gamma: 1, valid: true},
+			{kind: AlgAlphaMonteCarlo, gamma: 1, valid: true},
+			{kind: AlgQLearning, gamma: 0.999, valid: true},
+			{kind: AlgQLearning, gamma: 1, valid: false},
+			{kind: AlgSarsa, gamma: 1, valid: false},
+			{kind: AlgTDLambda, gamma: 1, valid: false},
+		} {
+			config := &TrainingConfig{
+				Algorithm:   AlgorithmConfig{Kind: tc.kind},
+				HyperParams: []HyperParameter{{Key: "gamma", Val: tc.gamma}},
+			}
+
+			Convey(fmt.Sprintf("Then gamma=%v is valid for %q: %v", tc.gamma, tc.kind, tc.valid), func() {
+				err := ValidateHyperParams(config)
+				if tc.valid {
+					So(err, ShouldBeNil)
+				} else {
+					So(errors.Is(err, ErrInvalidHyperParam), ShouldBeTrue)
+					So(err.Error(), ShouldContainSubstring, "gamma=1 must be < 1 for "+tc.kind)
+				}
+			})
+		}
+	})
+
+	Convey("Given the hyper-parameters documented in config.yaml", t, func() {
+		yaml, err := os.ReadFile("../config.yaml")
+		So(err, ShouldBeNil)
+		keys := regexp.MustCompile(`- key: (\w+)`).FindAllStringSubmatch(string(yaml), -1)
+		So(keys, ShouldNotBeEmpty)
+
+		Convey("Then each is known", func() {
+			for _, key := range keys {
+				So(hyperParamRanges, ShouldContainKey, key[1])
+			}
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package reinforcement
+
+import (
+	. "tabular/grid_world"
+)
+
+// move is the kinematic outcome of an action: the agent's next position and velocity.
+type move struct {
+	X, Y, VX, VY int
+}
+
+// proposeMove computes the move resulting from taking the passed action at position (x,y) with
+// velocity (vx,vy), on a grid of the passed dimensions, independent of the state grid and its
+// cell types. The new velocity is bounded per the kinematics, and the new position is clamped
+// to the grid, or wrapped around its edges for wrapping kinematics. Collisions are not checked;
+// see boxCollision. Returns false if both components of the new velocity are zero, which is
+// excluded by the problem definition; the move is nonetheless computed.
+func proposeMove(
+	x, y, vx, vy int,
+	action Action,
+	width, height int,
+	kinematics Kinematics,
+) (m move, ok bool) {
+	m.VX = clamp(vx+action.Dvx, kinematics.MinVelocity(), kinematics.MaxVelocity)
+	m.VY = clamp(vy+action.Dvy, kinematics.MinVelocity(), kinematics.MaxVelocity)
+	if kinematics.Wrap {
+		m.X = wrapIndex(x+m.VX, width)
+		m.Y = wrapIndex(y+m.VY, height)
+	} else {
+		m.X = clamp(x+m.VX, 0, width-1)
+		m.Y = clamp(y+m.VY, 0, height-1)
+	}
+	return m, m.VX != 0 || m.VY != 0
+}
+
+// boxCollision returns the first wall cell in the region spanned by (x,y) and (x+vx,y+vy), per
+// the passed isWall func, on a grid of the passed dimensions; see checkTerminalCollision. Off
+// grid cells are ignored, or wrapped around the grid edges if wrap is set.
+func boxCollision(
+	x, y, vx, vy int,
+	width, height int,
+	wrap bool,
+	isWall func(x, y int) bool,
+) (cx, cy int, collided bool) {
+	// Step from start toward start + (vx,vy) in each direction.
+	step_x, step_y := 1, 1
+	if vx < 0 {
+		step_x = -1
+	}
+	if vy < 0 {
+		step_y = -1
+	}
+
+	for dx := 0; dx*step_x <= vx*step_x; dx += step_x {
+		newx := x + dx
+		if wrap {
+			newx = wrapIndex(newx, width)
+		}
+		// Ignore out of bounds states
+		if newx < 0 || newx >= width {
+			continue
+		}
+		for dy := 0; dy*step_y <= vy*step_y; dy += step_y {
+			newy := y + dy
+			if wrap {
+				newy = wrapIndex(newy, height)
+			}
+			// Ignore out of bounds states
+			if newy < 0 || newy >= height {
+				continue
+			}
+
+			if isWall(newx, newy) {
+				return newx, newy, true
+			}
+		}
+	}
+	return
+}
+
+// wrapIndex returns the passed position index wrapped into [0, n), for toroidal grids.
+func wrapIndex(i, n int) int {
+	return (i%n + n) % n
+}
+
+// clamp returns val bounded to [lo, hi].
+func clamp(val, lo, hi int) int {
+	if val < lo {
+		return lo
+	}
+	if val > hi {
+		return hi
+	}
+	return val
+}
@@ -0,0 +1,88 @@
+package reinforcement
+
+import (
+	"fmt"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProposeMove(t *testing.T) {
+	// A 6 x 8 grid, the dimensions of the debug track.
+	const width, height = 6, 8
+	wrapping := Kinematics{MaxVelocity: MAX_VELOCITY, MaxAcceleration: MAX_ACCELERATION, Wrap: true}
+
+	cases := []struct {
+		name       string
+		x, y       int
+		vx, vy     int
+		action     Action
+		kinematics Kinematics
+		expected   move
+		ok         bool
+	}{
+		{"accelerating within the grid", 1, 1, 1, 1, Action{Dvx: 1, Dvy: 0}, DefaultKinematics, move{3, 2, 2, 1}, true},
+		{"clamped at the right edge", 4, 1, 1, 0, Action{Dvx: 1, Dvy: 0}, DefaultKinematics, move{5, 1, 2, 0}, true},
+		{"clamped at the top edge", 1, 6, 0, 2, Action{Dvx: 0, Dvy: 1}, DefaultKinematics, move{1, 7, 0, 3}, true},
+		{"clamped at the bottom left corner", 0, 0, -1, -1, Action{Dvx: -1, Dvy: -1}, DefaultKinematics, move{0, 0, -2, -2}, true},
+		{"bounded by the max velocity", 0, 0, 4, 0, Action{Dvx: 1, Dvy: 0}, DefaultKinematics, move{4, 0, 4, 0}, true},
+		{"bounded by the min velocity", 5, 7, -4, -4, Action{Dvx: -1, Dvy: -1}, DefaultKinematics, move{1, 3, -4, -4}, true},
+		{"reversing direction", 2, 2, 0, 1, Action{Dvx: -1, Dvy: -1}, DefaultKinematics, move{1, 2, -1, 0}, true},
+		{"rejected for zero velocity", 2, 2, 1, 0, Action{Dvx: -1, Dvy: 0}, DefaultKinematics, move{2, 2, 0, 0}, false},
+		{"wrapped off the right edge", 5, 0, 1, 0, Action{Dvx: 1, Dvy: 0}, wrapping, move{1, 0, 2, 0}, true},
+		{"wrapped off the bottom edge", 0, 1, 0, -2, Action{Dvx: 0, Dvy: -1}, wrapping, move{0, 6, 0, -3}, true},
+	}
+
+	for _, tc := range cases {
+		Convey(fmt.Sprintf("Given a move %s", tc.name), t, func() {
+			m, ok := proposeMove(tc.x, tc.y, tc.vx, tc.vy, tc.action, width, height, tc.kinematics)
+
+			Convey("Then the next position and velocity are as expected", func() {
+				So(m, ShouldResemble, tc.expected)
+				So(ok, ShouldEqual, tc.ok)
+			})
+		})
+	}
+}
+
+func TestBoxCollision(t *testing.T) {
+	// Rows top down, as for tracks; the walls are at (0,2) and (2,1).
+	rows := []string{
+		"Wooo",
+		"ooWo",
+		"oooo",
+	}
+	width, height := len(rows[0]), len(rows)
+	isWall := func(x, y int) bool {
+		return rows[height-y-1][x] == WALL
+	}
+
+	cases := []struct {
+		name     string
+		x, y     int
+		vx, vy   int
+		wrap     bool
+		cx, cy   int
+		collided bool
+	}{
+		{"in the open", 0, 0, 1, 1, false, 0, 0, false},
+		{"spanning a wall", 0, 0, 2, 1, false, 2, 1, true},
+		{"reaching the first wall column by column", 0, 0, 2, 2, false, 0, 2, true},
+		{"spanning a wall backward", 3, 2, -1, -2, false, 2, 1, true},
+		{"off the grid", 3, 0, 2, 0, false, 0, 0, false},
+		{"off the grid, wrapped onto a wall", 3, 2, 1, 0, true, 0, 2, true},
+	}
+
+	for _, tc := range cases {
+		Convey(fmt.Sprintf("Given a move %s", tc.name), t, func() {
+			cx, cy, collided := boxCollision(tc.x, tc.y, tc.vx, tc.vy, width, height, tc.wrap, isWall)
+
+			Convey("Then the collision is as expected", func() {
+				So(collided, ShouldEqual, tc.collided)
+				So([]int{cx, cy}, ShouldResemble, []int{tc.cx, tc.cy})
+			})
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package reinforcement
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// settledGoroutines waits up to the timeout for the number of goroutines to fall to at most
+// the passed baseline, returning the final count. Cancelled routines take a moment to be
+// scheduled and return, hence the count is polled.
+func settledGoroutines(baseline int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// goroutineStacks returns the stacks of all goroutines, for diagnosing leaks.
+func goroutineStacks() string {
+	buf := make([]byte, 1<<20)
+	return string(buf[:runtime.Stack(buf, true)])
+}
+
+func TestTrainingTeardown(t *testing.T) {
+	configs := map[string]*TrainingConfig{
+		AlgAlphaMonteCarlo:  {HyperParams: []HyperParameter{{Key: "oracleFraction", Val: 0.5}}},
+		"prioritized sweep": {Algorithm: AlgorithmConfig{Sweep: sweepPrioritized}},
+		"sharded estimator": {Algorithm: AlgorithmConfig{Estimators: 3}},
+		AlgQLearning:        {Algorithm: AlgorithmConfig{Kind: AlgQLearning}},
+		"qlearning replay":  {Algorithm: AlgorithmConfig{Kind: AlgQLearning}, Replay: ReplayConfig{Capacity: 100}},
+		AlgSarsa:            {Algorithm: AlgorithmConfig{Kind: AlgSarsa}},
+		AlgTDLambda:         {Algorithm: AlgorithmConfig{Kind: AlgTDLambda}},
+		"sequential":        {Algorithm: AlgorithmConfig{Sequential: true}},
+	}
+
+	for name, config := range configs {
+		Convey("Given "+name+" training with every option", t, func() {
+			baseline := runtime.NumGoroutine()
+			states := Convert(DebugTrack, DefaultKinematics)
+			ctx, cancel := context.WithCancel(context.Background())
+			Reset(cancel)
+
+			// Nothing receives the metrics or episodes, so the estimator and agents are blocked
+			// publishing them when cancelled, the likeliest place for them to hang.
+			done := make(chan error, 1)
+			go func() {
+				done <- TrainSync(ctx, states, config, 4,
+					WithMetrics(make(chan Metrics), 1),
+					WithEpisodeUpdates(make(chan *Episode), 1),
+					WithGate(NewGate()))
+			}()
+			time.Sleep(50 * time.Millisecond)
+
+			Convey("When training is cancelled", func() {
+				cancel()
+
+				Convey("Then TrainSync returns, and no training goroutines remain", func() {
+					select {
+					case err := <-done:
+						So(err, ShouldBeNil)
+					case <-time.After(5 * time.Second):
+						So(goroutineStacks(), ShouldBeEmpty)
+					}
+					if n := settledGoroutines(baseline, 2*time.Second); n > baseline {
+						So(goroutineStacks(), ShouldBeEmpty)
+					}
+				})
+			})
+		})
+	}
+
+	Convey("Given a paused trainer", t, func() {
+		baseline := runtime.NumGoroutine()
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		gate := NewGate()
+		gate.Pause()
+		trainer := NewTrainer(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 4,
+			func(context.Context, int) {}, WithGate(gate))
+		So(trainer.Start(), ShouldBeNil)
+
+		Convey("When it is reset, then cancelled", func() {
+			So(trainer.Reset(), ShouldBeNil)
+			cancel()
+			So(waitClosed(trainer.Done(), 5*time.Second), ShouldBeTrue)
+
+			Convey("Then no training goroutines remain from either run", func() {
+				if n := settledGoroutines(baseline, 2*time.Second); n > baseline {
+					So(goroutineStacks(), ShouldBeEmpty)
+				}
+			})
+		})
+	})
+}
@@ -11,17 +11,20 @@ modestly.
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
-	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"tabular/atomic_float"
 	. "tabular/grid_world"
 
+	"github.com/mitchellh/mapstructure"
 	channerics "github.com/niceyeti/channerics/channels"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 /*
@@ -88,10 +91,128 @@ type OuterConfig struct {
 type TrainingConfig struct {
 	// HyperParams is a key-val pair of param names and their value.
 	HyperParams []HyperParameter `mapstructure:"hyperParams"`
-	// Algorithm is an alg selector.
-	Algorithm map[string]string `mapstructure:"algorithm"`
-	// TrainingDeadline is a fixed deadline or duration describing when to terminate training.
-	TrainingDeadline map[string]string `mapstructure:"trainingDeadline"`
+	// Algorithm selects the training algorithm and its algorithm-specific params.
+	Algorithm AlgorithmConfig `mapstructure:"algorithm"`
+	// TrainingDeadline is a fixed deadline and/or duration describing when to terminate training.
+	TrainingDeadline DeadlineConfig `mapstructure:"trainingDeadline"`
+	// Checkpoint optionally describes where and how often to save state values during training.
+	Checkpoint CheckpointConfig `mapstructure:"checkpoint"`
+	// Convergence optionally describes when to stop training because learning has plateaued.
+	Convergence ConvergenceConfig `mapstructure:"convergence"`
+	// StartDistribution optionally selects how agents' start states are distributed: "uniform"
+	// (the default) or "partitioned", such that each agent starts from a disjoint region.
+	StartDistribution string `mapstructure:"startDistribution"`
+	// StartVelocity optionally selects the velocity of agents' start states: "random" (the default),
+	// any non-zero velocity reachable from a START cell, or "zero-only", at rest from START cells only.
+	StartVelocity string `mapstructure:"startVelocity"`
+	// Replay optionally describes an experience replay buffer, used by off-policy algorithms (qlearning).
+	Replay ReplayConfig `mapstructure:"replay"`
+	// Kinematics optionally overrides the maxVelocity and maxAcceleration hyperparameters.
+	Kinematics *Kinematics `mapstructure:"kinematics"`
+	// Exploration optionally selects an exploration preset, ExplorationEpsilonGreedy (the default)
+	// or ExplorationOptimistic, which sets the defaults of the hyper-params it depends on.
+	Exploration string `mapstructure:"exploration"`
+	// RewardPreset optionally selects a named set of rewards, RewardsShaped (the default) or
+	// RewardsSparse, which sets the defaults of the reward hyper-params.
+	RewardPreset string `mapstructure:"rewards"`
+}
+
+// The kinds of training algorithms, per AlgorithmConfig.Kind.
+const (
+	AlgAlphaMonteCarlo = "alpha-monte-carlo"
+	AlgQLearning       = "qlearning"
+	AlgSarsa           = "sarsa"
+	AlgTDLambda        = "tdlambda"
+)
+
+// AlgorithmConfig selects the training algorithm and its algorithm-specific params.
+type AlgorithmConfig struct {
+	// Kind is the algorithm, one of the Alg constants; empty selects alpha-monte-carlo.
+	Kind string `mapstructure:"kind" yaml:"kind"`
+	// Sweep optionally selects prioritized sweeping for alpha-monte-carlo: "prioritized", or "fifo" (the default).
+	// Other algorithms reject "prioritized".
+	Sweep string `mapstructure:"sweep" yaml:"sweep"`
+	// Lambda optionally sets the eligibility trace decay of tdlambda, overriding the lambda hyper-param.
+	Lambda *float64 `mapstructure:"lambda" yaml:"lambda"`
+	// NStep optionally sets the n-step returns of alpha-monte-carlo, overriding the nstep hyper-param.
+	NStep *int `mapstructure:"nStep" yaml:"nStep"`
+	// Sequential optionally trains alpha-monte-carlo with a single agent and no concurrency, for
+	// reproducibility, regardless of the number of workers. Zero workers also selects it.
+	Sequential bool `mapstructure:"sequential" yaml:"sequential"`
+	// Estimators optionally sets the number of alpha-monte-carlo estimators, each of which updates
+	// a disjoint partition of the states; 0 or 1 selects the single estimator. See shardedEstimate.
+	Estimators int `mapstructure:"estimators" yaml:"estimators"`
+	// Actions optionally restricts the actions agents choose among to one of the action sets of
+	// grid_world: "all" (the default), "manhattan", "king", or "diagonal". See Kinematics.Actions.
+	Actions string `mapstructure:"actions" yaml:"actions"`
+}
+
+// validate returns an error if the algorithm or its params are unknown or out of range.
+func (alg *AlgorithmConfig) validate() error {
+	switch alg.Kind {
+	case "", AlgAlphaMonteCarlo, AlgQLearning, AlgSarsa, AlgTDLambda:
+	default:
+		return fmt.Errorf("unknown algorithm kind %q", alg.Kind)
+	}
+	switch alg.Sweep {
+	case "", "fifo", sweepPrioritized:
+	default:
+		return fmt.Errorf("unknown sweep %q", alg.Sweep)
+	}
+	if alg.Lambda != nil && (*alg.Lambda < 0 || *alg.Lambda > 1) {
+		return fmt.Errorf("lambda %v is not within [0,1]", *alg.Lambda)
+	}
+	if alg.NStep != nil && *alg.NStep < 0 {
+		return fmt.Errorf("nStep %d is negative", *alg.NStep)
+	}
+	if alg.Sequential && !alg.isAlphaMonteCarlo() {
+		return fmt.Errorf("sequential training is not supported by %s", alg.Kind)
+	}
+	if alg.Sweep == sweepPrioritized && !alg.isAlphaMonteCarlo() {
+		return fmt.Errorf("prioritized sweeping is not supported by %s", alg.Kind)
+	}
+	if err := ValidateActionSet(alg.Actions); err != nil {
+		return err
+	}
+	if alg.Estimators < 0 {
+		return fmt.Errorf("estimators %d is negative", alg.Estimators)
+	}
+	if alg.Estimators > 1 && (!alg.isAlphaMonteCarlo() || alg.Sequential || alg.Sweep == sweepPrioritized) {
+		return errors.New("multiple estimators are only supported by concurrent alpha-monte-carlo without prioritized sweeping")
+	}
+	return nil
+}
+
+func (alg *AlgorithmConfig) isAlphaMonteCarlo() bool {
+	return alg.Kind == "" || alg.Kind == AlgAlphaMonteCarlo
+}
+
+// isBootstrapping returns whether the algorithm's targets are bootstrapped from the estimates
+// of successor states after every step, rather than from episodes' returns, such that gamma
+// must be < 1 for their estimates to remain stable.
+func (alg *AlgorithmConfig) isBootstrapping() bool {
+	return !alg.isAlphaMonteCarlo()
+}
+
+// isSequential returns whether training with the passed number of workers is sequential.
+func (alg *AlgorithmConfig) isSequential(nworkers int) bool {
+	return alg.isAlphaMonteCarlo() && (alg.Sequential || nworkers == 0)
+}
+
+// checkWorkers returns ErrNoWorkers if training requires more workers than passed: one, unless
+// training sequentially.
+func checkWorkers(config *TrainingConfig, nworkers int) error {
+	if nworkers < 0 || nworkers == 0 && !config.Algorithm.isSequential(nworkers) {
+		return ErrNoWorkers
+	}
+	return nil
+}
+
+// DeadlineConfig describes when to terminate training: a Duration from the start of training,
+// parsed per time.ParseDuration, and/or a hard Deadline as an RFC3339 timestamp.
+type DeadlineConfig struct {
+	Duration string `mapstructure:"duration" yaml:"duration"`
+	Deadline string `mapstructure:"deadline" yaml:"deadline"`
 }
 
 type HyperParameter struct {
@@ -99,29 +220,55 @@ type HyperParameter struct {
 	Val float64 `yaml:"val"`
 }
 
+// GetHyperParamOrDefault returns the value of the passed hyper-param if specified, else its default
+// per the exploration preset, if any, else per the reward preset, if any, else the passed default.
 func (cfg *TrainingConfig) GetHyperParamOrDefault(param string, defaultVal float64) float64 {
 	for _, kvp := range cfg.HyperParams {
 		if kvp.Key == param {
 			return kvp.Val
 		}
 	}
+	if val, ok := cfg.explorationDefault(param); ok {
+		return val
+	}
+	if val, ok := cfg.rewardPresetDefault(param); ok {
+		return val
+	}
 	return defaultVal
 }
 
-// WithTrainingDeadline returns a context extended by the training deadline, if one is specified.
+// WithTrainingDeadline returns a context extended by the training deadline, if one is specified:
+// a "duration" from now, or a hard "deadline" as an RFC3339 timestamp, e.g. for scheduled runs.
+// If both are specified, training stops at whichever occurs first. The returned cancel func
+// must be called to release the context's resources, as with context.WithDeadline.
 func (cfg *TrainingConfig) WithTrainingDeadline(
 	ctx context.Context,
-) (context.Context, error) {
-	if val, ok := cfg.TrainingDeadline["duration"]; ok {
-		if duration, err := time.ParseDuration(val); err != nil {
-			return nil, err
-		} else {
-			innerCtx, _ := context.WithTimeout(ctx, duration)
-			return innerCtx, nil
+) (context.Context, context.CancelFunc, error) {
+	var deadline time.Time
+	if val := cfg.TrainingDeadline.Duration; val != "" {
+		duration, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid training duration %q: %w", val, err)
 		}
+		deadline = time.Now().Add(duration)
+	}
+	if val := cfg.TrainingDeadline.Deadline; val != "" {
+		hardDeadline, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid training deadline %q, expected an RFC3339 timestamp such as %q: %w",
+				val, time.RFC3339, err)
+		}
+		if deadline.IsZero() || hardDeadline.Before(deadline) {
+			deadline = hardDeadline
+		}
+	}
+
+	if deadline.IsZero() {
+		innerCtx, cancel := context.WithCancel(ctx)
+		return innerCtx, cancel, nil
 	}
-	// FUTURE: support a hard-deadline. I don't see the use-case, since duration works just as well.
-	return ctx, nil
+	innerCtx, cancel := context.WithDeadline(ctx, deadline)
+	return innerCtx, cancel, nil
 }
 
 // FUTURE: a lesson learned from viper is that it doesn't seem very friendly toward multiple configs,
@@ -133,49 +280,35 @@ func (cfg *TrainingConfig) WithTrainingDeadline(
 func FromYaml(path string) (*TrainingConfig, error) {
 	// There was no strong reason to use viper, and app config is somewhat fragmented currently, just test driving.
 	vp := viper.New()
-	vp.SetConfigFile(filepath.Base(path))
+	vp.SetConfigFile(path)
 	vp.SetConfigType("yaml")
-	vp.AddConfigPath(filepath.Dir(path))
 	var err error
 	if err = vp.ReadInConfig(); err != nil {
 		return nil, err
 	}
 
-	outerConfig := &OuterConfig{}
-	if err = vp.Unmarshal(outerConfig); err != nil {
+	// Viper lower-cases keys, so the def is decoded directly, whose field names mapstructure matches
+	// case-insensitively. Unknown keys are errors, such that typos are not silently ignored.
+	innerConfig := &TrainingConfig{}
+	if err = vp.UnmarshalKey("def", innerConfig, func(dc *mapstructure.DecoderConfig) {
+		dc.ErrorUnused = true
+	}); err != nil {
 		return nil, err
 	}
-
-	var spec []byte
-	if spec, err = yaml.Marshal(outerConfig.Def); err != nil {
+	if err = innerConfig.Algorithm.validate(); err != nil {
 		return nil, err
 	}
-
-	innerConfig := &TrainingConfig{}
-	if err = yaml.Unmarshal(spec, innerConfig); err != nil {
+	if err = innerConfig.validateExploration(); err != nil {
 		return nil, err
 	}
-
-	return innerConfig, nil
-}
-
-// For MC random starts, grab a random state that is on the track (i.e. is actionable to the agent).
-func getRandomStartState(states [][][][]State) (start_state *State) {
-	max_x := len(states)
-	max_y := len(states[0])
-
-	start_state = &states[rand.Int()%max_x][rand.Int()%max_y][0][0]
-	for !(start_state.CellType == TRACK || start_state.CellType == START) {
-		start_state = &states[rand.Int()%max_x][rand.Int()%max_y][0][0]
+	if err = innerConfig.validateRewardPreset(); err != nil {
+		return nil, err
 	}
-	// Select a random non-zero velocity substate from this x/y position
-	rvx, rvy := 0, 0
-	for rvx == 0 && rvy == 0 {
-		rvx = rand.Int() % NUM_VELOCITIES
-		rvy = rand.Int() % NUM_VELOCITIES
+	if err = innerConfig.validateStartVelocity(); err != nil {
+		return nil, err
 	}
-	start_state = &states[start_state.X][start_state.Y][rvx][rvy]
-	return
+
+	return innerConfig, nil
 }
 
 // Gets the successor state given the domain kinematics: current position plus
@@ -189,85 +322,173 @@ func getSuccessor(
 	states [][][][]State,
 	cur_state *State,
 	action *Action,
+	collide collisionFunc,
+	kinematics Kinematics,
 ) (successor *State) {
-	// Get the proposed velocity per this Action, min of 0 and max of 4 per problem definition.
 	// Though it is a little odd that the state-encoding does not encompass the action, this is
 	// normal for MC, for which only state value estimates are of concern, not Q(s,a) values.
 	// Logically, however, the consequence of the action *is* stored in the next state's encoding.
-	new_vx := int(math.Max(math.Min(float64(cur_state.VX+action.Dvx), MAX_VELOCITY), MIN_VELOCITY))
-	new_vy := int(math.Max(math.Min(float64(cur_state.VY+action.Dvy), MAX_VELOCITY), MIN_VELOCITY))
-	// Get new x/y position, bounded by the grid.
-	max_x := float64(len(states) - 1)
-	max_y := float64(len(states[0]) - 1)
-	new_x := int(math.Max(math.Min(float64(cur_state.X+new_vx), max_x), 0))
-	new_y := int(math.Max(math.Min(float64(cur_state.Y+new_vy), max_y), 0))
-
-	successor = &states[new_x][new_y][new_vx][new_vy]
-	if collision := checkTerminalCollision(states, cur_state, new_vx, new_vy); collision != nil {
+	// Get new x/y position, bounded by the grid, or wrapped around its edges, per proposeMove.
+	m, _ := proposeMove(cur_state.X, cur_state.Y, cur_state.VX, cur_state.VY, *action, len(states), len(states[0]), kinematics)
+
+	successor = &states[m.X][m.Y][VelIndex(states, m.VX)][VelIndex(states, m.VY)]
+	if collision := collide(states, cur_state, m.VX, m.VY); collision != nil {
 		successor = collision
 	}
 
 	return
 }
 
+// getInitValue returns the initValue hyper-param, the initial value of every state, and of every
+// action value of qlearning and sarsa. It defaults to that of the exploration preset, if any, else
+// to the collision reward, the lowest reward, which is pessimistic: unvisited states look no
+// better than a crash, so the agents mostly follow the values they have learned. Values above the
+// rewards an agent can attain are optimistic: every state looks better than it is until visited,
+// and corrected downward, which drives exploration even by a greedy policy.
+func getInitValue(config *TrainingConfig) float64 {
+	return config.GetHyperParamOrDefault("initValue", config.GetRewards().Collision)
+}
+
+// getNoise returns the noise hyper-param, the probability that the environment ignores an agent's
+// action; see withSlip.
+func getNoise(config *TrainingConfig) float64 {
+	return config.GetHyperParamOrDefault("noise", 0)
+}
+
+// withSlip returns the passed policy in a stochastic environment, per the racetrack of Sutton and
+// Barto: with probability noise the velocity increments of the chosen action are forced to zero,
+// such that the successor is that of the zero action. The chosen action is returned regardless,
+// since it is what the agent did, e.g. for the action values updated from it. Zero noise returns
+// the policy unchanged, such that training remains deterministic given a seed.
+func withSlip(
+	states [][][][]State,
+	policy func(*State, *rand.Rand) (*State, *Action),
+	noise float64,
+	collide collisionFunc,
+	kinematics Kinematics,
+) func(*State, *rand.Rand) (*State, *Action) {
+	if noise == 0 {
+		return policy
+	}
+
+	slip := &Action{}
+	return func(state *State, rng *rand.Rand) (*State, *Action) {
+		target, action := policy(state, rng)
+		if rng.Float64() < noise {
+			target = getSuccessor(states, state, slip, collide, kinematics)
+		}
+		return target, action
+	}
+}
+
+// getNewVelocity returns the proposed velocity per this Action, bounded by the velocity bounds of the kinematics.
+func getNewVelocity(cur_state *State, action *Action, kinematics Kinematics) (new_vx, new_vy int) {
+	new_vx = clamp(cur_state.VX+action.Dvx, kinematics.MinVelocity(), kinematics.MaxVelocity)
+	new_vy = clamp(cur_state.VY+action.Dvy, kinematics.MinVelocity(), kinematics.MaxVelocity)
+	return
+}
+
 // The collision checking algorithm is a discrete simulation of what would kinematically
 // be some curving path based on the start position and velocity components. This returns
 // the first terminal state encountered if starting from the passed state and proceeding
 // for one time step with velocity components vx and vy. This is done by checking if the
 // region spanned by start and start + (vx,vy) contains any wall cells, a hyper-conservative
-// metric for collisions. Off grid actions are not accounted for.
+// metric for collisions. Velocities may be negative, in which case the region spans backward
+// from start. Off grid actions are not accounted for. See checkSupercoverCollision for a more
+// precise alternative, selected via the collisionMode hyperparameter.
 // Returns: the first state with which the agent would collide; nil, if no collision.
 func checkTerminalCollision(states [][][][]State, start *State, vx, vy int) (state *State) {
-	max_x := len(states) - 1
-	max_y := len(states[0]) - 1
+	return checkBoxCollision(states, start, vx, vy, false)
+}
 
-	for dx := 0; dx <= vx; dx++ {
-		newx := start.X + dx
-		// Ignore out of bounds states
-		if newx > max_x {
-			continue
-		}
-		for dy := 0; dy <= vy; dy++ {
-			newy := start.Y + dy
-			// Ignore out of bounds states
-			if newy > max_y {
-				continue
-			}
+// checkWrappedTerminalCollision is checkTerminalCollision for wrapping kinematics, whose
+// traversal wraps around the grid edges rather than ignoring off grid cells.
+func checkWrappedTerminalCollision(states [][][][]State, start *State, vx, vy int) (state *State) {
+	return checkBoxCollision(states, start, vx, vy, true)
+}
 
-			traversed := &states[newx][newy][vx][vy]
-			if traversed.CellType == WALL {
-				state = traversed
-				return
-			}
-		}
+// checkBoxCollision implements checkTerminalCollision per boxCollision, wrapping off grid cells if wrap is set.
+func checkBoxCollision(states [][][][]State, start *State, vx, vy int, wrap bool) (state *State) {
+	isWall := func(x, y int) bool {
+		return states[x][y][0][0].CellType == WALL
+	}
+	if x, y, collided := boxCollision(start.X, start.Y, vx, vy, len(states), len(states[0]), wrap, isWall); collided {
+		state = &states[x][y][VelIndex(states, vx)][VelIndex(states, vy)]
 	}
 	return
 }
 
-// Get a random velocity change (dv) in (-1,0,+1) (per problem def.).
-func getRandDv() int {
-	return rand.Int()%3 - 1
+// getRandAction returns a uniformly random action of the kinematics' action set.
+func getRandAction(cur_state *State, rng *rand.Rand, kinematics Kinematics) (action *Action) {
+	actions := kinematics.Actions()
+	// By problem def velocity components cannot both be zero, so the effect of this action must be checked.
+	for {
+		action = &actions[rng.Intn(len(actions))]
+		if vx, vy := getNewVelocity(cur_state, action, kinematics); vx != 0 || vy != 0 {
+			return
+		}
+	}
+}
+
+// Rewards are the rewards for stepping into each kind of cell. These default to the
+// grid_world reward constants, but may be set via hyperparameters to study how
+// reward shaping affects the learned policy.
+type Rewards struct {
+	Collision float64
+	Step      float64
+	Sand      float64
+	Finish    float64
+	// FinishTiers are the rewards of the tiered finish cells, keyed by cell type.
+	FinishTiers map[rune]float64
 }
 
-func getRandAction(cur_state *State) (action *Action) {
-	action = &Action{
-		Dvx: getRandDv(),
-		Dvy: getRandDv(),
+// GetRewards returns the rewards per the collisionReward, stepReward, sandReward, and finishReward
+// hyperparameters, defaulting to those of the reward preset, if any, else to the reward constants. The rewards of the tiered finish cells are
+// per the finishReward1, finishReward2, and finishReward3 hyperparameters, defaulting to the finish reward.
+func (cfg *TrainingConfig) GetRewards() *Rewards {
+	finish := cfg.GetHyperParamOrDefault("finishReward", FINISH_REWARD)
+	return &Rewards{
+		Collision: cfg.GetHyperParamOrDefault("collisionReward", COLLISION_REWARD),
+		Step:      cfg.GetHyperParamOrDefault("stepReward", STEP_REWARD),
+		Sand:      cfg.GetHyperParamOrDefault("sandReward", SAND_REWARD),
+		Finish:    finish,
+		FinishTiers: map[rune]float64{
+			FINISH_TIER_1: cfg.GetHyperParamOrDefault("finishReward1", finish),
+			FINISH_TIER_2: cfg.GetHyperParamOrDefault("finishReward2", finish),
+			FINISH_TIER_3: cfg.GetHyperParamOrDefault("finishReward3", finish),
+		},
 	}
-	// By problem def velocity components cannot both be zero, so the effect of this action must be checked.
-	for cur_state.VX+action.Dvx == 0 && cur_state.VY+action.Dvy == 0 {
-		action.Dvx = getRandDv()
-		action.Dvy = getRandDv()
+}
+
+// GetKinematics returns the velocity and acceleration bounds per the kinematics config, if any,
+// otherwise per the maxVelocity, maxAcceleration, and wrap hyperparameters, defaulting to
+// DefaultKinematics. The state grid passed to Train must be converted with the same kinematics.
+func (cfg *TrainingConfig) GetKinematics() (kinematics Kinematics) {
+	if cfg.Kinematics != nil {
+		kinematics = *cfg.Kinematics
+	} else {
+		kinematics = Kinematics{
+			MaxVelocity:     int(cfg.GetHyperParamOrDefault("maxVelocity", float64(DefaultKinematics.MaxVelocity))),
+			MaxAcceleration: int(cfg.GetHyperParamOrDefault("maxAcceleration", float64(DefaultKinematics.MaxAcceleration))),
+			Wrap:            cfg.GetHyperParamOrDefault("wrap", 0) != 0,
+		}
 	}
+	kinematics.ActionSet = cfg.Algorithm.Actions
 	return
 }
 
-func getReward(target *State) (reward float64) {
+func getReward(target *State, rewards *Rewards) (reward float64) {
 	switch target.CellType {
 	case WALL:
-		reward = COLLISION_REWARD
-	case START, TRACK, FINISH:
-		reward = STEP_REWARD
+		reward = rewards.Collision
+	case START, TRACK:
+		reward = rewards.Step
+	case SAND:
+		reward = rewards.Sand
+	case FINISH:
+		reward = rewards.Finish
+	case FINISH_TIER_1, FINISH_TIER_2, FINISH_TIER_3:
+		reward = rewards.FinishTiers[target.CellType]
 	default:
 		// Degenerate case; unreachable code if all actions are covered in switch.
 		panic("Shazbot!")
@@ -276,7 +497,24 @@ func getReward(target *State) (reward float64) {
 }
 
 func is_terminal(state *State) bool {
-	return state.CellType == WALL || state.CellType == FINISH
+	return state.CellType == WALL || IsFinish(state.CellType)
+}
+
+// DefaultMaxEpisodeLen is the default of the maxEpisodeLen hyper-param, the number of steps after
+// which episodes are truncated. It is generous, only intended to prevent pathological stalls.
+const DefaultMaxEpisodeLen = 100000
+
+func getMaxEpisodeLen(config *TrainingConfig) int {
+	return int(config.GetHyperParamOrDefault("maxEpisodeLen", DefaultMaxEpisodeLen))
+}
+
+// setTerminalValue sets the terminal state of the passed episode to the value of the reward for
+// stepping into it, unless the episode was truncated, whose last successor is not terminal.
+func setTerminalValue(episode *Episode) {
+	last_step := (*episode)[len(*episode)-1]
+	if !last_step.Truncated {
+		last_step.Successor.Value.AtomicSet(last_step.Reward)
+	}
 }
 
 // For a fixed grid position, print all of its velocity subvalues.
@@ -297,53 +535,311 @@ func print_substates(states [][][][]State, x, y int) {
 // state presumably being a low-valued collision state (a wall). But it just needs to remembered
 // that the agent's max value search must account for the environment, else its policy might converge
 // to something invalid due to invalid values, by evaluating bad states as good.
-func get_max_successor(states [][][][]State, cur_state *State) (target *State, action *Action) {
+// The first candidate is taken regardless of its value, such that a successor is found even if
+// every value is -Inf or NaN, e.g. upon divergence. If every action would yield zero velocity,
+// which no action set permits, the current state is returned with the no-op action, so the
+// returned state and action are never nil.
+func get_max_successor(
+	states [][][][]State,
+	cur_state *State,
+	collide collisionFunc,
+	kinematics Kinematics,
+) (target *State, action *Action) {
 	maxVal := -math.MaxFloat64
-	for dvx := -1; dvx < 2; dvx++ {
-		for dvy := -1; dvy < 2; dvy++ {
-			// Get the successor state and its value; trad MC does not store Q values for lookup, so hard-coded rules are used (e.g. for collision, etc.)
-			candidate_action := &Action{Dvx: dvx, Dvy: dvy}
-			successor := getSuccessor(states, cur_state, candidate_action)
-			// By problem def, velocity components cannot both be zero.
-			if successor.VX == 0 && successor.VY == 0 {
-				continue
-			}
+	actions := kinematics.Actions()
+	for i := range actions {
+		// Get the successor state and its value; trad MC does not store Q values for lookup, so hard-coded rules are used (e.g. for collision, etc.)
+		candidate_action := &actions[i]
+		successor := getSuccessor(states, cur_state, candidate_action, collide, kinematics)
+		// By problem def, velocity components cannot both be zero.
+		if successor.VX == 0 && successor.VY == 0 {
+			continue
+		}
 
-			val := successor.Value.AtomicRead()
-			if val > maxVal {
-				maxVal = val
-				target = successor
-				action = candidate_action
-			}
+		val := successor.Value.AtomicRead()
+		if target == nil || val > maxVal {
+			maxVal = val
+			target = successor
+			action = candidate_action
 		}
 	}
+	if target == nil {
+		return cur_state, &Action{}
+	}
 	return
 }
 
 // Train is async and initializes states and policies and begins training.
+// Options may be passed for optional behavior, such as publishing Metrics.
+// Zero workers selects sequential alpha-monte-carlo training; see AlgorithmConfig.Sequential.
+// An error is returned, and training is not begun, if the state grid was not built per the
+// configured kinematics.
 func Train(
 	ctx context.Context,
 	states [][][][]State,
 	config *TrainingConfig,
 	nworkers int,
-	progressFn ProgressFunc) {
-	// initialize the state values to something slightly larger than the lowest reward, for stability
-	initStateVals(states, COLLISION_REWARD)
+	progressFn ProgressFunc,
+	opts ...TrainOption) error {
+	if err := checkKinematics(states, config); err != nil {
+		return err
+	}
+	options := &trainOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.hyperParams == nil {
+		options.hyperParams = NewHyperParams(config)
+	}
+	if options.routines == nil {
+		options.routines = &sync.WaitGroup{}
+	}
+	sequential := config.Algorithm.isSequential(nworkers)
+	if sequential {
+		nworkers = 1
+	}
+	options.genInitStates = newStartStateFuncs(states, config, nworkers)
+	if options.stream != nil {
+		options.samplers = append(options.samplers, options.stream.sampler(ctx.Done()))
+	}
+
+	// Resume from the last checkpoint, if any; otherwise initialize the state values per the
+	// initValue hyper-param.
+	if options.fresh || !loadCheckpoint(states, config.Checkpoint) {
+		initStateVals(states, getInitValue(config))
+	}
+	// Visit counts describe the exploration of the current run, so are never resumed.
+	ResetVisits(states)
+	progressFn = withCheckpoints(states, config.Checkpoint, progressFn)
 	// display startup policy
 	ShowPolicy(states)
 	// show max values
 	ShowMaxValues(states)
 	ShowGrid(states)
-	alphaMonteCarloVanillaTrain(
-		ctx,
-		states,
-		nworkers,
-		config,
-		progressFn)
+
+	observer := episodeObservers{}
+	if monitor := getConvergenceMonitor(ctx); monitor != nil {
+		monitor.bind(states, config)
+		observer = append(observer, monitor)
+	}
+	if budget := getEpisodeBudget(ctx); budget != nil {
+		observer = append(observer, budget)
+	}
+	if options.metrics != nil {
+		generated := newEpisodeSampler(nil, 1)
+		options.samplers = append(options.samplers, generated)
+		recorder := newMetricsRecorder(ctx, options.metrics, options.metricsInterval, states, config, options.hyperParams, generated, options.reference)
+		observer = append(observer, recorder.evaluateAsync(options))
+	}
+	if options.logger != nil {
+		observer = append(observer, newTrainingLogRecorder(options.logger, options.logInterval, states))
+	}
+
+	switch config.Algorithm.Kind {
+	case AlgQLearning:
+		qLearningTrain(
+			ctx,
+			states,
+			nworkers,
+			config,
+			progressFn,
+			observer,
+			options)
+	case AlgSarsa:
+		sarsaTrain(
+			ctx,
+			states,
+			nworkers,
+			config,
+			progressFn,
+			observer,
+			options)
+	case AlgTDLambda:
+		tdLambdaTrain(
+			ctx,
+			states,
+			nworkers,
+			config,
+			progressFn,
+			observer,
+			options)
+	default:
+		if sequential {
+			alphaMonteCarloSequential(
+				ctx,
+				states,
+				config,
+				progressFn,
+				observer,
+				options)
+			return nil
+		}
+		alphaMonteCarloVanillaTrain(
+			ctx,
+			states,
+			nworkers,
+			config,
+			progressFn,
+			observer,
+			options)
+	}
+	return nil
+}
+
+// checkKinematics returns an error if the state grid was not built per the configured kinematics.
+func checkKinematics(states [][][][]State, config *TrainingConfig) error {
+	if kinematics := config.GetKinematics(); kinematics.NumVelocities() != len(states[0][0]) {
+		return fmt.Errorf("%w: state grid has %d velocities per component, but the kinematics specify %d",
+			ErrInvalidKinematics, len(states[0][0]), kinematics.NumVelocities())
+	}
+	return nil
 }
 
 func initStateVals(states [][][][]State, val float64) {
-	Visit(states, func(s *State) { s.Value.AtomicSet(val) })
+	ResetValues(states, val)
+}
+
+// agentWorker deploys an agent that generates episodes using the passed policy until done is closed.
+// Each episode begins in the state returned by genInitState and ends upon entering a terminal state,
+// or is truncated after maxSteps steps; see generateEpisode.
+// The agent's random choices are drawn from the passed rng, which must not be shared with other agents.
+// Episodes are offered to the passed samplers for publication. The agent is tracked by the passed routines.
+func agentWorker(
+	done <-chan struct{},
+	routines *sync.WaitGroup,
+	rng *rand.Rand,
+	genInitState func(*rand.Rand) *State,
+	policyFn func(*State, *rand.Rand) (*State, *Action),
+	rewards *Rewards,
+	maxSteps int,
+	samplers episodeSamplers) <-chan *Episode {
+
+	episodes := make(chan *Episode)
+	routines.Add(1)
+	go func() {
+		defer routines.Done()
+		defer close(episodes)
+
+		// Generate and send episodes until cancellation.
+		for {
+			// done-guard
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			episode := generateEpisode(rng, genInitState, policyFn, rewards, maxSteps)
+			samplers.sample(episode)
+
+			select {
+			case episodes <- episode:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return episodes
+}
+
+// generateEpisode runs a single episode per the passed policy, from the state returned by
+// genInitState until entering a terminal state. An agent whose policy is poor may wander for
+// very long without doing so, so the episode is truncated after maxSteps steps, and its last
+// step is marked as such. Each state in which the agent acts is counted as a visit to its position.
+func generateEpisode(
+	rng *rand.Rand,
+	genInitState func(*rand.Rand) *State,
+	policyFn func(*State, *rand.Rand) (*State, *Action),
+	rewards *Rewards,
+	maxSteps int) *Episode {
+
+	episode := Episode{}
+	state := genInitState(rng)
+	for !is_terminal(state) {
+		if len(episode) == maxSteps {
+			episode[len(episode)-1].Truncated = true
+			break
+		}
+		state.RecordVisit()
+		successor, action := policyFn(state, rng)
+		reward := getReward(successor, rewards)
+		episode = append(
+			episode,
+			Step{
+				State:     state,
+				Action:    action,
+				Reward:    reward,
+				Successor: successor,
+			})
+		state = successor
+	}
+	return &episode
+}
+
+// newPolicyAlphaMax returns an epsilon-greedy policy over the state values: with probability
+// epsilon() a random action is taken, otherwise the action leading to the max-valued successor.
+// Random choices are drawn from the rng passed by the calling agent.
+func newPolicyAlphaMax(
+	states [][][][]State,
+	epsilon func() float64,
+	collide collisionFunc,
+	kinematics Kinematics,
+) func(*State, *rand.Rand) (*State, *Action) {
+	return func(state *State, rng *rand.Rand) (target *State, action *Action) {
+		r := rng.Float64()
+		if r <= epsilon() {
+			// Exploration: do something random
+			action = getRandAction(state, rng, kinematics)
+			target = getSuccessor(states, state, action, collide, kinematics)
+		} else {
+			// Exploitation: search for max-valued state per available actions.
+			target, action = get_max_successor(states, state, collide, kinematics)
+		}
+		return target, action
+	}
+}
+
+// nStepReturns returns the target of each step of the episode: the gamma-discounted sum of the
+// next n rewards plus gamma^n times the value of the state n steps ahead, per the n-step return
+//
+//	G(t) = r(t) + gamma*r(t+1) + ... + gamma^(n-1)*r(t+n-1) + gamma^n*V(s(t+n))
+//
+// Steps within n of the end of the episode receive the discounted return to the terminal state,
+// whose value is excluded. If n is 0 or at least the episode length, every target is the full
+// discounted Monte Carlo return, G(t) = r(t) + gamma*G(t+1). Truncated episodes did not end in a
+// terminal state, so their returns instead bootstrap from the value of the last successor.
+func nStepReturns(episode *Episode, gamma float64, n int) (returns []float64) {
+	length := len(*episode)
+	returns = make([]float64, length)
+	// The value of the state following the episode: 0 if terminal.
+	final := 0.0
+	if last := (*episode)[length-1]; last.Truncated {
+		final = last.Successor.Value.AtomicRead()
+	}
+	if n <= 0 || n >= length {
+		ret := final
+		for _, t := range Rev(length) {
+			ret = (*episode)[t].Reward + gamma*ret
+			returns[t] = ret
+		}
+		return
+	}
+
+	for t := range *episode {
+		ret, discount := 0.0, 1.0
+		k := 0
+		for ; k < n && t+k < length; k++ {
+			ret += discount * (*episode)[t+k].Reward
+			discount *= gamma
+		}
+		if t+k < length {
+			ret += discount * (*episode)[t+k].State.Value.AtomicRead()
+		} else {
+			ret += discount * final
+		}
+		returns[t] = ret
+	}
+	return
 }
 
 // ProgressFunc is a callback by which the training method can lend progress details,
@@ -356,126 +852,239 @@ Implements vanilla alpha-MC using a fixed number of workers to generate episodes
 which are sent to the estimator to update the state values. Coordination is simple:
   - agents generate and queue episodes up to some stopping criteria
   - processor halts the agents to empty its episode queue and update state values
+
+If Algorithm.Sweep is "prioritized", the estimator additionally applies prioritized sweeping
+between episodes, propagating each episode's value changes backward to the states leading to them.
+If Algorithm.Estimators is more than one, the updates are instead applied by that many estimators
+over disjoint partitions of the states; see shardedEstimate.
 */
 func alphaMonteCarloVanillaTrain(
 	ctx context.Context,
 	states [][][][]State,
 	nworkers int,
 	config *TrainingConfig,
-	progressFn ProgressFunc) {
-
-	// Epsilon: the agent exploration/exploitation policy param.
-	epsilon := config.GetHyperParamOrDefault("epsilon", 0.1)
-	// Eta: the learning rate
-	eta := config.GetHyperParamOrDefault("eta", 0.01)
-	// Gamma: the look-ahead parameter, or how much to value future state values.
-	gamma := config.GetHyperParamOrDefault("gamma", 0.9)
-
-	// Note: remember to exclude invalid/out-of-bound states and zero-velocity states.
-	rand.Seed(time.Now().Unix())
-	randRestart := func() *State {
-		return getRandomStartState(states)
-	}
-
-	policyAlphaMax := func(state *State) (target *State, action *Action) {
-		r := rand.Float64()
-		if r <= epsilon {
-			// Exploration: do something random
-			action := getRandAction(state)
-			target = getSuccessor(states, state, action)
-		} else {
-			// Exploitation: search for max-valued state per available actions.
-			target, action = get_max_successor(states, state)
-		}
-		return target, action
-	}
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions) {
 
-	// deploy worker agents to generate episodes
-	agent_worker := func(
-		done <-chan struct{},
-		states [][][][]State,
-		genInitState func() *State,
-		policyFn func(*State) (*State, *Action)) <-chan *Episode {
-
-		episodes := make(chan *Episode)
-		go func() {
-			defer close(episodes)
-
-			// Generate and send episodes until cancellation.
-			for {
-				// done-guard
-				select {
-				case <-done:
-					return
-				default:
-				}
-
-				episode := Episode{}
-				state := genInitState()
-				for !is_terminal(state) {
-					successor, action := policyFn(state)
-					reward := getReward(successor)
-					episode = append(
-						episode,
-						Step{
-							State:     state,
-							Action:    action,
-							Reward:    reward,
-							Successor: successor,
-						})
-					state = successor
-				}
-
-				select {
-				case episodes <- &episode:
-				case <-done:
-					return
-				}
-			}
-		}()
-		return episodes
-	}
+	policies, est := newAlphaMonteCarlo(ctx, states, nworkers, config, progressFn, observer, options)
+	rewards := config.GetRewards()
+	maxSteps := getMaxEpisodeLen(config)
 
 	// Fan in the workers to a single channel. This allows the processor to throttle the agents
 	// by not pulling episodes from their chans, which in turn pseudo-serializes matrix read/write.
-	// Note: the serialization is not robust or production worthy sans locking the state matrix.
-	// Chans provide a sufficient coordination mechanism for prototyping, but is not rigorous (e.g.
-	// will fail builds with '-race' flag).
-	// TODO: locking algorithms or strategies for large resource space, where every item in the space
-	// feasibly requires a lock?
+	// Every state value is only accessed via its atomic methods, so agents, views, and the single
+	// estimator never race, per TestConcurrentTraining under -race. Agents may nonetheless act upon
+	// values that the estimator updates mid-episode, which is the usual asynchronous RL tradeoff.
 	workers := []<-chan *Episode{}
-	for i := 0; i < nworkers; i++ {
-		ch := agent_worker(ctx.Done(), states, randRestart, policyAlphaMax)
+	for i, rng := range newWorkerRands(config, nworkers) {
+		ch := agentWorker(ctx.Done(), options.routines, rng, options.genInitStates[i], policies[i], rewards, maxSteps, options.samplers)
 		workers = append(workers, ch)
 	}
-	episodes := channerics.Merge(ctx.Done(), workers...)
+	episodes := gated(ctx.Done(), options.gate, channerics.Merge(ctx.Done(), workers...))
 
 	// Estimator updates state values from agent experiences.
-	estimator := func(
-		eta, gamma float64,
-		progressFn ProgressFunc) {
-		episode_count := 0
+	if estimators := config.Algorithm.Estimators; estimators > 1 {
+		shardedEstimate(ctx.Done(), episodes, est, estimators, options)
+		return
+	}
+	options.spawn(func() {
 		for episode := range episodes {
-			// Set terminal states to the value of the reward for stepping into them.
-			last_step := (*episode)[len(*episode)-1]
-			last_step.Successor.Value.AtomicSet(last_step.Reward)
-			// Propagate rewards backward from terminal state per episode
-			reward := 0.0
-			for _, t := range Rev(len(*episode)) {
-				// NOTE: not tracking states' is-visited status, so for now this is an every-visit MC implementation.
-				step := (*episode)[t]
-				reward += step.Reward
-				val := step.State.Value.AtomicRead()
-				delta := eta * (reward - val)
-				// Note: intentionally discard rejected deltas. There won't be any, since add ops are serialized
-				// as there is a single estimator.
-				_, _ = step.State.Value.AtomicAdd(delta)
+			est.estimate(episode)
+		}
+	})
+}
+
+/*
+Implements alpha-MC without any concurrency, for debugging and reproducibility: a single agent
+generates an episode, the estimator immediately processes it, and so on. There are no channels,
+merge, or agents racing the estimator, hence given a fixed seed the state values are
+bit-reproducible per episode count. The exception is prioritized sweeping, whose queue order
+among predecessors of equal priority is unspecified. Training is nonetheless async, per Train,
+so the loop runs in a single routine.
+*/
+func alphaMonteCarloSequential(
+	ctx context.Context,
+	states [][][][]State,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions) {
+
+	agent := newSequentialAgent(ctx, states, config, progressFn, observer, options)
+	options.spawn(func() {
+		for {
+			options.gate.wait(ctx.Done())
+			if ctx.Err() != nil {
+				return
 			}
+			agent.step()
+		}
+	})
+}
+
+// sequentialAgent is the single agent of sequential alpha-MC, which applies each episode it
+// generates before generating the next; see alphaMonteCarloSequential and Trainer.Step.
+type sequentialAgent struct {
+	policy       func(*State, *rand.Rand) (*State, *Action)
+	est          *mcEstimator
+	rng          *rand.Rand
+	genInitState func(*rand.Rand) *State
+	rewards      *Rewards
+	maxSteps     int
+	samplers     episodeSamplers
+}
+
+func newSequentialAgent(
+	ctx context.Context,
+	states [][][][]State,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions,
+) *sequentialAgent {
+	policies, est := newAlphaMonteCarlo(ctx, states, 1, config, progressFn, observer, options)
+	return &sequentialAgent{
+		policy:       policies[0],
+		est:          est,
+		rng:          newWorkerRands(config, 1)[0],
+		genInitState: options.genInitStates[0],
+		rewards:      config.GetRewards(),
+		maxSteps:     getMaxEpisodeLen(config),
+		samplers:     options.samplers,
+	}
+}
+
+// step generates a single episode and applies its updates to the state values.
+func (agent *sequentialAgent) step() {
+	episode := generateEpisode(agent.rng, agent.genInitState, agent.policy, agent.rewards, agent.maxSteps)
+	agent.samplers.sample(episode)
+	agent.est.estimate(episode)
+}
+
+// newAlphaMonteCarlo returns the policies of the passed number of agents, some of which may
+// initially follow the oracle, and the estimator updating the state values from their episodes,
+// shared by the concurrent and sequential implementations.
+func newAlphaMonteCarlo(
+	ctx context.Context,
+	states [][][][]State,
+	nworkers int,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions,
+) (policies []func(*State, *rand.Rand) (*State, *Action), est *mcEstimator) {
+
+	// Nstep: the number of rewards summed before bootstrapping from a state value; 0 for full MC returns.
+	nstep := int(config.GetHyperParamOrDefault("nstep", 0))
+	if config.Algorithm.NStep != nil {
+		nstep = *config.Algorithm.NStep
+	}
+	collide := getCollisionFunc(config)
+	rewards := config.GetRewards()
+	kinematics := config.GetKinematics()
+	est = &mcEstimator{
+		ctx: ctx,
+		// Eta: the learning rate, optionally decayed per episode count.
+		etaFn: newEtaSchedule(config, options.hyperParams),
+		// Gamma: the look-ahead parameter, or how much to value future state values.
+		gamma:      options.hyperParams.Gamma,
+		nstep:      nstep,
+		sweeper:    newSweeper(states, config, collide, kinematics, rewards),
+		observer:   observer,
+		progressFn: progressFn,
+		gate:       options.gate,
+	}
 
-			// Hook: periodically do some other processing (publishing state values for views, etc.)
-			episode_count++
-			progressFn(ctx, episode_count)
+	// Epsilon: the agent exploration/exploitation policy param, optionally decayed per episode count.
+	epsilonFn := newEpsilonSchedule(config, options.hyperParams)
+	epsilon := func() float64 {
+		return epsilonFn(est.count())
+	}
+	policyAlphaMax := newPolicyAlphaMax(states, epsilon, collide, kinematics)
+	// Optionally, some workers initially follow the oracle to seed the values near the finish.
+	numOracles, oracleEpisodes := getOracleParams(config, nworkers)
+	policyOracle := policyAlphaMax
+	if numOracles > 0 {
+		policyOracle = withOracle(
+			newOraclePolicy(states, epsilon, collide, kinematics),
+			policyAlphaMax,
+			est.count,
+			oracleEpisodes)
+	}
+	policies = make([]func(*State, *rand.Rand) (*State, *Action), nworkers)
+	for i := range policies {
+		policies[i] = policyAlphaMax
+		if i < numOracles {
+			policies[i] = policyOracle
 		}
+		policies[i] = withSlip(states, policies[i], getNoise(config), collide, kinematics)
 	}
-	go estimator(eta, gamma, progressFn)
+	return
+}
+
+// mcEstimator updates the state values from the agents' episodes per alpha-MC, with optional
+// n-step returns and prioritized sweeping. It must only be used by a single routine; see
+// shardedEstimate for the exception.
+type mcEstimator struct {
+	// The number of episodes processed by the estimator, shared with the agents' policies.
+	episodeCount int64
+	ctx          context.Context
+	etaFn        func(int64) float64
+	gamma        *atomic_float.AtomicFloat64
+	nstep        int
+	sweeper      *sweeper
+	observer     episodeObserver
+	progressFn   ProgressFunc
+	// gate holds the estimator's updates for coherent snapshots; see Gate.Hold.
+	gate *Gate
+}
+
+// count returns the number of episodes processed.
+func (est *mcEstimator) count() int64 {
+	return atomic.LoadInt64(&est.episodeCount)
+}
+
+// estimate updates the state values from the passed episode.
+func (est *mcEstimator) estimate(episode *Episode) {
+	est.gate.apply(func() {
+		eta, returns := est.targets(episode)
+		// Propagate rewards backward from terminal state per episode
+		for _, t := range Rev(len(*episode)) {
+			// NOTE: not tracking states' is-visited status, so for now this is an every-visit MC implementation.
+			est.observer.Observe(mcUpdate(&(*episode)[t], eta, returns[t]))
+		}
+		if est.sweeper != nil {
+			est.sweeper.observe(episode, est.gamma.AtomicRead())
+			est.sweeper.sweep(eta, est.gamma.AtomicRead(), est.observer)
+		}
+	})
+	est.endEpisode(episode)
+}
+
+// targets returns the current learning rate and the return of each step of the passed episode,
+// toward which its state value is moved, after setting the value of its terminal state.
+func (est *mcEstimator) targets(episode *Episode) (eta float64, returns []float64) {
+	eta = est.etaFn(est.count())
+	setTerminalValue(episode)
+	returns = nStepReturns(episode, est.gamma.AtomicRead(), est.nstep)
+	return
+}
+
+// endEpisode completes the passed episode once all of its updates are applied.
+func (est *mcEstimator) endEpisode(episode *Episode) {
+	est.observer.EndEpisode(len(*episode))
+
+	// Hook: periodically do some other processing (publishing state values for views, etc.)
+	count := atomic.AddInt64(&est.episodeCount, 1)
+	est.progressFn(est.ctx, int(count))
+}
+
+// mcUpdate moves the value of the step's state toward the passed return, returning the delta.
+func mcUpdate(step *Step, eta, ret float64) (delta float64) {
+	delta = eta * (ret - step.State.Value.AtomicRead())
+	// Note: intentionally discard rejected deltas. There won't be any, since add ops are serialized
+	// as there is a single writer per state.
+	_, _ = step.State.Value.AtomicAdd(delta)
+	return
 }
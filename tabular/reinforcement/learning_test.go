@@ -0,0 +1,786 @@
+package reinforcement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sandTrack is the debug track with a sand patch along the inside of its corner.
+var sandTrack = []string{
+	"WWWWWW",
+	"Woooo+",
+	"WoSSo+",
+	"WoSWWW",
+	"WoSWWW",
+	"WoSWWW",
+	"WooWWW",
+	"W--WWW",
+}
+
+func TestGetReward(t *testing.T) {
+	Convey("When rewards are not configured", t, func() {
+		rewards := (&TrainingConfig{}).GetRewards()
+
+		Convey("Then each cell type yields its reward constant", func() {
+			So(getReward(&State{CellType: WALL}, rewards), ShouldEqual, COLLISION_REWARD)
+			So(getReward(&State{CellType: TRACK}, rewards), ShouldEqual, STEP_REWARD)
+			So(getReward(&State{CellType: START}, rewards), ShouldEqual, STEP_REWARD)
+			So(getReward(&State{CellType: SAND}, rewards), ShouldEqual, SAND_REWARD)
+			So(getReward(&State{CellType: FINISH}, rewards), ShouldEqual, FINISH_REWARD)
+		})
+	})
+
+	Convey("When rewards are configured via hyperparameters", t, func() {
+		rewards := (&TrainingConfig{
+			HyperParams: []HyperParameter{
+				{Key: "collisionReward", Val: -10},
+				{Key: "stepReward", Val: -2},
+				{Key: "sandReward", Val: -4},
+				{Key: "finishReward", Val: 5},
+			},
+		}).GetRewards()
+
+		Convey("Then each cell type yields the configured reward", func() {
+			So(getReward(&State{CellType: WALL}, rewards), ShouldEqual, -10)
+			So(getReward(&State{CellType: TRACK}, rewards), ShouldEqual, -2)
+			So(getReward(&State{CellType: START}, rewards), ShouldEqual, -2)
+			So(getReward(&State{CellType: SAND}, rewards), ShouldEqual, -4)
+			So(getReward(&State{CellType: FINISH}, rewards), ShouldEqual, 5)
+		})
+	})
+
+	Convey("Given a track with a sand patch", t, func() {
+		states := Convert(sandTrack, DefaultKinematics)
+		rewards := (&TrainingConfig{}).GetRewards()
+
+		Convey("Then stepping onto the sand yields the sand penalty, a larger penalty than the track", func() {
+			sand := &states[2][2][0][0]
+			So(sand.CellType, ShouldEqual, SAND)
+			So(is_terminal(sand), ShouldBeFalse)
+			So(getReward(sand, rewards), ShouldEqual, SAND_REWARD)
+			So(getReward(sand, rewards), ShouldBeLessThan, getReward(&states[1][2][0][0], rewards))
+		})
+	})
+
+	Convey("Given a track with finish cells of two tiers", t, func() {
+		states := Convert([]string{"1oo-oooo3"}, DefaultKinematics)
+		rewards := (&TrainingConfig{
+			HyperParams: []HyperParameter{
+				{Key: "finishReward", Val: 1},
+				{Key: "finishReward3", Val: 20},
+			},
+		}).GetRewards()
+		near, far := &states[0][0][0][0], &states[8][0][0][0]
+
+		Convey("Then both are terminal", func() {
+			So(is_terminal(near), ShouldBeTrue)
+			So(is_terminal(far), ShouldBeTrue)
+		})
+
+		Convey("Then each yields the reward of its tier, defaulting to the finish reward", func() {
+			So(getReward(near, rewards), ShouldEqual, 1)
+			So(getReward(far, rewards), ShouldEqual, 20)
+			So(getReward(&State{CellType: FINISH}, rewards), ShouldEqual, 1)
+		})
+	})
+
+	Convey("When the cell type is unknown", t, func() {
+		Convey("Then getReward panics", func() {
+			So(func() { getReward(&State{CellType: '?'}, &Rewards{}) }, ShouldPanic)
+		})
+	})
+}
+
+func TestWrapKinematics(t *testing.T) {
+	Convey("Given a track whose rows are open at both edges", t, func() {
+		states := Convert([]string{
+			"Wooo+",
+			"-oooo",
+		}, DefaultKinematics)
+		speedUp := &Action{Dvx: 1, Dvy: 0}
+		// Moving right at vx=1 from (3,0), accelerating to vx=2 takes the agent off the right edge.
+		state := &states[3][0][VelIndex(states, 1)][VelIndex(states, 0)]
+
+		Convey("When wrapping is enabled", func() {
+			config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "wrap", Val: 1}}}
+			kinematics := config.GetKinematics()
+			So(kinematics.Wrap, ShouldBeTrue)
+
+			Convey("Then stepping off the right edge reappears on the left", func() {
+				successor := getSuccessor(states, state, speedUp, getCollisionFunc(config), kinematics)
+				So(successor.X, ShouldEqual, 0)
+				So(successor.Y, ShouldEqual, 0)
+				So(successor.VX, ShouldEqual, 2)
+				So(successor.CellType, ShouldEqual, START)
+			})
+
+			Convey("Then collision checking wraps its traversal onto walls at the opposite edge", func() {
+				upper := &states[3][1][VelIndex(states, 1)][VelIndex(states, 0)]
+				for _, mode := range []float64{collisionModeBox, collisionModeSupercover} {
+					modeConfig := &TrainingConfig{HyperParams: append(config.HyperParams, HyperParameter{Key: "collisionMode", Val: mode})}
+					collision := getCollisionFunc(modeConfig)(states, upper, 2, 0)
+					So(collision, ShouldNotBeNil)
+					So(collision.X, ShouldEqual, 0)
+					So(collision.Y, ShouldEqual, 1)
+				}
+			})
+
+			Convey("Then negative positions wrap too", func() {
+				left := &states[0][0][VelIndex(states, -1)][VelIndex(states, 0)]
+				successor := getSuccessor(states, left, &Action{Dvx: 0, Dvy: 0}, getCollisionFunc(config), kinematics)
+				So(successor.X, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When clamping, the default", func() {
+			config := &TrainingConfig{}
+
+			Convey("Then stepping off the right edge stops at the edge", func() {
+				successor := getSuccessor(states, state, speedUp, getCollisionFunc(config), config.GetKinematics())
+				So(successor.X, ShouldEqual, 4)
+				So(getCollisionFunc(config)(states, &states[3][1][0][0], 2, 0), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestActionSlip(t *testing.T) {
+	Convey("Given a policy that always accelerates right", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		config := &TrainingConfig{}
+		collide, kinematics := getCollisionFunc(config), config.GetKinematics()
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 1)]
+		speedUp := &Action{Dvx: 1, Dvy: 0}
+		policy := func(state *State, rng *rand.Rand) (*State, *Action) {
+			return getSuccessor(states, state, speedUp, collide, kinematics), speedUp
+		}
+		accelerated := getSuccessor(states, state, speedUp, collide, kinematics)
+		slipped := getSuccessor(states, state, &Action{}, collide, kinematics)
+		So(accelerated, ShouldNotEqual, slipped)
+
+		Convey("When there is no noise", func() {
+			noisy := withSlip(states, policy, 0, collide, kinematics)
+
+			Convey("Then the successor is always that of the action, and no randomness is drawn", func() {
+				rng, ref := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+				for i := 0; i < 100; i++ {
+					target, action := noisy(state, rng)
+					So(target, ShouldEqual, accelerated)
+					So(action, ShouldEqual, speedUp)
+				}
+				So(rng.Int63(), ShouldEqual, ref.Int63())
+			})
+		})
+
+		Convey("When the noise is one", func() {
+			noisy := withSlip(states, policy, 1, collide, kinematics)
+
+			Convey("Then the velocity is always unchanged, though the action is that chosen", func() {
+				target, action := noisy(state, rand.New(rand.NewSource(1)))
+				So(target, ShouldEqual, slipped)
+				So(target.VX, ShouldEqual, state.VX)
+				So(target.VY, ShouldEqual, state.VY)
+				So(action, ShouldEqual, speedUp)
+			})
+		})
+
+		Convey("When the noise is the textbook's 0.1", func() {
+			noisy := withSlip(states, policy, 0.1, collide, kinematics)
+
+			Convey("Then about a tenth of actions slip", func() {
+				rng := rand.New(rand.NewSource(1))
+				n, slips := 10000, 0
+				for i := 0; i < n; i++ {
+					if target, _ := noisy(state, rng); target == slipped {
+						slips++
+					}
+				}
+				So(float64(slips)/float64(n), ShouldAlmostEqual, 0.1, 0.01)
+			})
+
+			Convey("Then training is reproducible given a seed", func() {
+				seeded := func() []float64 {
+					states := Convert(DebugTrack, DefaultKinematics)
+					config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 7}, {Key: "noise", Val: 0.1}}}
+					ctx, cancel := WithEpisodeBudget(context.Background(), 200)
+					defer cancel()
+					So(TrainSync(ctx, states, config, 0), ShouldBeNil)
+					return snapshotValues(states)
+				}
+				So(seeded(), ShouldResemble, seeded())
+			})
+		})
+	})
+}
+
+func TestInitValue(t *testing.T) {
+	Convey("Given configs with and without an initValue", t, func() {
+		pessimistic := &TrainingConfig{HyperParams: []HyperParameter{{Key: "collisionReward", Val: -7}}}
+		optimistic := &TrainingConfig{HyperParams: []HyperParameter{{Key: "initValue", Val: 3}, {Key: "seed", Val: 1}}}
+
+		Convey("Then the initial value defaults to the collision reward", func() {
+			So(getInitValue(pessimistic), ShouldEqual, -7)
+			So(getInitValue(optimistic), ShouldEqual, 3)
+		})
+
+		for _, kind := range []string{AlgAlphaMonteCarlo, AlgQLearning} {
+			kind := kind
+			Convey("When "+kind+" trains a single episode from the initial value", func() {
+				optimistic.Algorithm.Kind = kind
+				states := Convert(DebugTrack, DefaultKinematics)
+				ctx, cancel := WithEpisodeBudget(context.Background(), 1)
+				Reset(cancel)
+				So(TrainSync(ctx, states, optimistic, 1), ShouldBeNil)
+
+				Convey("Then the states it did not update for "+kind+" retain the initial value", func() {
+					unchanged := 0
+					Visit(states, func(s *State) {
+						if s.Value.AtomicRead() == 3 {
+							unchanged++
+						}
+					})
+					So(unchanged, ShouldBeGreaterThan, 0)
+				})
+			})
+		}
+	})
+}
+
+func TestVisitCounts(t *testing.T) {
+	Convey("Given states with visits left over from a prior run", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}
+		states := Convert(DebugTrack, DefaultKinematics)
+		wall := &states[0][0][0][0]
+		wall.RecordVisit()
+
+		for _, kind := range []string{AlgAlphaMonteCarlo, AlgQLearning, AlgSarsa, AlgTDLambda} {
+			kind := kind
+			Convey("When "+kind+" trains", func() {
+				config.Algorithm.Kind = kind
+				ctx, cancel := WithEpisodeBudget(context.Background(), 20)
+				Reset(cancel)
+				So(TrainSync(ctx, states, config, 1), ShouldBeNil)
+
+				Convey("Then the prior visits are reset, and the agents' visits counted for "+kind, func() {
+					So(wall.Visits(), ShouldEqual, 0)
+					// Every episode takes at least one step.
+					visits := int64(0)
+					VisitXYStates(states, func(velstates [][]State) {
+						visits += velstates[0][0].Visits()
+					})
+					So(visits, ShouldBeGreaterThanOrEqualTo, 20)
+				})
+			})
+		}
+	})
+}
+
+func TestWorkerRands(t *testing.T) {
+	Convey("Given a seed hyperparameter", t, func() {
+		config := &TrainingConfig{
+			HyperParams: []HyperParameter{{Key: "seed", Val: 42}},
+		}
+		states := Convert(DebugTrack, DefaultKinematics)
+
+		Convey("Then workers' random choices are reproducible", func() {
+			first := newWorkerRands(config, 2)
+			second := newWorkerRands(config, 2)
+			genInitState := newStartStateFuncs(states, config, 1)[0]
+			for i := range first {
+				for n := 0; n < 100; n++ {
+					start := genInitState(first[i])
+					So(genInitState(second[i]), ShouldEqual, start)
+					So(getRandAction(start, second[i], DefaultKinematics), ShouldResemble, getRandAction(start, first[i], DefaultKinematics))
+				}
+			}
+		})
+
+		Convey("Then each worker has a distinct sequence", func() {
+			rngs := newWorkerRands(config, 2)
+			So(rngs[0].Int63(), ShouldNotEqual, rngs[1].Int63())
+		})
+	})
+}
+
+func TestActionEnumeration(t *testing.T) {
+	Convey("Given a state on an open track from which every action yields a distinct successor", t, func() {
+		track := []string{}
+		for i := 0; i < 9; i++ {
+			track = append(track, "ooooooooo")
+		}
+		track[0] = "-oooooooo"
+		track[8] = "oooooooo+"
+		kinematics := DefaultKinematics
+		states := Convert(track, kinematics)
+		// Every new velocity is in [1,3], so no action is excluded by the zero-velocity rule.
+		state := &states[2][2][VelIndex(states, 2)][VelIndex(states, 2)]
+		qvals := NewActionValues(states, 0, kinematics)
+
+		allActions := []*Action{}
+		for dvx := kinematics.MinAcceleration(); dvx <= kinematics.MaxAcceleration; dvx++ {
+			for dvy := kinematics.MinAcceleration(); dvy <= kinematics.MaxAcceleration; dvy++ {
+				allActions = append(allActions, &Action{Dvx: dvx, Dvy: dvy})
+			}
+		}
+
+		Convey("Then there are 9 candidate actions with distinct action indices", func() {
+			So(len(allActions), ShouldEqual, 9)
+			So(kinematics.NumActions(), ShouldEqual, 9)
+			indices := map[int]bool{}
+			for _, action := range allActions {
+				indices[qvals.actionIndex(action)] = true
+			}
+			So(len(indices), ShouldEqual, kinematics.NumActions())
+		})
+
+		Convey("Then the max-successor search considers all 9 actions", func() {
+			for _, action := range allActions {
+				Visit(states, func(s *State) { s.Value.AtomicSet(0) })
+				best := getSuccessor(states, state, action, checkTerminalCollision, kinematics)
+				best.Value.AtomicSet(1)
+
+				target, maxAction := get_max_successor(states, state, checkTerminalCollision, kinematics)
+				So(target, ShouldEqual, best)
+				So(maxAction, ShouldResemble, action)
+			}
+		})
+
+		Convey("Then the max Q-value search considers all 9 actions", func() {
+			for _, action := range allActions {
+				qvals.Get(state, action).AtomicSet(float64(qvals.actionIndex(action) + 1))
+			}
+			maxAction, maxVal := qvals.MaxAction(state)
+			So(maxAction, ShouldResemble, &Action{Dvx: kinematics.MaxAcceleration, Dvy: kinematics.MaxAcceleration})
+			So(maxVal, ShouldEqual, kinematics.NumActions())
+		})
+
+		Convey("Then random actions span all 9 actions", func() {
+			rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+			seen := map[int]bool{}
+			for i := 0; i < 1000; i++ {
+				seen[qvals.actionIndex(getRandAction(state, rng, kinematics))] = true
+			}
+			So(len(seen), ShouldEqual, kinematics.NumActions())
+		})
+	})
+
+	Convey("Given a configured action set", t, func() {
+		track := []string{}
+		for i := 0; i < 9; i++ {
+			track = append(track, "ooooooooo")
+		}
+		track[0] = "-oooooooo"
+		track[8] = "oooooooo+"
+		states := Convert(track, DefaultKinematics)
+		state := &states[2][2][VelIndex(states, 2)][VelIndex(states, 2)]
+
+		for _, tc := range []struct {
+			actions string
+			size    int
+		}{{ActionsAll, 9}, {ActionsManhattan, 5}, {ActionsKing, 9}, {ActionsDiagonal, 5}} {
+			config := &TrainingConfig{Algorithm: AlgorithmConfig{Actions: tc.actions}}
+			kinematics := config.GetKinematics()
+			allowed := map[Action]bool{}
+			for _, action := range kinematics.Actions() {
+				allowed[action] = true
+			}
+
+			Convey(fmt.Sprintf("Then the %s set enumerates %d actions", tc.actions, tc.size), func() {
+				So(config.Algorithm.validate(), ShouldBeNil)
+				So(kinematics.ActionSet, ShouldEqual, tc.actions)
+				So(len(allowed), ShouldEqual, tc.size)
+			})
+
+			Convey(fmt.Sprintf("Then random %s actions span exactly the set", tc.actions), func() {
+				rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+				seen := map[Action]bool{}
+				for i := 0; i < 1000; i++ {
+					seen[*getRandAction(state, rng, kinematics)] = true
+				}
+				So(seen, ShouldResemble, allowed)
+			})
+
+			Convey(fmt.Sprintf("Then the %s max-successor and max Q-value searches are restricted to the set", tc.actions), func() {
+				// Every action outside the set leads to the highest value, which must be ignored.
+				Visit(states, func(s *State) { s.Value.AtomicSet(0) })
+				qvals := NewActionValues(states, 0, kinematics)
+				for dvx := kinematics.MinAcceleration(); dvx <= kinematics.MaxAcceleration; dvx++ {
+					for dvy := kinematics.MinAcceleration(); dvy <= kinematics.MaxAcceleration; dvy++ {
+						if action := (Action{Dvx: dvx, Dvy: dvy}); !allowed[action] {
+							getSuccessor(states, state, &action, checkTerminalCollision, kinematics).Value.AtomicSet(1)
+							qvals.Get(state, &action).AtomicSet(1)
+						}
+					}
+				}
+				_, action := get_max_successor(states, state, checkTerminalCollision, kinematics)
+				So(allowed[*action], ShouldBeTrue)
+				action, _ = qvals.MaxAction(state)
+				So(allowed[*action], ShouldBeTrue)
+			})
+		}
+	})
+
+	Convey("Given a stationary-adjacent state", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+
+		Convey("Then random actions never yield zero velocity", func() {
+			for i := 0; i < 1000; i++ {
+				vx, vy := getNewVelocity(state, getRandAction(state, rng, DefaultKinematics), DefaultKinematics)
+				So(vx == 0 && vy == 0, ShouldBeFalse)
+			}
+		})
+
+		Convey("Then negative velocities are reachable", func() {
+			vx, vy := getNewVelocity(state, &Action{Dvx: -1, Dvy: -1}, DefaultKinematics)
+			So(vx, ShouldEqual, 0)
+			So(vy, ShouldEqual, -1)
+		})
+	})
+
+	Convey("Given kinematics with a higher max velocity", t, func() {
+		kinematics := Kinematics{MaxVelocity: 6, MaxAcceleration: 2}
+		states := Convert(FullTrack, kinematics)
+		state := &states[3][3][VelIndex(states, 5)][VelIndex(states, -5)]
+
+		Convey("Then velocities are bounded by the kinematics rather than the defaults", func() {
+			vx, vy := getNewVelocity(state, &Action{Dvx: 2, Dvy: -2}, kinematics)
+			So(vx, ShouldEqual, 6)
+			So(vy, ShouldEqual, -6)
+		})
+
+		Convey("Then random actions span every acceleration pair", func() {
+			qvals := NewActionValues(states, 0, kinematics)
+			rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+			seen := map[int]bool{}
+			for i := 0; i < 1000; i++ {
+				seen[qvals.actionIndex(getRandAction(state, rng, kinematics))] = true
+			}
+			So(len(seen), ShouldEqual, 25)
+		})
+	})
+}
+
+func TestMaxSuccessorFallback(t *testing.T) {
+	Convey("Given a state whose every successor has a degenerate value", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		collide := checkTerminalCollision
+
+		for _, val := range []float64{math.Inf(-1), math.NaN()} {
+			Convey(fmt.Sprintf("Then a valid successor is returned when all values are %v", val), func() {
+				Visit(states, func(s *State) { s.Value.AtomicSet(val) })
+				target, action := get_max_successor(states, state, collide, DefaultKinematics)
+				So(target, ShouldNotBeNil)
+				So(action, ShouldNotBeNil)
+				So(target, ShouldEqual, getSuccessor(states, state, action, collide, DefaultKinematics))
+				vx, vy := getNewVelocity(state, action, DefaultKinematics)
+				So(vx == 0 && vy == 0, ShouldBeFalse)
+			})
+
+			Convey(fmt.Sprintf("Then the greedy policy does not panic when all values are %v", val), func() {
+				Visit(states, func(s *State) { s.Value.AtomicSet(val) })
+				policy := newPolicyAlphaMax(states, func() float64 { return 0 }, collide, DefaultKinematics)
+				rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+				So(func() {
+					target, _ := policy(state, rng)
+					getReward(target, (&TrainingConfig{}).GetRewards())
+				}, ShouldNotPanic)
+			})
+		}
+	})
+
+	Convey("Given a state from which every action would yield zero velocity", t, func() {
+		// No action set permits this, so the action set is emulated by a velocity bound of zero.
+		states := Convert(DebugTrack, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 0)][VelIndex(states, 0)]
+		stationary := Kinematics{MaxVelocity: 0, MaxAcceleration: 1}
+
+		Convey("Then the current state is returned with the no-op action", func() {
+			target, action := get_max_successor(states, state, checkTerminalCollision, stationary)
+			So(target, ShouldEqual, state)
+			So(action, ShouldResemble, &Action{})
+		})
+	})
+}
+
+func TestMaxActionFallback(t *testing.T) {
+	Convey("Given a state whose every action-value is degenerate", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		collide := checkTerminalCollision
+
+		for _, val := range []float64{math.Inf(-1), math.NaN()} {
+			qvals := NewActionValues(states, val, DefaultKinematics)
+
+			Convey(fmt.Sprintf("Then an allowed action is returned when all values are %v", val), func() {
+				action, _ := qvals.MaxAction(state)
+				So(action, ShouldNotBeNil)
+				vx, vy := getNewVelocity(state, action, DefaultKinematics)
+				So(vx == 0 && vy == 0, ShouldBeFalse)
+			})
+
+			Convey(fmt.Sprintf("Then the greedy policy does not panic when all values are %v", val), func() {
+				policy := newPolicyQMax(states, qvals, func() float64 { return 0 }, collide)
+				rng := newWorkerRands(&TrainingConfig{HyperParams: []HyperParameter{{Key: "seed", Val: 1}}}, 1)[0]
+				var target *State
+				var action *Action
+				So(func() { target, action = policy(state, rng) }, ShouldNotPanic)
+				So(action, ShouldNotBeNil)
+				So(target, ShouldNotBeNil)
+			})
+		}
+	})
+}
+
+func TestNStepReturns(t *testing.T) {
+	Convey("Given a three step episode whose intermediate states have values", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		s0 := &states[1][1][VelIndex(states, 0)][VelIndex(states, 1)]
+		s1 := &states[1][2][VelIndex(states, 0)][VelIndex(states, 1)]
+		s2 := &states[1][3][VelIndex(states, 0)][VelIndex(states, 1)]
+		wall := &states[0][4][VelIndex(states, 0)][VelIndex(states, 1)]
+		s1.Value.AtomicSet(-10)
+		s2.Value.AtomicSet(-20)
+		episode := &Episode{
+			{State: s0, Reward: -1, Successor: s1},
+			{State: s1, Reward: -1, Successor: s2},
+			{State: s2, Reward: -1, Successor: wall},
+		}
+		gamma := 0.5
+
+		Convey("When n is 1, each target bootstraps from the next state's value", func() {
+			So(nStepReturns(episode, gamma, 1), ShouldResemble, []float64{-6, -11, -1})
+		})
+
+		Convey("When n is 2, two rewards are summed before bootstrapping", func() {
+			So(nStepReturns(episode, gamma, 2), ShouldResemble, []float64{-6.5, -1.5, -1})
+		})
+
+		Convey("When n is 0 or the episode length, the targets are the full discounted returns", func() {
+			So(nStepReturns(episode, gamma, 0), ShouldResemble, []float64{-1.75, -1.5, -1})
+			So(nStepReturns(episode, gamma, 3), ShouldResemble, []float64{-1.75, -1.5, -1})
+		})
+
+		for _, tc := range []struct {
+			gamma   float64
+			returns []float64
+		}{
+			// Undiscounted: each return is the sum of the remaining rewards.
+			{gamma: 1, returns: []float64{-3, -2, -1}},
+			// G2 = -1; G1 = -1 + 0.9*G2; G0 = -1 + 0.9*G1
+			{gamma: 0.9, returns: []float64{-2.71, -1.9, -1}},
+			// Each return is only the immediate reward.
+			{gamma: 0, returns: []float64{-1, -1, -1}},
+		} {
+			Convey(fmt.Sprintf("When n is 0 and gamma is %v, later rewards are discounted per step", tc.gamma), func() {
+				returns := nStepReturns(episode, tc.gamma, 0)
+				So(len(returns), ShouldEqual, len(tc.returns))
+				for i := range returns {
+					So(returns[i], ShouldAlmostEqual, tc.returns[i])
+				}
+			})
+		}
+
+		Convey("When the episode is truncated, the targets bootstrap from the last successor's value", func() {
+			truncated := &Episode{
+				{State: s0, Reward: -1, Successor: s1},
+				{State: s1, Reward: -1, Successor: s2, Truncated: true},
+			}
+			So(nStepReturns(truncated, gamma, 0), ShouldResemble, []float64{-6.5, -11})
+			So(nStepReturns(truncated, gamma, 1), ShouldResemble, []float64{-6, -11})
+		})
+	})
+}
+
+func TestEpisodeTruncation(t *testing.T) {
+	Convey("Given a policy that never reaches a terminal state", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		start := &states[1][1][VelIndex(states, 0)][VelIndex(states, 1)]
+		genInitState := func(*rand.Rand) *State { return start }
+		stay := func(state *State, _ *rand.Rand) (*State, *Action) { return state, &Action{} }
+
+		Convey("Then episodes are truncated at the max length, and marked as such", func() {
+			episode := generateEpisode(rand.New(rand.NewSource(1)), genInitState, stay, (&TrainingConfig{}).GetRewards(), 10)
+			So(len(*episode), ShouldEqual, 10)
+			So((*episode)[9].Truncated, ShouldBeTrue)
+			for _, step := range (*episode)[:9] {
+				So(step.Truncated, ShouldBeFalse)
+			}
+		})
+
+		Convey("Then a truncated episode's last successor keeps its value", func() {
+			start.Value.AtomicSet(-42)
+			setTerminalValue(generateEpisode(rand.New(rand.NewSource(1)), genInitState, stay, (&TrainingConfig{}).GetRewards(), 10))
+			So(start.Value.AtomicRead(), ShouldEqual, -42)
+		})
+	})
+
+	for _, alg := range []string{AlgAlphaMonteCarlo, AlgQLearning, AlgSarsa, AlgTDLambda} {
+		Convey(fmt.Sprintf("Given %s training on a single row track with a small maxEpisodeLen", alg), t, func() {
+			states := Convert([]string{"-oo+"}, DefaultKinematics)
+			config := &TrainingConfig{
+				HyperParams: []HyperParameter{{Key: "maxEpisodeLen", Val: 20}},
+				Algorithm:   AlgorithmConfig{Kind: alg},
+			}
+			timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Second)
+			Reset(cancelTimeout)
+			ctx, cancel := WithEpisodeBudget(timeout, 200)
+			Reset(cancel)
+
+			Convey("Then training completes its budget rather than stalling", func() {
+				So(TrainSync(ctx, states, config, 2), ShouldBeNil)
+				So(timeout.Err(), ShouldBeNil)
+			})
+		})
+	}
+}
+
+func TestTrainingDeadline(t *testing.T) {
+	withDeadline := func(spec DeadlineConfig) (context.Context, error) {
+		ctx, cancel, err := (&TrainingConfig{TrainingDeadline: spec}).WithTrainingDeadline(context.Background())
+		if cancel != nil {
+			Reset(cancel)
+		}
+		return ctx, err
+	}
+
+	Convey("Given neither a duration nor a deadline", t, func() {
+		ctx, err := withDeadline(DeadlineConfig{})
+
+		Convey("Then training has no deadline", func() {
+			So(err, ShouldBeNil)
+			_, ok := ctx.Deadline()
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given only a duration", t, func() {
+		before := time.Now()
+		ctx, err := withDeadline(DeadlineConfig{Duration: "2m"})
+
+		Convey("Then training ends after the duration", func() {
+			So(err, ShouldBeNil)
+			deadline, ok := ctx.Deadline()
+			So(ok, ShouldBeTrue)
+			So(deadline, ShouldHappenWithin, time.Second, before.Add(2*time.Minute))
+		})
+	})
+
+	Convey("Given only a deadline", t, func() {
+		ctx, err := withDeadline(DeadlineConfig{Deadline: "2030-01-02T06:00:00-08:00"})
+
+		Convey("Then training ends at the deadline", func() {
+			So(err, ShouldBeNil)
+			deadline, ok := ctx.Deadline()
+			So(ok, ShouldBeTrue)
+			So(deadline.Equal(time.Date(2030, 1, 2, 14, 0, 0, 0, time.UTC)), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given both a duration and a deadline", t, func() {
+		Convey("When the deadline is first", func() {
+			ctx, err := withDeadline(DeadlineConfig{Duration: "2m", Deadline: "2020-01-02T06:00:00Z"})
+
+			Convey("Then training ends at the deadline", func() {
+				So(err, ShouldBeNil)
+				deadline, _ := ctx.Deadline()
+				So(deadline.Equal(time.Date(2020, 1, 2, 6, 0, 0, 0, time.UTC)), ShouldBeTrue)
+				So(errors.Is(ctx.Err(), context.DeadlineExceeded), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the duration is first", func() {
+			before := time.Now()
+			ctx, err := withDeadline(DeadlineConfig{Duration: "2m", Deadline: "2030-01-02T06:00:00Z"})
+
+			Convey("Then training ends after the duration", func() {
+				So(err, ShouldBeNil)
+				deadline, _ := ctx.Deadline()
+				So(deadline, ShouldHappenWithin, time.Second, before.Add(2*time.Minute))
+			})
+		})
+	})
+
+	Convey("Given invalid specifications", t, func() {
+		Convey("Then malformed deadlines are rejected descriptively", func() {
+			_, err := withDeadline(DeadlineConfig{Deadline: "tomorrow at 6"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "RFC3339")
+		})
+
+		Convey("Then malformed durations are rejected", func() {
+			_, err := withDeadline(DeadlineConfig{Duration: "2 minutes"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestFromYaml(t *testing.T) {
+	fromYaml := func(def string) (*TrainingConfig, error) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		So(os.WriteFile(path, []byte("kind: TrainingConfig\ndef:\n"+def), 0o644), ShouldBeNil)
+		return FromYaml(path)
+	}
+
+	Convey("Given a config of typed sections with camel-cased keys", t, func() {
+		config, err := fromYaml(`
+  algorithm:
+    kind: tdlambda
+    lambda: 0.5
+    nStep: 3
+  trainingDeadline:
+    duration: 2m
+  replay:
+    capacity: 100
+    batchSize: 8
+  kinematics:
+    maxVelocity: 3
+    maxAcceleration: 2
+`)
+
+		Convey("Then each field is decoded", func() {
+			So(err, ShouldBeNil)
+			So(config.Algorithm.Kind, ShouldEqual, AlgTDLambda)
+			So(*config.Algorithm.Lambda, ShouldEqual, 0.5)
+			So(*config.Algorithm.NStep, ShouldEqual, 3)
+			So(config.TrainingDeadline, ShouldResemble, DeadlineConfig{Duration: "2m"})
+			So(config.Replay, ShouldResemble, ReplayConfig{Capacity: 100, BatchSize: 8})
+			So(config.GetKinematics(), ShouldResemble, Kinematics{MaxVelocity: 3, MaxAcceleration: 2})
+		})
+	})
+
+	Convey("Given invalid configs", t, func() {
+		Convey("Then unknown keys are errors", func() {
+			_, err := fromYaml("  algorithm:\n    kindd: qlearning\n")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then unknown algorithms are errors", func() {
+			_, err := fromYaml("  algorithm:\n    kind: dqn\n")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then out of range algorithm params are errors", func() {
+			_, err := fromYaml("  algorithm:\n    kind: tdlambda\n    lambda: 2\n")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then unknown action sets are errors", func() {
+			_, err := fromYaml("  algorithm:\n    actions: rook\n")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
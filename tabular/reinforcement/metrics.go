@@ -0,0 +1,247 @@
+package reinforcement
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	. "tabular/grid_world"
+)
+
+// Metrics summarizes training progress over the most recent publication interval.
+type Metrics struct {
+	// EpisodeCount is the total number of episodes processed by the estimator.
+	EpisodeCount int
+	// MeanAbsDelta is the mean absolute value-delta applied over the interval.
+	MeanAbsDelta float64
+	// MeanEpisodeLength is the mean number of steps per episode over the interval.
+	MeanEpisodeLength float64
+	// Elapsed is the wall-clock time since training began.
+	Elapsed time.Duration
+	// EpisodesPerSecond is the mean episode rate over the interval.
+	EpisodesPerSecond float64
+	// Epsilon is the current exploration rate, per the epsilon schedule.
+	Epsilon float64
+	// Eta is the current learning rate, per the eta schedule.
+	Eta float64
+	// PolicySteps is the mean number of steps for the greedy policy to finish, per EvaluatePolicy.
+	PolicySteps float64
+	// PolicyFailRate is the fraction of greedy rollouts that crash or loop, per EvaluatePolicy.
+	PolicyFailRate float64
+	// EpisodesGenerated is the total number of episodes generated by the agents.
+	EpisodesGenerated int
+	// EpisodeBacklog is the number of episodes generated but not yet processed by the estimator.
+	// Each agent blocks holding at most one episode, so it approaches the number of agents when
+	// the estimator is the bottleneck, and zero when the agents are.
+	EpisodeBacklog int
+	// ValueError is the RMS error of the state values against the reference passed via
+	// WithReference, per ValueError; NaN without a reference.
+	ValueError float64
+	// PolicyChanges is the number of cells whose greedy action changed since the last Metrics,
+	// per EvaluatePolicyStability; every cell with an action for the first.
+	PolicyChanges int
+}
+
+// TrainOption configures optional training behavior.
+type TrainOption func(*trainOptions)
+
+type trainOptions struct {
+	metrics         chan<- Metrics
+	metricsInterval int
+	gate            *Gate
+	hyperParams     *HyperParams
+	logger          TrainingLogger
+	logInterval     int
+	samplers        episodeSamplers
+	// stream is set by TrainStream, whose sampler is added to the samplers by Train.
+	stream *episodeStream
+	// genInitStates are the workers' start state generators, set by Train per the start distribution.
+	genInitStates []func(*rand.Rand) *State
+	// routines tracks the agents and the estimator, such that callers may wait for them to exit
+	// once training is cancelled; see TrainSync.
+	routines *sync.WaitGroup
+	// fresh initializes the state values rather than resuming from the last checkpoint; see Trainer.
+	fresh bool
+	// reference is the state grid against whose values the published metrics' ValueError is computed.
+	reference [][][][]State
+}
+
+// WithMetrics publishes Metrics to the passed channel every interval episodes. The greedy
+// policy is evaluated and the metrics published by a routine of their own, so neither a slow
+// evaluation nor a slow receiver blocks the estimator; while they lag, the intervals of the
+// following publications are merged, such that a publication may span several intervals.
+func WithMetrics(metrics chan<- Metrics, interval int) TrainOption {
+	return func(opts *trainOptions) {
+		opts.metrics = metrics
+		opts.metricsInterval = interval
+	}
+}
+
+// WithReference computes the ValueError of each published Metrics against the values of the
+// passed state grid, e.g. a prior converged run restored via LoadValues. The reference must be
+// of the same track as the trained states, else the ValueError is NaN.
+func WithReference(reference [][][][]State) TrainOption {
+	return func(opts *trainOptions) {
+		opts.reference = reference
+	}
+}
+
+// metricsRecorder accumulates the estimator's deltas and episode lengths and
+// periodically publishes them as Metrics, along with an evaluation of the current
+// greedy policy. Only used by the estimator, and by its evaluator routine, if any;
+// see evaluateAsync.
+type metricsRecorder struct {
+	ctx      context.Context
+	metrics  chan<- Metrics
+	interval int
+	states   [][][][]State
+	config   *TrainingConfig
+	// Counts the episodes generated by the agents, as one of their samplers.
+	generated *episodeSampler
+	// The reference values of ValueError; nil if none.
+	reference [][][][]State
+	// The greedy actions of the last publication, per GreedyActions.
+	policy [][]*Action
+	start  time.Time
+	// The time of the last publication, for computing rates over the interval.
+	last      time.Time
+	epsilonFn func(int64) float64
+	etaFn     func(int64) float64
+	// pending passes the metrics of each interval to the evaluator routine; nil if the policy
+	// is evaluated and the metrics published by the estimator.
+	pending chan Metrics
+
+	episodeCount int
+	// Accumulators for the current interval
+	sumAbsDelta float64
+	numDeltas   int
+	numSteps    int
+	numEpisodes int
+}
+
+func newMetricsRecorder(
+	ctx context.Context,
+	metrics chan<- Metrics,
+	interval int,
+	states [][][][]State,
+	config *TrainingConfig,
+	hyperParams *HyperParams,
+	generated *episodeSampler,
+	reference [][][][]State,
+) *metricsRecorder {
+	if interval <= 0 {
+		interval = 1
+	}
+	if reference != nil {
+		if err := checkSameGrid(states, reference); err != nil {
+			log.Println("value error not computed:", err)
+			reference = nil
+		}
+	}
+	now := time.Now()
+	return &metricsRecorder{
+		ctx:       ctx,
+		metrics:   metrics,
+		interval:  interval,
+		states:    states,
+		config:    config,
+		generated: generated,
+		reference: reference,
+		start:     now,
+		last:      now,
+		epsilonFn: newEpsilonSchedule(config, hyperParams),
+		etaFn:     newEtaSchedule(config, hyperParams),
+	}
+}
+
+func (mr *metricsRecorder) Observe(delta float64) {
+	mr.sumAbsDelta += math.Abs(delta)
+	mr.numDeltas++
+}
+
+func (mr *metricsRecorder) EndEpisode(steps int) {
+	mr.episodeCount++
+	mr.numEpisodes++
+	mr.numSteps += steps
+	if mr.episodeCount%mr.interval != 0 {
+		return
+	}
+
+	now := time.Now()
+	metrics := Metrics{
+		EpisodeCount:      mr.episodeCount,
+		MeanEpisodeLength: float64(mr.numSteps) / float64(mr.numEpisodes),
+		Elapsed:           now.Sub(mr.start),
+		Epsilon:           mr.epsilonFn(int64(mr.episodeCount)),
+		Eta:               mr.etaFn(int64(mr.episodeCount)),
+		ValueError:        math.NaN(),
+	}
+	metrics.EpisodesGenerated = mr.generated.sampled()
+	// SARSA agents count their episodes after sending the last step, which the estimator may
+	// process first, hence the backlog is bounded below by zero.
+	if backlog := metrics.EpisodesGenerated - mr.episodeCount; backlog > 0 {
+		metrics.EpisodeBacklog = backlog
+	}
+	if mr.numDeltas > 0 {
+		metrics.MeanAbsDelta = mr.sumAbsDelta / float64(mr.numDeltas)
+	}
+	if interval := now.Sub(mr.last).Seconds(); interval > 0 {
+		metrics.EpisodesPerSecond = float64(mr.numEpisodes) / interval
+	}
+
+	if mr.pending == nil {
+		mr.evaluate(&metrics)
+		mr.publish(metrics)
+	} else {
+		select {
+		case mr.pending <- metrics:
+		default:
+			// The evaluator lags, so the interval is merged into the next.
+			return
+		}
+	}
+	mr.last = now
+	mr.sumAbsDelta, mr.numDeltas, mr.numSteps, mr.numEpisodes = 0, 0, 0, 0
+}
+
+// evaluateAsync evaluates the policy and publishes the metrics of each interval in a routine
+// of the passed options, rather than on the estimator, since evaluation visits every state.
+// One interval may be pending while the previous is evaluated or published.
+func (mr *metricsRecorder) evaluateAsync(opts *trainOptions) *metricsRecorder {
+	mr.pending = make(chan Metrics, 1)
+	opts.spawn(func() {
+		for {
+			select {
+			case metrics := <-mr.pending:
+				mr.evaluate(&metrics)
+				mr.publish(metrics)
+			case <-mr.ctx.Done():
+				return
+			}
+		}
+	})
+	return mr
+}
+
+// evaluate sets the metrics' evaluation of the current greedy policy and values.
+func (mr *metricsRecorder) evaluate(metrics *Metrics) {
+	if mr.reference != nil {
+		// The grids are checked upon construction, hence ValueError cannot fail.
+		metrics.ValueError, _ = ValueError(mr.states, mr.reference)
+	}
+	metrics.PolicySteps, metrics.PolicyFailRate = EvaluatePolicy(mr.states, mr.config)
+	policy := GreedyActions(mr.states, mr.config)
+	metrics.PolicyChanges = EvaluatePolicyStability(mr.policy, policy)
+	mr.policy = policy
+}
+
+// publish blocks until the metrics are received or training is cancelled.
+func (mr *metricsRecorder) publish(metrics Metrics) {
+	select {
+	case mr.metrics <- metrics:
+	case <-mr.ctx.Done():
+	}
+}
@@ -0,0 +1,129 @@
+package reinforcement
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEpisodeBacklog(t *testing.T) {
+	Convey("Given sequential training", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 0,
+			func(context.Context, int) {}, WithMetrics(metrics, 1))
+
+		Convey("Then every generated episode is processed immediately", func() {
+			for i := 0; i < 20; i++ {
+				latest := <-metrics
+				So(latest.EpisodesGenerated, ShouldEqual, latest.EpisodeCount)
+				So(latest.EpisodeBacklog, ShouldEqual, 0)
+			}
+		})
+	})
+
+	Convey("Given an estimator slower than its agents", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		slow := func(context.Context, int) { time.Sleep(time.Millisecond) }
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 4, slow, WithMetrics(metrics, 10))
+
+		Convey("Then the agents' episodes back up", func() {
+			var latest Metrics
+			for latest.EpisodeCount < 100 {
+				latest = <-metrics
+			}
+			So(latest.EpisodeBacklog, ShouldBeGreaterThan, 0)
+			So(latest.EpisodesGenerated, ShouldEqual, latest.EpisodeCount+latest.EpisodeBacklog)
+		})
+	})
+}
+
+func TestMetricsValueError(t *testing.T) {
+	Convey("Given sequential training without a reference", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 0,
+			func(context.Context, int) {}, WithMetrics(metrics, 1))
+
+		Convey("Then the value error is NaN", func() {
+			So(math.IsNaN((<-metrics).ValueError), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given sequential training with a reference of the same track", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		reference := Convert(DebugTrack, DefaultKinematics)
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 0,
+			func(context.Context, int) {}, WithMetrics(metrics, 1), WithReference(reference))
+
+		Convey("Then the value error is computed", func() {
+			latest := <-metrics
+			So(math.IsNaN(latest.ValueError), ShouldBeFalse)
+			So(latest.ValueError, ShouldBeGreaterThanOrEqualTo, 0)
+		})
+	})
+
+	Convey("Given sequential training with a reference of a different track", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		reference := Convert(DebugTrack[1:], DefaultKinematics)
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 0,
+			func(context.Context, int) {}, WithMetrics(metrics, 1), WithReference(reference))
+
+		Convey("Then the reference is ignored", func() {
+			So(math.IsNaN((<-metrics).ValueError), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMetricsOffEstimator(t *testing.T) {
+	Convey("Given training whose metrics are never received", t, func() {
+		ctx, cancel := WithEpisodeBudget(context.Background(), 200)
+		Reset(cancel)
+		done := make(chan error, 1)
+		go func() {
+			done <- TrainSync(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 2,
+				WithMetrics(make(chan Metrics), 1))
+		}()
+
+		Convey("Then the estimator is not blocked, and training completes its budget", func() {
+			select {
+			case err := <-done:
+				So(err, ShouldBeNil)
+			case <-time.After(5 * time.Second):
+				So("training completed", ShouldEqual, "training blocked")
+			}
+		})
+	})
+
+	Convey("Given a receiver slower than the metrics interval", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		metrics := make(chan Metrics)
+		Train(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 2,
+			func(context.Context, int) {}, WithMetrics(metrics, 1))
+
+		Convey("Then the lagging intervals are merged into later publications", func() {
+			first := <-metrics
+			time.Sleep(50 * time.Millisecond)
+			// At most one interval is being published and one pending, so the third must span
+			// the episodes processed while the receiver slept.
+			<-metrics
+			<-metrics
+			third := <-metrics
+			So(third.EpisodeCount, ShouldBeGreaterThan, first.EpisodeCount+3)
+		})
+	})
+}
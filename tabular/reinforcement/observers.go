@@ -0,0 +1,23 @@
+package reinforcement
+
+// episodeObserver observes the value-deltas applied by an estimator, per episode.
+// Estimators call Observe for every update and EndEpisode upon completing each episode.
+type episodeObserver interface {
+	Observe(delta float64)
+	EndEpisode(steps int)
+}
+
+// episodeObservers multiplexes estimator observations to a set of observers.
+type episodeObservers []episodeObserver
+
+func (obs episodeObservers) Observe(delta float64) {
+	for _, ob := range obs {
+		ob.Observe(delta)
+	}
+}
+
+func (obs episodeObservers) EndEpisode(steps int) {
+	for _, ob := range obs {
+		ob.EndEpisode(steps)
+	}
+}
@@ -0,0 +1,173 @@
+package reinforcement
+
+import (
+	"math"
+	"math/rand"
+
+	. "tabular/grid_world"
+)
+
+/*
+The oracle is a heuristic demonstration agent, per the notes on alpha-MC: initial agents act
+randomly and may take an eternity to reach a finish cell and thereby propagate useful values
+back from it. The oracle instead drives greedily toward the nearest FINISH cell: it takes the
+action whose successor is nearest a finish cell, per the shortest path distances over the
+track, excluding actions leading to states from which a collision is inevitable.
+Ties are broken randomly, and like the other policies the oracle takes a random action with
+probability epsilon, which also ensures its episodes terminate.
+
+A fraction of the workers, per the oracleFraction hyperparameter, run the oracle policy for the
+first oracleEpisodes episodes of training, after which they switch to the learned policy.
+*/
+
+// getOracleParams returns the number of workers that initially run the oracle policy and
+// the number of training episodes for which they do so.
+func getOracleParams(config *TrainingConfig, nworkers int) (numOracles int, oracleEpisodes int64) {
+	fraction := math.Max(math.Min(config.GetHyperParamOrDefault("oracleFraction", 0), 1), 0)
+	numOracles = int(math.Ceil(fraction * float64(nworkers)))
+	oracleEpisodes = int64(config.GetHyperParamOrDefault("oracleEpisodes", 1000))
+	return
+}
+
+// withOracle returns a policy that follows the oracle until episodeCount() reaches the
+// passed number of episodes, and the passed policy thereafter.
+func withOracle(
+	oracle func(*State, *rand.Rand) (*State, *Action),
+	policy func(*State, *rand.Rand) (*State, *Action),
+	episodeCount func() int64,
+	oracleEpisodes int64,
+) func(*State, *rand.Rand) (*State, *Action) {
+	return func(state *State, rng *rand.Rand) (*State, *Action) {
+		if episodeCount() < oracleEpisodes {
+			return oracle(state, rng)
+		}
+		return policy(state, rng)
+	}
+}
+
+// newOraclePolicy returns the epsilon-greedy oracle policy for the passed states.
+func newOraclePolicy(
+	states [][][][]State,
+	epsilon func() float64,
+	collide collisionFunc,
+	kinematics Kinematics,
+) func(*State, *rand.Rand) (*State, *Action) {
+	distances := finishDistances(states)
+	viable := viableStates(states, collide, kinematics)
+
+	return func(state *State, rng *rand.Rand) (target *State, action *Action) {
+		if rng.Float64() <= epsilon() {
+			// Exploration: do something random
+			action = getRandAction(state, rng, kinematics)
+			return getSuccessor(states, state, action, collide, kinematics), action
+		}
+
+		minDist, numTies := math.MaxInt, 0
+		actions := kinematics.Actions()
+		for i := range actions {
+			candidate := &actions[i]
+			if vx, vy := getNewVelocity(state, candidate, kinematics); vx == 0 && vy == 0 {
+				continue
+			}
+			successor := getSuccessor(states, state, candidate, collide, kinematics)
+			dist := distances[successor.X][successor.Y]
+			if dist < 0 || dist > minDist || !viable[successor] {
+				continue
+			}
+			if dist < minDist {
+				minDist, numTies = dist, 0
+			}
+			// Reservoir sampling selects uniformly among the tied actions.
+			numTies++
+			if rng.Intn(numTies) == 0 {
+				target, action = successor, candidate
+			}
+		}
+
+		if action == nil {
+			// Every action eventually collides.
+			action = getRandAction(state, rng, kinematics)
+			target = getSuccessor(states, state, action, collide, kinematics)
+		}
+		return target, action
+	}
+}
+
+// viableStates returns the set of states from which some sequence of actions avoids collision,
+// including finish states. Beginning with every non-wall state, states whose every action leads to
+// a wall or a non-viable state are removed until none remain; per the problem definition, actions
+// yielding zero velocity are excluded.
+func viableStates(
+	states [][][][]State,
+	collide collisionFunc,
+	kinematics Kinematics,
+) (viable map[*State]bool) {
+	viable = map[*State]bool{}
+	Visit(states, func(state *State) {
+		if state.CellType != WALL {
+			viable[state] = true
+		}
+	})
+
+	actions := kinematics.Actions()
+	hasViableAction := func(state *State) bool {
+		for i := range actions {
+			action := &actions[i]
+			if vx, vy := getNewVelocity(state, action, kinematics); vx == 0 && vy == 0 {
+				continue
+			}
+			if viable[getSuccessor(states, state, action, collide, kinematics)] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for state := range viable {
+			if !is_terminal(state) && !hasViableAction(state) {
+				delete(viable, state)
+				changed = true
+			}
+		}
+	}
+	return
+}
+
+// finishDistances returns the minimum number of cell-steps from each cell of the grid to a
+// finish cell of any tier, moving horizontally, vertically, or diagonally through non-wall cells, by
+// breadth-first search from the finish cells. Cells from which no finish cell is reachable,
+// including walls, have distance -1.
+func finishDistances(states [][][][]State) (distances [][]int) {
+	width, height := len(states), len(states[0])
+	distances = make([][]int, width)
+	queue := [][2]int{}
+	for x := range distances {
+		distances[x] = make([]int, height)
+		for y := range distances[x] {
+			distances[x][y] = -1
+			if IsFinish(states[x][y][0][0].CellType) {
+				distances[x][y] = 0
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				x, y := cell[0]+dx, cell[1]+dy
+				if x < 0 || x >= width || y < 0 || y >= height ||
+					distances[x][y] != -1 || states[x][y][0][0].CellType == WALL {
+					continue
+				}
+				distances[x][y] = distances[cell[0]][cell[1]] + 1
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package reinforcement
+
+import (
+	"math/rand"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOracle(t *testing.T) {
+	Convey("Given the debug track", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+
+		Convey("Then finish distances count cell-steps through non-wall cells", func() {
+			distances := finishDistances(states)
+			So(distances[5][5], ShouldEqual, 0)
+			So(distances[4][5], ShouldEqual, 1)
+			So(distances[1][0], ShouldEqual, 7)
+			So(distances[0][0], ShouldEqual, -1)
+		})
+	})
+
+	Convey("Given an oracle without exploration", t, func() {
+		for name, track := range map[string][]string{"debug": DebugTrack, "full": FullTrack} {
+			states := Convert(track, DefaultKinematics)
+			oracle := newOraclePolicy(states, func() float64 { return 0 }, checkSupercoverCollision, DefaultKinematics)
+			rng := rand.New(rand.NewSource(1))
+
+			Convey("Then it drives from every start cell of the "+name+" track to the finish", func() {
+				Visit(states, func(start *State) {
+					if start.CellType != START || start.VX != 0 || start.VY != 1 {
+						return
+					}
+					state := start
+					for steps := 0; steps < 100 && !is_terminal(state); steps++ {
+						state, _ = oracle(state, rng)
+					}
+					So(state.CellType, ShouldEqual, FINISH)
+				})
+			})
+		}
+	})
+
+	Convey("Given a policy with an oracle for the first episodes", t, func() {
+		count := int64(0)
+		fromOracle := &State{CellType: FINISH}
+		fromPolicy := &State{CellType: WALL}
+		policy := withOracle(
+			func(*State, *rand.Rand) (*State, *Action) { return fromOracle, nil },
+			func(*State, *rand.Rand) (*State, *Action) { return fromPolicy, nil },
+			func() int64 { return count },
+			10)
+
+		Convey("Then the oracle is followed until the episode count is reached", func() {
+			next, _ := policy(nil, nil)
+			So(next, ShouldEqual, fromOracle)
+			count = 10
+			next, _ = policy(nil, nil)
+			So(next, ShouldEqual, fromPolicy)
+		})
+	})
+}
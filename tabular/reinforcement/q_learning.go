@@ -0,0 +1,120 @@
+package reinforcement
+
+import (
+	"context"
+	"sync/atomic"
+
+	"tabular/atomic_float"
+	. "tabular/grid_world"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+/*
+Implements Q-learning using a fixed number of workers to generate episodes which are sent to
+the estimator to update the action values, Q(s,a). Per the notes on alpha-MC, Q-learning is
+off-policy: the target of each update is the max-valued successor action, not the action the
+agent actually took, so actions determined by obsolete values do not invalidate the updates.
+Hence the estimator has no need to halt the agents; it merely consumes their episodes.
+
+State values are maintained as V(s) = max_a Q(s,a) after each update, such that the
+views, which only know about State.Value, continue to reflect training progress.
+
+Being off-policy, Q-learning may also learn from past transitions. If replay is configured,
+the estimator adds each episode's transitions to a replay buffer and updates from a batch
+sampled uniformly from it, which decorrelates the updates from the agents' latest episodes.
+*/
+func qLearningTrain(
+	ctx context.Context,
+	states [][][][]State,
+	nworkers int,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions) {
+
+	// Epsilon: the agent exploration/exploitation policy param, optionally decayed per episode count.
+	epsilonFn := newEpsilonSchedule(config, options.hyperParams)
+	// Eta: the learning rate, optionally decayed per episode count.
+	etaFn := newEtaSchedule(config, options.hyperParams)
+	// Gamma: the look-ahead parameter, or how much to value future state values.
+	gamma := options.hyperParams.Gamma
+	// The number of episodes processed by the estimator, shared with the agents' policies.
+	var episodeCount int64
+
+	rewards := config.GetRewards()
+	qvals := NewActionValues(states, getInitValue(config), config.GetKinematics())
+
+	collide := getCollisionFunc(config)
+	policyQMax := withSlip(states, newPolicyQMax(states, qvals, func() float64 {
+		return epsilonFn(atomic.LoadInt64(&episodeCount))
+	}, collide), getNoise(config), collide, qvals.kinematics)
+
+	replay, batchSize := newReplayBuffer(config, nworkers)
+
+	maxSteps := getMaxEpisodeLen(config)
+	workers := []<-chan *Episode{}
+	for i, rng := range newWorkerRands(config, nworkers) {
+		ch := agentWorker(ctx.Done(), options.routines, rng, options.genInitStates[i], policyQMax, rewards, maxSteps, options.samplers)
+		workers = append(workers, ch)
+	}
+	episodes := gated(ctx.Done(), options.gate, channerics.Merge(ctx.Done(), workers...))
+
+	// Estimator updates action values from agent experiences, one step at a time.
+	estimator := func(
+		etaFn func(int64) float64,
+		gamma *atomic_float.AtomicFloat64,
+		progressFn ProgressFunc) {
+		for episode := range episodes {
+			eta := etaFn(atomic.LoadInt64(&episodeCount))
+			options.gate.apply(func() {
+				// Set terminal states to the value of the reward for stepping into them, for display.
+				setTerminalValue(episode)
+
+				steps := *episode
+				if replay != nil {
+					for _, step := range steps {
+						replay.Add(step)
+					}
+					steps = replay.Sample(batchSize)
+				}
+				for i := range steps {
+					qLearningUpdate(&steps[i], qvals, eta, gamma.AtomicRead(), observer)
+				}
+			})
+			observer.EndEpisode(len(*episode))
+
+			// Hook: periodically do some other processing (publishing state values for views, etc.)
+			count := atomic.AddInt64(&episodeCount, 1)
+			progressFn(ctx, int(count))
+		}
+	}
+	options.spawn(func() {
+		estimator(etaFn, gamma, progressFn)
+	})
+}
+
+// qLearningUpdate applies the Q-learning update for the passed transition, and sets the
+// value of its state to its max action value. The target of a truncated step is its reward
+// alone, as for a terminal one.
+func qLearningUpdate(
+	step *Step,
+	qvals *ActionValues,
+	eta, gamma float64,
+	observer episodeObserver,
+) {
+	// Off-policy TD target: r + gamma * max_a' Q(s',a'). Terminal states have no successor actions.
+	target := step.Reward
+	if !is_terminal(step.Successor) && !step.Truncated {
+		_, maxQ := qvals.MaxAction(step.Successor)
+		target += gamma * maxQ
+	}
+	qval := qvals.Get(step.State, step.Action)
+	delta := eta * (target - qval.AtomicRead())
+	// Note: intentionally discard rejected deltas; add ops are serialized by the single estimator.
+	_, _ = qval.AtomicAdd(delta)
+	observer.Observe(delta)
+
+	_, maxQ := qvals.MaxAction(step.State)
+	step.State.Value.AtomicSet(maxQ)
+}
@@ -0,0 +1,50 @@
+package reinforcement
+
+import (
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQLearningUpdate(t *testing.T) {
+	Convey("Given a transition whose successor's action-values are known", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		qvals := NewActionValues(states, 0, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		action := &Action{Dvx: 0, Dvy: 1}
+		successor := &states[2][1][VelIndex(states, 1)][VelIndex(states, 1)]
+		finish := &states[5][6][VelIndex(states, 1)][VelIndex(states, 0)]
+		So(is_terminal(successor), ShouldBeFalse)
+		So(is_terminal(finish), ShouldBeTrue)
+
+		qvals.Get(state, action).AtomicSet(3)
+		// The max successor action-value is 4, the rest being 0 or -2.
+		qvals.Get(successor, &Action{Dvx: 1, Dvy: 0}).AtomicSet(4)
+		qvals.Get(successor, &Action{Dvx: -1, Dvy: 0}).AtomicSet(-2)
+		qvals.Get(finish, &Action{Dvx: 1, Dvy: 0}).AtomicSet(4)
+		eta, gamma := 0.25, 0.5
+
+		Convey("Then Q(s,a) moves by eta*(target-Q), the target bootstrapping from max_a Q(s',a)", func() {
+			step := &Step{State: state, Action: action, Reward: -1, Successor: successor}
+			qLearningUpdate(step, qvals, eta, gamma, episodeObservers{})
+			// target = -1 + 0.5*4 = 1; Q = 3 + 0.25*(1-3)
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 2.5)
+			So(state.Value.AtomicRead(), ShouldAlmostEqual, 2.5)
+		})
+
+		Convey("Then the target of a terminal successor is the reward alone", func() {
+			step := &Step{State: state, Action: action, Reward: -1, Successor: finish}
+			qLearningUpdate(step, qvals, eta, gamma, episodeObservers{})
+			// target = -1; Q = 3 + 0.25*(-1-3)
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 2)
+		})
+
+		Convey("Then the target of a truncated step is the reward alone", func() {
+			step := &Step{State: state, Action: action, Reward: -1, Successor: successor, Truncated: true}
+			qLearningUpdate(step, qvals, eta, gamma, episodeObservers{})
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package reinforcement
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// TestConcurrentTraining trains each algorithm briefly with multiple workers, while another
+// routine reads the state values as the views do. It is intended to be run with -race, which
+// reports any unsynchronized access along the agents' read-act-update path.
+func TestConcurrentTraining(t *testing.T) {
+	configs := map[string]*TrainingConfig{
+		AlgAlphaMonteCarlo:  {HyperParams: []HyperParameter{{Key: "nstep", Val: 2}, {Key: "oracleFraction", Val: 0.5}}},
+		"prioritized sweep": {Algorithm: AlgorithmConfig{Sweep: sweepPrioritized}},
+		"sharded estimator": {Algorithm: AlgorithmConfig{Estimators: 3}},
+		AlgQLearning:        {Algorithm: AlgorithmConfig{Kind: AlgQLearning}},
+		"qlearning replay":  {Algorithm: AlgorithmConfig{Kind: AlgQLearning}, Replay: ReplayConfig{Capacity: 100}},
+		AlgSarsa:            {Algorithm: AlgorithmConfig{Kind: AlgSarsa}},
+		AlgTDLambda:         {Algorithm: AlgorithmConfig{Kind: AlgTDLambda}},
+	}
+
+	for name, config := range configs {
+		Convey("Given "+name+" training with multiple workers", t, func() {
+			states := Convert(DebugTrack, DefaultKinematics)
+			timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Second)
+			Reset(cancelTimeout)
+			ctx, cancel := WithEpisodeBudget(timeout, 100)
+			Reset(cancel)
+
+			metrics := make(chan Metrics)
+			episodes := make(chan *Episode)
+			Train(ctx, states, config, 4, func(context.Context, int) {},
+				WithMetrics(metrics, 10),
+				WithEpisodeUpdates(episodes, 10),
+				WithGate(NewGate()),
+				WithTrainingLogger(NewJSONLogger(io.Discard), 10))
+
+			Convey("When the values are read concurrently", func() {
+				reads := 0
+				for ctx.Err() == nil {
+					select {
+					case <-metrics:
+					case episode := <-episodes:
+						for _, step := range *episode {
+							_ = step.State.Value.AtomicRead()
+						}
+					default:
+						greedyValueStats(states)
+						reads++
+					}
+				}
+
+				Convey("Then training completes its budget", func() {
+					So(timeout.Err(), ShouldBeNil)
+					So(reads, ShouldBeGreaterThan, 0)
+				})
+			})
+		})
+	}
+}
+
+// TestStepAfterPause steps a trainer immediately after pausing its run, while its estimator may
+// still be applying an episode it pulled before the pause. It is intended to be run with -race.
+func TestStepAfterPause(t *testing.T) {
+	Convey("Given a trainer whose run is training with multiple workers", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		gate := NewGate()
+		trainer := NewTrainer(ctx, Convert(DebugTrack, DefaultKinematics), &TrainingConfig{}, 4, func(context.Context, int) {}, WithGate(gate))
+		So(trainer.Start(), ShouldBeNil)
+
+		Convey("Then it may be stepped as soon as it is paused", func() {
+			for i := 0; i < 20; i++ {
+				time.Sleep(time.Millisecond)
+				gate.Pause()
+				_, err := trainer.Step()
+				So(err, ShouldBeNil)
+				gate.Resume()
+			}
+		})
+
+		Convey("Then a step waits for the episode the estimator is applying", func() {
+			applying, release := make(chan struct{}), make(chan struct{})
+			// Emulates the run's estimator, mid-episode when the run is paused.
+			go gate.apply(func() {
+				close(applying)
+				<-release
+			})
+			<-applying
+			gate.Pause()
+
+			stepped := make(chan error, 1)
+			go func() {
+				_, err := trainer.Step()
+				stepped <- err
+			}()
+			select {
+			case <-stepped:
+				So("step did not wait", ShouldEqual, "step waited")
+			case <-time.After(50 * time.Millisecond):
+			}
+			close(release)
+			So(<-stepped, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,21 @@
+package reinforcement
+
+import (
+	"math/rand"
+	"time"
+)
+
+// newWorkerRands returns an independent random number generator for each of the passed number
+// of workers, such that workers do not contend on the global rand mutex. The generators are
+// seeded from the seed hyperparameter, or from the time if omitted, per the original behavior.
+// Given a fixed seed each worker's sequence is reproducible; the overall training run is only
+// fully reproducible with a single worker, since the order in which the estimator receives the
+// workers' experiences is subject to goroutine scheduling.
+func newWorkerRands(config *TrainingConfig, nworkers int) []*rand.Rand {
+	seed := int64(config.GetHyperParamOrDefault("seed", float64(time.Now().UnixNano())))
+	rngs := make([]*rand.Rand, nworkers)
+	for i := range rngs {
+		rngs[i] = rand.New(rand.NewSource(seed + int64(i)))
+	}
+	return rngs
+}
@@ -0,0 +1,88 @@
+package reinforcement
+
+import (
+	"math/rand"
+	"sync"
+
+	. "tabular/grid_world"
+)
+
+// ReplayConfig optionally describes an experience replay buffer for off-policy algorithms:
+// the estimator adds each episode's transitions to a buffer of the last Capacity transitions,
+// then updates from BatchSize transitions sampled uniformly from it, rather than consuming
+// each episode exactly once. Replay is disabled when Capacity is not positive.
+type ReplayConfig struct {
+	Capacity  int `mapstructure:"capacity" yaml:"capacity"`
+	BatchSize int `mapstructure:"batchSize" yaml:"batchSize"`
+}
+
+// The number of transitions sampled per episode if the batch size is omitted.
+const defaultReplayBatchSize = 64
+
+// ReplayBuffer is a fixed-capacity ring of transitions, from which the oldest are evicted.
+type ReplayBuffer struct {
+	mu    sync.Mutex
+	steps []Step
+	// The index at which the next transition is added, which wraps once the buffer is full.
+	next int
+	rng  *rand.Rand
+}
+
+// NewReplayBuffer returns an empty buffer of the passed capacity, which must be positive.
+// Samples are drawn from the passed rng, which must not be shared.
+func NewReplayBuffer(capacity int, rng *rand.Rand) *ReplayBuffer {
+	return &ReplayBuffer{
+		steps: make([]Step, 0, capacity),
+		rng:   rng,
+	}
+}
+
+// Add adds the passed transition, evicting the oldest if the buffer is full.
+func (rb *ReplayBuffer) Add(step Step) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if len(rb.steps) < cap(rb.steps) {
+		rb.steps = append(rb.steps, step)
+	} else {
+		rb.steps[rb.next] = step
+	}
+	rb.next = (rb.next + 1) % cap(rb.steps)
+}
+
+// Len returns the number of transitions in the buffer.
+func (rb *ReplayBuffer) Len() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return len(rb.steps)
+}
+
+// Sample returns n transitions drawn uniformly, with replacement, from the buffer.
+// Returns nil if the buffer is empty.
+func (rb *ReplayBuffer) Sample(n int) (batch []Step) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if len(rb.steps) == 0 {
+		return nil
+	}
+	batch = make([]Step, n)
+	for i := range batch {
+		batch[i] = rb.steps[rb.rng.Intn(len(rb.steps))]
+	}
+	return
+}
+
+// newReplayBuffer returns the replay buffer per the config, or nil if replay is disabled.
+// Its rng is seeded after those of the passed number of workers.
+func newReplayBuffer(config *TrainingConfig, nworkers int) (buffer *ReplayBuffer, batchSize int) {
+	if config.Replay.Capacity <= 0 {
+		return nil, 0
+	}
+	batchSize = config.Replay.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultReplayBatchSize
+	}
+	rng := newWorkerRands(config, nworkers+1)[nworkers]
+	return NewReplayBuffer(config.Replay.Capacity, rng), batchSize
+}
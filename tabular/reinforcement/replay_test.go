@@ -0,0 +1,75 @@
+package reinforcement
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	Convey("Given a full replay buffer", t, func() {
+		capacity := 10
+		buffer := NewReplayBuffer(capacity, rand.New(rand.NewSource(1)))
+		for i := 0; i < capacity; i++ {
+			buffer.Add(Step{Reward: float64(i)})
+		}
+
+		Convey("Then samples are drawn uniformly from its transitions", func() {
+			n := 100000
+			counts := map[float64]int{}
+			for _, step := range buffer.Sample(n) {
+				counts[step.Reward]++
+			}
+			So(len(counts), ShouldEqual, capacity)
+			expected := float64(n) / float64(capacity)
+			for _, count := range counts {
+				So(math.Abs(float64(count)-expected), ShouldBeLessThan, 0.05*expected)
+			}
+		})
+
+		Convey("When more transitions are added", func() {
+			for i := capacity; i < capacity+7; i++ {
+				buffer.Add(Step{Reward: float64(i)})
+			}
+
+			Convey("Then the oldest are evicted", func() {
+				So(buffer.Len(), ShouldEqual, capacity)
+				counts := map[float64]int{}
+				for _, step := range buffer.Sample(10000) {
+					counts[step.Reward]++
+				}
+				So(len(counts), ShouldEqual, capacity)
+				for reward := range counts {
+					So(reward, ShouldBeGreaterThanOrEqualTo, 7)
+					So(reward, ShouldBeLessThan, capacity+7)
+				}
+			})
+		})
+	})
+
+	Convey("Given an empty replay buffer", t, func() {
+		buffer := NewReplayBuffer(5, rand.New(rand.NewSource(1)))
+
+		Convey("Then nothing is sampled", func() {
+			So(buffer.Len(), ShouldEqual, 0)
+			So(buffer.Sample(3), ShouldBeNil)
+		})
+	})
+
+	Convey("Given the replay config", t, func() {
+		Convey("Then replay is disabled by default", func() {
+			buffer, _ := newReplayBuffer(&TrainingConfig{}, 2)
+			So(buffer, ShouldBeNil)
+		})
+
+		Convey("Then the batch size defaults when omitted", func() {
+			buffer, batchSize := newReplayBuffer(&TrainingConfig{Replay: ReplayConfig{Capacity: 100}}, 2)
+			So(buffer, ShouldNotBeNil)
+			So(batchSize, ShouldEqual, defaultReplayBatchSize)
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package reinforcement
+
+import (
+	"fmt"
+
+	. "tabular/grid_world"
+)
+
+// The reward presets, per TrainingConfig.RewardPreset.
+const (
+	// RewardsShaped, the default, charges every step, such that shorter paths are preferred, and
+	// charges collisions and sand more than steps: collision -5, step -1, sand -3, finish 0.
+	RewardsShaped = "shaped"
+	// RewardsSparse is the textbook setup, which only rewards the outcome of an episode: collision
+	// -1, step 0, sand 0, finish +1. Shorter paths are then only preferred when gamma < 1.
+	RewardsSparse = "sparse"
+)
+
+// rewardPresets are the reward hyper-params of each preset. The tiered finish rewards default to
+// the finish reward, so are not set.
+var rewardPresets = map[string]map[string]float64{
+	RewardsShaped: {
+		"collisionReward": COLLISION_REWARD,
+		"stepReward":      STEP_REWARD,
+		"sandReward":      SAND_REWARD,
+		"finishReward":    FINISH_REWARD,
+	},
+	RewardsSparse: {
+		"collisionReward": -1,
+		"stepReward":      0,
+		"sandReward":      0,
+		"finishReward":    1,
+	},
+}
+
+// validateRewardPreset returns an error if the reward preset is unknown.
+func (cfg *TrainingConfig) validateRewardPreset() error {
+	if _, ok := rewardPresets[cfg.RewardPreset]; ok || cfg.RewardPreset == "" {
+		return nil
+	}
+	return fmt.Errorf("unknown rewards preset %q, expected %q or %q",
+		cfg.RewardPreset, RewardsShaped, RewardsSparse)
+}
+
+// rewardPresetDefault returns the default of the passed hyper-param per the reward preset, if the
+// preset has one. Explicit hyper-params override the preset's defaults.
+func (cfg *TrainingConfig) rewardPresetDefault(param string) (float64, bool) {
+	val, ok := rewardPresets[cfg.RewardPreset][param]
+	return val, ok
+}
@@ -0,0 +1,54 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRewardPresets(t *testing.T) {
+	Convey("Given each reward preset", t, func() {
+		for _, tc := range []struct {
+			preset                  string
+			collision, step, finish float64
+		}{
+			{preset: "", collision: COLLISION_REWARD, step: STEP_REWARD, finish: FINISH_REWARD},
+			{preset: RewardsShaped, collision: -5, step: -1, finish: 0},
+			{preset: RewardsSparse, collision: -1, step: 0, finish: 1},
+		} {
+			config := &TrainingConfig{RewardPreset: tc.preset}
+
+			Convey("Then the "+tc.preset+" preset maps to its documented rewards", func() {
+				So(config.validateRewardPreset(), ShouldBeNil)
+				rewards := config.GetRewards()
+				So(rewards.Collision, ShouldEqual, tc.collision)
+				So(rewards.Step, ShouldEqual, tc.step)
+				So(rewards.Finish, ShouldEqual, tc.finish)
+				So(rewards.FinishTiers[FINISH_TIER_2], ShouldEqual, tc.finish)
+			})
+		}
+
+		Convey("Then explicit reward hyper-params override the preset's", func() {
+			config := &TrainingConfig{
+				RewardPreset: RewardsSparse,
+				HyperParams:  []HyperParameter{{Key: "finishReward", Val: 10}},
+			}
+			rewards := config.GetRewards()
+			So(rewards.Finish, ShouldEqual, 10)
+			So(rewards.Collision, ShouldEqual, -1)
+			So(getInitValue(config), ShouldEqual, -1)
+		})
+	})
+
+	Convey("Given an unknown reward preset", t, func() {
+		config := &TrainingConfig{RewardPreset: "dense"}
+
+		Convey("Then it is rejected", func() {
+			So(config.validateRewardPreset(), ShouldNotBeNil)
+			So(TrainSync(context.Background(), Convert(DebugTrack, DefaultKinematics), config, 0), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package reinforcement
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"tabular/atomic_float"
+	. "tabular/grid_world"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+/*
+Implements SARSA, on-policy TD control, using a fixed number of workers. Unlike alpha-MC, which
+propagates rewards backward over whole episodes, SARSA updates per-step using the action the agent
+actually took in the successor state:
+
+	Q(s,a) += eta * (r + gamma * Q(s',a') - Q(s,a))
+
+Hence the agents emit (s,a,r,s',a') steps as they are generated rather than full episodes. Being
+on-policy, the usual caveat about agents acting on values being updated underneath them applies;
+this is intended as a baseline for comparing convergence against Q-learning.
+*/
+func sarsaTrain(
+	ctx context.Context,
+	states [][][][]State,
+	nworkers int,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions) {
+
+	// Epsilon: the agent exploration/exploitation policy param, optionally decayed per episode count.
+	epsilonFn := newEpsilonSchedule(config, options.hyperParams)
+	// Eta: the learning rate, optionally decayed per episode count.
+	etaFn := newEtaSchedule(config, options.hyperParams)
+	// Gamma: the look-ahead parameter, or how much to value future state values.
+	gamma := options.hyperParams.Gamma
+	// The number of episodes processed by the estimator, shared with the agents' policies.
+	var episodeCount int64
+
+	rewards := config.GetRewards()
+	qvals := NewActionValues(states, getInitValue(config), config.GetKinematics())
+
+	collide := getCollisionFunc(config)
+	policyQMax := withSlip(states, newPolicyQMax(states, qvals, func() float64 {
+		return epsilonFn(atomic.LoadInt64(&episodeCount))
+	}, collide), getNoise(config), collide, qvals.kinematics)
+
+	maxSteps := getMaxEpisodeLen(config)
+	workers := []<-chan *Step{}
+	for i, rng := range newWorkerRands(config, nworkers) {
+		ch := sarsaAgentWorker(ctx.Done(), options.routines, rng, options.genInitStates[i], policyQMax, rewards, maxSteps, options.samplers)
+		workers = append(workers, ch)
+	}
+	steps := gated(ctx.Done(), options.gate, channerics.Merge(ctx.Done(), workers...))
+
+	// Estimator updates action values per step, as steps arrive.
+	estimator := func(
+		etaFn func(int64) float64,
+		gamma *atomic_float.AtomicFloat64,
+		progressFn ProgressFunc) {
+		// Steps of different agents' episodes interleave, so observations are made over the
+		// steps between consecutive terminal or truncated steps rather than strictly per episode.
+		episodeSteps := 0
+		for step := range steps {
+			episodeSteps++
+			eta := etaFn(atomic.LoadInt64(&episodeCount))
+			options.gate.apply(func() {
+				sarsaUpdate(step, qvals, eta, gamma.AtomicRead(), observer)
+			})
+
+			if is_terminal(step.Successor) || step.Truncated {
+				observer.EndEpisode(episodeSteps)
+				episodeSteps = 0
+				// Hook: periodically do some other processing (publishing state values for views, etc.)
+				count := atomic.AddInt64(&episodeCount, 1)
+				progressFn(ctx, int(count))
+			}
+		}
+	}
+	options.spawn(func() {
+		estimator(etaFn, gamma, progressFn)
+	})
+}
+
+// sarsaUpdate applies the SARSA update for the passed transition, and sets the value of its
+// state to its max action value. Truncated steps retain their successor action, hence
+// bootstrap from it; terminal steps have none, so their target is the reward alone.
+func sarsaUpdate(
+	step *Step,
+	qvals *ActionValues,
+	eta, gamma float64,
+	observer episodeObserver,
+) {
+	// On-policy TD target: r + gamma * Q(s',a'). Terminal states have no successor action.
+	target := step.Reward
+	if step.SuccessorAction != nil {
+		target += gamma * qvals.Get(step.Successor, step.SuccessorAction).AtomicRead()
+	}
+	qval := qvals.Get(step.State, step.Action)
+	delta := eta * (target - qval.AtomicRead())
+	// Note: intentionally discard rejected deltas; add ops are serialized by the single estimator.
+	_, _ = qval.AtomicAdd(delta)
+	observer.Observe(delta)
+
+	// Maintain V(s) = max_a Q(s,a) for the views.
+	_, maxQ := qvals.MaxAction(step.State)
+	step.State.Value.AtomicSet(maxQ)
+
+	// Set terminal states to the value of the reward for stepping into them, for display.
+	if is_terminal(step.Successor) && !step.Truncated {
+		step.Successor.Value.AtomicSet(step.Reward)
+	}
+}
+
+// sarsaAgentWorker deploys an agent that emits (s,a,r,s',a') steps as it generates episodes
+// using the passed policy, until done is closed. The successor action a' is selected before
+// the step is sent, and is then the action taken from s'. Episodes are truncated after maxSteps
+// steps, whose last step retains its successor action for bootstrapping. Completed episodes
+// are offered to the passed samplers for publication. The agent's random choices are drawn from the
+// passed rng, which must not be shared with other agents.
+func sarsaAgentWorker(
+	done <-chan struct{},
+	routines *sync.WaitGroup,
+	rng *rand.Rand,
+	genInitState func(*rand.Rand) *State,
+	policyFn func(*State, *rand.Rand) (*State, *Action),
+	rewards *Rewards,
+	maxSteps int,
+	samplers episodeSamplers) <-chan *Step {
+
+	steps := make(chan *Step)
+	routines.Add(1)
+	go func() {
+		defer routines.Done()
+		defer close(steps)
+
+		for {
+			// done-guard
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			state := genInitState(rng)
+			successor, action := policyFn(state, rng)
+			// Steps are only accumulated for sampling, since the estimator consumes them individually.
+			var episode Episode
+			for n := 1; ; n++ {
+				state.RecordVisit()
+				step := &Step{
+					State:     state,
+					Action:    action,
+					Reward:    getReward(successor, rewards),
+					Successor: successor,
+				}
+				// Select a' in s', if any; its successor is carried forward to the next step.
+				var nextSuccessor *State
+				if !is_terminal(successor) {
+					nextSuccessor, step.SuccessorAction = policyFn(successor, rng)
+					step.Truncated = n == maxSteps
+				}
+
+				select {
+				case steps <- step:
+				case <-done:
+					return
+				}
+
+				if len(samplers) > 0 {
+					episode = append(episode, *step)
+				}
+				if nextSuccessor == nil || step.Truncated {
+					samplers.sample(&episode)
+					break
+				}
+				state, action, successor = successor, step.SuccessorAction, nextSuccessor
+			}
+		}
+	}()
+	return steps
+}
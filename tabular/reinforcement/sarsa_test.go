@@ -0,0 +1,55 @@
+package reinforcement
+
+import (
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSarsaUpdate(t *testing.T) {
+	Convey("Given a transition whose successor's action-values are known", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		qvals := NewActionValues(states, 0, DefaultKinematics)
+		state := &states[1][1][VelIndex(states, 1)][VelIndex(states, 0)]
+		action := &Action{Dvx: 0, Dvy: 1}
+		successor := &states[2][1][VelIndex(states, 1)][VelIndex(states, 1)]
+		finish := &states[5][6][VelIndex(states, 1)][VelIndex(states, 0)]
+		So(is_terminal(finish), ShouldBeTrue)
+
+		qvals.Get(state, action).AtomicSet(3)
+		// The successor action taken is valued -2, though the max successor action-value is 4.
+		taken := &Action{Dvx: -1, Dvy: 0}
+		qvals.Get(successor, &Action{Dvx: 1, Dvy: 0}).AtomicSet(4)
+		qvals.Get(successor, taken).AtomicSet(-2)
+		eta, gamma := 0.25, 0.5
+
+		Convey("Then the target bootstraps from the successor action taken, Q(s',a'), not the max", func() {
+			step := &Step{State: state, Action: action, Reward: -1, Successor: successor, SuccessorAction: taken}
+			sarsaUpdate(step, qvals, eta, gamma, episodeObservers{})
+			// target = -1 + 0.5*-2 = -2; Q = 3 + 0.25*(-2-3), whereas Q-learning's max target yields 2.5.
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 1.75)
+
+			qLearned := NewActionValues(states, 0, DefaultKinematics)
+			qLearned.Get(state, action).AtomicSet(3)
+			qLearned.Get(successor, &Action{Dvx: 1, Dvy: 0}).AtomicSet(4)
+			qLearningUpdate(&Step{State: state, Action: action, Reward: -1, Successor: successor}, qLearned, eta, gamma, episodeObservers{})
+			So(qLearned.Get(state, action).AtomicRead(), ShouldAlmostEqual, 2.5)
+		})
+
+		Convey("Then the target of a terminal successor, which has no action, is the reward alone", func() {
+			step := &Step{State: state, Action: action, Reward: 5, Successor: finish}
+			sarsaUpdate(step, qvals, eta, gamma, episodeObservers{})
+			// target = 5; Q = 3 + 0.25*(5-3)
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 3.5)
+			So(finish.Value.AtomicRead(), ShouldEqual, 5)
+		})
+
+		Convey("Then a truncated step bootstraps from its retained successor action", func() {
+			step := &Step{State: state, Action: action, Reward: -1, Successor: successor, SuccessorAction: taken, Truncated: true}
+			sarsaUpdate(step, qvals, eta, gamma, episodeObservers{})
+			So(qvals.Get(state, action).AtomicRead(), ShouldAlmostEqual, 1.75)
+		})
+	})
+}
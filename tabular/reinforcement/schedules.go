@@ -0,0 +1,54 @@
+package reinforcement
+
+import "math"
+
+// newEpsilonSchedule returns the agent exploration rate as a function of the episode count,
+// annealing exploration over time: epsilon_t = max(epsilonMin, epsilon * epsilonDecay^t).
+// Decay is optional; when epsilonDecay is omitted (or 1.0) epsilon is constant, per the
+// original behavior. Epsilon is read from the passed hyper-parameters on every call.
+// The first warmupEpisodes episodes (default 0) are purely random, epsilon 1, since the values
+// are still uniform and the greedy actions arbitrary; the schedule then starts from t = 0.
+func newEpsilonSchedule(config *TrainingConfig, hyperParams *HyperParams) func(episodeCount int64) float64 {
+	decay := config.GetHyperParamOrDefault("epsilonDecay", 1.0)
+	epsilonMin := config.GetHyperParamOrDefault("epsilonMin", 0.0)
+	warmup := int64(config.GetHyperParamOrDefault("warmupEpisodes", 0))
+
+	schedule := func(_ int64) float64 {
+		return hyperParams.Epsilon.AtomicRead()
+	}
+	if decay != 1.0 {
+		schedule = func(episodeCount int64) float64 {
+			epsilon := hyperParams.Epsilon.AtomicRead()
+			return math.Max(epsilonMin, epsilon*math.Pow(decay, float64(episodeCount)))
+		}
+	}
+	if warmup == 0 {
+		return schedule
+	}
+
+	return func(episodeCount int64) float64 {
+		if episodeCount < warmup {
+			return 1
+		}
+		return schedule(episodeCount - warmup)
+	}
+}
+
+// newEtaSchedule returns the learning rate as a function of the episode count, per classical
+// Robbins-Monro step sizes: eta_t = eta / (1 + etaDecay * t). The shrinking step size keeps
+// repeated passes from overwriting converged values. Decay is optional; when etaDecay is
+// omitted (or 0) eta is constant, per the original behavior. Eta is read from the passed
+// hyper-parameters on every call.
+func newEtaSchedule(config *TrainingConfig, hyperParams *HyperParams) func(episodeCount int64) float64 {
+	decay := config.GetHyperParamOrDefault("etaDecay", 0.0)
+
+	if decay == 0.0 {
+		return func(_ int64) float64 {
+			return hyperParams.Eta.AtomicRead()
+		}
+	}
+
+	return func(episodeCount int64) float64 {
+		return hyperParams.Eta.AtomicRead() / (1.0 + decay*float64(episodeCount))
+	}
+}
@@ -0,0 +1,139 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEpsilonSchedule(t *testing.T) {
+	Convey("Given an epsilon decay and minimum", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "epsilon", Val: 0.8},
+			{Key: "epsilonDecay", Val: 0.5},
+			{Key: "epsilonMin", Val: 0.1},
+		}}
+		epsilon := newEpsilonSchedule(config, NewHyperParams(config))
+
+		Convey("Then epsilon decays geometrically toward the minimum, and no further", func() {
+			So(epsilon(0), ShouldAlmostEqual, 0.8)
+			So(epsilon(1), ShouldAlmostEqual, 0.4)
+			So(epsilon(2), ShouldAlmostEqual, 0.2)
+			So(epsilon(3), ShouldAlmostEqual, 0.1)
+			So(epsilon(4), ShouldEqual, 0.1)
+			So(epsilon(1000), ShouldEqual, 0.1)
+		})
+	})
+
+	Convey("Given no epsilon decay", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "epsilon", Val: 0.3}}}
+		hyperParams := NewHyperParams(config)
+		epsilon := newEpsilonSchedule(config, hyperParams)
+
+		Convey("Then epsilon is constant, and follows the hyper-parameter as it is set", func() {
+			So(epsilon(0), ShouldEqual, 0.3)
+			So(epsilon(1000), ShouldEqual, 0.3)
+			hyperParams.Epsilon.AtomicSet(0.05)
+			So(epsilon(1000), ShouldEqual, 0.05)
+		})
+	})
+}
+
+func TestEtaSchedule(t *testing.T) {
+	Convey("Given an eta decay", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "eta", Val: 0.6},
+			{Key: "etaDecay", Val: 0.5},
+		}}
+		eta := newEtaSchedule(config, NewHyperParams(config))
+
+		Convey("Then eta_t = eta / (1 + decay*t)", func() {
+			for _, count := range []int64{0, 1, 2, 10, 1000} {
+				So(eta(count), ShouldAlmostEqual, 0.6/(1+0.5*float64(count)))
+			}
+			So(eta(2), ShouldAlmostEqual, 0.3)
+		})
+	})
+
+	Convey("Given no eta decay", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "eta", Val: 0.6}}}
+		hyperParams := NewHyperParams(config)
+		eta := newEtaSchedule(config, hyperParams)
+
+		Convey("Then eta is constant, and follows the hyper-parameter as it is set", func() {
+			So(eta(0), ShouldEqual, 0.6)
+			So(eta(1000), ShouldEqual, 0.6)
+			hyperParams.Eta.AtomicSet(0.1)
+			So(eta(1000), ShouldEqual, 0.1)
+		})
+	})
+}
+
+func TestEpsilonWarmup(t *testing.T) {
+	Convey("Given warmup episodes", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{
+			{Key: "epsilon", Val: 0.2},
+			{Key: "warmupEpisodes", Val: 100},
+		}}
+
+		Convey("Then epsilon is 1 during warmup, and the configured epsilon after", func() {
+			epsilon := newEpsilonSchedule(config, NewHyperParams(config))
+			So(epsilon(0), ShouldEqual, 1)
+			So(epsilon(99), ShouldEqual, 1)
+			So(epsilon(100), ShouldEqual, 0.2)
+			So(epsilon(10000), ShouldEqual, 0.2)
+		})
+
+		Convey("Then the epsilon decay begins once warmup completes", func() {
+			config.HyperParams = append(config.HyperParams, HyperParameter{Key: "epsilonDecay", Val: 0.5})
+			epsilon := newEpsilonSchedule(config, NewHyperParams(config))
+			So(epsilon(99), ShouldEqual, 1)
+			So(epsilon(100), ShouldEqual, 0.2)
+			So(epsilon(101), ShouldEqual, 0.1)
+		})
+
+		Convey("Then greedy agents explore randomly during warmup", func() {
+			// Greedy agents from pessimistic initial values only revisit the states they have already
+			// learned, so random warmup episodes visit more of the states in the same number of episodes.
+			visited := func(warmup float64) (n int) {
+				config := &TrainingConfig{HyperParams: []HyperParameter{
+					{Key: "epsilon", Val: 0},
+					{Key: "seed", Val: 7},
+					{Key: "warmupEpisodes", Val: warmup},
+				}}
+				states := Convert(DebugTrack, DefaultKinematics)
+				ctx, cancel := WithEpisodeBudget(context.Background(), 200)
+				defer cancel()
+				So(TrainSync(ctx, states, config, 0), ShouldBeNil)
+				init := getInitValue(config)
+				Visit(states, func(s *State) {
+					if !is_terminal(s) && s.Value.AtomicRead() != init {
+						n++
+					}
+				})
+				return
+			}
+			So(visited(200), ShouldBeGreaterThan, visited(0))
+		})
+	})
+
+	Convey("Given no warmup episodes", t, func() {
+		config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "epsilon", Val: 0.2}}}
+
+		Convey("Then epsilon is as configured from the first episode", func() {
+			So(newEpsilonSchedule(config, NewHyperParams(config))(0), ShouldEqual, 0.2)
+		})
+	})
+
+	Convey("Given negative or fractional warmup episodes", t, func() {
+		Convey("Then they are rejected", func() {
+			for _, val := range []float64{-1, 2.5} {
+				config := &TrainingConfig{HyperParams: []HyperParameter{{Key: "warmupEpisodes", Val: val}}}
+				So(ValidateHyperParams(config), ShouldNotBeNil)
+			}
+		})
+	})
+}
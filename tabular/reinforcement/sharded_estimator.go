@@ -0,0 +1,135 @@
+package reinforcement
+
+import (
+	. "tabular/grid_world"
+)
+
+/*
+Given enough agents the single alpha-MC estimator is the bottleneck of training, since it alone
+writes the state values. Per the design notes, updates may instead be separated by disjoint state
+sets: each of M shard estimators owns the states whose x-coordinate modulo M is its index, and only
+it writes their values, so shards never contend. A dispatcher computes the targets of each episode
+and routes the update of each step to the shard owning its state. A collector then observes the
+shards' deltas and completes each episode once every shard has applied its part.
+
+Each shard applies its parts in the order the episodes were dispatched, and every visit to a state
+is routed to the same shard, so each state receives the same updates in the same order as from the
+single estimator. The approximation is in the targets of cross-partition steps. The shards drift
+apart by up to shardBacklog episodes, so the dispatcher computes an episode's n-step returns from
+the values of states owned by other shards, which may not yet reflect the prior episodes, or may
+already reflect later ones. Full Monte Carlo returns (nstep 0) depend only on rewards, so are
+unaffected, except that the eta schedule advances per completed episode, which lags those
+dispatched. Prioritized sweeping updates states across partitions, so is not supported.
+*/
+
+// shardBacklog is the number of episodes by which shard estimators may drift apart.
+const shardBacklog = 8
+
+// shardWork is a shard's part of an episode: the indices of the steps whose states it owns, in the
+// order they are applied, and the targets of every step.
+type shardWork struct {
+	episode *Episode
+	steps   []int
+	eta     float64
+	returns []float64
+}
+
+// shardResult is the deltas applied by a shard for its part of an episode.
+type shardResult struct {
+	episode *Episode
+	deltas  []float64
+}
+
+// shardedEstimate updates the state values from the passed episodes using the passed number of
+// shard estimators, per the passed estimator's targets, until the episodes are closed or done is.
+// The estimator's observer and progress hook are only called by a single collector routine.
+func shardedEstimate(
+	done <-chan struct{},
+	episodes <-chan *Episode,
+	est *mcEstimator,
+	numShards int,
+	options *trainOptions,
+) {
+	works := make([]chan shardWork, numShards)
+	results := make([]chan shardResult, numShards)
+	for i := range works {
+		works[i] = make(chan shardWork, shardBacklog)
+		results[i] = make(chan shardResult, shardBacklog)
+		work, result := works[i], results[i]
+		options.spawn(func() {
+			applyShard(done, options.gate, work, result)
+		})
+	}
+
+	// Dispatcher routes the steps of each episode to the shards owning their states.
+	options.spawn(func() {
+		defer func() {
+			for _, work := range works {
+				close(work)
+			}
+		}()
+
+		for episode := range episodes {
+			var eta float64
+			var returns []float64
+			// Computing the targets sets the value of the episode's terminal state.
+			options.gate.apply(func() { eta, returns = est.targets(episode) })
+			parts := make([]shardWork, numShards)
+			for _, t := range Rev(len(*episode)) {
+				shard := (*episode)[t].State.X % numShards
+				parts[shard].steps = append(parts[shard].steps, t)
+			}
+			// Every shard receives a part, possibly empty, such that the collector completes
+			// an episode upon receiving a result from each shard.
+			for i := range parts {
+				parts[i].episode, parts[i].eta, parts[i].returns = episode, eta, returns
+				select {
+				case works[i] <- parts[i]:
+				case <-done:
+					return
+				}
+			}
+		}
+	})
+
+	// Collector observes the shards' deltas per episode, in the order dispatched.
+	options.spawn(func() {
+		for {
+			var episode *Episode
+			for _, result := range results {
+				res, ok := <-result
+				if !ok {
+					return
+				}
+				for _, delta := range res.deltas {
+					est.observer.Observe(delta)
+				}
+				episode = res.episode
+			}
+			est.endEpisode(episode)
+		}
+	})
+}
+
+// applyShard applies the updates of each shard work, while not held by the passed gate, until the
+// works are closed or done is.
+func applyShard(done <-chan struct{}, gate *Gate, works <-chan shardWork, results chan<- shardResult) {
+	defer close(results)
+
+	for work := range works {
+		res := shardResult{
+			episode: work.episode,
+			deltas:  make([]float64, 0, len(work.steps)),
+		}
+		gate.apply(func() {
+			for _, t := range work.steps {
+				res.deltas = append(res.deltas, mcUpdate(&(*work.episode)[t], work.eta, work.returns[t]))
+			}
+		})
+		select {
+		case results <- res:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package reinforcement
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShardedEstimate(t *testing.T) {
+	Convey("Given episodes of random play", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		config := &TrainingConfig{}
+		kinematics := config.GetKinematics()
+		collide := getCollisionFunc(config)
+		randomPolicy := func(state *State, rng *rand.Rand) (*State, *Action) {
+			action := getRandAction(state, rng, kinematics)
+			return getSuccessor(states, state, action, collide, kinematics), action
+		}
+		randomStart := newStartStateFuncs(states, config, 1)[0]
+		rng := rand.New(rand.NewSource(1))
+		episodes := []*Episode{}
+		for i := 0; i < 200; i++ {
+			episodes = append(episodes, generateEpisode(rng, randomStart, randomPolicy, config.GetRewards(), 1000))
+		}
+
+		// Returns an estimator of freshly initialized state values.
+		newEstimator := func() *mcEstimator {
+			initStateVals(states, config.GetRewards().Collision)
+			_, est := newAlphaMonteCarlo(context.Background(), states, 1, config, func(context.Context, int) {},
+				episodeObservers{}, &trainOptions{hyperParams: NewHyperParams(config)})
+			return est
+		}
+
+		Convey("When they are estimated by multiple shards", func() {
+			est := newEstimator()
+			for _, episode := range episodes {
+				est.estimate(episode)
+			}
+			single := snapshotValues(states)
+
+			est = newEstimator()
+			input := make(chan *Episode)
+			routines := &sync.WaitGroup{}
+			shardedEstimate(make(chan struct{}), input, est, 3, &trainOptions{routines: routines})
+			for _, episode := range episodes {
+				input <- episode
+			}
+			close(input)
+			routines.Wait()
+
+			Convey("Then each state receives the same updates as from the single estimator", func() {
+				So(est.count(), ShouldEqual, len(episodes))
+				So(snapshotValues(states), ShouldResemble, single)
+			})
+		})
+	})
+
+	Convey("Given multiple estimators for other than concurrent alpha-monte-carlo", t, func() {
+		configs := []AlgorithmConfig{
+			{Kind: AlgQLearning, Estimators: 2},
+			{Sequential: true, Estimators: 2},
+			{Sweep: sweepPrioritized, Estimators: 2},
+			{Estimators: -1},
+		}
+
+		Convey("Then they are rejected", func() {
+			for _, alg := range configs {
+				So(alg.validate(), ShouldNotBeNil)
+			}
+			So((&AlgorithmConfig{Estimators: 2}).validate(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package reinforcement
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	. "tabular/grid_world"
+)
+
+// Start state distributions, selected via the startDistribution config option.
+const (
+	// startDistributionUniform starts every agent's episodes from any START, TRACK, or SAND cell.
+	startDistributionUniform = "uniform"
+	// startDistributionPartitioned divides the START, TRACK, and SAND cells among the agents, such
+	// that each agent starts its episodes from a disjoint region. Per the design notes, this
+	// reduces the agents' interference by ensuring their trajectories are not totally overlapping.
+	startDistributionPartitioned = "partitioned"
+)
+
+// Start velocity modes, selected via the startVelocity config option.
+const (
+	// startVelocityRandom starts episodes from any START, TRACK, or SAND cell, with a random
+	// non-zero velocity at which the cell is reachable from a START cell at rest.
+	startVelocityRandom = "random"
+	// startVelocityZero starts episodes at rest from START cells only, as in a real race.
+	startVelocityZero = "zero-only"
+)
+
+// validateStartVelocity returns an error if the start velocity mode is unknown.
+func (cfg *TrainingConfig) validateStartVelocity() error {
+	switch cfg.StartVelocity {
+	case "", startVelocityRandom, startVelocityZero:
+		return nil
+	}
+	return fmt.Errorf("unknown startVelocity %q, expected %q or %q",
+		cfg.StartVelocity, startVelocityRandom, startVelocityZero)
+}
+
+// newStartStateFuncs returns a function generating the initial state of each episode for each
+// of the passed number of workers, per the start distribution and start velocity mode. States
+// are drawn uniformly from the worker's start states, per startStates. Defaults to the uniform
+// distribution and random velocities.
+func newStartStateFuncs(
+	states [][][][]State,
+	config *TrainingConfig,
+	nworkers int,
+) (genInitStates []func(*rand.Rand) *State) {
+	zeroOnly := config.StartVelocity == startVelocityZero
+	cells := startCells(states, zeroOnly)
+	buckets := [][]*State{cells}
+	if config.StartDistribution == startDistributionPartitioned {
+		buckets = partitionStartCells(cells, nworkers)
+	}
+	var reachable map[*State]bool
+	if !zeroOnly {
+		reachable = reachableStates(states, config)
+	}
+
+	genInitStates = make([]func(*rand.Rand) *State, nworkers)
+	for i, bucket := range buckets {
+		starts := startStates(states, bucket, reachable)
+		for worker := i; worker < nworkers; worker += len(buckets) {
+			genInitStates[worker] = func(rng *rand.Rand) *State {
+				return starts[rng.Intn(len(starts))]
+			}
+		}
+	}
+	return
+}
+
+// startCells returns the cells from which episodes may start, ordered bottom to top and left to
+// right: the START cells if zeroOnly, else the START, TRACK, and SAND cells.
+func startCells(states [][][][]State, zeroOnly bool) (cells []*State) {
+	for x := range states {
+		for y := range states[x] {
+			cell := &states[x][y][0][0]
+			if cell.CellType == START || !zeroOnly && (cell.CellType == TRACK || cell.CellType == SAND) {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return
+}
+
+// startStates returns the start states of the passed cells: their zero velocity substates if
+// reachable is nil, else their non-zero velocity substates that are reachable. If none of the
+// cells' substates are reachable, e.g. if the cells are cut off from the START cells, all of
+// their non-zero velocity substates are returned instead.
+func startStates(states [][][][]State, cells []*State, reachable map[*State]bool) (starts []*State) {
+	if reachable == nil {
+		for _, cell := range cells {
+			starts = append(starts, &states[cell.X][cell.Y][VelIndex(states, 0)][VelIndex(states, 0)])
+		}
+		return
+	}
+
+	nonZero := []*State{}
+	for _, cell := range cells {
+		velstates := states[cell.X][cell.Y]
+		for vx := range velstates {
+			for vy := range velstates[vx] {
+				if state := &velstates[vx][vy]; state.VX != 0 || state.VY != 0 {
+					nonZero = append(nonZero, state)
+					if reachable[state] {
+						starts = append(starts, state)
+					}
+				}
+			}
+		}
+	}
+	if len(starts) == 0 {
+		return nonZero
+	}
+	return
+}
+
+// reachableStates returns the non-terminal states reachable from the START cells at rest, per
+// the kinematics, action set, and collisions of the config. Velocities may exceed what can be
+// attained within the distance to any START cell, e.g. the max velocity beside one, and random
+// starts from such states would train values of states that never occur in a race.
+func reachableStates(states [][][][]State, config *TrainingConfig) map[*State]bool {
+	collide := getCollisionFunc(config)
+	kinematics := config.GetKinematics()
+	actions := kinematics.Actions()
+
+	reachable := map[*State]bool{}
+	queue := []*State{}
+	for _, cell := range startCells(states, true) {
+		start := &states[cell.X][cell.Y][VelIndex(states, 0)][VelIndex(states, 0)]
+		reachable[start] = true
+		queue = append(queue, start)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for i := range actions {
+			successor := getSuccessor(states, state, &actions[i], collide, kinematics)
+			if !reachable[successor] && !is_terminal(successor) {
+				reachable[successor] = true
+				queue = append(queue, successor)
+			}
+		}
+	}
+	return reachable
+}
+
+// partitionStartCells divides the passed cells, ordered per startCells, into n contiguous
+// buckets of nearly equal size, such that each bucket is a band of the track. If there are fewer
+// cells than buckets, some cells are shared; no bucket is empty.
+func partitionStartCells(cells []*State, n int) (buckets [][]*State) {
+	buckets = make([][]*State, n)
+	for i := range buckets {
+		lo, hi := i*len(cells)/n, (i+1)*len(cells)/n
+		if hi == lo {
+			hi = lo + 1
+		}
+		buckets[i] = cells[lo:hi]
+	}
+	return
+}
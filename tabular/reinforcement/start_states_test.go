@@ -0,0 +1,126 @@
+package reinforcement
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStartStates(t *testing.T) {
+	Convey("Given the full track", t, func() {
+		states := Convert(FullTrack, DefaultKinematics)
+		numCells := 0
+		Visit(states, func(s *State) {
+			if (s.CellType == START || s.CellType == TRACK) && s.VX == 0 && s.VY == 0 {
+				numCells++
+			}
+		})
+
+		Convey("When its start cells are partitioned among workers", func() {
+			buckets := partitionStartCells(startCells(states, false), 4)
+
+			Convey("Then the buckets are disjoint and cover every start and track cell", func() {
+				seen := map[*State]bool{}
+				for _, bucket := range buckets {
+					So(bucket, ShouldNotBeEmpty)
+					for _, cell := range bucket {
+						So(seen[cell], ShouldBeFalse)
+						seen[cell] = true
+					}
+				}
+				So(len(seen), ShouldEqual, numCells)
+			})
+		})
+
+		Convey("When the start distribution is partitioned", func() {
+			config := &TrainingConfig{StartDistribution: startDistributionPartitioned}
+			genInitStates := newStartStateFuncs(states, config, 4)
+			buckets := partitionStartCells(startCells(states, false), 4)
+			rng := rand.New(rand.NewSource(1))
+
+			Convey("Then each worker only starts from its own cells, with non-zero velocity", func() {
+				for i, genInitState := range genInitStates {
+					cells := map[[2]int]bool{}
+					for _, cell := range buckets[i] {
+						cells[[2]int{cell.X, cell.Y}] = true
+					}
+					for n := 0; n < 100; n++ {
+						start := genInitState(rng)
+						So(cells[[2]int{start.X, start.Y}], ShouldBeTrue)
+						So(start.VX == 0 && start.VY == 0, ShouldBeFalse)
+					}
+				}
+			})
+		})
+	})
+
+	Convey("Given more workers than start cells", t, func() {
+		states := Convert([]string{"o+", "-W"}, DefaultKinematics)
+
+		Convey("Then no worker's bucket is empty", func() {
+			for _, bucket := range partitionStartCells(startCells(states, false), 5) {
+				So(bucket, ShouldNotBeEmpty)
+			}
+		})
+	})
+}
+
+func TestStartVelocity(t *testing.T) {
+	Convey("Given the debug track", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		rng := rand.New(rand.NewSource(1))
+		zero := VelIndex(states, 0)
+
+		Convey("When agents start at rest", func() {
+			for _, distribution := range []string{startDistributionUniform, startDistributionPartitioned} {
+				config := &TrainingConfig{StartVelocity: startVelocityZero, StartDistribution: distribution}
+
+				Convey("Then "+distribution+" starts are the START cells' zero velocity states", func() {
+					So(config.validateStartVelocity(), ShouldBeNil)
+					for _, genInitState := range newStartStateFuncs(states, config, 3) {
+						for n := 0; n < 100; n++ {
+							start := genInitState(rng)
+							So(start.CellType, ShouldEqual, START)
+							So(start, ShouldEqual, &states[start.X][start.Y][zero][zero])
+						}
+					}
+				})
+			}
+		})
+
+		Convey("When agents start at random velocities", func() {
+			config := &TrainingConfig{StartVelocity: startVelocityRandom}
+			reachable := reachableStates(states, config)
+
+			Convey("Then only velocities reachable from the START cells are reachable", func() {
+				// The track cell above the start cell at (1,0) is one step from it at rest, but
+				// cannot be reached at max velocity, which would require starting below the track.
+				So(reachable[&states[1][1][zero][VelIndex(states, 1)]], ShouldBeTrue)
+				So(reachable[&states[1][1][zero][VelIndex(states, DefaultKinematics.MaxVelocity)]], ShouldBeFalse)
+			})
+
+			Convey("Then every start is a reachable non-zero velocity state", func() {
+				cells := map[[2]int]bool{}
+				for _, genInitState := range newStartStateFuncs(states, config, 2) {
+					for n := 0; n < 500; n++ {
+						start := genInitState(rng)
+						So(reachable[start], ShouldBeTrue)
+						So(start.VX == 0 && start.VY == 0, ShouldBeFalse)
+						cells[[2]int{start.X, start.Y}] = true
+					}
+				}
+				So(len(cells), ShouldBeGreaterThan, 2)
+			})
+		})
+
+		Convey("Then unknown start velocity modes are rejected", func() {
+			config := &TrainingConfig{StartVelocity: "fast"}
+			So(config.validateStartVelocity(), ShouldNotBeNil)
+			So(TrainSync(context.Background(), states, config, 0), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package reinforcement
+
+import (
+	"context"
+	"errors"
+
+	. "tabular/grid_world"
+)
+
+// StreamPolicy determines how TrainStream treats a consumer slower than the agents.
+type StreamPolicy int
+
+const (
+	// StreamBlock blocks the agents until the consumer receives each episode,
+	// such that training proceeds at the pace of the consumer. This is the default.
+	StreamBlock StreamPolicy = iota
+	// StreamDrop drops the episodes the consumer is not ready to receive,
+	// such that training is never slowed by the consumer.
+	StreamDrop
+)
+
+// ErrNoWorkers indicates training was requested without any agents, other than sequentially.
+var ErrNoWorkers = errors.New("training requires at least one worker")
+
+// episodeStream forwards the sampled episodes of all agents to a single consumer.
+type episodeStream struct {
+	interval int
+	policy   StreamPolicy
+	// The agents' sampled episodes, which are never closed since there are many senders.
+	in chan *Episode
+}
+
+// sampler returns the sampler by which the agents publish to the stream until done is closed.
+func (stream *episodeStream) sampler(done <-chan struct{}) *episodeSampler {
+	sampler := newEpisodeSampler(stream.in, stream.interval)
+	sampler.block = stream.policy == StreamBlock
+	sampler.done = done
+	return sampler
+}
+
+func withEpisodeStream(stream *episodeStream) TrainOption {
+	return func(opts *trainOptions) {
+		opts.stream = stream
+	}
+}
+
+// WithStreamSampling sets TrainStream to yield every interval-th episode generated by any
+// agent, rather than every episode, and how a slow consumer is treated. It has no effect on Train.
+func WithStreamSampling(interval int, policy StreamPolicy) TrainOption {
+	return func(opts *trainOptions) {
+		if opts.stream != nil {
+			opts.stream.interval = interval
+			opts.stream.policy = policy
+		}
+	}
+}
+
+// TrainStream is like Train, but yields the agents' episodes for external analysis, such as
+// computing statistics, writing them out, or filling a replay buffer. By default every episode
+// is yielded and the agents block on the consumer; see WithStreamSampling.
+// Both chans are closed when ctx is cancelled. The error chan yields an error only if training
+// could not be started, e.g. because the state grid does not match the configured kinematics.
+// Episodes must not be modified, since they are shared with the estimator.
+func TrainStream(
+	ctx context.Context,
+	states [][][][]State,
+	config *TrainingConfig,
+	nworkers int,
+	opts ...TrainOption,
+) (<-chan *Episode, <-chan error) {
+	episodes := make(chan *Episode)
+	errs := make(chan error, 1)
+
+	stream := &episodeStream{
+		interval: 1,
+		policy:   StreamBlock,
+		in:       make(chan *Episode),
+	}
+	opts = append([]TrainOption{withEpisodeStream(stream)}, opts...)
+
+	err := ValidateHyperParams(config)
+	if err == nil {
+		err = checkWorkers(config, nworkers)
+	}
+	if err == nil {
+		err = Train(ctx, states, config, nworkers, func(context.Context, int) {}, opts...)
+	}
+	if err != nil {
+		errs <- err
+		close(errs)
+		close(episodes)
+		return episodes, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(episodes)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case episode := <-stream.in:
+				select {
+				case episodes <- episode:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return episodes, errs
+}
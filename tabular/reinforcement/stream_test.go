@@ -0,0 +1,86 @@
+package reinforcement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrainStream(t *testing.T) {
+	config := &TrainingConfig{
+		HyperParams: []HyperParameter{{Key: "seed", Val: 42}},
+	}
+
+	Convey("Given a streamed training run", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := Convert(DebugTrack, DefaultKinematics)
+		episodes, errs := TrainStream(ctx, states, config, 2)
+
+		Convey("Then complete episodes are yielded", func() {
+			for i := 0; i < 10; i++ {
+				episode := <-episodes
+				So(episode, ShouldNotBeNil)
+				So(*episode, ShouldNotBeEmpty)
+				last := (*episode)[len(*episode)-1]
+				So(is_terminal(last.Successor), ShouldBeTrue)
+			}
+		})
+
+		Convey("Then both chans are closed upon cancellation", func() {
+			cancel()
+			closed := func() bool {
+				timeout := time.After(time.Second)
+				for {
+					select {
+					case _, ok := <-episodes:
+						if !ok {
+							return true
+						}
+					case <-timeout:
+						return false
+					}
+				}
+			}
+			So(closed(), ShouldBeTrue)
+			_, ok := <-errs
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a streamed training run that drops episodes", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := Convert(DebugTrack, DefaultKinematics)
+		metrics := make(chan Metrics)
+		_, errs := TrainStream(ctx, states, config, 2,
+			WithStreamSampling(5, StreamDrop),
+			WithMetrics(metrics, 10))
+
+		Convey("Then training proceeds though the episodes are not received", func() {
+			var latest Metrics
+			for latest.EpisodeCount < 100 {
+				latest = <-metrics
+			}
+			So(latest.EpisodeCount, ShouldBeGreaterThanOrEqualTo, 100)
+			cancel()
+			_, ok := <-errs
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a state grid that does not match the configured kinematics", t, func() {
+		states := Convert(DebugTrack, Kinematics{MaxVelocity: 6, MaxAcceleration: 1})
+		episodes, errs := TrainStream(context.Background(), states, config, 2)
+
+		Convey("Then an error is yielded and no episodes", func() {
+			So(<-errs, ShouldNotBeNil)
+			_, ok := <-episodes
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
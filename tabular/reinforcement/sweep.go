@@ -0,0 +1,162 @@
+package reinforcement
+
+import (
+	"container/heap"
+	"math"
+
+	. "tabular/grid_world"
+)
+
+// sweepPrioritized selects prioritized sweeping via Algorithm.Sweep.
+const sweepPrioritized = "prioritized"
+
+/*
+sweeper implements prioritized sweeping for the state values, per Sutton and Barto. The
+estimator's updates change the values of the states visited in an episode; the states
+leading to them, their predecessors, then have stale values, which are most stale for the
+predecessors of the largest changes. The sweeper thus queues each predecessor keyed by the
+absolute TD error of its one-step backup,
+
+	delta = r + gamma*V(s') - V(s)
+
+where s' is its max-valued successor per the deterministic environment, and between
+episodes pops and updates the highest-error states first, queueing their predecessors in
+turn. Predecessors are learned from the observed episodes, since the environment model
+only provides successors.
+
+The sweeper is only used by the (single) estimator, hence is not synchronized.
+*/
+type sweeper struct {
+	states       [][][][]State
+	collide      collisionFunc
+	kinematics   Kinematics
+	rewards      *Rewards
+	predecessors map[*State]map[*State]bool
+	queue        sweepQueue
+	queued       map[*State]*sweepItem
+	// Only errors above threshold are queued.
+	threshold float64
+	// The max number of queued updates applied between episodes.
+	updates int
+}
+
+// newSweeper returns a sweeper if prioritized sweeping is configured, otherwise nil.
+func newSweeper(
+	states [][][][]State,
+	config *TrainingConfig,
+	collide collisionFunc,
+	kinematics Kinematics,
+	rewards *Rewards,
+) *sweeper {
+	if config.Algorithm.Sweep != sweepPrioritized {
+		return nil
+	}
+	return &sweeper{
+		states:       states,
+		collide:      collide,
+		kinematics:   kinematics,
+		rewards:      rewards,
+		predecessors: map[*State]map[*State]bool{},
+		queued:       map[*State]*sweepItem{},
+		threshold:    config.GetHyperParamOrDefault("sweepThreshold", 0.0001),
+		updates:      int(config.GetHyperParamOrDefault("sweepUpdates", 10)),
+	}
+}
+
+// observe records the predecessors of the episode's states, and queues them
+// per the errors of their backups, since the episode's states were just updated.
+func (sw *sweeper) observe(episode *Episode, gamma float64) {
+	for _, step := range *episode {
+		preds, ok := sw.predecessors[step.Successor]
+		if !ok {
+			preds = map[*State]bool{}
+			sw.predecessors[step.Successor] = preds
+		}
+		preds[step.State] = true
+	}
+	for _, step := range *episode {
+		sw.pushPredecessors(step.State, gamma)
+	}
+}
+
+// sweep applies up to the configured number of queued updates, highest error first.
+func (sw *sweeper) sweep(eta, gamma float64, observer episodeObserver) {
+	for i := 0; i < sw.updates && sw.queue.Len() > 0; i++ {
+		item := heap.Pop(&sw.queue).(*sweepItem)
+		delete(sw.queued, item.state)
+
+		// Values may have changed since the state was queued, so the error is recomputed.
+		tdError := sw.backupError(item.state, gamma)
+		delta := eta * tdError
+		_, _ = item.state.Value.AtomicAdd(delta)
+		observer.Observe(delta)
+		sw.pushPredecessors(item.state, gamma)
+	}
+}
+
+// backupError returns the TD error of the one-step backup of the passed state from its
+// max-valued successor.
+func (sw *sweeper) backupError(state *State, gamma float64) float64 {
+	successor, _ := get_max_successor(sw.states, state, sw.collide, sw.kinematics)
+	target := getReward(successor, sw.rewards)
+	if !is_terminal(successor) {
+		target += gamma * successor.Value.AtomicRead()
+	}
+	return target - state.Value.AtomicRead()
+}
+
+// pushPredecessors queues the known predecessors of the passed state whose backup errors
+// exceed the threshold. Queued states' priorities are raised to their current errors.
+func (sw *sweeper) pushPredecessors(state *State, gamma float64) {
+	for pred := range sw.predecessors[state] {
+		priority := math.Abs(sw.backupError(pred, gamma))
+		if priority <= sw.threshold {
+			continue
+		}
+		if item, ok := sw.queued[pred]; ok {
+			if priority > item.priority {
+				item.priority = priority
+				heap.Fix(&sw.queue, item.index)
+			}
+			continue
+		}
+		item := &sweepItem{state: pred, priority: priority}
+		heap.Push(&sw.queue, item)
+		sw.queued[pred] = item
+	}
+}
+
+type sweepItem struct {
+	state    *State
+	priority float64
+	// The item's index in the heap, maintained by the heap.
+	index int
+}
+
+// sweepQueue is a max-heap of states keyed by priority, per container/heap.
+type sweepQueue []*sweepItem
+
+func (sq sweepQueue) Len() int { return len(sq) }
+
+func (sq sweepQueue) Less(i, j int) bool { return sq[i].priority > sq[j].priority }
+
+func (sq sweepQueue) Swap(i, j int) {
+	sq[i], sq[j] = sq[j], sq[i]
+	sq[i].index = i
+	sq[j].index = j
+}
+
+func (sq *sweepQueue) Push(x any) {
+	item := x.(*sweepItem)
+	item.index = len(*sq)
+	*sq = append(*sq, item)
+}
+
+func (sq *sweepQueue) Pop() any {
+	old := *sq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*sq = old[:n-1]
+	return item
+}
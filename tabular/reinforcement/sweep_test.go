@@ -0,0 +1,122 @@
+package reinforcement
+
+import (
+	"container/heap"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSweeper(t *testing.T) {
+	Convey("Given a sweep queue", t, func() {
+		queue := sweepQueue{}
+		for _, priority := range []float64{0.5, 3, 0.1, 2, 1} {
+			heap.Push(&queue, &sweepItem{priority: priority})
+		}
+
+		Convey("Then the highest priorities are popped first", func() {
+			popped := []float64{}
+			for queue.Len() > 0 {
+				popped = append(popped, heap.Pop(&queue).(*sweepItem).priority)
+			}
+			So(popped, ShouldResemble, []float64{3, 2, 1, 0.5, 0.1})
+		})
+	})
+
+	Convey("Given a sweeper on the debug track", t, func() {
+		config := &TrainingConfig{Algorithm: AlgorithmConfig{Sweep: sweepPrioritized}}
+		states := Convert(DebugTrack, DefaultKinematics)
+		initStateVals(states, -10)
+		sw := newSweeper(states, config, getCollisionFunc(config), DefaultKinematics, config.GetRewards())
+		So(sw, ShouldNotBeNil)
+
+		// A two-step episode ending at the finish, whose last state's value was just updated.
+		first := &states[3][6][VelIndex(states, 1)][VelIndex(states, 0)]
+		second := &states[4][6][VelIndex(states, 1)][VelIndex(states, 0)]
+		finish := &states[5][6][VelIndex(states, 1)][VelIndex(states, 0)]
+		episode := &Episode{
+			{State: first, Successor: second, Reward: -1},
+			{State: second, Successor: finish, Reward: 0},
+		}
+		second.Value.AtomicSet(-3)
+
+		Convey("When the episode is observed", func() {
+			sw.observe(episode, 1.0)
+
+			Convey("Then the predecessor of the changed state is queued", func() {
+				So(sw.queue.Len(), ShouldEqual, 1)
+				So(sw.queue[0].state, ShouldEqual, first)
+			})
+
+			Convey("Then sweeping backs up the queued state from its max successor", func() {
+				sw.sweep(1.0, 1.0, episodeObservers{})
+				So(first.Value.AtomicRead(), ShouldEqual, -4)
+				So(sw.queue.Len(), ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given the default config", t, func() {
+		Convey("Then prioritized sweeping is disabled", func() {
+			states := Convert(DebugTrack, DefaultKinematics)
+			config := &TrainingConfig{}
+			So(newSweeper(states, config, getCollisionFunc(config), DefaultKinematics, config.GetRewards()), ShouldBeNil)
+		})
+	})
+
+	Convey("Given prioritized sweeping for algorithms other than alpha-monte-carlo", t, func() {
+		Convey("Then it is rejected rather than ignored", func() {
+			for _, kind := range []string{AlgQLearning, AlgSarsa, AlgTDLambda} {
+				alg := &AlgorithmConfig{Kind: kind, Sweep: sweepPrioritized}
+				So(alg.validate(), ShouldNotBeNil)
+				alg.Sweep = "fifo"
+				So(alg.validate(), ShouldBeNil)
+			}
+			So((&AlgorithmConfig{Kind: AlgAlphaMonteCarlo, Sweep: sweepPrioritized}).validate(), ShouldBeNil)
+		})
+	})
+}
+
+// BenchmarkSweepConvergence reports the number of episodes for alpha-MC to converge on the
+// debug track, with and without prioritized sweeping, as the custom 'episodes' metric.
+func BenchmarkSweepConvergence(b *testing.B) {
+	for _, sweep := range []string{"fifo", sweepPrioritized} {
+		b.Run(sweep, func(b *testing.B) {
+			total := 0
+			for i := 0; i < b.N; i++ {
+				config := &TrainingConfig{
+					HyperParams: []HyperParameter{
+						{Key: "seed", Val: float64(i)},
+						{Key: "eta", Val: 0.1},
+						{Key: "epsilonDecay", Val: 0.995},
+					},
+					Algorithm:   AlgorithmConfig{Sweep: sweep},
+					Convergence: ConvergenceConfig{Threshold: 0.001, Window: 100},
+				}
+				states := Convert(DebugTrack, DefaultKinematics)
+				timeout, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				ctx, err := config.WithConvergenceStop(timeout)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				var episodes int64
+				Train(ctx, states, config, 1, func(_ context.Context, count int) {
+					atomic.StoreInt64(&episodes, int64(count))
+				})
+				<-ctx.Done()
+				if timeout.Err() != nil {
+					b.Fatal("training did not converge")
+				}
+				cancel()
+				total += int(atomic.LoadInt64(&episodes))
+			}
+			b.ReportMetric(float64(total)/float64(b.N), "episodes")
+		})
+	}
+}
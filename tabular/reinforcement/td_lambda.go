@@ -0,0 +1,118 @@
+package reinforcement
+
+import (
+	"context"
+	"sync/atomic"
+
+	"tabular/atomic_float"
+	. "tabular/grid_world"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+/*
+Implements TD(lambda) with accumulating eligibility traces, a method between alpha-MC and
+one-step TD. Agents follow the same epsilon-greedy state-value policy as alpha-MC; for each
+step of an episode the estimator computes the one-step TD error,
+
+	delta = r + gamma*V(s') - V(s)
+
+and applies it to every state visited so far in the episode in proportion to its eligibility:
+
+	V(s) += eta*delta*e(s)
+
+where e(s) is incremented upon each visit to s and decays by gamma*lambda per step. Thus
+lambda=0 reduces to TD(0), updating only the current state, and lambda=1 approximates
+every-visit MC, since each reward is propagated undecayed (besides gamma) to all prior states.
+
+Traces are per-episode, and thereby per-agent, so they are kept in a map local to the update
+of each episode rather than on the shared state grid.
+*/
+func tdLambdaTrain(
+	ctx context.Context,
+	states [][][][]State,
+	nworkers int,
+	config *TrainingConfig,
+	progressFn ProgressFunc,
+	observer episodeObserver,
+	options *trainOptions) {
+
+	// Epsilon: the agent exploration/exploitation policy param, optionally decayed per episode count.
+	epsilonFn := newEpsilonSchedule(config, options.hyperParams)
+	// Eta: the learning rate, optionally decayed per episode count.
+	etaFn := newEtaSchedule(config, options.hyperParams)
+	// Gamma: the look-ahead parameter, or how much to value future state values.
+	gamma := options.hyperParams.Gamma
+	// Lambda: the trace decay, or how far back each TD error is propagated.
+	lambda := config.GetHyperParamOrDefault("lambda", 0.9)
+	if config.Algorithm.Lambda != nil {
+		lambda = *config.Algorithm.Lambda
+	}
+	// The number of episodes processed by the estimator, shared with the agents' policies.
+	var episodeCount int64
+	rewards := config.GetRewards()
+
+	collide, kinematics := getCollisionFunc(config), config.GetKinematics()
+	policyAlphaMax := withSlip(states, newPolicyAlphaMax(states, func() float64 {
+		return epsilonFn(atomic.LoadInt64(&episodeCount))
+	}, collide, kinematics), getNoise(config), collide, kinematics)
+
+	maxSteps := getMaxEpisodeLen(config)
+	workers := []<-chan *Episode{}
+	for i, rng := range newWorkerRands(config, nworkers) {
+		ch := agentWorker(ctx.Done(), options.routines, rng, options.genInitStates[i], policyAlphaMax, rewards, maxSteps, options.samplers)
+		workers = append(workers, ch)
+	}
+	episodes := gated(ctx.Done(), options.gate, channerics.Merge(ctx.Done(), workers...))
+
+	// Estimator updates state values from agent experiences, one step at a time.
+	estimator := func(
+		etaFn func(int64) float64,
+		gamma *atomic_float.AtomicFloat64,
+		progressFn ProgressFunc) {
+		for episode := range episodes {
+			eta := etaFn(atomic.LoadInt64(&episodeCount))
+			options.gate.apply(func() {
+				// Set terminal states to the value of the reward for stepping into them, for display.
+				setTerminalValue(episode)
+
+				tdLambdaUpdate(episode, eta, gamma.AtomicRead(), lambda, observer)
+			})
+			observer.EndEpisode(len(*episode))
+
+			// Hook: periodically do some other processing (publishing state values for views, etc.)
+			count := atomic.AddInt64(&episodeCount, 1)
+			progressFn(ctx, int(count))
+		}
+	}
+	options.spawn(func() {
+		estimator(etaFn, gamma, progressFn)
+	})
+}
+
+// tdLambdaUpdate applies the TD(lambda) updates of the passed episode in order, using
+// accumulating eligibility traces local to the episode. Terminal successors have no
+// value, since the reward for entering them is already included in the TD error.
+func tdLambdaUpdate(
+	episode *Episode,
+	eta, gamma, lambda float64,
+	observer episodeObserver,
+) {
+	traces := map[*State]float64{}
+	for _, step := range *episode {
+		target := step.Reward
+		if !is_terminal(step.Successor) {
+			target += gamma * step.Successor.Value.AtomicRead()
+		}
+		tdError := target - step.State.Value.AtomicRead()
+
+		traces[step.State] += 1
+		for state, trace := range traces {
+			delta := eta * tdError * trace
+			// Note: intentionally discard rejected deltas; add ops are serialized by the single estimator.
+			_, _ = state.Value.AtomicAdd(delta)
+			observer.Observe(delta)
+			traces[state] = gamma * lambda * trace
+		}
+	}
+}
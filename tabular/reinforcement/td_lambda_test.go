@@ -0,0 +1,42 @@
+package reinforcement
+
+import (
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTdLambdaUpdate(t *testing.T) {
+	Convey("Given a two step episode ending in a collision", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		first := &states[1][1][VelIndex(states, 0)][VelIndex(states, 1)]
+		second := &states[1][2][VelIndex(states, -1)][VelIndex(states, 1)]
+		wall := &states[0][3][VelIndex(states, -1)][VelIndex(states, 1)]
+		episode := &Episode{
+			{State: first, Reward: -1, Successor: second},
+			{State: second, Reward: -1, Successor: wall},
+		}
+		eta, gamma := 0.5, 0.9
+
+		Convey("When lambda is 0, only the current state is updated, as in TD(0)", func() {
+			tdLambdaUpdate(episode, eta, gamma, 0, episodeObservers{})
+			So(first.Value.AtomicRead(), ShouldAlmostEqual, -0.5)
+			So(second.Value.AtomicRead(), ShouldAlmostEqual, -0.5)
+		})
+
+		Convey("When lambda is 1, the final TD error also reaches the first state, discounted by gamma", func() {
+			tdLambdaUpdate(episode, eta, gamma, 1, episodeObservers{})
+			// -0.5 from the first step, plus eta * -1 * gamma from the second.
+			So(first.Value.AtomicRead(), ShouldAlmostEqual, -0.95)
+			So(second.Value.AtomicRead(), ShouldAlmostEqual, -0.5)
+		})
+
+		Convey("The terminal state's value is excluded from the target", func() {
+			wall.Value.AtomicSet(-100)
+			tdLambdaUpdate(episode, eta, gamma, 0, episodeObservers{})
+			So(second.Value.AtomicRead(), ShouldAlmostEqual, -0.5)
+		})
+	})
+}
@@ -0,0 +1,276 @@
+package reinforcement
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	. "tabular/grid_world"
+)
+
+// ErrTrainerStopped is returned when restarting a Trainer whose context is done.
+var ErrTrainerStopped = errors.New("trainer stopped")
+
+// ErrStepUnsupported is returned by Step for algorithms other than alpha-monte-carlo.
+var ErrStepUnsupported = errors.New("stepping is only supported by alpha-monte-carlo")
+
+// ErrTrainerRunning is returned by Step while the current run is training, i.e. neither
+// paused nor complete.
+var ErrTrainerRunning = errors.New("training is running; pause it before stepping")
+
+// ErrTrainerNotStarted is returned by Step before Start or Reset has initialized the state
+// values of a run.
+var ErrTrainerNotStarted = errors.New("trainer not started; start it before stepping")
+
+// Trainer launches training against a fixed state grid and restarts it on demand, e.g. to
+// re-run from scratch without restarting the process. Each run is bounded by the training
+// deadline and convergence stop of the config, which restart with the run.
+type Trainer struct {
+	ctx        context.Context
+	states     [][][][]State
+	config     *TrainingConfig
+	nworkers   int
+	progressFn ProgressFunc
+	opts       []TrainOption
+
+	mu sync.Mutex
+	// Cancels the current run.
+	cancel context.CancelFunc
+	// The context of the current run, done once it completes.
+	run context.Context
+	// Tracks the agents and estimator of the current run.
+	routines *sync.WaitGroup
+	// Closed once a run completes other than by Reset.
+	done     chan struct{}
+	doneOnce sync.Once
+	// Generates the episodes of Step; built upon the first Step after each Reset.
+	stepper *stepper
+}
+
+// stepper is the agent of Step, and the chan to which the metrics of its episodes are published.
+type stepper struct {
+	agent   *sequentialAgent
+	metrics chan Metrics
+}
+
+// NewTrainer returns a Trainer whose runs are derived from the passed context and train per
+// the passed args, as for Train. Call Start to begin the first run.
+func NewTrainer(
+	ctx context.Context,
+	states [][][][]State,
+	config *TrainingConfig,
+	nworkers int,
+	progressFn ProgressFunc,
+	opts ...TrainOption,
+) *Trainer {
+	return &Trainer{
+		ctx:        ctx,
+		states:     states,
+		config:     config,
+		nworkers:   nworkers,
+		progressFn: progressFn,
+		opts:       opts,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start begins the first run, resuming from the last checkpoint if one is configured.
+func (t *Trainer) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.start(false)
+}
+
+// Reset stops the current run and waits for its estimator to stop, then restarts training
+// from scratch: the state values are re-initialized as by Train, ignoring any checkpoint. The
+// agents' gate and hyper-parameters are those passed to NewTrainer, hence are unchanged.
+func (t *Trainer) Reset() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		t.cancel()
+		t.routines.Wait()
+	}
+	if t.ctx.Err() != nil {
+		return ErrTrainerStopped
+	}
+	t.stepper = nil
+	return t.start(true)
+}
+
+// Step generates a single episode with a single agent and synchronously applies its updates to
+// the state values, per sequential alpha-MC, returning the Metrics of that episode, e.g. its
+// mean absolute value-delta, its length, and the number of cells whose greedy action changed
+// since the last step. A run must have been launched by Start or Reset, which initialize the
+// state values, and must be paused via the gate passed to NewTrainer, or be complete, such
+// that the changes are the step's alone; the step waits for any episode the run's estimators
+// are still applying. The agent persists across steps until
+// Reset, and its epsilon and eta schedules count only its own episodes. Steps are not passed to
+// the progress func, whose intervals count the run's episodes.
+func (t *Trainer) Step() (Metrics, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ctx.Err() != nil {
+		return Metrics{}, ErrTrainerStopped
+	}
+	if !t.config.Algorithm.isAlphaMonteCarlo() {
+		return Metrics{}, ErrStepUnsupported
+	}
+	if t.run == nil {
+		return Metrics{}, ErrTrainerNotStarted
+	}
+	options := t.trainOptions()
+	if t.run.Err() == nil && !options.gate.Paused() {
+		return Metrics{}, ErrTrainerRunning
+	}
+
+	if t.stepper == nil {
+		t.stepper = t.newStepper(options)
+	}
+	// Pausing only withholds new episodes from the run's estimators, so the step holds them,
+	// waiting for any episode they are still applying, such that their updates never interleave.
+	options.gate.Hold(t.stepper.agent.step)
+	select {
+	case metrics := <-t.stepper.metrics:
+		return metrics, nil
+	case <-t.ctx.Done():
+		return Metrics{}, ErrTrainerStopped
+	}
+}
+
+// trainOptions returns the options passed to NewTrainer, with their defaults as set by Train.
+func (t *Trainer) trainOptions() *trainOptions {
+	options := &trainOptions{}
+	for _, opt := range t.opts {
+		opt(options)
+	}
+	if options.hyperParams == nil {
+		options.hyperParams = NewHyperParams(t.config)
+	}
+	return options
+}
+
+// newStepper returns the agent of Step, which publishes the metrics of every episode. Its
+// updates are applied under the gate's Hold by Step, rather than per the gate as the run's.
+func (t *Trainer) newStepper(options *trainOptions) *stepper {
+	stepOptions := *options
+	stepOptions.gate = nil
+	generated := newEpisodeSampler(nil, 1)
+	stepOptions.samplers = append(stepOptions.samplers, generated)
+	stepOptions.genInitStates = newStartStateFuncs(t.states, t.config, 1)
+	// The metrics are buffered, such that the agent never blocks publishing them.
+	metrics := make(chan Metrics, 1)
+	recorder := newMetricsRecorder(t.ctx, metrics, 1, t.states, t.config, stepOptions.hyperParams, generated, stepOptions.reference)
+	return &stepper{
+		agent:   newSequentialAgent(t.ctx, t.states, t.config, func(context.Context, int) {}, recorder, &stepOptions),
+		metrics: metrics,
+	}
+}
+
+// Done returns a chan that is closed once a run completes other than by Reset, per its
+// deadline or convergence, or the trainer's context, and its agents and estimator have exited.
+func (t *Trainer) Done() <-chan struct{} {
+	return t.done
+}
+
+// start launches a run. The caller must hold the lock.
+func (t *Trainer) start(fresh bool) error {
+	runCtx, cancel, err := t.config.WithTrainingDeadline(t.ctx)
+	if err != nil {
+		return err
+	}
+	if runCtx, err = t.config.WithConvergenceStop(runCtx); err != nil {
+		cancel()
+		return err
+	}
+
+	routines := &sync.WaitGroup{}
+	opts := append(t.opts[:len(t.opts):len(t.opts)], withRoutines(routines))
+	if fresh {
+		opts = append(opts, withoutCheckpointResume())
+	}
+	if err := Train(runCtx, t.states, t.config, t.nworkers, t.progressFn, opts...); err != nil {
+		cancel()
+		return err
+	}
+	t.cancel, t.run, t.routines = cancel, runCtx, routines
+
+	go func() {
+		<-runCtx.Done()
+		routines.Wait()
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// Runs replaced by Reset are not complete.
+		if t.routines == routines {
+			t.doneOnce.Do(func() { close(t.done) })
+		}
+	}()
+	return nil
+}
+
+// TrainSync trains as Train does, but blocks until the context is cancelled, e.g. per its
+// deadline or an episode budget, and the agents and estimator have exited. Invalid configs
+// are returned as errors rather than panicking, as for TrainStream, and are the only errors:
+// the agents and estimator do not fail, so a run that starts returns nil once drained, whether
+// the context was cancelled or expired. Upon returning, the state values are no longer
+// updated, hence may be inspected deterministically.
+func TrainSync(
+	ctx context.Context,
+	states [][][][]State,
+	config *TrainingConfig,
+	nworkers int,
+	opts ...TrainOption,
+) error {
+	err := ValidateHyperParams(config)
+	if err == nil {
+		err = config.Algorithm.validate()
+	}
+	if err == nil {
+		err = config.validateExploration()
+	}
+	if err == nil {
+		err = config.validateRewardPreset()
+	}
+	if err == nil {
+		err = config.validateStartVelocity()
+	}
+	if err == nil {
+		err = checkWorkers(config, nworkers)
+	}
+	routines := &sync.WaitGroup{}
+	if err == nil {
+		opts = append(opts[:len(opts):len(opts)], withRoutines(routines))
+		err = Train(ctx, states, config, nworkers, func(context.Context, int) {}, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	routines.Wait()
+	return nil
+}
+
+// withRoutines tracks the agents and estimator with the passed WaitGroup.
+func withRoutines(routines *sync.WaitGroup) TrainOption {
+	return func(opts *trainOptions) {
+		opts.routines = routines
+	}
+}
+
+// withoutCheckpointResume initializes the state values rather than resuming from the last checkpoint.
+func withoutCheckpointResume() TrainOption {
+	return func(opts *trainOptions) {
+		opts.fresh = true
+	}
+}
+
+// spawn runs fn in a routine tracked by the options' routines.
+func (opts *trainOptions) spawn(fn func()) {
+	opts.routines.Add(1)
+	go func() {
+		defer opts.routines.Done()
+		fn()
+	}()
+}
@@ -0,0 +1,297 @@
+package reinforcement
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrainer(t *testing.T) {
+	Convey("Given a trainer whose agents are paused", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		states := Convert(DebugTrack, DefaultKinematics)
+		gate := NewGate()
+		gate.Pause()
+		trainer := NewTrainer(ctx, states, &TrainingConfig{}, 2, func(context.Context, int) {}, WithGate(gate))
+		So(trainer.Start(), ShouldBeNil)
+
+		Convey("When it is reset", func() {
+			initStateVals(states, 42)
+			So(trainer.Reset(), ShouldBeNil)
+
+			Convey("Then the state values are re-initialized", func() {
+				Visit(states, func(s *State) {
+					So(s.Value.AtomicRead(), ShouldEqual, COLLISION_REWARD)
+				})
+			})
+
+			Convey("Then training has not completed", func() {
+				So(isClosed(trainer.Done()), ShouldBeFalse)
+			})
+		})
+
+		Convey("When its context is cancelled", func() {
+			cancel()
+
+			Convey("Then training completes, and cannot be reset", func() {
+				So(waitClosed(trainer.Done(), 5*time.Second), ShouldBeTrue)
+				So(errors.Is(trainer.Reset(), ErrTrainerStopped), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a trainer with a training duration", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		config := &TrainingConfig{TrainingDeadline: DeadlineConfig{Duration: "100ms"}}
+		trainer := NewTrainer(ctx, Convert(DebugTrack, DefaultKinematics), config, 2, func(context.Context, int) {})
+		So(trainer.Start(), ShouldBeNil)
+
+		Convey("Then training completes once the duration elapses", func() {
+			So(waitClosed(trainer.Done(), 5*time.Second), ShouldBeTrue)
+		})
+
+		Convey("Then training restarts when reset after completing", func() {
+			So(waitClosed(trainer.Done(), 5*time.Second), ShouldBeTrue)
+			So(trainer.Reset(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a trainer of a state grid of other kinematics than the config's", t, func() {
+		states := Convert(DebugTrack, Kinematics{MaxVelocity: 2, MaxAcceleration: 1})
+		trainer := NewTrainer(context.Background(), states, &TrainingConfig{}, 1, func(context.Context, int) {})
+
+		Convey("Then Start and Reset return an error rather than panicking", func() {
+			So(errors.Is(trainer.Start(), ErrInvalidKinematics), ShouldBeTrue)
+			So(errors.Is(trainer.Reset(), ErrInvalidKinematics), ShouldBeTrue)
+		})
+	})
+}
+
+// isClosed returns whether the passed chan is closed, without blocking.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitClosed returns whether the passed chan is closed within the timeout.
+func waitClosed(ch <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestTrainSync(t *testing.T) {
+	algorithms := []string{AlgAlphaMonteCarlo, AlgQLearning, AlgSarsa, AlgTDLambda}
+
+	for _, alg := range algorithms {
+		Convey("Given "+alg+" training synchronously with an episode budget", t, func() {
+			states := Convert(DebugTrack, DefaultKinematics)
+			timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Second)
+			Reset(cancelTimeout)
+			ctx, cancel := WithEpisodeBudget(timeout, 50)
+			Reset(cancel)
+
+			err := TrainSync(ctx, states, &TrainingConfig{Algorithm: AlgorithmConfig{Kind: alg}}, 4)
+
+			Convey("Then it returns once the budget is spent, after which the values are quiescent", func() {
+				So(err, ShouldBeNil)
+				So(timeout.Err(), ShouldBeNil)
+				before := snapshotValues(states)
+				time.Sleep(20 * time.Millisecond)
+				So(snapshotValues(states), ShouldResemble, before)
+			})
+		})
+	}
+
+	Convey("Given a state grid of other kinematics than the config's", t, func() {
+		states := Convert(DebugTrack, Kinematics{MaxVelocity: 2, MaxAcceleration: 1})
+
+		Convey("Then TrainSync returns an error rather than panicking", func() {
+			So(errors.Is(TrainSync(context.Background(), states, &TrainingConfig{}, 1), ErrInvalidKinematics), ShouldBeTrue)
+		})
+
+		Convey("Then Train returns an error rather than panicking", func() {
+			err := Train(context.Background(), states, &TrainingConfig{}, 1, func(context.Context, int) {})
+			So(errors.Is(err, ErrInvalidKinematics), ShouldBeTrue)
+		})
+	})
+}
+
+func TestSequentialTraining(t *testing.T) {
+	// Trains per the passed config and number of workers with a fixed seed, returning the values.
+	train := func(config *TrainingConfig, nworkers int) []float64 {
+		states := Convert(DebugTrack, DefaultKinematics)
+		ctx, cancel := WithEpisodeBudget(context.Background(), 200)
+		defer cancel()
+		So(TrainSync(ctx, states, config, nworkers), ShouldBeNil)
+		return snapshotValues(states)
+	}
+	hyperParams := []HyperParameter{
+		{Key: "seed", Val: 42},
+		{Key: "nstep", Val: 2},
+		{Key: "oracleFraction", Val: 1},
+		{Key: "oracleEpisodes", Val: 50},
+	}
+
+	Convey("Given zero workers and a fixed seed", t, func() {
+		config := &TrainingConfig{HyperParams: hyperParams}
+
+		Convey("Then alpha-monte-carlo trains sequentially, and its values are reproducible", func() {
+			first := train(config, 0)
+			So(train(config, 0), ShouldResemble, first)
+
+			initial := Convert(DebugTrack, DefaultKinematics)
+			initStateVals(initial, config.GetRewards().Collision)
+			So(first, ShouldNotResemble, snapshotValues(initial))
+		})
+	})
+
+	Convey("Given a sequential config with many workers", t, func() {
+		config := &TrainingConfig{
+			HyperParams: hyperParams,
+			Algorithm:   AlgorithmConfig{Sequential: true},
+		}
+
+		Convey("Then training is the same as with zero workers", func() {
+			So(train(config, 4), ShouldResemble, train(&TrainingConfig{HyperParams: hyperParams}, 0))
+		})
+	})
+
+	Convey("Given zero workers or a sequential config for other algorithms", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		zeroWorkers := &TrainingConfig{Algorithm: AlgorithmConfig{Kind: AlgQLearning}}
+		sequential := &TrainingConfig{Algorithm: AlgorithmConfig{Kind: AlgQLearning, Sequential: true}}
+
+		Convey("Then they are rejected", func() {
+			So(TrainSync(context.Background(), states, zeroWorkers, 0), ShouldEqual, ErrNoWorkers)
+			So(TrainSync(context.Background(), states, sequential, 1), ShouldNotBeNil)
+			So(TrainSync(context.Background(), states, &TrainingConfig{}, -1), ShouldEqual, ErrNoWorkers)
+		})
+	})
+}
+
+func TestTrainerStep(t *testing.T) {
+	hyperParams := []HyperParameter{
+		{Key: "seed", Val: 42},
+		{Key: "nstep", Val: 2},
+	}
+
+	Convey("Given a trainer whose run is paused", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		states := Convert(DebugTrack, DefaultKinematics)
+		gate := NewGate()
+		gate.Pause()
+		// Every reward differs from the initial values, such that every update changes them.
+		config := &TrainingConfig{HyperParams: append([]HyperParameter{{Key: "initValue", Val: 0}}, hyperParams...)}
+		trainer := NewTrainer(ctx, states, config, 2, func(context.Context, int) {}, WithGate(gate))
+		So(trainer.Start(), ShouldBeNil)
+		initial := snapshotValues(states)
+
+		Convey("When it is stepped", func() {
+			first, err := trainer.Step()
+			So(err, ShouldBeNil)
+
+			Convey("Then a single episode is applied, and its metrics returned", func() {
+				So(first.EpisodeCount, ShouldEqual, 1)
+				So(first.MeanEpisodeLength, ShouldBeGreaterThan, 0)
+				So(first.MeanAbsDelta, ShouldBeGreaterThan, 0)
+				So(first.PolicyChanges, ShouldBeGreaterThan, 0)
+				So(snapshotValues(states), ShouldNotResemble, initial)
+			})
+
+			Convey("Then subsequent steps continue from it, until reset", func() {
+				second, err := trainer.Step()
+				So(err, ShouldBeNil)
+				So(second.EpisodeCount, ShouldEqual, 2)
+
+				So(trainer.Reset(), ShouldBeNil)
+				third, err := trainer.Step()
+				So(err, ShouldBeNil)
+				So(third.EpisodeCount, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When it is resumed", func() {
+			gate.Resume()
+
+			Convey("Then it cannot be stepped", func() {
+				_, err := trainer.Step()
+				So(errors.Is(err, ErrTrainerRunning), ShouldBeTrue)
+			})
+		})
+
+		Convey("When its context is cancelled", func() {
+			cancel()
+
+			Convey("Then it cannot be stepped", func() {
+				_, err := trainer.Step()
+				So(errors.Is(err, ErrTrainerStopped), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a trainer of another algorithm", t, func() {
+		config := &TrainingConfig{Algorithm: AlgorithmConfig{Kind: AlgQLearning}}
+		trainer := NewTrainer(context.Background(), Convert(DebugTrack, DefaultKinematics), config, 1, func(context.Context, int) {})
+
+		Convey("Then it cannot be stepped", func() {
+			_, err := trainer.Step()
+			So(errors.Is(err, ErrStepUnsupported), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a trainer that has not started", t, func() {
+		config := &TrainingConfig{HyperParams: hyperParams}
+		trainer := NewTrainer(context.Background(), Convert(DebugTrack, DefaultKinematics), config, 1, func(context.Context, int) {})
+
+		Convey("Then it cannot be stepped", func() {
+			_, err := trainer.Step()
+			So(errors.Is(err, ErrTrainerNotStarted), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a trainer started paused", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		Reset(cancel)
+		config := &TrainingConfig{HyperParams: hyperParams}
+		states := Convert(DebugTrack, DefaultKinematics)
+		gate := NewGate()
+		gate.Pause()
+		trainer := NewTrainer(ctx, states, config, 1, func(context.Context, int) {}, WithGate(gate))
+		So(trainer.Start(), ShouldBeNil)
+
+		Convey("Then stepping matches sequential training with the same seed", func() {
+			for i := 0; i < 50; i++ {
+				_, err := trainer.Step()
+				So(err, ShouldBeNil)
+			}
+
+			sequential := Convert(DebugTrack, DefaultKinematics)
+			ctx, cancel := WithEpisodeBudget(context.Background(), 50)
+			defer cancel()
+			So(TrainSync(ctx, sequential, config, 0), ShouldBeNil)
+			So(snapshotValues(states), ShouldResemble, snapshotValues(sequential))
+		})
+	})
+}
+
+// snapshotValues returns a copy of the state values.
+func snapshotValues(states [][][][]State) (values []float64) {
+	Visit(states, func(s *State) { values = append(values, s.Value.AtomicRead()) })
+	return
+}
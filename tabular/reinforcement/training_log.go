@@ -0,0 +1,135 @@
+package reinforcement
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	. "tabular/grid_world"
+)
+
+// TrainingLog summarizes the state values and episodes of a logging interval.
+// The values are those of the greedy policy, the max over each cell's velocity
+// substates, for the track, sand, and start cells.
+type TrainingLog struct {
+	Episode        int     `json:"episode"`
+	MeanValue      float64 `json:"meanValue"`
+	MaxValue       float64 `json:"maxValue"`
+	MinValue       float64 `json:"minValue"`
+	MeanEpisodeLen float64 `json:"meanEpisodeLen"`
+	ElapsedMs      int64   `json:"elapsedMs"`
+}
+
+// TrainingLogger records a TrainingLog per logging interval, and the states as of then.
+// Loggers are called by the estimator, so they should be quick.
+type TrainingLogger interface {
+	Log(entry TrainingLog, states [][][][]State) error
+}
+
+// JSONLogger writes each TrainingLog as a JSON object per line, for scripting, e.g. with jq.
+type JSONLogger struct {
+	enc *json.Encoder
+}
+
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{enc: json.NewEncoder(w)}
+}
+
+func (jl *JSONLogger) Log(entry TrainingLog, _ [][][][]State) error {
+	return jl.enc.Encode(entry)
+}
+
+// ConsoleLogger prints each TrainingLog and the max values grid, per ShowMaxValues.
+type ConsoleLogger struct{}
+
+func (ConsoleLogger) Log(entry TrainingLog, states [][][][]State) error {
+	fmt.Printf("episode %d: mean %.2f, max %.2f, min %.2f, mean episode len %.1f, elapsed %v\n",
+		entry.Episode, entry.MeanValue, entry.MaxValue, entry.MinValue, entry.MeanEpisodeLen,
+		time.Duration(entry.ElapsedMs)*time.Millisecond)
+	ShowMaxValues(states)
+	return nil
+}
+
+// WithTrainingLogger logs training progress to the passed logger every interval episodes.
+func WithTrainingLogger(logger TrainingLogger, interval int) TrainOption {
+	return func(opts *trainOptions) {
+		opts.logger = logger
+		opts.logInterval = interval
+	}
+}
+
+// trainingLogRecorder accumulates episode lengths and periodically logs them with the
+// state value stats. Only used by the estimator.
+type trainingLogRecorder struct {
+	logger   TrainingLogger
+	states   [][][][]State
+	interval int
+	start    time.Time
+
+	episodeCount int
+	// Accumulators for the current interval
+	numSteps    int
+	numEpisodes int
+	// Whether a logging error has been reported, such that a failed writer isn't reported per interval.
+	failed bool
+}
+
+func newTrainingLogRecorder(logger TrainingLogger, interval int, states [][][][]State) *trainingLogRecorder {
+	if interval <= 0 {
+		interval = 1
+	}
+	return &trainingLogRecorder{
+		logger:   logger,
+		states:   states,
+		interval: interval,
+		start:    time.Now(),
+	}
+}
+
+func (lr *trainingLogRecorder) Observe(float64) {}
+
+func (lr *trainingLogRecorder) EndEpisode(steps int) {
+	lr.episodeCount++
+	lr.numEpisodes++
+	lr.numSteps += steps
+	if lr.episodeCount%lr.interval != 0 {
+		return
+	}
+
+	entry := TrainingLog{
+		Episode:        lr.episodeCount,
+		MeanEpisodeLen: float64(lr.numSteps) / float64(lr.numEpisodes),
+		ElapsedMs:      time.Since(lr.start).Milliseconds(),
+	}
+	entry.MeanValue, entry.MaxValue, entry.MinValue = greedyValueStats(lr.states)
+	lr.numSteps, lr.numEpisodes = 0, 0
+
+	if err := lr.logger.Log(entry, lr.states); err != nil && !lr.failed {
+		fmt.Println("training log failed:", err)
+		lr.failed = true
+	}
+}
+
+// greedyValueStats returns the mean, max, and min of the max substate values of the track, sand, and start cells.
+func greedyValueStats(states [][][][]State) (mean, max, min float64) {
+	max, min = math.Inf(-1), math.Inf(1)
+	n := 0
+	for x := range states {
+		for y := range states[x] {
+			if cellType := states[x][y][0][0].CellType; cellType != TRACK && cellType != START && cellType != SAND {
+				continue
+			}
+			_, val := MaxVelStateValue(states[x][y])
+			mean += val
+			max = math.Max(max, val)
+			min = math.Min(min, val)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0, 0
+	}
+	return mean / float64(n), max, min
+}
@@ -0,0 +1,58 @@
+package reinforcement
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrainingLog(t *testing.T) {
+	Convey("Given a JSON training log recorder on the debug track", t, func() {
+		states := Convert(DebugTrack, DefaultKinematics)
+		initStateVals(states, -2)
+		// The max substate of one cell is greater, and the finish cells are excluded.
+		states[2][1][VelIndex(states, 1)][VelIndex(states, 0)].Value.AtomicSet(4)
+		Visit(states, func(s *State) {
+			if s.CellType == FINISH {
+				s.Value.AtomicSet(100)
+			}
+		})
+
+		buf := &bytes.Buffer{}
+		recorder := newTrainingLogRecorder(NewJSONLogger(buf), 2, states)
+
+		Convey("When episodes end", func() {
+			for _, steps := range []int{3, 5, 10, 20, 7} {
+				recorder.EndEpisode(steps)
+			}
+
+			Convey("Then an object per line is logged every interval", func() {
+				entries := []TrainingLog{}
+				scanner := bufio.NewScanner(buf)
+				for scanner.Scan() {
+					entry := TrainingLog{}
+					So(json.Unmarshal(scanner.Bytes(), &entry), ShouldBeNil)
+					entries = append(entries, entry)
+				}
+				So(len(entries), ShouldEqual, 2)
+				So(entries[0].Episode, ShouldEqual, 2)
+				So(entries[0].MeanEpisodeLen, ShouldEqual, 4)
+				So(entries[1].Episode, ShouldEqual, 4)
+				So(entries[1].MeanEpisodeLen, ShouldEqual, 15)
+			})
+
+			Convey("Then the value stats are of the greedy values of the track and start cells", func() {
+				entry := TrainingLog{}
+				So(json.NewDecoder(buf).Decode(&entry), ShouldBeNil)
+				So(entry.MaxValue, ShouldEqual, 4)
+				So(entry.MinValue, ShouldEqual, -2)
+				So(entry.MeanValue, ShouldBeBetween, -2, 4)
+			})
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package reinforcement
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// watchDebounce is how long the config file must be unchanged before it is reloaded.
+// Editors often write a file several times per save, e.g. truncating and then writing it.
+const watchDebounce = 200 * time.Millisecond
+
+// WatchConfig watches the config file at path, calling onChange with the reloaded config after
+// each change, once the file has stopped changing for the debounce period. Changes which fail to
+// load or whose hyper-parameters are invalid are reported and otherwise ignored, such that a
+// mid-edit typo doesn't affect training. The watch lasts for the life of the process, per viper.
+func WatchConfig(path string, onChange func(*TrainingConfig)) error {
+	vp := viper.New()
+	vp.SetConfigFile(path)
+	vp.SetConfigType("yaml")
+	if err := vp.ReadInConfig(); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var timer *time.Timer
+	reload := func() {
+		config, err := FromYaml(path)
+		if err == nil {
+			err = ValidateHyperParams(config)
+		}
+		if err != nil {
+			fmt.Printf("ignoring config change to %s: %v\n", path, err)
+			return
+		}
+		onChange(config)
+	}
+
+	vp.OnConfigChange(func(fsnotify.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(watchDebounce, reload)
+	})
+	vp.WatchConfig()
+	return nil
+}
+
+// Reload sets the hyper-parameters which may be changed during training per the passed config,
+// leaving those it omits unchanged.
+func (hp *HyperParams) Reload(config *TrainingConfig) error {
+	for _, param := range config.HyperParams {
+		switch param.Key {
+		case "epsilon", "eta", "gamma":
+			if err := hp.SetParam(param.Key, param.Val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
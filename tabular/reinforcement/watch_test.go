@@ -0,0 +1,71 @@
+package reinforcement
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWatchConfig(t *testing.T) {
+	writeConfig := func(path string, epsilon float64) {
+		yaml := fmt.Sprintf("kind: TrainingConfig\ndef:\n  hyperParams:\n  - key: epsilon\n    val: %v\n", epsilon)
+		So(os.WriteFile(path, []byte(yaml), 0o644), ShouldBeNil)
+	}
+
+	Convey("Given a watched config", t, func() {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		writeConfig(path, 0.1)
+		changes := make(chan *TrainingConfig, 10)
+		So(WatchConfig(path, func(config *TrainingConfig) { changes <- config }), ShouldBeNil)
+
+		Convey("When it is written several times in quick succession", func() {
+			for _, epsilon := range []float64{0.2, 0.3, 0.4} {
+				writeConfig(path, epsilon)
+			}
+
+			Convey("Then the last write is reloaded once", func() {
+				select {
+				case config := <-changes:
+					So(config.GetHyperParamOrDefault("epsilon", 0), ShouldEqual, 0.4)
+				case <-time.After(5 * time.Second):
+					So("config was not reloaded", ShouldBeEmpty)
+				}
+				time.Sleep(2 * watchDebounce)
+				So(changes, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When it is written with invalid hyper-parameters", func() {
+			writeConfig(path, 2)
+
+			Convey("Then the change is ignored", func() {
+				time.Sleep(4 * watchDebounce)
+				So(changes, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestReload(t *testing.T) {
+	Convey("Given hyper-parameters", t, func() {
+		hp := NewHyperParams(&TrainingConfig{})
+
+		Convey("When reloaded from a config", func() {
+			err := hp.Reload(&TrainingConfig{HyperParams: []HyperParameter{
+				{Key: "epsilon", Val: 0.3},
+				{Key: "lambda", Val: 0.5},
+			}})
+
+			Convey("Then the live params it specifies are set, and others are unchanged", func() {
+				So(err, ShouldBeNil)
+				So(hp.Epsilon.AtomicRead(), ShouldEqual, 0.3)
+				So(hp.Eta.AtomicRead(), ShouldEqual, 0.01)
+				So(hp.Gamma.AtomicRead(), ShouldEqual, 0.9)
+			})
+		})
+	})
+}
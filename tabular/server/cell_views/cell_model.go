@@ -20,11 +20,17 @@ type Cell struct {
 	PolicyArrowRotation int
 	PolicyArrowScale    int
 	Fill                string
+	// CellType is the grid_world cell type of the position, e.g. grid_world.WALL.
+	CellType rune
+	// Visits is the number of visits to the cell's position during the current training run.
+	Visits int64
 }
 
 // Convert transforms the passed state models into Cells for consumption by values-views.
 // The y indices into [][]Cell matrix are flipped per svg y-axis orientation, where 0 is the top of
-// the coordinate system.
+// the coordinate system. Values and visits are read atomically, hence never race with training,
+// and each cell's Max is the value by which its policy arrow was selected. Cells are not coherent
+// with one another unless training is held meanwhile; see Server.snapshotCells.
 // TODO: where can this live? Is reorg needed? Notice how this references model.State and helpers.
 // I suppose this is fine, but re-evaluate.
 func Convert(states [][][][]grid_world.State) (cells [][]Cell) {
@@ -37,20 +43,42 @@ func Convert(states [][][][]grid_world.State) (cells [][]Cell) {
 	grid_world.VisitXYStates(states, func(velstates [][]grid_world.State) {
 		x, y := velstates[0][0].X, velstates[0][0].Y
 		cellType := velstates[0][0].CellType
-		maxState := grid_world.MaxVelState(velstates)
+		maxState, maxVal := grid_world.MaxVelStateValue(velstates)
 		// flip the y indices for displaying in svg coordinate system
 		cells[x][y] = Cell{
 			X:                   x,
 			Y:                   max_y - y - 1,
-			Max:                 maxState.Value.AtomicRead(),
+			Max:                 maxVal,
 			PolicyArrowRotation: getDegrees(maxState),
 			PolicyArrowScale:    getScale(maxState),
 			Fill:                getFill(cellType),
+			CellType:            cellType,
+			Visits:              maxState.Visits(),
 		}
 	})
 	return
 }
 
+// MaxViewWidth is the width in pixels within which the grid views are fit by FitCellDim,
+// such that the full track fits within a reasonable viewport.
+const MaxViewWidth = 1200
+
+// FitCellDim returns the largest cell dimension up to maxCellDim, in pixels, such that the
+// passed number of cells spans at most MaxViewWidth, but not less than minCellDim.
+func FitCellDim(numCells, maxCellDim, minCellDim int) int {
+	if numCells <= 0 {
+		return maxCellDim
+	}
+	cellDim := MaxViewWidth / numCells
+	if cellDim > maxCellDim {
+		return maxCellDim
+	}
+	if cellDim < minCellDim {
+		return minCellDim
+	}
+	return cellDim
+}
+
 func getScale(state *grid_world.State) int {
 	return int(math.Hypot(float64(state.VX), float64(state.VY)))
 }
@@ -73,10 +101,19 @@ func getFill(cellType rune) (fill string) {
 		fill = "lightgreen"
 	case grid_world.TRACK:
 		fill = "lightgray"
+	case grid_world.SAND:
+		fill = "tan"
 	case grid_world.START:
 		fill = "lightblue"
 	case grid_world.FINISH:
 		fill = "lightyellow"
+	// Tiered finish cells are shaded from light to deep gold with their tier.
+	case grid_world.FINISH_TIER_1:
+		fill = "khaki"
+	case grid_world.FINISH_TIER_2:
+		fill = "gold"
+	case grid_world.FINISH_TIER_3:
+		fill = "orange"
 	}
 	return
 }
@@ -0,0 +1,125 @@
+package cell_views
+
+import (
+	"testing"
+
+	"tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFitCellDim(t *testing.T) {
+	Convey("Given the values grid cell dims", t, func() {
+		Convey("Then narrow tracks keep the max cell dim", func() {
+			So(FitCellDim(len(grid_world.DebugTrack[0]), DefaultValuesCellDim, MinValuesCellDim), ShouldEqual, DefaultValuesCellDim)
+		})
+
+		Convey("Then the full track is scaled to fit the max view width", func() {
+			numCells := len(grid_world.FullTrack[0])
+			cellDim := FitCellDim(numCells, DefaultValuesCellDim, MinValuesCellDim)
+			So(cellDim, ShouldBeLessThan, DefaultValuesCellDim)
+			So(cellDim*numCells, ShouldBeLessThanOrEqualTo, MaxViewWidth)
+		})
+
+		Convey("Then very wide tracks are not scaled below the min cell dim", func() {
+			So(FitCellDim(1000, DefaultValuesCellDim, MinValuesCellDim), ShouldEqual, MinValuesCellDim)
+		})
+	})
+}
+
+func TestConvertCellTypes(t *testing.T) {
+	Convey("Given the cells of the debug track", t, func() {
+		track := grid_world.DebugTrack
+		cells := Convert(grid_world.Convert(track, grid_world.DefaultKinematics))
+
+		Convey("Then each cell carries the type of its track cell, and the fill of that type", func() {
+			for x := range cells {
+				for y, cell := range cells[x] {
+					So(cell.CellType, ShouldEqual, rune(track[cell.Y][x]))
+					So(cell.CellType, ShouldEqual, rune(track[len(track)-1-y][x]))
+					So(cell.Fill, ShouldEqual, getFill(cell.CellType))
+				}
+			}
+		})
+	})
+}
+
+func TestConvert(t *testing.T) {
+	// The track's bottom row is y 0 in grid coordinates, but 1 in the svg coordinates of cells.
+	track := []string{
+		"o+",
+		"-o",
+	}
+	kinematics := grid_world.Kinematics{MaxVelocity: 2, MaxAcceleration: 1}
+
+	for _, tc := range []struct {
+		name           string
+		x, y           int
+		vx, vy         int
+		rotation       int
+		scale          int
+		svgY           int
+		zeroVelocityUp bool
+	}{
+		{name: "right", x: 0, y: 0, vx: 1, vy: 0, rotation: 90, scale: 1, svgY: 1},
+		{name: "up", x: 0, y: 0, vx: 0, vy: 2, rotation: 0, scale: 2, svgY: 1},
+		{name: "left", x: 1, y: 0, vx: -2, vy: 0, rotation: -90, scale: 2, svgY: 1},
+		{name: "down", x: 0, y: 1, vx: 0, vy: -1, rotation: 180, scale: 1, svgY: 0},
+		{name: "up-right", x: 0, y: 1, vx: 1, vy: 1, rotation: 45, scale: 1, svgY: 0},
+		{name: "fast up-right", x: 1, y: 1, vx: 2, vy: 2, rotation: 45, scale: 2, svgY: 0},
+		{name: "down-left", x: 1, y: 0, vx: -1, vy: -1, rotation: 225, scale: 1, svgY: 1},
+		{name: "max beside a higher zero velocity", x: 0, y: 0, vx: -1, vy: 2, rotation: -26, scale: 2, svgY: 1, zeroVelocityUp: true},
+	} {
+		Convey("Given states whose max-valued velocity at a cell is "+tc.name, t, func() {
+			states := grid_world.Convert(track, kinematics)
+			grid_world.Visit(states, func(s *grid_world.State) { s.Value.Store(-10) })
+			states[tc.x][tc.y][grid_world.VelIndex(states, tc.vx)][grid_world.VelIndex(states, tc.vy)].Value.Store(5)
+			if tc.zeroVelocityUp {
+				// Zero velocity states are excluded by the problem definition, hence by the views.
+				states[tc.x][tc.y][grid_world.VelIndex(states, 0)][grid_world.VelIndex(states, 0)].Value.Store(100)
+			}
+
+			Convey("When the states are converted to cells", func() {
+				cells := Convert(states)
+
+				Convey("Then the cells are indexed [x][y] per grid coordinates, with their y flipped for svg", func() {
+					So(len(cells), ShouldEqual, len(track[0]))
+					for x := range cells {
+						So(len(cells[x]), ShouldEqual, len(track))
+						for y, cell := range cells[x] {
+							So(cell.X, ShouldEqual, x)
+							So(cell.Y, ShouldEqual, len(track)-1-y)
+						}
+					}
+					So(cells[tc.x][tc.y].Y, ShouldEqual, tc.svgY)
+				})
+
+				Convey("Then the cell shows its max-valued velocity", func() {
+					cell := cells[tc.x][tc.y]
+					So(cell.Max, ShouldEqual, 5)
+					So(cell.PolicyArrowRotation, ShouldEqual, tc.rotation)
+					So(cell.PolicyArrowScale, ShouldEqual, tc.scale)
+				})
+
+				Convey("Then the other cells are unaffected", func() {
+					for x := range cells {
+						for y, cell := range cells[x] {
+							if x != tc.x || y != tc.y {
+								So(cell.Max, ShouldEqual, -10)
+							}
+						}
+					}
+				})
+			})
+		})
+	}
+
+	Convey("Given a zero velocity", t, func() {
+		state := &grid_world.State{}
+
+		Convey("Then its arrow is unrotated and unscaled", func() {
+			So(getDegrees(state), ShouldEqual, 0)
+			So(getScale(state), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,109 @@
+package cell_views
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ColorMap maps a value to a css color per its position among all values, given by their
+// min and max. Fill always returns a valid css rgb() color, even when min equals max.
+type ColorMap interface {
+	Fill(val, min, max float64) string
+}
+
+// ColorMaps are the available colormaps, by the name by which clients select them.
+var ColorMaps = map[string]ColorMap{
+	"redblue":   RedBlue{},
+	"viridis":   Viridis{},
+	"grayscale": Grayscale{},
+	"redgreen":  RedGreen{},
+}
+
+// DefaultColorMap is the colormap with which views are initially rendered.
+var DefaultColorMap ColorMap = RedBlue{}
+
+// ColorMapNames returns the names of the available colormaps in sorted order.
+func ColorMapNames() (names []string) {
+	for name := range ColorMaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// The fraction at which values are colored when their position is undefined, i.e. when all
+// values are equal or are not numbers.
+const neutralFrac = 0.5
+
+// clampUnit clamps the passed fraction into [0, 1], such that floating point noise cannot
+// produce out-of-range colors. NaN is mapped to the neutral fraction.
+func clampUnit(f float64) float64 {
+	if math.IsNaN(f) {
+		return neutralFrac
+	}
+	return math.Max(0, math.Min(1, f))
+}
+
+// position returns where val lies between min and max as a fraction in [0, 1]. If all values
+// are equal, as before training has diverged them, val lies at the midpoint.
+func position(val, min, max float64) float64 {
+	if max == min {
+		return neutralFrac
+	}
+	return clampUnit((val - min) / (max - min))
+}
+
+// RedBlue blends red and blue by the magnitude of the value relative to the range of values,
+// such that, for the negative values of costs, the most costly states are red.
+type RedBlue struct{}
+
+func (RedBlue) Fill(val, min, max float64) string {
+	// Allocate fill based on proportion of blue and red only; this should give a basic relative range.
+	redFrac := neutralFrac
+	if max != min {
+		redFrac = clampUnit(math.Abs(val) / math.Abs(max-min))
+	}
+	redPct := int(100.0 * redFrac)
+	return fmt.Sprintf("rgb(%d%%,0%%,%d%%)", redPct, 100-redPct)
+}
+
+// Grayscale ranges from black at the min to white at the max.
+type Grayscale struct{}
+
+func (Grayscale) Fill(val, min, max float64) string {
+	pct := int(100.0 * position(val, min, max))
+	return fmt.Sprintf("rgb(%d%%,%d%%,%d%%)", pct, pct, pct)
+}
+
+// RedGreen ranges from red at the min to green at the max.
+type RedGreen struct{}
+
+func (RedGreen) Fill(val, min, max float64) string {
+	greenPct := int(100.0 * position(val, min, max))
+	return fmt.Sprintf("rgb(%d%%,%d%%,0%%)", 100-greenPct, greenPct)
+}
+
+// Viridis approximates the perceptually uniform viridis colormap, from dark purple at the
+// min through blue and green to yellow at the max, by interpolating between its stops.
+type Viridis struct{}
+
+var viridisStops = [][3]float64{
+	{68, 1, 84},
+	{59, 82, 139},
+	{33, 145, 140},
+	{94, 201, 98},
+	{253, 231, 37},
+}
+
+func (Viridis) Fill(val, min, max float64) string {
+	scaled := position(val, min, max) * float64(len(viridisStops)-1)
+	i := int(math.Min(scaled, float64(len(viridisStops)-2)))
+	frac := scaled - float64(i)
+	lo, hi := viridisStops[i], viridisStops[i+1]
+	rgb := [3]int{}
+	for c := range rgb {
+		rgb[c] = int(math.Round(lo[c] + frac*(hi[c]-lo[c])))
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", rgb[0], rgb[1], rgb[2])
+}
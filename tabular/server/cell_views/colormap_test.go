@@ -0,0 +1,84 @@
+package cell_views
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// cssRGB matches css rgb() colors of either all-integer or all-percentage components.
+var cssRGB = regexp.MustCompile(`^rgb\((\d+)(%?),(\d+)(%?),(\d+)(%?)\)$`)
+
+// isValidRGB reports whether the passed string is a css rgb() color whose components are in range.
+func isValidRGB(fill string) bool {
+	match := cssRGB.FindStringSubmatch(fill)
+	if match == nil || match[2] != match[4] || match[4] != match[6] {
+		return false
+	}
+	limit := 255
+	if match[2] == "%" {
+		limit = 100
+	}
+	for _, component := range []string{match[1], match[3], match[5]} {
+		if n, err := strconv.Atoi(component); err != nil || n > limit {
+			return false
+		}
+	}
+	return true
+}
+
+func TestColorMaps(t *testing.T) {
+	ranges := []struct{ min, max float64 }{
+		{-10, 0},
+		{-25.5, -3},
+		{0, 1},
+		{5, 10},
+		{-7, -7},
+		{0, 0},
+	}
+
+	for _, name := range ColorMapNames() {
+		colorMap := ColorMaps[name]
+		Convey("Given the "+name+" colormap", t, func() {
+			for _, r := range ranges {
+				Convey(fmt.Sprintf("Then fills across [%v, %v] are valid css colors", r.min, r.max), func() {
+					for i := 0; i <= 10; i++ {
+						val := r.min + float64(i)*(r.max-r.min)/10
+						fill := colorMap.Fill(val, r.min, r.max)
+						So(isValidRGB(fill), ShouldBeTrue)
+					}
+				})
+			}
+		})
+	}
+
+	Convey("Given the default colormap", t, func() {
+		Convey("Then it blends red and blue by the value's magnitude relative to the range", func() {
+			So(DefaultColorMap.Fill(-10, -10, 0), ShouldEqual, "rgb(100%,0%,0%)")
+			So(DefaultColorMap.Fill(-2.5, -10, 0), ShouldEqual, "rgb(25%,0%,75%)")
+			So(DefaultColorMap.Fill(0, -10, 0), ShouldEqual, "rgb(0%,0%,100%)")
+		})
+
+		Convey("Then equal values are the neutral midpoint color", func() {
+			So(DefaultColorMap.Fill(-7, -7, -7), ShouldEqual, "rgb(50%,0%,50%)")
+			So(DefaultColorMap.Fill(0, 0, 0), ShouldEqual, "rgb(50%,0%,50%)")
+		})
+
+		Convey("Then values beyond the range are clamped", func() {
+			So(DefaultColorMap.Fill(-1e9, -10, 0), ShouldEqual, "rgb(100%,0%,0%)")
+			So(DefaultColorMap.Fill(-10-1e-12, -10, 0), ShouldEqual, "rgb(100%,0%,0%)")
+			So(DefaultColorMap.Fill(10, 5, 10), ShouldEqual, "rgb(100%,0%,0%)")
+			So(DefaultColorMap.Fill(math.Inf(-1), -10, 0), ShouldEqual, "rgb(100%,0%,0%)")
+			So(DefaultColorMap.Fill(math.MaxFloat64, -math.MaxFloat64, 0), ShouldEqual, "rgb(100%,0%,0%)")
+		})
+
+		Convey("Then non-numeric values are the neutral midpoint color", func() {
+			So(DefaultColorMap.Fill(math.NaN(), -10, 0), ShouldEqual, "rgb(50%,0%,50%)")
+			So(DefaultColorMap.Fill(-5, math.NaN(), 0), ShouldEqual, "rgb(50%,0%,50%)")
+		})
+	})
+}
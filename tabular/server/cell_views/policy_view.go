@@ -0,0 +1,140 @@
+package cell_views
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// PolicyView presents only the policy: an arrow per cell pointing in the direction of its
+// max-valued velocity state, whose length is scaled by the velocity's magnitude and whose
+// color is given by the state's value relative to all others. Unlike the ValuesGrid, it
+// has no value text, giving an uncluttered plot of the policy on large tracks.
+type PolicyView struct {
+	id      string
+	updates <-chan []fastview.EleUpdate
+}
+
+func NewPolicyView(
+	done <-chan struct{},
+	cells <-chan [][]Cell,
+) (pv *PolicyView) {
+	id := "policyview"
+	if strings.Contains(id, "-") {
+		fmt.Println("WARNING: hyphenated names interfere with html/template's `template` directive")
+	}
+	pv = &PolicyView{id: template.HTMLEscapeString(id)}
+	pv.updates = channerics.Convert(done, cells, pv.onUpdate)
+	return
+}
+
+func (pv *PolicyView) Updates() <-chan []fastview.EleUpdate {
+	return pv.updates
+}
+
+const (
+	policyCellDim = 40
+	// The arrow length, in pixels, per unit of velocity magnitude, and its bounds within a cell.
+	policyArrowUnit   = 3
+	policyArrowMinLen = 4
+	policyArrowMaxLen = policyCellDim/2 - 2
+)
+
+// getArrowPath returns an svg path of an upward arrow from the origin, whose length is
+// scaled by the passed velocity magnitude. Arrows are rotated per the cell's policy.
+func getArrowPath(scale int) string {
+	length := policyArrowMinLen + policyArrowUnit*scale
+	if length > policyArrowMaxLen {
+		length = policyArrowMaxLen
+	}
+	return fmt.Sprintf("M0,0 L0,%d M-4,%d L0,%d L4,%d", -length, 6-length, -length, 6-length)
+}
+
+func (pv *PolicyView) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = pv.id
+	addedMap := template.FuncMap{
+		"getArrowPath": getArrowPath,
+	}
+	_, err = parent.Funcs(addedMap).Parse(
+		`{{ define "` + name + `" }}
+		<div>
+			{{ $x_cells := len . }}
+			{{ $y_cells := len (index . 0) }}
+			{{ $cell_dim := ` + strconv.Itoa(policyCellDim) + ` }}
+			{{ $half_dim := div $cell_dim 2 }}
+			<svg id="` + pv.id + `"
+				width="{{ add (mult $cell_dim $x_cells) 1 }}px"
+				height="{{ add (mult $cell_dim $y_cells) 1 }}px">
+				{{ range $row := . }}
+					{{ range $cell := $row }}
+					<rect
+						onclick="inspectCell({{ $cell.X }}, {{ sub (sub $y_cells $cell.Y) 1 }})"
+						style="cursor: pointer;"
+						x="{{ mult $cell.X $cell_dim }}"
+						y="{{ mult $cell.Y $cell_dim }}"
+						width="{{ $cell_dim }}"
+						height="{{ $cell_dim }}"
+						fill="{{ $cell.Fill }}"
+						fill-opacity="0.3"
+						stroke="lightgrey"
+						stroke-width="1"/>
+					<g transform="translate({{ add (mult $cell.X $cell_dim) $half_dim }}, {{ add (mult $cell.Y $cell_dim) $half_dim }})"
+						pointer-events="none">
+						<path id="{{ $cell.X }}-{{ $cell.Y }}-policy-path"
+							d="{{ getArrowPath $cell.PolicyArrowScale }}"
+							transform="rotate({{ $cell.PolicyArrowRotation }})"
+							fill="none"
+							stroke="grey"
+							stroke-width="2"
+							stroke-linecap="round"/>
+					</g>
+					{{ end }}
+				{{ end }}
+			</svg>
+		</div>
+		{{ end }}`)
+	return
+}
+
+// Returns the set of view updates needed for the view to reflect the current policy.
+func (pv *PolicyView) onUpdate(
+	cells [][]Cell,
+) (ops []fastview.EleUpdate) {
+	minVal, maxVal := math.MaxFloat64, -math.MaxFloat64
+	for _, row := range cells {
+		for _, cell := range row {
+			minVal = math.Min(minVal, cell.Max)
+			maxVal = math.Max(maxVal, cell.Max)
+		}
+	}
+
+	for _, row := range cells {
+		for _, cell := range row {
+			ops = append(ops, fastview.EleUpdate{
+				EleId: fmt.Sprintf("%d-%d-policy-path", cell.X, cell.Y),
+				Ops: []fastview.Op{
+					{
+						Key:   "d",
+						Value: getArrowPath(cell.PolicyArrowScale),
+					},
+					{
+						Key:   "transform",
+						Value: fmt.Sprintf("rotate(%d)", cell.PolicyArrowRotation),
+					},
+					{
+						Key:   "stroke",
+						Value: DefaultColorMap.Fill(cell.Max, minVal, maxVal),
+					},
+				},
+			})
+		}
+	}
+	return
+}
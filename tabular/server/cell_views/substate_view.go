@@ -0,0 +1,192 @@
+package cell_views
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"tabular/grid_world"
+	"tabular/server/fastview"
+)
+
+// SubstateView presents the values of all of the velocity substates of a single cell, selected
+// by clients via the CmdInspect command, e.g. by clicking a cell in the values grid. It is the
+// view equivalent of printing a cell's substates to the console.
+// The view reads the values directly from the state grid, which is updated in place by training,
+// and refreshes them upon every cell update. Each value is read atomically, but the substates are
+// not read coherently, since training is not held; they may straddle an episode's updates until
+// the next refresh.
+type SubstateView struct {
+	id      string
+	updates <-chan []fastview.EleUpdate
+	states  [][][][]grid_world.State
+	// Cells selected by clients, applied by the update routine.
+	selections chan gridPos
+	done       <-chan struct{}
+}
+
+// gridPos is a position in the state grid, whose y-axis is not flipped like that of Cells.
+type gridPos struct {
+	x, y int
+}
+
+// CmdInspect is the client command to inspect the cell at the command's x and y grid coordinates.
+const CmdInspect = "inspect"
+
+// ErrInvalidCell indicates an inspected cell is not within the grid.
+var ErrInvalidCell = errors.New("cell is not within the grid")
+
+func NewSubstateView(
+	done <-chan struct{},
+	cells <-chan [][]Cell,
+	states [][][][]grid_world.State,
+) (sv *SubstateView) {
+	id := "substateview"
+	if strings.Contains(id, "-") {
+		fmt.Println("WARNING: hyphenated names interfere with html/template's `template` directive")
+	}
+	sv = &SubstateView{
+		id:         template.HTMLEscapeString(id),
+		states:     states,
+		selections: make(chan gridPos),
+		done:       done,
+	}
+	sv.updates = sv.run(done, cells)
+	return
+}
+
+func (sv *SubstateView) Updates() <-chan []fastview.EleUpdate {
+	return sv.updates
+}
+
+// run emits the substate values of the selected cell, if any, upon each cell update and selection.
+func (sv *SubstateView) run(
+	done <-chan struct{},
+	cells <-chan [][]Cell,
+) <-chan []fastview.EleUpdate {
+	updates := make(chan []fastview.EleUpdate)
+	go func() {
+		defer close(updates)
+
+		var selected *gridPos
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-cells:
+				if !ok {
+					return
+				}
+			case pos := <-sv.selections:
+				selected = &pos
+			}
+			// Nothing has been selected, so there is nothing to refresh.
+			if selected == nil {
+				continue
+			}
+
+			select {
+			case updates <- sv.onUpdate(*selected):
+			case <-done:
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// HandleCommand applies the CmdInspect command, returning false for all other commands.
+// The view is shared by all clients, thus so is the inspected cell.
+func (sv *SubstateView) HandleCommand(cmd fastview.Command) (bool, error) {
+	if cmd.Cmd != CmdInspect {
+		return false, nil
+	}
+	return true, sv.Inspect(cmd.X, cmd.Y)
+}
+
+// Inspect selects the cell at the passed grid coordinates, whose substates are then presented.
+func (sv *SubstateView) Inspect(x, y int) error {
+	if x < 0 || x >= len(sv.states) || y < 0 || y >= len(sv.states[0]) {
+		return fmt.Errorf("%w: (%d,%d)", ErrInvalidCell, x, y)
+	}
+
+	select {
+	case sv.selections <- gridPos{x: x, y: y}:
+	case <-sv.done:
+	}
+	return nil
+}
+
+// velocities returns the velocity components of the substates in ascending order,
+// per the dimensions of the state grid.
+func (sv *SubstateView) velocities() (vels []int) {
+	for i := range sv.states[0][0] {
+		vels = append(vels, sv.states[0][0][i][0].VX)
+	}
+	return
+}
+
+// Returns the set of view updates presenting the substate values of the passed cell.
+func (sv *SubstateView) onUpdate(pos gridPos) (ops []fastview.EleUpdate) {
+	velStates := sv.states[pos.x][pos.y]
+	ops = append(ops, fastview.EleUpdate{
+		EleId: sv.id + "-title",
+		Ops: []fastview.Op{
+			{
+				Key:   "textContent",
+				Value: fmt.Sprintf("Velocity vals for cell (%d,%d), %c", pos.x, pos.y, velStates[0][0].CellType),
+			},
+		},
+	})
+	for i := range velStates {
+		for j := range velStates[i] {
+			state := &velStates[i][j]
+			ops = append(ops, fastview.EleUpdate{
+				EleId: fmt.Sprintf("%s-%d-%d", sv.id, state.VX, state.VY),
+				Ops: []fastview.Op{
+					{
+						Key:   "textContent",
+						Value: fmt.Sprintf("%.2f", state.Value.AtomicRead()),
+					},
+				},
+			})
+		}
+	}
+	return
+}
+
+// Parse returns a table of the substate values, whose columns are x-velocities
+// and whose rows are y-velocities, descending such that up is positive.
+func (sv *SubstateView) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = sv.id
+	vels := sv.velocities()
+	descVels := make([]int, len(vels))
+	for i, vel := range vels {
+		descVels[len(vels)-i-1] = vel
+	}
+	addedMap := template.FuncMap{
+		"substateVels":     func() []int { return vels },
+		"substateVelsDesc": func() []int { return descVels },
+	}
+	_, err = parent.Funcs(addedMap).Parse(
+		`{{ define "` + name + `" }}
+		<div style="padding:10px;">
+			<div id="` + sv.id + `-title">Click a cell to inspect its velocity substates</div>
+			<table id="` + sv.id + `" style="font-family: monospace; text-align: right;">
+				<tr>
+					<th>vy \ vx</th>
+					{{ range $vx := substateVels }}<th>{{ $vx }}</th>{{ end }}
+				</tr>
+				{{ range $vy := substateVelsDesc }}
+				<tr>
+					<th>{{ $vy }}</th>
+					{{ range $vx := substateVels }}<td id="` + sv.id + `-{{ $vx }}-{{ $vy }}">-</td>{{ end }}
+				</tr>
+				{{ end }}
+			</table>
+		</div>
+		{{ end }}`)
+	return
+}
@@ -0,0 +1,178 @@
+package cell_views
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// The ids of the trajectory overlay elements, which are drawn within the ValuesGrid svg.
+const (
+	trajectoryMarkerId = "trajectory-marker"
+	trajectoryPathId   = "trajectory-path"
+)
+
+const (
+	// The interval at which the marker advances one step. This must exceed the websocket
+	// client's publication resolution, otherwise intervening steps are dropped.
+	trajectoryStepInterval = time.Millisecond * 150
+	// The number of trailing positions drawn behind the marker; older positions disappear.
+	trajectoryTrailLength = 8
+	// Early episodes may wander for thousands of steps; only their beginning is animated.
+	maxTrajectorySteps = 100
+)
+
+// TrajectoryView animates an agent's episode over the ValuesGrid: a marker that moves
+// per step, followed by a trailing polyline of its most recent positions. Episodes that
+// arrive while another is animating are dropped. The overlay elements themselves are
+// defined by the ValuesGrid, since they must reside within its svg.
+type TrajectoryView struct {
+	id      string
+	height  int
+	cellDim int
+	updates <-chan []fastview.EleUpdate
+}
+
+// NewTrajectoryView returns a view animating the passed episodes over a grid of the passed height,
+// whose cells are of the passed size in pixels, which must be that of the ValuesGrid.
+func NewTrajectoryView(
+	done <-chan struct{},
+	episodes <-chan *grid_world.Episode,
+	height int,
+	cellDim int,
+) (tv *TrajectoryView) {
+	tv = &TrajectoryView{
+		id:      "trajectory",
+		height:  height,
+		cellDim: cellDim,
+	}
+
+	updates := make(chan []fastview.EleUpdate)
+	tv.updates = updates
+	go func() {
+		defer close(updates)
+
+		for episode := range channerics.OrDone(done, episodes) {
+			if !tv.animate(done, episode, updates) {
+				return
+			}
+		}
+	}()
+	return
+}
+
+func (tv *TrajectoryView) Updates() <-chan []fastview.EleUpdate {
+	return tv.updates
+}
+
+// animate sends the updates for each step of the episode at the step interval.
+// Returns false if done was closed.
+func (tv *TrajectoryView) animate(
+	done <-chan struct{},
+	episode *grid_world.Episode,
+	updates chan<- []fastview.EleUpdate,
+) bool {
+	positions := tv.positions(episode)
+	for i := range positions {
+		trailStart := i - trajectoryTrailLength
+		if trailStart < 0 {
+			trailStart = 0
+		}
+
+		select {
+		case updates <- tv.onStep(positions[i], positions[trailStart:i+1]):
+		case <-done:
+			return false
+		}
+
+		select {
+		case <-time.After(trajectoryStepInterval):
+		case <-done:
+			return false
+		}
+	}
+	return true
+}
+
+// positions returns the svg coordinates of the centers of the cells visited by the episode,
+// including its final successor, up to the max number of animated steps.
+func (tv *TrajectoryView) positions(episode *grid_world.Episode) (points [][2]int) {
+	if episode == nil || len(*episode) == 0 {
+		return
+	}
+
+	for i, step := range *episode {
+		if i == maxTrajectorySteps {
+			return
+		}
+		points = append(points, tv.center(step.State))
+	}
+	last := (*episode)[len(*episode)-1]
+	points = append(points, tv.center(last.Successor))
+	return
+}
+
+// center returns the svg coordinates of the center of the passed state's cell,
+// whose y index is flipped per the svg coordinate system, as in Convert.
+func (tv *TrajectoryView) center(state *grid_world.State) [2]int {
+	halfDim := tv.cellDim / 2
+	return [2]int{
+		state.X*tv.cellDim + halfDim,
+		(tv.height-state.Y-1)*tv.cellDim + halfDim,
+	}
+}
+
+// onStep returns the updates moving the marker to the passed position and redrawing the trail.
+func (tv *TrajectoryView) onStep(
+	position [2]int,
+	trail [][2]int,
+) []fastview.EleUpdate {
+	points := make([]string, len(trail))
+	for i, pt := range trail {
+		points[i] = fmt.Sprintf("%d,%d", pt[0], pt[1])
+	}
+
+	return []fastview.EleUpdate{
+		{
+			EleId: trajectoryMarkerId,
+			Ops: []fastview.Op{
+				{
+					Key:   "cx",
+					Value: fmt.Sprintf("%d", position[0]),
+				},
+				{
+					Key:   "cy",
+					Value: fmt.Sprintf("%d", position[1]),
+				},
+				{
+					Key:   "visibility",
+					Value: "visible",
+				},
+			},
+		},
+		{
+			EleId: trajectoryPathId,
+			Ops: []fastview.Op{
+				{
+					Key:   "points",
+					Value: strings.Join(points, " "),
+				},
+			},
+		},
+	}
+}
+
+// Parse defines an empty template, since the overlay elements are defined by the ValuesGrid.
+func (tv *TrajectoryView) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = tv.id
+	_, err = parent.Parse(`{{ define "` + name + `" }}{{ end }}`)
+	return
+}
@@ -1,14 +1,14 @@
 package cell_views
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"math"
 	"strings"
-	"sync"
+	"tabular/grid_world"
 	"tabular/server/fastview"
-
-	channerics "github.com/niceyeti/channerics/channels"
 )
 
 // ValueFunction presents a view of the current value function as a 2d
@@ -16,69 +16,217 @@ import (
 type ValueFunction struct {
 	id      string
 	updates <-chan []fastview.EleUpdate
+	// Angle and colormap changes requested by clients, applied by the update routine.
+	angles    chan float64
+	colorMaps chan ColorMap
+	done      <-chan struct{}
+
+	width, height float64 // canvas size in pixels
+	cellDim       float64 // cell height/width size in pixels
+	xyscale       float64 // pixels per x or y unit
+	zscale        float64 // pixels per z unit
 }
 
+// The default and min cell height/width size in pixels.
+const (
+	DefaultValueFunctionCellDim = 80
+	MinValueFunctionCellDim     = 20
+)
+
+// NewValueFunction returns a value function view of a grid of the passed dimensions in cells,
+// whose cells are of the passed size in pixels, or DefaultValueFunctionCellDim if not positive.
+// The view spans twice the width of the grid, per its projection; see FitCellDim.
 func NewValueFunction(
 	done <-chan struct{},
 	cells <-chan [][]Cell,
+	xCells, yCells int,
+	cellDim int,
 ) (vf *ValueFunction) {
 	id := "valuefunction"
 	if strings.Contains(id, "-") {
 		fmt.Println("WARNING: hyphenated interfere with html/template's `template` directive")
 	}
-	vf = &ValueFunction{id: template.HTMLEscapeString(id)}
-	vf.updates = channerics.Convert(done, cells, vf.onUpdate)
+	vf = &ValueFunction{
+		id:        template.HTMLEscapeString(id),
+		angles:    make(chan float64),
+		colorMaps: make(chan ColorMap),
+		done:      done,
+	}
+	if cellDim <= 0 {
+		cellDim = DefaultValueFunctionCellDim
+	}
+	vf.setParams(xCells, yCells, float64(cellDim))
+	vf.updates = vf.run(done, cells)
 	return
 }
 
+// run emits the view updates for each cell update, and re-emits them for the last cells
+// whenever the view angle or colormap changes. These are owned by this routine, hence are not
+// shared with the template, which always renders the initial view from the default angle.
+func (vf *ValueFunction) run(
+	done <-chan struct{},
+	cells <-chan [][]Cell,
+) <-chan []fastview.EleUpdate {
+	updates := make(chan []fastview.EleUpdate)
+	go func() {
+		defer close(updates)
+
+		proj := vf.newProjection(DefaultAngle)
+		colorMap := DefaultColorMap
+		var last [][]Cell
+		for {
+			select {
+			case <-done:
+				return
+			case cs, ok := <-cells:
+				if !ok {
+					return
+				}
+				last = cs
+			case degrees := <-vf.angles:
+				proj = vf.newProjection(degrees)
+			case colorMap = <-vf.colorMaps:
+			}
+			// No cells have been received, so view changes apply from the first update.
+			if last == nil {
+				continue
+			}
+
+			select {
+			case updates <- vf.onUpdate(last, proj, colorMap):
+			case <-done:
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// Client commands handled by the view.
+const (
+	// CmdSetAngle sets the view angle, in degrees, to one of the PresetAngles.
+	CmdSetAngle = "setAngle"
+	// CmdSetColorMap sets the colormap to that of the ColorMaps named by the command's key.
+	CmdSetColorMap = "setColorMap"
+)
+
+// PresetAngles are the supported view angles in degrees, i.e. the angle of the x and y axes.
+var PresetAngles = []float64{30, 45, 60}
+
+// DefaultAngle is the angle in degrees from which the view is initially rendered.
+const DefaultAngle = 30.0
+
+// ErrInvalidAngle indicates a requested view angle is not one of the PresetAngles.
+var ErrInvalidAngle = errors.New("view angle must be one of the preset angles")
+
+// ErrUnknownColorMap indicates a requested colormap is not one of the ColorMaps.
+var ErrUnknownColorMap = errors.New("unknown colormap")
+
+// HandleCommand applies the CmdSetAngle and CmdSetColorMap commands, returning false for all
+// other commands. The view is shared by all clients, thus so are its angle and colormap.
+func (vf *ValueFunction) HandleCommand(cmd fastview.Command) (bool, error) {
+	switch cmd.Cmd {
+	case CmdSetAngle:
+		return true, vf.SetAngle(cmd.Val)
+	case CmdSetColorMap:
+		return true, vf.SetColorMap(cmd.Key)
+	default:
+		return false, nil
+	}
+}
+
+// SetColorMap sets the colormap by name, re-emitting the view for the current values.
+func (vf *ValueFunction) SetColorMap(name string) error {
+	colorMap, ok := ColorMaps[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownColorMap, name)
+	}
+
+	select {
+	case vf.colorMaps <- colorMap:
+	case <-vf.done:
+	}
+	return nil
+}
+
+// SetAngle sets the view angle in degrees, re-emitting the view for the current values.
+func (vf *ValueFunction) SetAngle(degrees float64) error {
+	if !IsPresetAngle(degrees) {
+		return fmt.Errorf("%w: %v", ErrInvalidAngle, degrees)
+	}
+
+	select {
+	case vf.angles <- degrees:
+	case <-vf.done:
+	}
+	return nil
+}
+
+// IsPresetAngle returns whether the passed angle in degrees is one of the PresetAngles.
+func IsPresetAngle(degrees float64) bool {
+	for _, preset := range PresetAngles {
+		if degrees == preset {
+			return true
+		}
+	}
+	return false
+}
+
 // TODO: Updates() is weird and seemingly trivial. Should this be done otherwise?
 func (vf *ValueFunction) Updates() <-chan []fastview.EleUpdate {
 	return vf.updates
 }
 
-var (
-	// TODO: some of these are parameters that must be set per the first [][]Cell update dimensions.
-	width, height float64      // canvas size in pixels
-	cellDim       float64 = 80 // cell height/width size in pixels
-	cells         float64      // number of grid cells
-	xyscale       float64      // pixels per x or y unit
-	zscale        float64      // pixels per z unit
-	// ang could easily be a dynamic parameter, from the user or otherwise, for a fixed set of view angles (30, 45, etc.)
-	ang                      = math.Pi / 6 // angle of x, y axes (e.g. =30°)
-	sinAng, cosAng           = math.Sin(ang), math.Cos(ang)
-	setViewParams  sync.Once = sync.Once{} // TODO: sync.Once is a code smell. This should change when views are refactored to pass in the initial [][]Cell values.
-)
+// setParams sets the view parameters per the grid dimensions and cell size. These are fixed
+// for the lifetime of the view, hence are safely shared by its update routine and template.
+func (vf *ValueFunction) setParams(xCells, yCells int, cellDim float64) {
+	vf.cellDim = cellDim
+	vf.width = float64(xCells) * cellDim
+	vf.height = float64(yCells) * cellDim
+	vf.zscale = cellDim * 0.3
+	vf.xyscale = cellDim
+}
+
+// isoProjection is an isometric projection from the given angle of the x and y axes.
+type isoProjection struct {
+	sin, cos        float64
+	xyscale, zscale float64
+}
 
-func setParams(cs [][]Cell) {
-	cells = float64(len(cs))
-	width = cells * cellDim
-	height = float64(len(cs[0])) * cellDim
-	zscale = cellDim * 0.3
-	xyscale = cellDim
+// newProjection returns the view's projection from the passed angle in degrees.
+func (vf *ValueFunction) newProjection(degrees float64) isoProjection {
+	rad := degrees * math.Pi / 180
+	return isoProjection{
+		sin:     math.Sin(rad),
+		cos:     math.Cos(rad),
+		xyscale: vf.xyscale,
+		zscale:  vf.zscale,
+	}
 }
 
 // Project applies an isometric projection to the passed points.
-func projectIso(x, y, z float64) (float64, float64) {
-	sx := (x - y) * cosAng * xyscale
-	sy := (x+y)*sinAng*xyscale - z*zscale
+func (proj isoProjection) projectIso(x, y, z float64) (float64, float64) {
+	sx := (x - y) * proj.cos * proj.xyscale
+	sy := (x+y)*proj.sin*proj.xyscale - z*proj.zscale
 	return sx, sy
 }
 
 // Cell-A is bottom left, Cell-B is top left, Cell-C is top right, and Cell-D is bottom right.
 // The polygon is projected into 2d using the lissajous transformation described in The Go Programming Language.
-func getPolyPoints(
+func (vf *ValueFunction) getPolyPoints(
 	cellA Cell,
 	cellB Cell,
 	cellC Cell,
 	cellD Cell,
 ) string {
-	return makeFuncPolygon("", cellA, cellB, cellC, cellD).String()
+	return makeFuncPolygon("", vf.newProjection(DefaultAngle), cellA, cellB, cellC, cellD).String()
 }
 
 // Returns an svg polygon describing these four, adjacent cells.
 // The polygon is projected into 2d using a similar to the lissajous transformation described in The Go Programming Language.
 func makeFuncPolygon(
 	id string,
+	proj isoProjection,
 	cellA Cell,
 	cellB Cell,
 	cellC Cell,
@@ -87,10 +235,10 @@ func makeFuncPolygon(
 	fp = &funcPolygon{
 		Id: id,
 	}
-	fp.ax, fp.ay = projectIso(float64(cellA.X), float64(cellA.Y), cellA.Max)
-	fp.bx, fp.by = projectIso(float64(cellB.X), float64(cellB.Y), cellB.Max)
-	fp.cx, fp.cy = projectIso(float64(cellC.X), float64(cellC.Y), cellC.Max)
-	fp.dx, fp.dy = projectIso(float64(cellD.X), float64(cellD.Y), cellD.Max)
+	fp.ax, fp.ay = proj.projectIso(float64(cellA.X), float64(cellA.Y), cellA.Max)
+	fp.bx, fp.by = proj.projectIso(float64(cellB.X), float64(cellB.Y), cellB.Max)
+	fp.cx, fp.cy = proj.projectIso(float64(cellC.X), float64(cellC.Y), cellC.Max)
+	fp.dx, fp.dy = proj.projectIso(float64(cellD.X), float64(cellD.Y), cellD.Max)
 	return
 }
 
@@ -152,24 +300,83 @@ func avg(f ...float64) float64 {
 	return sum / n
 }
 
+// The fixed fills of the polygons adjoining terminal cells, whose values are fixed by the rewards,
+// or the initial value for walls, so would otherwise muddle the gradient of the learned values.
+const (
+	wallFill   = "gray"
+	finishFill = "gold"
+)
+
+// isTerminalCell returns whether the cell is a wall or finish cell.
+func isTerminalCell(cell Cell) bool {
+	return cell.CellType == grid_world.WALL || grid_world.IsFinish(cell.CellType)
+}
+
+// flattenWalls returns a copy of the passed cells whose walls' values are the passed floor. If
+// every cell is terminal, hence the floor is undefined, the cells are returned unchanged.
+func flattenWalls(cells [][]Cell, floor float64) [][]Cell {
+	if floor == math.MaxFloat64 {
+		return cells
+	}
+	flat := make([][]Cell, len(cells))
+	for ri, row := range cells {
+		flat[ri] = append([]Cell(nil), row...)
+		for ci := range flat[ri] {
+			if flat[ri][ci].CellType == grid_world.WALL {
+				flat[ri][ci].Max = floor
+			}
+		}
+	}
+	return flat
+}
+
+// polygonFill returns the fill of the polygon between the passed cells: the finish fill if any
+// cell is a finish, forming a band along the finish line, else the colormap's fill of the mean
+// value of its non-terminal cells, or the wall fill if all of its cells are walls.
+func polygonFill(colorMap ColorMap, minVal, maxVal float64, cells ...Cell) string {
+	vals := []float64{}
+	for _, cell := range cells {
+		switch {
+		case grid_world.IsFinish(cell.CellType):
+			return finishFill
+		case cell.CellType != grid_world.WALL:
+			vals = append(vals, cell.Max)
+		}
+	}
+	if len(vals) == 0 {
+		return wallFill
+	}
+	return colorMap.Fill(avg(vals...), minVal, maxVal)
+}
+
 // Returns the set of view updates needed for the view to reflect current values.
 func (vf *ValueFunction) onUpdate(
 	cells [][]Cell,
+	proj isoProjection,
+	colorMap ColorMap,
 ) (ops []fastview.EleUpdate) {
-	// TODO: refactor to move/remove
-	setViewParams.Do(func() { setParams(cells) })
+	// The surface consists of polygons between each four adjacent cells, hence a grid of a single
+	// row or column has no surface.
+	if len(cells) < 2 || len(cells[0]) < 2 {
+		return nil
+	}
 
 	// Get the min and max function values, for plotting pseudo-gradients on the surface.
 	// These determine the logical stop points of the gradient extremes; each polygon is
 	// manually shaded with the average of its four max-values. The alternative to this is
 	// that each polygon has-a linear-gradient than it updates, using some complex math.
+	// Terminal cells are excluded, since their values are fixed rather than learned.
 	minVal, maxVal := math.MaxFloat64, -math.MaxFloat64
 	for _, row := range cells {
 		for _, cell := range row {
-			minVal = math.Min(minVal, cell.Max)
-			maxVal = math.Max(maxVal, cell.Max)
+			if !isTerminalCell(cell) {
+				minVal = math.Min(minVal, cell.Max)
+				maxVal = math.Max(maxVal, cell.Max)
+			}
 		}
 	}
+	// Walls are plotted as a flat floor at the min value, rather than at their initial value.
+	cells = flattenWalls(cells, minVal)
 
 	// First build up the polygons, so we can later center their svg coordinates within the view axe.
 	xmin, ymin := math.MaxFloat64, math.MaxFloat64
@@ -183,6 +390,7 @@ func (vf *ValueFunction) onUpdate(
 			cellD := cells[ri+1][ci+1]
 			polygon := makeFuncPolygon(
 				fmt.Sprintf("%d-%d-value-polygon", cell.X, cell.Y),
+				proj,
 				cellA, cellB, cellC, cellD,
 			)
 
@@ -192,8 +400,7 @@ func (vf *ValueFunction) onUpdate(
 			ymin = math.Min(ymin, polygon.MinY())
 			ymax = math.Max(ymax, polygon.MaxY())
 
-			avgVal := avg(cellA.Max, cellB.Max, cellC.Max, cellD.Max)
-			fill := getRGBFill(avgVal, minVal, maxVal)
+			fill := polygonFill(colorMap, minVal, maxVal, cellA, cellB, cellC, cellD)
 
 			ops = append(ops, fastview.EleUpdate{
 				EleId: polygon.Id,
@@ -217,8 +424,8 @@ func (vf *ValueFunction) onUpdate(
 	// Scale down by the maximum required to fit the full plot in view, but only if needed (when scaler < 1.0)
 	scaler := math.Min(
 		math.Min(
-			math.Abs(width/(xmax-xmin)),
-			math.Abs(height/(ymax-ymin)),
+			math.Abs(vf.width/(xmax-xmin)),
+			math.Abs(vf.height/(ymax-ymin)),
 		),
 		1.0,
 	)
@@ -236,14 +443,6 @@ func (vf *ValueFunction) onUpdate(
 	return
 }
 
-// Returns an RGB value defined by where avgVal lies along the number line between minVal and maxVal.
-// Some proportion of RGB values is assigned based on this relative position.
-func getRGBFill(avgVal, minVal, maxVal float64) string {
-	// Allocate fill based on proportion of blue and red only; this should give a basic relative range.
-	redPct := int(100.0 * math.Abs(avgVal) / math.Abs(maxVal-minVal))
-	return fmt.Sprintf("rgb(%d%%,0%%,%d%%)", redPct, 100-redPct)
-}
-
 // Parse returns an svg of polygons plotting the value-function surface as a 2D projection.
 func (vf *ValueFunction) Parse(
 	t *template.Template,
@@ -251,7 +450,7 @@ func (vf *ValueFunction) Parse(
 	// FUTURE: disambiguate the id and template name. Conflating them like this prevents multiple instatiations of views, for instance.
 	name = vf.id
 	addedMap := template.FuncMap{
-		"getPolyPoints": getPolyPoints,
+		"getPolyPoints": vf.getPolyPoints,
 	}
 	// Note: the order of polygon creation forms a nice visual surface by obscuring prior polygons. Order matters.
 	// Scale and height/width are also poorly parameterized, basically hardcoded to loosely center most surfaces.
@@ -262,7 +461,7 @@ func (vf *ValueFunction) Parse(
 			{{ $y_cells := len (index . 0) }}
 			{{ $num_x_polys := sub $x_cells 1 }}
 			{{ $num_y_polys := sub $y_cells 1 }}
-			{{ $cell_width := ` + fmt.Sprintf("%d", int(cellDim)) + ` }}
+			{{ $cell_width := ` + fmt.Sprintf("%d", int(vf.cellDim)) + ` }}
 			{{ $cell_height := $cell_width }}
 			{{ $width := mult $cell_width $x_cells }}
 			{{ $height := mult $cell_height $y_cells }}
@@ -297,3 +496,39 @@ func (vf *ValueFunction) Parse(
 		{{ end }}`)
 	return
 }
+
+// WriteSnapshot writes a standalone svg of the value function of the passed cells, as viewed
+// from the passed angle in degrees, one of the PresetAngles, and colored per the passed colormap.
+// The polygons are those of the live view's updates, stacked in the order of its template, so
+// the snapshot matches the view, and is empty for grids of a single row or column. Snapshots
+// share no state with the view's update routine, hence may be written concurrently with it,
+// given cells converted from the current states.
+func (vf *ValueFunction) WriteSnapshot(w io.Writer, cells [][]Cell, degrees float64, colorMap ColorMap) error {
+	ops := map[string][]fastview.Op{}
+	for _, update := range vf.onUpdate(cells, vf.newProjection(degrees), colorMap) {
+		ops[update.EleId] = update.Ops
+	}
+	attrs := func(eleId string) string {
+		var sb strings.Builder
+		for _, op := range ops[eleId] {
+			fmt.Fprintf(&sb, ` %s="%s"`, op.Key, template.HTMLEscapeString(op.Value))
+		}
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%dpx" height="%dpx" `+
+		`style="shape-rendering: crispEdges; stroke: lightgrey; stroke-opacity: 1.0; stroke-width: 3;">`+"\n",
+		int(vf.width*2), int(vf.height*2))
+	fmt.Fprintf(&sb, "<g%s>\n", attrs(vf.id+"-group"))
+	// Per the template, rows ascend and columns descend, such that nearer polygons obscure farther ones.
+	for ri, row := range cells[:len(cells)-1] {
+		for ci := len(row) - 2; ci >= 0; ci-- {
+			fmt.Fprintf(&sb, "<polygon fill-opacity=\"1.0\"%s/>\n", attrs(fmt.Sprintf("%d-%d-value-polygon", cells[ri][ci].X, cells[ri][ci].Y)))
+		}
+	}
+	sb.WriteString("</g>\n</svg>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
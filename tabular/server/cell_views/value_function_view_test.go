@@ -0,0 +1,96 @@
+package cell_views
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"tabular/grid_world"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValueFunctionShapes(t *testing.T) {
+	tracks := map[string][]string{
+		"single row":    {"-oo+"},
+		"single column": {"+", "o", "-"},
+	}
+
+	for name, track := range tracks {
+		Convey("Given the value function of a "+name+" track", t, func() {
+			states := grid_world.Convert(track, grid_world.DefaultKinematics)
+			cells := Convert(states)
+			done := make(chan struct{})
+			Reset(func() { close(done) })
+			vf := NewValueFunction(done, make(chan [][]Cell), len(states), len(states[0]), 0)
+
+			Convey("Then it has no surface to update", func() {
+				So(vf.onUpdate(cells, vf.newProjection(DefaultAngle), DefaultColorMap), ShouldBeEmpty)
+			})
+
+			Convey("Then its snapshot is a valid, empty svg", func() {
+				buf := &bytes.Buffer{}
+				So(vf.WriteSnapshot(buf, cells, DefaultAngle, DefaultColorMap), ShouldBeNil)
+				So(xml.Unmarshal(buf.Bytes(), new(struct{})), ShouldBeNil)
+				So(strings.Contains(buf.String(), "<polygon"), ShouldBeFalse)
+			})
+		})
+	}
+}
+
+func TestValueFunctionTerminalCells(t *testing.T) {
+	Convey("Given the value function of the debug track, whose walls keep their initial value", t, func() {
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		grid_world.Visit(states, func(s *grid_world.State) {
+			switch {
+			case s.CellType == grid_world.WALL:
+				s.Value.Store(-1000)
+			case grid_world.IsFinish(s.CellType):
+				s.Value.Store(0)
+			default:
+				s.Value.Store(float64(-s.Y))
+			}
+		})
+		cells := Convert(states)
+		done := make(chan struct{})
+		Reset(func() { close(done) })
+		vf := NewValueFunction(done, make(chan [][]Cell), len(states), len(states[0]), 0)
+		fills := map[string]string{}
+		for _, update := range vf.onUpdate(cells, vf.newProjection(DefaultAngle), DefaultColorMap) {
+			for _, op := range update.Ops {
+				if op.Key == "fill" {
+					fills[update.EleId] = op.Value
+				}
+			}
+		}
+
+		Convey("Then cells carry their cell type", func() {
+			So(cells[0][0].CellType, ShouldEqual, grid_world.WALL)
+			So(cells[1][0].CellType, ShouldEqual, grid_world.START)
+			So(cells[5][6].CellType, ShouldEqual, grid_world.FINISH)
+		})
+
+		// Cells are indexed by grid coordinates, whereas their ids are per their svg coordinates,
+		// whose y is flipped, such that the polygon of cells[x][y] has the id of x and 7-y.
+		Convey("Then polygons of walls only are flat gray, and those adjoining a finish are gold", func() {
+			So(fills["3-6-value-polygon"], ShouldEqual, wallFill)
+			So(fills["4-2-value-polygon"], ShouldEqual, finishFill)
+		})
+
+		Convey("Then track polygons span the colormap over the track values only", func() {
+			track := fills["1-6-value-polygon"]
+			So(track, ShouldNotEqual, wallFill)
+			So(track, ShouldNotEqual, finishFill)
+			So(track, ShouldEqual, polygonFill(DefaultColorMap, -6, 0, cells[1][1], cells[2][1], cells[1][2], cells[2][2]))
+			So(track, ShouldEqual, DefaultColorMap.Fill(-1.5, -6, 0))
+		})
+
+		Convey("Then walls are plotted at the min track value rather than their own", func() {
+			flat := flattenWalls(cells, -6)
+			So(flat[0][0].Max, ShouldEqual, -6)
+			So(cells[0][0].Max, ShouldEqual, -1000)
+			So(flat[1][1].Max, ShouldEqual, cells[1][1].Max)
+		})
+	})
+}
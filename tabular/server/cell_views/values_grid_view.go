@@ -12,18 +12,35 @@ import (
 
 type ValuesGrid struct {
 	id      string
+	cellDim int // cell height/width size in pixels
 	updates <-chan []fastview.EleUpdate
 }
 
+// The default and min cell height/width of the values grid in pixels. Below the min, the cell
+// values are not legible.
+const (
+	DefaultValuesCellDim = 75
+	MinValuesCellDim     = 50
+)
+
+// NewValuesGrid returns a values grid view whose cells are of the passed size in pixels, or
+// DefaultValuesCellDim if not positive; see FitCellDim.
 func NewValuesGrid(
 	done <-chan struct{},
 	cells <-chan [][]Cell,
+	cellDim int,
 ) (vg *ValuesGrid) {
 	id := "valuesgrid"
 	if strings.Contains(id, "-") {
 		fmt.Println("WARNING: hyphenated names interfere with html/template's `template` directive")
 	}
-	vg = &ValuesGrid{id: template.HTMLEscapeString(id)}
+	if cellDim <= 0 {
+		cellDim = DefaultValuesCellDim
+	}
+	vg = &ValuesGrid{
+		id:      template.HTMLEscapeString(id),
+		cellDim: cellDim,
+	}
 	vg.updates = channerics.Convert(done, cells, vg.onUpdate)
 	return
 }
@@ -32,8 +49,6 @@ func (vg *ValuesGrid) Updates() <-chan []fastview.EleUpdate {
 	return vg.updates
 }
 
-const valuCellDim = 75
-
 func (vg *ValuesGrid) Parse(
 	parent *template.Template,
 ) (name string, err error) {
@@ -44,7 +59,7 @@ func (vg *ValuesGrid) Parse(
 		<div>
 			{{ $x_cells := len . }}
 			{{ $y_cells := len (index . 0) }}
-			{{ $cell_width := ` + strconv.FormatInt(valuCellDim, 10) + ` }}
+			{{ $cell_width := ` + strconv.Itoa(vg.cellDim) + ` }}
 			{{ $cell_height := $cell_width }}
 			{{ $width := mult $cell_width $x_cells }}
 			{{ $height := mult $cell_height $y_cells }}
@@ -56,7 +71,7 @@ func (vg *ValuesGrid) Parse(
 				style="shape-rendering: crispEdges;">
 				{{ range $row := . }}
 					{{ range $cell := $row }}
-					<g>
+					<g onclick="inspectCell({{ $cell.X }}, {{ sub (sub $y_cells $cell.Y) 1 }})" style="cursor: pointer;">
 						<rect
 							x="{{ mult $cell.X $cell_width }}"
 							y="{{ mult $cell.Y $cell_height }}"
@@ -67,11 +82,11 @@ func (vg *ValuesGrid) Parse(
 							stroke-width="1"/>
 						<text id="{{ $cell.X }}-{{ $cell.Y }}-value-text"
 							x="{{ add (mult $cell.X $cell_width) $half_width }}" 
-							y="{{ add (mult $cell.Y $cell_height) (sub $half_height 10) }}" 
+							y="{{ add (mult $cell.Y $cell_height) (sub $half_height (div $cell_height 8)) }}" 
 							stroke="blue"
 							dominant-baseline="text-top" text-anchor="middle"
 							>{{ printf "%.2f" $cell.Max }}</text>
-						<g transform="translate({{ add (mult $cell.X $cell_width) $half_width }}, {{ add (mult $cell.Y $cell_height) (add $half_height 20)  }})">
+						<g transform="translate({{ add (mult $cell.X $cell_width) $half_width }}, {{ add (mult $cell.Y $cell_height) (add $half_height (div $cell_height 4))  }})">
 							<text id="{{ $cell.X }}-{{ $cell.Y }}-policy-arrow"
 							stroke="blue" stroke-width="1"
 							dominant-baseline="central" text-anchor="middle"
@@ -81,6 +96,19 @@ func (vg *ValuesGrid) Parse(
 					</g>
 					{{ end }}
 				{{ end }}
+				<!-- The trajectory overlay, animated by the TrajectoryView. -->
+				<polyline id="` + trajectoryPathId + `"
+					points=""
+					fill="none"
+					stroke="red"
+					stroke-opacity="0.5"
+					stroke-width="4"
+					stroke-linecap="round"
+					stroke-linejoin="round"/>
+				<circle id="` + trajectoryMarkerId + `"
+					cx="0" cy="0" r="10"
+					fill="red"
+					visibility="hidden"/>
 			</svg>
 		</div>
 		{{ end }}`)
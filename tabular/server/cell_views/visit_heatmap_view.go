@@ -0,0 +1,144 @@
+package cell_views
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"tabular/grid_world"
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// VisitHeatmap presents the exploration coverage of training: each cell is colored by the
+// number of visits to its position relative to all others, such that under-explored regions
+// of the track stand out. Visits are heavily skewed toward the start cells, so cells are
+// colored by the log of their visits. Walls are never visited, so keep their cell fill.
+type VisitHeatmap struct {
+	id      string
+	updates <-chan []fastview.EleUpdate
+	// walls are the wall positions, indexed as the cells, [x][y], per the initial states.
+	walls [][]bool
+}
+
+// heatmapCellDim is the cell height/width of the heatmap in pixels.
+const heatmapCellDim = 40
+
+// heatmapColorMap is perceptually uniform, hence suited to comparing frequencies.
+var heatmapColorMap ColorMap = Viridis{}
+
+func NewVisitHeatmap(
+	done <-chan struct{},
+	cells <-chan [][]Cell,
+	states [][][][]grid_world.State,
+) (vh *VisitHeatmap) {
+	id := "visitheatmap"
+	if strings.Contains(id, "-") {
+		fmt.Println("WARNING: hyphenated names interfere with html/template's `template` directive")
+	}
+	vh = &VisitHeatmap{
+		id:    template.HTMLEscapeString(id),
+		walls: make([][]bool, len(states)),
+	}
+	for x := range states {
+		vh.walls[x] = make([]bool, len(states[x]))
+		for y := range states[x] {
+			vh.walls[x][y] = states[x][y][0][0].CellType == grid_world.WALL
+		}
+	}
+	vh.updates = channerics.Convert(done, cells, vh.onUpdate)
+	return
+}
+
+func (vh *VisitHeatmap) Updates() <-chan []fastview.EleUpdate {
+	return vh.updates
+}
+
+func (vh *VisitHeatmap) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = vh.id
+	_, err = parent.Parse(
+		`{{ define "` + name + `" }}
+		<div>
+			{{ $x_cells := len . }}
+			{{ $y_cells := len (index . 0) }}
+			{{ $cell_dim := ` + strconv.Itoa(heatmapCellDim) + ` }}
+			<svg id="` + vh.id + `"
+				width="{{ add (mult $cell_dim $x_cells) 1 }}px"
+				height="{{ add (mult $cell_dim $y_cells) 1 }}px"
+				style="shape-rendering: crispEdges;">
+				{{ range $row := . }}
+					{{ range $cell := $row }}
+					<rect id="{{ $cell.X }}-{{ $cell.Y }}-visit-rect"
+						onclick="inspectCell({{ $cell.X }}, {{ sub (sub $y_cells $cell.Y) 1 }})"
+						style="cursor: pointer;"
+						x="{{ mult $cell.X $cell_dim }}"
+						y="{{ mult $cell.Y $cell_dim }}"
+						width="{{ $cell_dim }}"
+						height="{{ $cell_dim }}"
+						fill="{{ $cell.Fill }}"
+						stroke="lightgrey"
+						stroke-width="1">
+						<title id="{{ $cell.X }}-{{ $cell.Y }}-visit-title">{{ $cell.Visits }} visits</title>
+					</rect>
+					{{ end }}
+				{{ end }}
+			</svg>
+		</div>
+		{{ end }}`)
+	return
+}
+
+// visitHeat returns the log-scaled visits of the passed cell by which it is colored.
+func visitHeat(cell Cell) float64 {
+	return math.Log1p(float64(cell.Visits))
+}
+
+// Returns the set of view updates needed for the view to reflect the current visit counts.
+// Walls keep their fill and are excluded from the range of visits, such that unvisited track
+// cells are colored as the least visited.
+func (vh *VisitHeatmap) onUpdate(
+	cells [][]Cell,
+) (ops []fastview.EleUpdate) {
+	minHeat, maxHeat := math.MaxFloat64, -math.MaxFloat64
+	for x, row := range cells {
+		for y, cell := range row {
+			if !vh.walls[x][y] {
+				minHeat = math.Min(minHeat, visitHeat(cell))
+				maxHeat = math.Max(maxHeat, visitHeat(cell))
+			}
+		}
+	}
+
+	for x, row := range cells {
+		for y, cell := range row {
+			fill := cell.Fill
+			if !vh.walls[x][y] {
+				fill = heatmapColorMap.Fill(visitHeat(cell), minHeat, maxHeat)
+			}
+			ops = append(ops,
+				fastview.EleUpdate{
+					EleId: fmt.Sprintf("%d-%d-visit-rect", cell.X, cell.Y),
+					Ops: []fastview.Op{
+						{
+							Key:   "fill",
+							Value: fill,
+						},
+					},
+				},
+				fastview.EleUpdate{
+					EleId: fmt.Sprintf("%d-%d-visit-title", cell.X, cell.Y),
+					Ops: []fastview.Op{
+						{
+							Key:   "textContent",
+							Value: fmt.Sprintf("%d visits", cell.Visits),
+						},
+					},
+				})
+		}
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package cell_views
+
+import (
+	"fmt"
+	"testing"
+
+	"tabular/grid_world"
+	"tabular/server/fastview"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVisitHeatmap(t *testing.T) {
+	Convey("Given states whose positions have been visited unevenly", t, func() {
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		for i := 0; i < 100; i++ {
+			states[1][1][0][0].RecordVisit()
+		}
+		states[1][2][0][0].RecordVisit()
+		done := make(chan struct{})
+		defer close(done)
+		vh := NewVisitHeatmap(done, nil, states)
+
+		Convey("When the cells are converted", func() {
+			cells := Convert(states)
+
+			Convey("Then each cell carries the visits of its position", func() {
+				So(cells[1][1].Visits, ShouldEqual, 100)
+				So(cells[1][2].Visits, ShouldEqual, 1)
+				So(cells[2][2].Visits, ShouldEqual, 0)
+			})
+
+			Convey("Then the heatmap colors track cells by their visits and keeps the walls' fill", func() {
+				ops := map[string][]fastview.Op{}
+				for _, update := range vh.onUpdate(cells) {
+					ops[update.EleId] = update.Ops
+				}
+				fill := func(cell Cell) string {
+					return ops[fmt.Sprintf("%d-%d-visit-rect", cell.X, cell.Y)][0].Value
+				}
+				So(fill(cells[1][1]), ShouldEqual, heatmapColorMap.Fill(1, 0, 1))
+				So(fill(cells[2][2]), ShouldEqual, heatmapColorMap.Fill(0, 0, 1))
+				So(fill(cells[1][2]), ShouldNotEqual, fill(cells[2][2]))
+				So(fill(cells[0][0]), ShouldEqual, cells[0][0].Fill)
+				So(ops[fmt.Sprintf("%d-%d-visit-title", cells[1][1].X, cells[1][1].Y)][0].Value, ShouldEqual, "100 visits")
+			})
+		})
+	})
+}
@@ -2,9 +2,12 @@ package fastview
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,8 +21,6 @@ const (
 	// Maximum message size allowed from peer.
 	maxMessageSize = 8192
 
-	// The rate at which ele-updates will be sent to the client, so as not to overburden.
-	pubResolution  = time.Millisecond * 100
 	pingResolution = time.Millisecond * 200
 	// Example code sets this to 10*pingResolution. By definition, it encompasses the number of
 	// pings to tolerate losing before concluding the peer is gone.
@@ -27,11 +28,18 @@ const (
 )
 
 var (
-	upgrader = websocket.Upgrader{}
 	// ErrPongDeadlineExceeded indicates too much time elapsed without a pong from the client.
 	ErrPongDeadlineExceeded error = errors.New("client disconnect, pong deadline exceeded")
+	// errPeerClosed indicates the client closed the websocket, which stops the client's routines
+	// like any error, but is not an error of Sync.
+	errPeerClosed = errors.New("client closed the websocket")
 )
 
+// prioritizer is optionally implemented by updates that must never be dropped, such as Message.
+type prioritizer interface {
+	Priority() bool
+}
+
 // A client encapsulates a mechanism for publishing updates unidirectionally
 // to websocket clients. As much as possible I'd like this to represent
 // a standard websocket client, including the future capability of reading client
@@ -41,39 +49,64 @@ var (
 // idempotent web-client's views with it. Likewise shared realtime data displays.
 // Though consider WebRTC (udp) and whether TCP (websockets) per use case.
 type client[T any] struct {
-	updates <-chan T
-	ws      *websock
-	rootCtx context.Context
+	updates  <-chan T
+	commands chan Command
+	ws       *websock
+	rootCtx  context.Context
+	// The min interval between updates sent to the client, so as not to overburden.
+	pubResolution time.Duration
+	// Counts the updates dropped because the socket was congested; nil if not counted.
+	dropped *atomic.Int64
 }
 
 // NewClient returns a publisher for sending ui or other updates to clients
 // via websocket. Items in the updates chan should represent idempotent update
 // objects, since intervening updates are discarded when they are received too
-// quickly (> pub-rate), and only sending the latest update is sufficient to
+// quickly (within pubResolution of the last), and only sending the latest update is sufficient to
 // specify the new client state (a ui, for example).
+// The upgrade is rejected, and replied to with an http error, if checkOrigin returns
+// false; if checkOrigin is nil, requests whose Origin host differs from their Host are rejected.
+// Updates dropped because the socket is congested are added to dropped, unless it is nil.
 func NewClient[T any](
 	updates <-chan T,
 	w http.ResponseWriter,
 	r *http.Request,
+	checkOrigin func(*http.Request) bool,
+	pubResolution time.Duration,
+	dropped *atomic.Int64,
 ) (*client[T], error) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: checkOrigin,
+	}
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		// The upgrader has already replied with an http error.
 		return nil, err
 	}
 
 	return &client[T]{
-		updates: updates,
-		ws:      NewWebSocket(ws),
-		rootCtx: r.Context(),
+		updates:       updates,
+		commands:      make(chan Command),
+		ws:            NewWebSocket(ws),
+		rootCtx:       r.Context(),
+		pubResolution: pubResolution,
+		dropped:       dropped,
 	}, nil
 }
 
+// Commands returns the commands sent by the client, which is closed when the client
+// stops reading messages. It must be drained while Sync is running, since reading
+// messages from the client also services its pongs.
+func (cli *client[T]) Commands() <-chan Command {
+	return cli.commands
+}
+
 // Sync starts routines to publish incoming updates to the passed client request,
-// after upgrading it to a websocket from http. Updates are published at a compiled
-// rate; updates received faster than that rate are discarded. This makes this publisher
+// after upgrading it to a websocket from http. Updates are published at most once per
+// pubResolution; updates received faster than that are discarded. This makes this publisher
 // best-suited to idempotent updates.
-// Sync returns nil upon client disconnect or an error if an unexpected error occurred.
+// Sync returns nil upon client disconnect or cancellation of the request's context, or an
+// error if an unexpected error occurred. The websocket is closed before Sync returns.
 // NOTE: the websocket code exemplifies the externalmost layer in Uncle Bobs architecture: net,
 // db, drivers, etc. This should be broken out as such, using his "dependency rule".
 // NOTE: taking too long here could block senders on the updates chan; this will surely change
@@ -90,17 +123,30 @@ func (cli *client[T]) Sync() error {
 	group.Go(func() error {
 		return cli.publish(groupCtx)
 	})
+	// Upon cancellation or failure of any routine, close the websocket, such that the peer
+	// receives a close frame rather than a dropped connection, and the blocked reader returns.
+	group.Go(func() error {
+		<-groupCtx.Done()
+		_ = cli.ws.Close()
+		return nil
+	})
 
-	return group.Wait()
+	if err := group.Wait(); !errors.Is(err, errPeerClosed) {
+		return err
+	}
+	return nil
 }
 
 // Runs the ping-pong for the client liveness check.
 // NOTE: This function requires that readPump is running to ensure the pong handler is called.
 func (cli *client[T]) pingPong(ctx context.Context) error {
-	pong := make(chan struct{})
-	defer close(pong)
+	// The reader may outlive this routine while the websocket closes, so pongs must never block.
+	pong := make(chan struct{}, 1)
 	cli.ws.Conn().SetPongHandler(func(_ string) error {
-		pong <- struct{}{}
+		select {
+		case pong <- struct{}{}:
+		default:
+		}
 		return nil
 	})
 
@@ -137,27 +183,60 @@ func (cli *client[T]) ping(ctx context.Context) error {
 		})
 }
 
-// readMessages monitors for messages from the client.
+// readMessages monitors for messages from the client and publishes them as commands.
 // Errors returned by websocket Read methods are permanent, hence any error
-// must trigger full teardown.
+// must trigger full teardown. Malformed messages are logged and ignored.
 func (cli *client[T]) readMessages(ctx context.Context) error {
+	defer close(cli.commands)
+
 	for {
-		// FUTURE: this is where it would be easy to implement a bidirectional @client by merely
-		// passing received messages to an output chan of messages from the client.
+		var msg []byte
 		err := cli.ws.Read(
 			ctx,
 			func(ws *websocket.Conn) (readErr error) {
-				_, _, readErr = ws.ReadMessage()
+				_, msg, readErr = ws.ReadMessage()
 				return
 			})
 		if err != nil {
+			// Closure by either peer is not an error, but closure by the client must still stop
+			// the other routines, hence is returned as errPeerClosed.
+			if cli.ws.isClosed() {
+				return nil
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return errPeerClosed
+			}
 			return err
 		}
+		// Read returns nil without reading when the context is done.
+		if msg == nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+
+		cmd := Command{}
+		if err = json.Unmarshal(msg, &cmd); err != nil {
+			log.Println("client message ignored:", err)
+			continue
+		}
+
+		select {
+		case cli.commands <- cmd:
+		case <-ctx.Done():
+			return nil
+		}
 	}
 }
 
+// publish writes the incoming updates to the websocket until ctx is done or the updates chan is
+// closed. Congestion is transient, e.g. a slow ping holding the socket, so an update that cannot
+// be written within the write deadline is dropped, as are updates received too quickly; any other
+// write error is fatal. Priority updates are never dropped: they are retried until written.
 func (cli *client[T]) publish(ctx context.Context) error {
-	lastSync := time.Now()
+	// Zero-valued such that the first update, e.g. the initial state, is always sent.
+	var lastSync time.Time
 
 	for {
 		select {
@@ -168,27 +247,27 @@ func (cli *client[T]) publish(ctx context.Context) error {
 			if !ok {
 				return nil
 			}
-			// Drop updates when receiving too quickly.
-			if time.Since(lastSync) < pubResolution {
-				break
+			// Drop updates when receiving too quickly, unless they are a priority. Priority updates
+			// do not count toward the resolution, such that the following update is not dropped.
+			p, ok := any(updates).(prioritizer)
+			priority := ok && p.Priority()
+			if !priority {
+				if time.Since(lastSync) < cli.pubResolution {
+					break
+				}
+				lastSync = time.Now()
 			}
 
-			lastSync = time.Now()
-			err := cli.ws.Write(
-				ctx,
-				func(ws *websocket.Conn) (writeErr error) {
-					if writeErr = ws.SetWriteDeadline(time.Now().Add(writeWait)); writeErr != nil {
-						writeErr = fmt.Errorf("failed to set deadline: %T %w", writeErr, writeErr)
-						return
-					}
-
-					if writeErr = ws.WriteJSON(updates); writeErr != nil {
-						if isError(writeErr) {
-							writeErr = fmt.Errorf("publish failed: %T %v", writeErr, writeErr)
-						}
-					}
-					return
-				})
+			err := cli.write(ctx, updates)
+			for priority && errors.Is(err, ErrSockCongestion) {
+				err = cli.write(ctx, updates)
+			}
+			if errors.Is(err, ErrSockCongestion) {
+				if cli.dropped != nil {
+					cli.dropped.Add(1)
+				}
+				break
+			}
 			if err != nil {
 				return err
 			}
@@ -196,6 +275,26 @@ func (cli *client[T]) publish(ctx context.Context) error {
 	}
 }
 
+// write writes the passed update to the websocket as json, returning ErrSockCongestion if the
+// socket is not available within the write deadline.
+func (cli *client[T]) write(ctx context.Context, update T) error {
+	return cli.ws.Write(
+		ctx,
+		func(ws *websocket.Conn) (err error) {
+			if err = ws.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				err = fmt.Errorf("failed to set deadline: %T %w", err, err)
+				return
+			}
+
+			if err = ws.WriteJSON(update); err != nil {
+				if isError(err) {
+					err = fmt.Errorf("publish failed: %T %v", err, err)
+				}
+			}
+			return
+		})
+}
+
 func isError(err error) bool {
 	return err != nil && websocket.IsUnexpectedCloseError(
 		err,
@@ -249,21 +348,27 @@ func (sock *websock) isClosed() bool {
 	}
 }
 
-// Closes the websocket. This should only be called once no further read/writers exist.
+// Closes the websocket per the closing handshake: a close frame is sent to the peer, which
+// is then given the grace period to reply with its own, upon which any pending read returns.
+// The underlying connection is then closed regardless. Close must only be called once.
 func (sock *websock) Close() error {
 	if sock.isClosed() {
 		return ErrSockClosed
 	}
 
-	// Blocks all subsequent read/write attempts
-	sock.readSem <- struct{}{}
+	// Blocks all subsequent writes
 	sock.writeSem <- struct{}{}
-
 	_ = sock.ws.SetWriteDeadline(time.Now().Add(writeWait))
 	_ = sock.ws.WriteMessage(
 		websocket.CloseMessage,
 		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	time.Sleep(closeGracePeriod)
+
+	// Blocks all subsequent reads, once the pending read receives the peer's close frame.
+	select {
+	case sock.readSem <- struct{}{}:
+	case <-time.After(closeGracePeriod):
+	}
+	close(sock.closed)
 	return sock.ws.Close()
 }
 
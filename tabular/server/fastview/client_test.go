@@ -0,0 +1,138 @@
+package fastview
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClientSyncPeerClose(t *testing.T) {
+	Convey("Given a client syncing a websocket", t, func() {
+		synced := make(chan error, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cli, err := NewClient(make(chan int), w, r, nil, time.Millisecond, nil)
+			if err != nil {
+				synced <- err
+				return
+			}
+			go func() {
+				for range cli.Commands() {
+				}
+			}()
+			synced <- cli.Sync()
+		}))
+		defer ts.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		Convey("When the peer closes the websocket", func() {
+			So(conn.WriteMessage(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")), ShouldBeNil)
+
+			Convey("Then Sync returns nil promptly, rather than upon a failed write or ping", func() {
+				select {
+				case err := <-synced:
+					So(err, ShouldBeNil)
+				case <-time.After(pongWait / 2):
+					t.Fatal("Sync did not return upon the peer's closure")
+				}
+			})
+		})
+	})
+}
+
+func TestClientPublishCongestion(t *testing.T) {
+	Convey("Given a client whose websocket is held by another writer", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		updates := make(chan int)
+		var dropped atomic.Int64
+		cli := &client[int]{
+			updates: updates,
+			// The socket is never written, since its write semaphore is never released.
+			ws: &websock{
+				writeSem: make(chan struct{}, 1),
+				closed:   make(chan struct{}),
+			},
+			dropped: &dropped,
+		}
+		cli.ws.writeSem <- struct{}{}
+
+		published := make(chan error, 1)
+		go func() { published <- cli.publish(ctx) }()
+
+		Convey("When an update cannot be written within the write deadline", func() {
+			updates <- 1
+			deadline := time.Now().Add(5 * writeDeadline)
+			for dropped.Load() == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			Convey("Then the update is dropped and counted, and publishing continues", func() {
+				So(dropped.Load(), ShouldEqual, 1)
+				select {
+				case updates <- 2:
+				case err := <-published:
+					So(err, ShouldBeNil)
+					t.Fatal("publish returned upon congestion")
+				}
+				cancel()
+				So(<-published, ShouldBeNil)
+			})
+		})
+	})
+}
+
+// priorityUpdate is an update that must never be dropped.
+type priorityUpdate int
+
+func (priorityUpdate) Priority() bool { return true }
+
+func TestClientPublishPriorityCongestion(t *testing.T) {
+	Convey("Given a client whose websocket is held by another writer", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		updates := make(chan priorityUpdate)
+		var dropped atomic.Int64
+		cli := &client[priorityUpdate]{
+			updates: updates,
+			ws: &websock{
+				writeSem: make(chan struct{}, 1),
+				closed:   make(chan struct{}),
+			},
+			dropped: &dropped,
+		}
+		cli.ws.writeSem <- struct{}{}
+
+		published := make(chan error, 1)
+		go func() { published <- cli.publish(ctx) }()
+
+		Convey("When a priority update cannot be written within the write deadline", func() {
+			updates <- 1
+
+			Convey("Then it is retried rather than dropped, until the context is done", func() {
+				select {
+				case updates <- 2:
+					t.Fatal("publish received another update while the priority update was pending")
+				case err := <-published:
+					So(err, ShouldBeNil)
+					t.Fatal("publish returned upon congestion")
+				case <-time.After(3 * writeDeadline):
+				}
+				So(dropped.Load(), ShouldEqual, 0)
+				cancel()
+				So(<-published, ShouldBeNil)
+			})
+		})
+	})
+}
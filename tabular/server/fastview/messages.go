@@ -0,0 +1,94 @@
+package fastview
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// SchemaVersion is the version of the websocket message schema. Clients request it via the
+// websocket url's "v" query param, and dispatch each Message on its Type:
+//
+//	{"version": 1, "type": "ele", "ops": [{"EleId": "1-1-value-text", "Ops": [{"Key": "textContent", "Value": "-12.34"}]}]}
+//
+// Version 0 is the legacy schema, a bare array of ele-updates, which is sent to clients that do
+// not request a version, such as pages loaded before an upgrade. It will be removed in version 2.
+const SchemaVersion = 1
+
+// The types of Message.
+const (
+	// MessageEle carries ele-updates in its Ops.
+	MessageEle = "ele"
+	// MessageFullState carries the latest ele-update of every element of the views in its Ops,
+	// which the client applies in one pass, as any ele-updates, e.g. upon reconnecting.
+	MessageFullState = "fullstate"
+)
+
+// Message is the envelope of every message pushed to clients over the websocket, such that
+// messages other than ele-updates may share the socket without ambiguity.
+type Message struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	// Ops are the ele-updates of the message.
+	Ops []EleUpdate `json:"ops,omitempty"`
+}
+
+// Priority returns whether the message must be published regardless of the publish resolution:
+// full-state messages are sent once, upon connecting or request, so dropping one loses it.
+func (msg Message) Priority() bool {
+	return msg.Type == MessageFullState
+}
+
+// MarshalJSON encodes version 0 messages per the legacy schema, as their bare ele-updates.
+func (msg Message) MarshalJSON() ([]byte, error) {
+	if msg.Version == 0 {
+		return json.Marshal(msg.Ops)
+	}
+	// The alias has no methods, hence is encoded per the struct tags rather than recursively.
+	type message Message
+	return json.Marshal(message(msg))
+}
+
+// EleMessages wraps each of the passed ele-updates in a Message of the passed schema version.
+// The returned chan is closed once the passed chan is or done is.
+func EleMessages(
+	done <-chan struct{},
+	updates <-chan []EleUpdate,
+	version int,
+) <-chan Message {
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for {
+			select {
+			case <-done:
+				return
+			case ops, ok := <-updates:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- Message{Version: version, Type: MessageEle, Ops: ops}:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return messages
+}
+
+// RequestedVersion returns the schema version requested by the passed websocket request, 0 if
+// none, or an error if it is not a supported version.
+func RequestedVersion(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("v")
+	if v == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 0 || version > SchemaVersion {
+		return 0, fmt.Errorf("unsupported schema version %q, the latest is %d", v, SchemaVersion)
+	}
+	return version, nil
+}
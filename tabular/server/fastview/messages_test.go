@@ -0,0 +1,65 @@
+package fastview
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMessages(t *testing.T) {
+	Convey("Given ele-updates", t, func() {
+		ops := []EleUpdate{{EleId: "a", Ops: []Op{{Key: "textContent", Value: "1"}}}}
+
+		Convey("Then version 1 messages are encoded in their envelope", func() {
+			data, err := json.Marshal(Message{Version: 1, Type: MessageEle, Ops: ops})
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual,
+				`{"version":1,"type":"ele","ops":[{"EleId":"a","Ops":[{"Key":"textContent","Value":"1"}]}]}`)
+		})
+
+		Convey("Then version 0 messages are encoded as their bare ele-updates", func() {
+			data, err := json.Marshal(Message{Type: MessageEle, Ops: ops})
+			So(err, ShouldBeNil)
+			legacy, _ := json.Marshal(ops)
+			So(string(data), ShouldEqual, string(legacy))
+		})
+
+		Convey("Then they are wrapped in messages of the passed version until done", func() {
+			done := make(chan struct{})
+			updates := make(chan []EleUpdate)
+			messages := EleMessages(done, updates, SchemaVersion)
+			updates <- ops
+			So(<-messages, ShouldResemble, Message{Version: SchemaVersion, Type: MessageEle, Ops: ops})
+			close(done)
+			_, ok := <-messages
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given messages of each type", t, func() {
+		Convey("Then only full-state messages are a priority", func() {
+			So(Message{Version: 1, Type: MessageFullState}.Priority(), ShouldBeTrue)
+			So(Message{Version: 1, Type: MessageEle}.Priority(), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given websocket requests", t, func() {
+		Convey("Then the requested version defaults to the legacy schema", func() {
+			version, err := RequestedVersion(httptest.NewRequest("GET", "/ws", nil))
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 0)
+		})
+
+		Convey("Then supported versions are accepted, and others rejected", func() {
+			version, err := RequestedVersion(httptest.NewRequest("GET", "/ws?v=1", nil))
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 1)
+			for _, v := range []string{"2", "-1", "one"} {
+				_, err = RequestedVersion(httptest.NewRequest("GET", "/ws?v="+v, nil))
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
@@ -22,6 +22,22 @@ type Op struct {
 	Value string
 }
 
+// Command is a message sent from a client to the server over the websocket, as json:
+//
+//	{"cmd": "pause"}
+//	{"cmd": "setParam", "key": "epsilon", "val": 0.2}
+//	{"cmd": "inspect", "x": 3, "y": 4}
+//
+// The set of commands and their semantics are defined by the server;
+// Key, Val, X and Y are merely optional command arguments.
+type Command struct {
+	Cmd string  `json:"cmd"`
+	Key string  `json:"key,omitempty"`
+	Val float64 `json:"val,omitempty"`
+	X   int     `json:"x,omitempty"`
+	Y   int     `json:"y,omitempty"`
+}
+
 // ViewComponent implements server side views: Write to allow writing their initial form
 // to an output stream and Updates to obtain the chan by which ele-updates are notified.
 type ViewComponent interface {
@@ -32,3 +48,10 @@ type ViewComponent interface {
 	// 'works' a posteriori.
 	Parse(*template.Template) (string, error)
 }
+
+// CommandHandler is optionally implemented by view components that respond to client commands.
+type CommandHandler interface {
+	// HandleCommand applies the command, returning false if it is not handled by the view, or
+	// an error if it is but cannot be applied, e.g. due to invalid arguments.
+	HandleCommand(Command) (bool, error)
+}
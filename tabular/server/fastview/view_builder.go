@@ -46,7 +46,10 @@ func (vb *ViewBuilder[DataModel, ViewModel]) WithView(
 }
 
 // WithContext ensures that all downstream channels are closed when context is cancelled.
-// TODO: channel closure communication needs to be evaluated.
+// The view-model chan, its broadcast to each view, and the views' update chans each close
+// upon cancellation, and every send among them also selects on it, so none block once the
+// context is cancelled, regardless of whether the model chan or the views' readers are done.
+// Without a context, the chans close only once the model chan is closed.
 func (vb *ViewBuilder[DataModel, ViewModel]) WithContext(
 	ctx context.Context,
 ) *ViewBuilder[DataModel, ViewModel] {
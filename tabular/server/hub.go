@@ -0,0 +1,158 @@
+package server
+
+import (
+	"sync"
+
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// hub multiplexes a single ele-update channel to any number of websocket clients,
+// which may connect and disconnect at any time. The hub also maintains the latest
+// update for every ele-id, such that newly connected clients immediately receive
+// the full current state rather than waiting for the next update of each element.
+//
+// Updates are merged per ele-id for each subscriber until the subscriber receives
+// them, so a slow client never blocks the hub or other clients; it merely receives
+// fewer, larger updates. This is valid since ele-updates are idempotent.
+type hub struct {
+	mu          sync.Mutex
+	latest      map[string]fastview.EleUpdate
+	subscribers map[*subscriber]struct{}
+}
+
+// subscriber is a single client's pending updates, and a notification that they exist.
+type subscriber struct {
+	pending map[string]fastview.EleUpdate
+	notify  chan struct{}
+}
+
+// newHub returns a hub that publishes the passed updates to its subscribers until done is closed.
+func newHub(
+	done <-chan struct{},
+	source <-chan []fastview.EleUpdate,
+) *hub {
+	h := &hub{
+		latest:      map[string]fastview.EleUpdate{},
+		subscribers: map[*subscriber]struct{}{},
+	}
+
+	go func() {
+		for updates := range channerics.OrDone(done, source) {
+			h.publish(updates)
+		}
+	}()
+
+	return h
+}
+
+// publish merges the passed updates into the current state and every subscriber's pending updates.
+func (h *hub) publish(updates []fastview.EleUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, update := range updates {
+		h.latest[update.EleId] = update
+	}
+	for sub := range h.subscribers {
+		sub.merge(updates)
+	}
+}
+
+// Subscribe returns a channel of all updates, beginning with the full current state, until done is closed.
+func (h *hub) Subscribe(done <-chan struct{}) <-chan []fastview.EleUpdate {
+	_, updates := h.subscribe(done, true)
+	return updates
+}
+
+// SubscribeLatest returns the latest update of every ele-id, and a channel of all subsequent
+// updates until done is closed, such that none are missed between the two.
+func (h *hub) SubscribeLatest(done <-chan struct{}) ([]fastview.EleUpdate, <-chan []fastview.EleUpdate) {
+	return h.subscribe(done, false)
+}
+
+// Latest returns the latest update of every ele-id.
+func (h *hub) Latest() []fastview.EleUpdate {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slicedVals(h.latest)
+}
+
+// subscribe returns the latest update of every ele-id, and a channel of all subsequent updates
+// until done is closed, which begins with the latest updates if pendLatest is set.
+func (h *hub) subscribe(
+	done <-chan struct{},
+	pendLatest bool,
+) ([]fastview.EleUpdate, <-chan []fastview.EleUpdate) {
+	sub := &subscriber{
+		pending: map[string]fastview.EleUpdate{},
+		notify:  make(chan struct{}, 1),
+	}
+
+	h.mu.Lock()
+	latest := slicedVals(h.latest)
+	if pendLatest {
+		sub.merge(latest)
+	}
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	output := make(chan []fastview.EleUpdate)
+	go func() {
+		defer close(output)
+		defer h.unsubscribe(sub)
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-sub.notify:
+			}
+
+			h.mu.Lock()
+			updates := slicedVals(sub.pending)
+			sub.pending = map[string]fastview.EleUpdate{}
+			h.mu.Unlock()
+
+			select {
+			case output <- updates:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return latest, output
+}
+
+func (h *hub) unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, sub)
+}
+
+// merge adds the passed updates to the subscriber's pending updates and notifies it, if any.
+// The caller must hold the hub's lock.
+func (sub *subscriber) merge(updates []fastview.EleUpdate) {
+	if len(updates) == 0 {
+		return
+	}
+
+	for _, update := range updates {
+		sub.pending[update.EleId] = update
+	}
+	// Non-blocking: a pending notification already covers these updates.
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// returns the values of a map as a slice
+func slicedVals[T1 comparable, T2 any](mp map[T1]T2) (sliced []T2) {
+	for _, v := range mp {
+		sliced = append(sliced, v)
+	}
+	return
+}
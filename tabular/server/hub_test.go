@@ -0,0 +1,103 @@
+package server
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"tabular/server/fastview"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func eleUpdate(id, val string) fastview.EleUpdate {
+	return fastview.EleUpdate{
+		EleId: id,
+		Ops:   []fastview.Op{{Key: "textContent", Value: val}},
+	}
+}
+
+// receive reads one batch of updates from the passed chan, sorted by ele-id.
+func receive(updates <-chan []fastview.EleUpdate) []fastview.EleUpdate {
+	select {
+	case batch := <-updates:
+		sort.Slice(batch, func(i, j int) bool { return batch[i].EleId < batch[j].EleId })
+		return batch
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+func TestHub(t *testing.T) {
+	Convey("Given a hub", t, func() {
+		done := make(chan struct{})
+		defer close(done)
+		source := make(chan []fastview.EleUpdate)
+		h := newHub(done, source)
+
+		Convey("When multiple clients are subscribed", func() {
+			clientDone := make(chan struct{})
+			defer close(clientDone)
+			first := h.Subscribe(clientDone)
+			second := h.Subscribe(clientDone)
+
+			source <- []fastview.EleUpdate{eleUpdate("a", "1")}
+
+			Convey("Every client receives every update", func() {
+				So(receive(first), ShouldResemble, []fastview.EleUpdate{eleUpdate("a", "1")})
+				So(receive(second), ShouldResemble, []fastview.EleUpdate{eleUpdate("a", "1")})
+			})
+		})
+
+		Convey("When a client subscribes after updates were published", func() {
+			source <- []fastview.EleUpdate{eleUpdate("a", "1"), eleUpdate("b", "1")}
+			source <- []fastview.EleUpdate{eleUpdate("a", "2")}
+			// The source is unbuffered and relayed by one intermediate routine,
+			// so two more sends ensure the prior updates were published.
+			source <- []fastview.EleUpdate{}
+			source <- []fastview.EleUpdate{}
+
+			clientDone := make(chan struct{})
+			defer close(clientDone)
+			late := h.Subscribe(clientDone)
+
+			Convey("It first receives the latest value of every element", func() {
+				So(receive(late), ShouldResemble, []fastview.EleUpdate{
+					eleUpdate("a", "2"),
+					eleUpdate("b", "1"),
+				})
+			})
+		})
+
+		Convey("When a client subscribes to the latest values after updates were published", func() {
+			source <- []fastview.EleUpdate{eleUpdate("a", "1"), eleUpdate("b", "1")}
+			source <- []fastview.EleUpdate{}
+			source <- []fastview.EleUpdate{}
+
+			clientDone := make(chan struct{})
+			defer close(clientDone)
+			latest, updates := h.SubscribeLatest(clientDone)
+
+			Convey("It is returned the latest value of every element, and receives only subsequent updates", func() {
+				sort.Slice(latest, func(i, j int) bool { return latest[i].EleId < latest[j].EleId })
+				So(latest, ShouldResemble, []fastview.EleUpdate{eleUpdate("a", "1"), eleUpdate("b", "1")})
+				source <- []fastview.EleUpdate{eleUpdate("b", "2")}
+				So(receive(updates), ShouldResemble, []fastview.EleUpdate{eleUpdate("b", "2")})
+			})
+		})
+
+		Convey("When a client disconnects", func() {
+			clientDone := make(chan struct{})
+			updates := h.Subscribe(clientDone)
+			close(clientDone)
+
+			Convey("Its channel is closed and it is unsubscribed", func() {
+				for range updates {
+				}
+				h.mu.Lock()
+				defer h.mu.Unlock()
+				So(h.subscribers, ShouldBeEmpty)
+			})
+		})
+	})
+}
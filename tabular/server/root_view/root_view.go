@@ -2,13 +2,15 @@ package root_view
 
 import (
 	"context"
+	"errors"
 	"html/template"
-	"log"
 	"time"
 
 	"tabular/grid_world"
+	"tabular/reinforcement"
 	"tabular/server/cell_views"
 	"tabular/server/fastview"
+	"tabular/server/stats_views"
 
 	channerics "github.com/niceyeti/channerics/channels"
 )
@@ -18,38 +20,113 @@ import (
 type RootView struct {
 	views   []fastview.ViewComponent
 	updates <-chan []fastview.EleUpdate
+	// The value function view, also rendered on demand as a standalone snapshot.
+	valueFunction *cell_views.ValueFunction
 }
 
-// NewRootView create the main page and the views it contains.
+// NewRootView create the main page and the views it contains, returning an error if the views
+// cannot be built, e.g. from an empty state grid. The views' updates are batched per batchWindow.
 func NewRootView(
 	ctx context.Context,
 	initialStates [][][][]grid_world.State,
 	stateUpdates <-chan [][][][]grid_world.State,
-) *RootView {
+	metricsUpdates <-chan reinforcement.Metrics,
+	episodeUpdates <-chan *grid_world.Episode,
+	batchWindow time.Duration,
+) (*RootView, error) {
 	// Build all of the views on server construction. This is a tad weird, and has alternatives.
 	// For example views could be constructed on the fly per endpoint, broken out by view (separate pages).
 	// But this could also be done by building/managing the views in advance and querying them on the fly.
 	// So whatevs. I guess its nice that the factory provides this mobile encapsulation of views and chans,
 	// and extends other options. Serving views is the server's only responsibility, so this fits.
+	if len(initialStates) == 0 || len(initialStates[0]) == 0 {
+		return nil, errors.New("no states from which to build views")
+	}
+
+	// The grid views are scaled down to fit the width of the track within the viewport. The value
+	// function's projection spans twice the grid's width.
+	xCells, yCells := len(initialStates), len(initialStates[0])
+	valuesCellDim := cell_views.FitCellDim(xCells, cell_views.DefaultValuesCellDim, cell_views.MinValuesCellDim)
+	valueFunctionCellDim := cell_views.FitCellDim(
+		2*xCells, cell_views.DefaultValueFunctionCellDim, cell_views.MinValueFunctionCellDim)
+
+	var valueFunction *cell_views.ValueFunction
 	views, err := fastview.NewViewBuilder[[][][][]grid_world.State, [][]cell_views.Cell]().
 		WithContext(ctx).
 		WithModel(stateUpdates, cell_views.Convert).
 		WithView(func(
 			done <-chan struct{},
 			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
-			return cell_views.NewValuesGrid(done, cellUpdates)
+			return cell_views.NewValuesGrid(done, cellUpdates, valuesCellDim)
+		}).
+		WithView(func(
+			done <-chan struct{},
+			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
+			return cell_views.NewPolicyView(done, cellUpdates)
+		}).
+		WithView(func(
+			done <-chan struct{},
+			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
+			return cell_views.NewVisitHeatmap(done, cellUpdates, initialStates)
+		}).
+		WithView(func(
+			done <-chan struct{},
+			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
+			valueFunction = cell_views.NewValueFunction(done, cellUpdates, xCells, yCells, valueFunctionCellDim)
+			return valueFunction
+		}).
+		WithView(func(
+			done <-chan struct{},
+			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
+			return cell_views.NewSubstateView(done, cellUpdates, initialStates)
 		}).
 		WithView(func(
 			done <-chan struct{},
 			cellUpdates <-chan [][]cell_views.Cell) fastview.ViewComponent {
-			return cell_views.NewValueFunction(done, cellUpdates)
+			return stats_views.NewTelemetryView(done, cellUpdates, time.Second)
 		}).
 		Build()
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
+	// Training progress views are driven by the training metrics, independent of the grid.
+	statsViews, err := fastview.NewViewBuilder[reinforcement.Metrics, reinforcement.Metrics]().
+		WithContext(ctx).
+		WithModel(metricsUpdates, func(metrics reinforcement.Metrics) reinforcement.Metrics {
+			return metrics
+		}).
+		WithView(func(
+			done <-chan struct{},
+			metricsUpdates <-chan reinforcement.Metrics) fastview.ViewComponent {
+			return stats_views.NewProgressView(done, metricsUpdates)
+		}).
+		Build()
+
+	if err != nil {
+		return nil, err
+	}
+	views = append(views, statsViews...)
+
+	// The trajectory overlay is driven by sampled episodes, drawn over the values grid.
+	trajectoryViews, err := fastview.NewViewBuilder[*grid_world.Episode, *grid_world.Episode]().
+		WithContext(ctx).
+		WithModel(episodeUpdates, func(episode *grid_world.Episode) *grid_world.Episode {
+			return episode
+		}).
+		WithView(func(
+			done <-chan struct{},
+			episodeUpdates <-chan *grid_world.Episode) fastview.ViewComponent {
+			return cell_views.NewTrajectoryView(done, episodeUpdates, yCells, valuesCellDim)
+		}).
+		Build()
+
+	if err != nil {
+		return nil, err
+	}
+	views = append(views, trajectoryViews...)
+
 	// TODO: this is a bandaid. Similar to the index-html template note, by abstracting
 	// the views I have left the server in a state of insufficient abstraction. The next
 	// step will be figuring out where some of this can live appropriately. For example,
@@ -58,12 +135,31 @@ func NewRootView(
 	// channels and throttles its updates to the clients. The primary models here are all fastview,
 	// so perhaps this is clearly part of a controller for fastview. Testability drives
 	// decomposition.
-	updates := fanIn(ctx.Done(), views)
+	updates := fanIn(ctx.Done(), views, batchWindow)
 
 	return &RootView{
-		views:   views,
-		updates: updates,
+		views:         views,
+		updates:       updates,
+		valueFunction: valueFunction,
+	}, nil
+}
+
+// ValueFunction returns the value function view, e.g. for writing snapshots.
+func (rv *RootView) ValueFunction() *cell_views.ValueFunction {
+	return rv.valueFunction
+}
+
+// HandleCommand offers the client command to each view that handles commands, returning
+// false if none handled it, or the error of the view that did.
+func (rv *RootView) HandleCommand(cmd fastview.Command) (bool, error) {
+	for _, vc := range rv.views {
+		if handler, ok := vc.(fastview.CommandHandler); ok {
+			if handled, err := handler.HandleCommand(cmd); handled {
+				return true, err
+			}
+		}
 	}
+	return false, nil
 }
 
 // Updates returns the main ele-update channel for all the views.
@@ -71,8 +167,10 @@ func (rt *RootView) Updates() <-chan []fastview.EleUpdate {
 	return rt.updates
 }
 
-// Parse builds the main page's template, with websocket bootstrap code, and returns its name.
-// It also sets up the func-map that many child components depend on.
+// Parse builds the main page's template, which loads the websocket bootstrap script, and returns
+// its name. It also sets up the func-map that many child components depend on. The parent must
+// define the "wsURL" func, returning the url of the websocket endpoint, and serve the script at
+// /static/bootstrap.js.
 func (rv *RootView) Parse(
 	parent *template.Template,
 ) (name string, err error) {
@@ -96,6 +194,7 @@ func (rv *RootView) Parse(
 				}
 				return j
 			},
+			"colorMapNames": cell_views.ColorMapNames,
 		})
 
 	viewTemplates := []string{}
@@ -114,7 +213,7 @@ func (rv *RootView) Parse(
 		bodySpec += (`{{ template "` + tname + `" . }}`)
 	}
 
-	// The main template bootstraps the rest: sets up client websocket and updates, aggregates views.
+	// The main template bootstraps the rest: loads the client websocket script, aggregates views.
 	name = "mainpage"
 	indexTemplate := `
 	{{ define "` + name + `" }}
@@ -122,36 +221,33 @@ func (rv *RootView) Parse(
 	<html>
 		<head>
 			<link rel="icon" href="data:,">
-			<script>
-				const ws = new WebSocket("ws://localhost:8080/ws");
-				ws.onopen = function (event) {
-					console.log("Web socket opened")
-				};
-
-				// Listen for errors
-				ws.onerror = function (event) {
-					console.log('WebSocket error: ', event);
-				};
-
-				// The meat: when the server pushes view updates, find these eles and update them.
-				ws.onmessage = function (event) {
-					items = JSON.parse(event.data)
-					// FUTURE: scope the updates per view. Not really needed now, just grab them by id from doc level.
-					// Iterate the data updates
-					for (const update of items) {
-						const ele = document.getElementById(update.EleId)
-						for (const op of update.Ops) {
-							if (op.Key === "textContent") {
-								ele.textContent = op.Value;
-							} else {
-								ele.setAttribute(op.Key, op.Value)
-							}
-						}
-					}
-				}
-			</script>
+			<script src="/static/bootstrap.js" data-websocket="{{ wsURL }}"></script>
 		</head>
 		<body>
+		<div style="padding:10px;">
+			<button onclick="sendCommand('pause')">Pause</button>
+			<button onclick="sendCommand('resume')">Resume</button>
+			<button onclick="sendCommand('step')" title="Apply a single episode while paused">Step</button>
+			<button onclick="sendCommand('reset')">Reset</button>
+			<button onclick="sendCommand('resync')">Resync</button>
+			<select id="param-key">
+				<option value="epsilon">epsilon</option>
+				<option value="eta">eta</option>
+				<option value="gamma">gamma</option>
+			</select>
+			<input id="param-val" type="number" min="0" max="1" step="0.01">
+			<button onclick="sendParam()">Set</button>
+			<select id="view-angle" onchange="sendAngle()">
+				<option value="30">30&deg;</option>
+				<option value="45">45&deg;</option>
+				<option value="60">60&deg;</option>
+			</select>
+			<select id="color-map" onchange="sendColorMap()">
+				{{ range colorMapNames }}
+				<option value="{{ . }}" {{ if eq . "redblue" }}selected{{ end }}>{{ . }}</option>
+				{{ end }}
+			</select>
+		</div>
 		` + bodySpec + `
 		</body></html>
 	{{ end }}
@@ -162,11 +258,12 @@ func (rv *RootView) Parse(
 }
 
 // fanIn aggregates the views' ele-update channels into a single channel,
-// and throttle its output.
+// and throttle its output to a batch per batchWindow.
 // TODO: see note in caller. This is needs a different home
 func fanIn(
 	done <-chan struct{},
 	views []fastview.ViewComponent,
+	batchWindow time.Duration,
 ) <-chan []fastview.EleUpdate {
 	inputs := make([]<-chan []fastview.EleUpdate, len(views))
 	for i, view := range views {
@@ -175,7 +272,7 @@ func fanIn(
 	return batchify(
 		done,
 		channerics.Merge(done, inputs...),
-		time.Millisecond*20)
+		batchWindow)
 }
 
 // batchify batches within the passed time frame before sending, over-writing previously
@@ -0,0 +1,114 @@
+package root_view
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// closed returns whether the passed chan closes within the timeout, draining any values.
+func closed[T any](ch <-chan T, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return true
+			}
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// viewRoutines returns the stacks of the goroutines, other than the caller's, running code of the
+// view pipeline: the views, fastview, and channerics. Unlike a count of goroutines, these are
+// unaffected by unrelated routines, e.g. those of other tests or the runtime.
+func viewRoutines() []string {
+	buf := make([]byte, 1<<20)
+	stacks := strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n")
+	var routines []string
+	// The first stack is the caller's.
+	for _, stack := range stacks[1:] {
+		for _, pkg := range []string{"tabular/server/", "niceyeti/channerics"} {
+			if strings.Contains(stack, pkg) {
+				routines = append(routines, stack)
+				break
+			}
+		}
+	}
+	return routines
+}
+
+// settledViewRoutines polls viewRoutines until none remain or the timeout elapses, returning those
+// that remain. Cancelled routines take a moment to be scheduled and return.
+func settledViewRoutines(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+	for {
+		routines := viewRoutines()
+		if len(routines) == 0 || time.Now().After(deadline) {
+			return routines
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRootViewCancellation(t *testing.T) {
+	Convey("Given a root view whose inputs are streaming", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		stateUpdates := make(chan [][][][]grid_world.State)
+		metricsUpdates := make(chan reinforcement.Metrics)
+		episodeUpdates := make(chan *grid_world.Episode)
+		rv, err := NewRootView(ctx, states, stateUpdates, metricsUpdates, episodeUpdates, time.Millisecond)
+		So(err, ShouldBeNil)
+
+		// The producers never close their chans, as in training, so only cancellation stops the views.
+		episode := &grid_world.Episode{{State: &states[1][1][0][0], Successor: &states[1][2][0][0]}}
+		producers := make(chan struct{})
+		go func() {
+			defer close(producers)
+			for {
+				select {
+				case stateUpdates <- states:
+				case metricsUpdates <- reinforcement.Metrics{EpisodeCount: 1}:
+				case episodeUpdates <- episode:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		Convey("When the context is cancelled mid-stream", func() {
+			for i := 0; i < 5; i++ {
+				<-rv.Updates()
+			}
+			cancel()
+
+			Convey("Then every view's updates chan closes", func() {
+				for _, view := range rv.views {
+					So(closed(view.Updates(), 5*time.Second), ShouldBeTrue)
+				}
+			})
+
+			Convey("Then the aggregated updates chan closes", func() {
+				So(closed(rv.Updates(), 5*time.Second), ShouldBeTrue)
+			})
+
+			Convey("Then no routine of the pipeline is left blocked", func() {
+				So(closed(rv.Updates(), 5*time.Second), ShouldBeTrue)
+				<-producers
+				So(settledViewRoutines(5*time.Second), ShouldBeEmpty)
+			})
+		})
+	})
+}
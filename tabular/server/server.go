@@ -2,15 +2,26 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	channerics "github.com/niceyeti/channerics/channels"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"tabular/grid_world"
+	"tabular/reinforcement"
 	"tabular/server/cell_views"
 	"tabular/server/fastview"
 	"tabular/server/root_view"
@@ -20,13 +31,12 @@ import (
 // 1) websocket pingpong
 // 2) Uncle Bob app rearchitecting
 
-// Server serves a single page, to a single client, over a single websocket.
+// Server serves a single page to any number of clients, each over its own websocket.
 // So intentionally very little generalization, this is just a prototype. This is
 // currently useful for solo RL development, just to develop and see html views; but it
-// is completely incomplete as a real webserver, as the ele-update channel can be
-// listened to by only a single client, among similar quantification issues. You
-// could go hog-wild and fully abstract each endpoint (a page and websocket combo),
-// beginning with simply muxing the ele-update channel to service multiple clients.
+// is completely incomplete as a real webserver. The root view's ele-update channel is
+// multiplexed to clients by a hub, but you could go hog-wild and fully abstract each
+// endpoint (a page and websocket combo).
 // The server currently builds and represents a single view; no layering at all.
 // For experience it would be desirable to rearchitect the server into appropriate
 // layers via Uncle Bob's architecture  manifesto. Currently it is a mishmash of
@@ -43,19 +53,178 @@ import (
 // to something like ads. But websockets are more expressive but connection heavy.
 type Server struct {
 	addr string
-	// TODO: eliminate? 'last' patterns are always a code smell; the initial state should be pumped regardless...
-	lastUpdate [][]cell_views.Cell
-	rootView   *root_view.RootView
+	// The state grid, which training updates in place. The page is rendered from its current
+	// values, and the hub then pushes the latest update of every element upon connecting,
+	// so clients connecting mid-training are immediately consistent.
+	states   [][][][]grid_world.State
+	rootView *root_view.RootView
+	// Multiplexes the root view's updates to all connected clients.
+	hub *hub
+	// Pushes the current states through the views, alongside the state updates of training.
+	refreshes chan [][][][]grid_world.State
+	// Controls training per client commands; nil if training is not controllable.
+	controller TrainingController
+	// Closed when the server should shut down, per the context passed to NewServer.
+	done <-chan struct{}
+	// The active websocket clients, which must close before shutdown completes.
+	clients sync.WaitGroup
+	// Reports whether a websocket upgrade request's origin is permitted; nil for same-origin.
+	checkOrigin func(*http.Request) bool
+	// The secret which websocket upgrades and control requests must pass as their 'token' query
+	// param; empty if auth is disabled.
+	authToken string
+	// The rates at which the views' updates are batched and published to clients.
+	publish PublishConfig
+	// The number of connected websocket clients.
+	connected atomic.Int64
+	// The number of updates dropped because a client's websocket was congested.
+	dropped atomic.Int64
+	// The registry of the Prometheus metrics served at /metrics.
+	registry *prometheus.Registry
 }
 
-// NewServer initializes all of the views and returns a server.
+// PublishConfig sets the rates at which the views' updates are sent to clients.
+type PublishConfig struct {
+	// BatchWindow is the interval over which the views' updates are batched, such that only
+	// the latest update of each element in the window is sent.
+	BatchWindow time.Duration
+	// Resolution is the min interval between the batches sent to each client; intervening
+	// batches are dropped. It must not be shorter than the batch window, else clients would
+	// be sent partial batches.
+	Resolution time.Duration
+}
+
+// The publish config's defaults, used for any of its fields that are zero.
+const (
+	DefaultBatchWindow       = 20 * time.Millisecond
+	DefaultPublishResolution = 100 * time.Millisecond
+)
+
+// ServerOption configures optional server behavior.
+type ServerOption func(*Server)
+
+// WithCheckOrigin sets the function reporting whether a websocket upgrade request's origin
+// is permitted. By default, upgrades are only permitted from the same origin as the server,
+// e.g. from the served page; cross-origin upgrades are rejected with 403 Forbidden.
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) ServerOption {
+	return func(server *Server) {
+		server.checkOrigin = checkOrigin
+	}
+}
+
+// WithAuthToken requires websocket upgrades and control requests, such as /reset, to pass the
+// secret token as their 'token' query param, else they are rejected with 401 Unauthorized. The
+// page forwards the token of its own url to its websocket, so clients browse to /?token=secret.
+// An empty token disables auth, the default, which is only suitable for local development.
+func WithAuthToken(token string) ServerOption {
+	return func(server *Server) {
+		server.authToken = token
+	}
+}
+
+// WithPublishConfig sets the rates at which the views' updates are batched and published to
+// clients. Zero fields are set to their defaults.
+func WithPublishConfig(config PublishConfig) ServerOption {
+	return func(server *Server) {
+		if config.BatchWindow != 0 {
+			server.publish.BatchWindow = config.BatchWindow
+		}
+		if config.Resolution != 0 {
+			server.publish.Resolution = config.Resolution
+		}
+	}
+}
+
+// validate returns an error if the publish config is invalid.
+func (config PublishConfig) validate() error {
+	if config.BatchWindow < 0 || config.Resolution < 0 {
+		return fmt.Errorf("publish intervals must not be negative: %+v", config)
+	}
+	if config.Resolution < config.BatchWindow {
+		return fmt.Errorf("publish resolution %v is shorter than the batch window %v",
+			config.Resolution, config.BatchWindow)
+	}
+	return nil
+}
+
+// The time allowed for shutdown, including the websocket closing handshakes with clients.
+const shutdownTimeout = 15 * time.Second
+
+// TrainingController pauses, resumes, and adjusts training on behalf of clients.
+type TrainingController interface {
+	Pause()
+	Resume()
+	// SetParam sets a hyper-parameter, returning an error if the key or value is invalid.
+	SetParam(key string, val float64) error
+	// Reset re-initializes the state values and restarts training from scratch.
+	Reset() error
+	// Hold runs fn while training applies no updates to the state values, e.g. to snapshot them
+	// coherently; see reinforcement.Gate.Hold.
+	Hold(fn func())
+	// Step applies a single episode while training is paused, returning its metrics; see
+	// reinforcement.Trainer.Step.
+	Step() (reinforcement.Metrics, error)
+}
+
+// Client commands, sent as fastview.Commands.
+const (
+	CmdPause    = "pause"
+	CmdResume   = "resume"
+	CmdSetParam = "setParam"
+	CmdReset    = "reset"
+	// CmdResync requests a full-state message; see fastview.MessageFullState.
+	CmdResync = "resync"
+	// CmdStep applies a single training episode while paused, after which the views are refreshed.
+	CmdStep = "step"
+)
+
+// NewServer initializes all of the views and returns a server, which shuts down when ctx is cancelled.
+// An error is returned if the publish config is invalid, the views cannot be built, or their
+// templates cannot be parsed.
 func NewServer(
 	ctx context.Context,
 	addr string,
 	initialStates [][][][]grid_world.State,
 	stateUpdates <-chan [][][][]grid_world.State,
+	metricsUpdates <-chan reinforcement.Metrics,
+	episodeUpdates <-chan *grid_world.Episode,
+	controller TrainingController,
+	opts ...ServerOption,
 ) (*Server, error) {
-	rootView := root_view.NewRootView(ctx, initialStates, stateUpdates)
+	server := &Server{
+		addr:       addr,
+		states:     initialStates,
+		controller: controller,
+		done:       ctx.Done(),
+		refreshes:  make(chan [][][][]grid_world.State),
+		publish: PublishConfig{
+			BatchWindow: DefaultBatchWindow,
+			Resolution:  DefaultPublishResolution,
+		},
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	if err := server.publish.validate(); err != nil {
+		return nil, err
+	}
+	telemetry := newTelemetry(server)
+	server.registry = telemetry.registry()
+	if metricsUpdates != nil {
+		metricsUpdates = telemetry.observe(ctx.Done(), metricsUpdates)
+	}
+
+	stateUpdates = channerics.Merge(ctx.Done(), stateUpdates, server.refreshes)
+	rootView, err := root_view.NewRootView(
+		ctx, initialStates, stateUpdates, metricsUpdates, episodeUpdates, server.publish.BatchWindow)
+	if err != nil {
+		return nil, fmt.Errorf("build views: %w", err)
+	}
+	// The page is parsed per request, but parsing it upfront surfaces template errors at startup.
+	probe := template.New("index.html").Funcs(template.FuncMap{"wsURL": func() string { return "" }})
+	if _, err = rootView.Parse(probe); err != nil {
+		return nil, fmt.Errorf("parse views: %w", err)
+	}
 
 	// TODO: this is incomplete/confused abstraction of the views. The last bit of coupling is that
 	// the cells must be passed into the template; the template seems to reside at a higher level
@@ -66,44 +235,131 @@ func NewServer(
 	// fully view-agnostic server whose only responsibility is serving. This would be worthwhile
 	// golang MVC server research. Best to read Uncle Bob's architecture manifesto and redo the
 	// whole app.
-	initialCells := cell_views.Convert(initialStates)
-
-	return &Server{
-		addr:       addr,
-		lastUpdate: initialCells,
-		rootView:   rootView,
-	}, nil
+	server.rootView = rootView
+	server.hub = newHub(ctx.Done(), rootView.Updates())
+	return server, nil
 }
 
-func (server *Server) Serve() (err error) {
+// handler returns the server's routes.
+func (server *Server) handler() http.Handler {
 	mux := mux.NewRouter()
 
 	mux.HandleFunc("/", server.serveIndex).
 		Methods(http.MethodGet)
-	mux.HandleFunc("/ws", server.serveWebsocket).
+	mux.HandleFunc("/ws", server.authorize(server.serveWebsocket)).
+		Methods(http.MethodGet)
+	mux.HandleFunc("/snapshot.svg", server.serveSnapshot).
+		Methods(http.MethodGet)
+	mux.HandleFunc("/reset", server.authorize(server.serveReset)).
+		Methods(http.MethodPost)
+	mux.HandleFunc("/value", server.serveValue).
+		Methods(http.MethodGet)
+	mux.HandleFunc("/grid", server.serveGrid).
+		Methods(http.MethodGet)
+	mux.Handle("/metrics", promhttp.HandlerFor(server.registry, promhttp.HandlerOpts{})).
+		Methods(http.MethodGet)
+	mux.PathPrefix("/static/").Handler(serveStatic()).
 		Methods(http.MethodGet)
 
 	//http.HandleFunc("/profile", pprof.Profile)
 
-	if err = http.ListenAndServe(server.addr, mux); err != nil {
-		err = fmt.Errorf("serve: %w", err)
+	return mux
+}
+
+// authorize wraps the passed handler, responding 401 to requests whose 'token' query param is
+// not the server's auth token, if any, before the handler runs, e.g. before websocket upgrades.
+func (server *Server) authorize(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if server.authToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(server.authToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
 	}
+}
 
-	return
+// Serve serves until the server's context is cancelled, upon which it stops accepting
+// connections and closes the websockets of connected clients, waiting for their closing
+// handshakes up to the shutdown timeout. Serve returns nil after a graceful shutdown.
+func (server *Server) Serve() (err error) {
+	httpServer := &http.Server{
+		Addr:    server.addr,
+		Handler: server.handler(),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-server.done:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err = httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	// Shutdown does not track hijacked connections, so wait for the websocket clients,
+	// which close themselves upon the server's cancellation.
+	clientsClosed := make(chan struct{})
+	go func() {
+		server.clients.Wait()
+		close(clientsClosed)
+	}()
+	select {
+	case <-clientsClosed:
+	case <-shutdownCtx.Done():
+		return fmt.Errorf("shutdown: %w", shutdownCtx.Err())
+	}
+
+	return nil
 }
 
 // NOTE: the websocket code is fubar until/if I refactor the server and fastviews. This code
 // does not strictly define the relationships between clients and websockets, nor closure.
-// serveWebsocket publishes state updates to the client via websocket.
-// TODO: managing multiple websockets, when multiple pages open, etc. These scenarios.
-// This currently assumes this handler is hit only once, one client.
+// serveWebsocket publishes state updates to the client via websocket. Each client subscribes
+// to the hub for the lifetime of its request, receiving the full current state upon connecting.
+// Messages are encoded per the schema version requested by the client; see fastview.SchemaVersion.
 // TODO: handle closure and failure paths for websocket.
 func (server *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	version, err := fastview.RequestedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	server.clients.Add(1)
+	defer server.clients.Done()
+
+	// The websocket outlives the request per Shutdown, so it is also closed upon the server's cancellation.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-server.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	r = r.WithContext(ctx)
+
 	// FWIW, there is a DDOS risk here by not limiting the number of websocket and http->websocket upgrade attempts per client.
-	client, err := fastview.NewClient(server.rootView.Updates(), w, r)
+	// The context is cancelled when this handler returns, which unsubscribes the client.
+	resyncs := make(chan struct{}, 1)
+	updates := server.clientMessages(ctx.Done(), resyncs, version)
+	client, err := fastview.NewClient(
+		updates, w, r, server.checkOrigin, server.publish.Resolution, &server.dropped)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Println("websocket endpoint:", err)
+		return
 	}
+	server.connected.Add(1)
+	defer server.connected.Add(-1)
+	go server.handleCommands(client.Commands(), resyncs)
 
 	if err := client.Sync(); err != nil {
 		log.Println("websocket endpoint:", err)
@@ -111,6 +367,135 @@ func (server *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// clientMessages returns the messages of a single client until done is closed: a full-state
+// message upon connecting and upon each resync, and the hub's ele-updates otherwise. Clients of
+// the legacy schema only receive ele-updates, beginning with the latest of every element, and
+// their resyncs are ignored.
+func (server *Server) clientMessages(
+	done <-chan struct{},
+	resyncs <-chan struct{},
+	version int,
+) <-chan fastview.Message {
+	if version < 1 {
+		return fastview.EleMessages(done, server.hub.Subscribe(done), version)
+	}
+	latest, updates := server.hub.SubscribeLatest(done)
+	eleMessages := fastview.EleMessages(done, updates, version)
+
+	messages := make(chan fastview.Message)
+	go func() {
+		defer close(messages)
+		// The full state is sent first, such that the client is synced upon connecting.
+		msg := fullState(version, latest)
+		for {
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+
+			var ok bool
+			select {
+			case <-done:
+				return
+			case <-resyncs:
+				msg = fullState(version, server.hub.Latest())
+			case msg, ok = <-eleMessages:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return messages
+}
+
+// fullState returns a full-state message of the passed ele-updates, the latest of every element.
+func fullState(version int, latest []fastview.EleUpdate) fastview.Message {
+	return fastview.Message{
+		Version: version,
+		Type:    fastview.MessageFullState,
+		Ops:     latest,
+	}
+}
+
+// refresh pushes the current states through the views, whose ele-updates are then published to
+// clients as usual, e.g. after a step, whose changes are not otherwise published since state
+// updates are sent per the run's episodes.
+func (server *Server) refresh() {
+	select {
+	case server.refreshes <- server.states:
+	case <-server.done:
+	}
+}
+
+// snapshotCells returns the cells of the current states. The states are read atomically, hence
+// never race with training, and while training is held if it is controllable, such that the
+// cells are also internally consistent rather than mixing values from before and after some updates.
+func (server *Server) snapshotCells() (cells [][]cell_views.Cell) {
+	convert := func() { cells = cell_views.Convert(server.states) }
+	if server.controller == nil {
+		convert()
+		return
+	}
+	server.controller.Hold(convert)
+	return
+}
+
+// handleCommands applies client commands until the client's command chan is closed.
+// Resync commands are forwarded to the client's resyncs, coalescing those already pending.
+// View commands are offered to the root view first. Unknown commands and invalid parameters
+// are logged and ignored.
+func (server *Server) handleCommands(commands <-chan fastview.Command, resyncs chan<- struct{}) {
+	for cmd := range commands {
+		if cmd.Cmd == CmdResync {
+			select {
+			case resyncs <- struct{}{}:
+			default:
+			}
+			continue
+		}
+
+		// View commands, e.g. changing the view angle, are handled by the views themselves.
+		if handled, err := server.rootView.HandleCommand(cmd); handled {
+			if err != nil {
+				log.Println("view command ignored:", err)
+			}
+			continue
+		}
+
+		if server.controller == nil {
+			continue
+		}
+
+		switch cmd.Cmd {
+		case CmdPause:
+			server.controller.Pause()
+		case CmdResume:
+			server.controller.Resume()
+		case CmdSetParam:
+			if err := server.controller.SetParam(cmd.Key, cmd.Val); err != nil {
+				log.Println("set param command ignored:", err)
+			}
+		case CmdReset:
+			if err := server.controller.Reset(); err != nil {
+				log.Println("reset command failed:", err)
+			}
+		case CmdStep:
+			metrics, err := server.controller.Step()
+			if err != nil {
+				log.Println("step command failed:", err)
+				continue
+			}
+			log.Printf("stepped episode %d: %.0f steps, mean abs delta %.4f, %d policy changes",
+				metrics.EpisodeCount, metrics.MeanEpisodeLength, metrics.MeanAbsDelta, metrics.PolicyChanges)
+			server.refresh()
+		default:
+			log.Println("unknown client command:", cmd.Cmd)
+		}
+	}
+}
+
 // Serve the index.html main page.
 func (server *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -123,19 +508,166 @@ func (server *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "text/html")
 
-	// FUTURE: see note elsewhere. Execute requires the initial State or Cell data, but the server
+	// The page connects its websocket to the address by which the client reached the server,
+	// rather than the listen address, whose host may be empty or unreachable from the client.
+	wsURL := url.URL{Scheme: "ws", Host: r.Host, Path: "/ws"}
+	if r.TLS != nil {
+		wsURL.Scheme = "wss"
+	}
+	funcs := template.FuncMap{
+		"wsURL": func() string { return wsURL.String() },
+	}
+
+	// FUTURE: see note elsewhere. Execute requires the current State or Cell data, but the server
 	// shouldn't know about either type, hence this should be moved down...
-	if err := renderTemplate(w, server.rootView, server.lastUpdate); err != nil {
+	if err := renderTemplate(w, server.rootView, server.snapshotCells(), funcs); err != nil {
 		_, _ = w.Write([]byte(err.Error()))
 	}
 }
 
+// serveSnapshot serves a standalone svg of the current value function, e.g. for publication.
+// The optional 'angle' and 'colormap' query params select one of the cell_views.PresetAngles
+// and cell_views.ColorMaps, defaulting to those initially presented by the page. PNG is not
+// supported, since rasterizing svg requires dependencies beyond the standard library; per
+// the 'format' param, it is rejected rather than silently served as svg.
+func (server *Server) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "svg" {
+		http.Error(w, fmt.Sprintf("unsupported snapshot format %q, only svg is supported", format), http.StatusNotImplemented)
+		return
+	}
+
+	degrees := cell_views.DefaultAngle
+	if angle := query.Get("angle"); angle != "" {
+		var err error
+		if degrees, err = strconv.ParseFloat(angle, 64); err != nil || !cell_views.IsPresetAngle(degrees) {
+			http.Error(w, fmt.Sprintf("invalid angle %q, expected one of %v", angle, cell_views.PresetAngles), http.StatusBadRequest)
+			return
+		}
+	}
+	colorMap := cell_views.DefaultColorMap
+	if name := query.Get("colormap"); name != "" {
+		var ok bool
+		if colorMap, ok = cell_views.ColorMaps[name]; !ok {
+			http.Error(w, fmt.Sprintf("unknown colormap %q, expected one of %v", name, cell_views.ColorMapNames()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The cells are converted from the current states, held coherent, and are rendered
+	// independently of the view's update routine.
+	w.Header().Set("Content-Type", "image/svg+xml")
+	cells := server.snapshotCells()
+	if err := server.rootView.ValueFunction().WriteSnapshot(w, cells, degrees, colorMap); err != nil {
+		log.Println("snapshot:", err)
+	}
+}
+
+// serveReset re-initializes the state values and restarts training, responding once training
+// has restarted. Responds 404 if training is not controllable.
+func (server *Server) serveReset(w http.ResponseWriter, r *http.Request) {
+	if server.controller == nil {
+		http.Error(w, "training is not controllable", http.StatusNotFound)
+		return
+	}
+	if err := server.controller.Reset(); err != nil {
+		http.Error(w, fmt.Sprintf("reset failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stateValue is the response of /value.
+type stateValue struct {
+	Value    float64 `json:"value"`
+	CellType string  `json:"cellType"`
+}
+
+// serveValue responds with the current value and cell type of the state given by the x, y, vx,
+// and vy query params, e.g. /value?x=3&y=4&vx=1&vy=-2. The position is in grid coordinates, whose
+// origin is the bottom left of the track, and vx and vy are velocities, not indices, hence may be
+// negative. Responds 400 if a param is missing, not an integer, or out of the grid's range.
+func (server *Server) serveValue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	maxVelocity := (len(server.states[0][0]) - 1) / 2
+	params := []struct {
+		key      string
+		min, max int
+		val      int
+	}{
+		{key: "x", max: len(server.states) - 1},
+		{key: "y", max: len(server.states[0]) - 1},
+		{key: "vx", min: -maxVelocity, max: maxVelocity},
+		{key: "vy", min: -maxVelocity, max: maxVelocity},
+	}
+	for i := range params {
+		param := &params[i]
+		val, err := strconv.Atoi(query.Get(param.key))
+		if err != nil || val < param.min || val > param.max {
+			http.Error(w, fmt.Sprintf("invalid %s %q, expected an integer in [%d, %d]",
+				param.key, query.Get(param.key), param.min, param.max), http.StatusBadRequest)
+			return
+		}
+		param.val = val
+	}
+
+	x, y, vx, vy := params[0].val, params[1].val, params[2].val, params[3].val
+	state := &server.states[x][y][grid_world.VelIndex(server.states, vx)][grid_world.VelIndex(server.states, vy)]
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stateValue{
+		Value:    state.Value.AtomicRead(),
+		CellType: string(state.CellType),
+	}); err != nil {
+		log.Println("value:", err)
+	}
+}
+
+// gridLayout is the response of /grid.
+type gridLayout struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// The number of velocities of each component, from -MaxVelocity to MaxVelocity.
+	Velocities  int `json:"velocities"`
+	MaxVelocity int `json:"maxVelocity"`
+	// The cell type of each position, indexed by [x][y].
+	Cells [][]string `json:"cells"`
+}
+
+// serveGrid responds with the track's immutable layout, from which external renderers may draw
+// the track before consuming value updates. Cells are indexed [x][y] in grid coordinates, as by
+// /value: y increases upward from the bottom row of the track. The console track and the svg
+// views are instead ordered top row first, so their row r is y = height-1-r.
+func (server *Server) serveGrid(w http.ResponseWriter, r *http.Request) {
+	layout := gridLayout{
+		Width:       len(server.states),
+		Height:      len(server.states[0]),
+		Velocities:  len(server.states[0][0]),
+		MaxVelocity: (len(server.states[0][0]) - 1) / 2,
+		Cells:       make([][]string, len(server.states)),
+	}
+	for x := range layout.Cells {
+		layout.Cells[x] = make([]string, layout.Height)
+	}
+	grid_world.VisitXYStates(server.states, func(velstates [][]grid_world.State) {
+		state := &velstates[0][0]
+		layout.Cells[state.X][state.Y] = string(state.CellType)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(layout); err != nil {
+		log.Println("grid:", err)
+	}
+}
+
+// renderTemplate renders the view component with the passed data. The passed funcs are
+// available to the templates of the view component and its children.
 func renderTemplate(
 	w io.Writer,
 	vc fastview.ViewComponent,
 	data interface{},
+	funcs template.FuncMap,
 ) (err error) {
-	t := template.New("index.html")
+	t := template.New("index.html").Funcs(funcs)
 	var tname string
 	if tname, err = vc.Parse(t); err != nil {
 		return
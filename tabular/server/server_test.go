@@ -0,0 +1,819 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+	"tabular/server/fastview"
+
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// dial attempts a websocket upgrade from the passed origin, returning the handshake response status.
+func dial(ts *httptest.Server, origin string) (int, error) {
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {origin}})
+	if conn != nil {
+		defer conn.Close()
+	}
+	if resp == nil {
+		return 0, err
+	}
+	return resp.StatusCode, err
+}
+
+func TestServerOrigins(t *testing.T) {
+	newTestServer := func(ctx context.Context, opts ...ServerOption) *httptest.Server {
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, nil, opts...)
+		So(err, ShouldBeNil)
+		return httptest.NewServer(srv.handler())
+	}
+
+	Convey("Given a server with the default origin check", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ts := newTestServer(ctx)
+		defer ts.Close()
+
+		Convey("Then same-origin upgrades are accepted", func() {
+			status, err := dial(ts, ts.URL)
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		Convey("Then cross-origin upgrades are rejected", func() {
+			status, err := dial(ts, "http://elsewhere.example")
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(status, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Then the page's websocket url is that by which the server was reached", func() {
+			resp, err := http.Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			host := strings.TrimPrefix(ts.URL, "http://")
+			So(string(body), ShouldContainSubstring, `data-websocket="ws://`+host+`/ws"`)
+		})
+
+		Convey("Then the page loads its bootstrap script, which reconnects its websocket", func() {
+			resp, err := http.Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			So(string(body), ShouldContainSubstring, `<script src="/static/bootstrap.js"`)
+
+			script, err := http.Get(ts.URL + "/static/bootstrap.js")
+			So(err, ShouldBeNil)
+			defer script.Body.Close()
+			So(script.StatusCode, ShouldEqual, http.StatusOK)
+			So(script.Header.Get("Content-Type"), ShouldStartWith, "text/javascript")
+			content, _ := io.ReadAll(script.Body)
+			So(string(content), ShouldContainSubstring, "setTimeout(connect, reconnectDelay)")
+		})
+
+		Convey("Then the bootstrap script may be revalidated by its ETag", func() {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/static/bootstrap.js", nil)
+			req.Header.Set("If-None-Match", staticETags["static/bootstrap.js"])
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotModified)
+		})
+
+		Convey("Then unknown static files are not found", func() {
+			resp, err := http.Get(ts.URL + "/static/missing.js")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+
+	Convey("Given a server configured to permit a single origin", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ts := newTestServer(ctx, WithCheckOrigin(func(r *http.Request) bool {
+			return r.Header.Get("Origin") == "http://allowed.example"
+		}))
+		defer ts.Close()
+
+		Convey("Then upgrades from that origin are accepted", func() {
+			status, err := dial(ts, "http://allowed.example")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		Convey("Then upgrades from other origins are rejected", func() {
+			status, err := dial(ts, "http://elsewhere.example")
+			So(err, ShouldEqual, websocket.ErrBadHandshake)
+			So(status, ShouldEqual, http.StatusForbidden)
+		})
+	})
+}
+
+func TestServerInitialState(t *testing.T) {
+	Convey("Given a server whose states are updated after it starts", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		stateUpdates := make(chan [][][][]grid_world.State)
+		srv, err := NewServer(ctx, "", states, stateUpdates, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		grid_world.Visit(states, func(s *grid_world.State) { s.Value.AtomicSet(-12.34) })
+
+		Convey("Then the page is rendered with the current values", func() {
+			resp, err := http.Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			So(string(body), ShouldContainSubstring, "-12.34")
+		})
+
+		Convey("When a client connects after the views have published the update", func() {
+			stateUpdates <- states
+			// Views publish asynchronously, so wait for the hub to have received the update.
+			deadline := time.Now().Add(5 * time.Second)
+			for !hasLatest(srv.hub, "1-1-value-text") && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", http.Header{"Origin": {ts.URL}})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			Convey("Then it immediately receives the latest update of every cell", func() {
+				updates := []fastview.EleUpdate{}
+				So(conn.ReadJSON(&updates), ShouldBeNil)
+				values := map[string]string{}
+				for _, update := range updates {
+					values[update.EleId] = update.Ops[0].Value
+				}
+				for x := range states {
+					for y := range states[x] {
+						So(values, ShouldContainKey, fmt.Sprintf("%d-%d-value-text", x, y))
+					}
+				}
+				So(values["1-1-value-text"], ShouldEqual, "-12.34")
+			})
+		})
+	})
+}
+
+// hasLatest returns whether the hub has received an update for the passed ele-id.
+func hasLatest(h *hub, eleId string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.latest[eleId]
+	return ok
+}
+
+func TestServerSchemaVersions(t *testing.T) {
+	Convey("Given a server whose views have published an update", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		stateUpdates := make(chan [][][][]grid_world.State)
+		srv, err := NewServer(ctx, "", states, stateUpdates, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		stateUpdates <- states
+		deadline := time.Now().Add(5 * time.Second)
+		for !hasLatest(srv.hub, "1-1-value-text") && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+		Convey("When a client requests the current schema version", func() {
+			conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?v=1", http.Header{"Origin": {ts.URL}})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+
+			Convey("Then it receives the latest update of every element as the full state, then versioned ele messages", func() {
+				msg := fastview.Message{}
+				So(conn.ReadJSON(&msg), ShouldBeNil)
+				So(msg.Version, ShouldEqual, fastview.SchemaVersion)
+				So(msg.Type, ShouldEqual, fastview.MessageFullState)
+				So(msg.Ops, ShouldHaveLength, len(srv.hub.Latest()))
+				eleIds := map[string]bool{}
+				for _, update := range msg.Ops {
+					eleIds[update.EleId] = true
+				}
+				// Both the cell views' and the value function's elements are included.
+				So(eleIds, ShouldContainKey, "1-1-value-text")
+				So(eleIds, ShouldContainKey, "1-1-policy-path")
+				So(eleIds, ShouldContainKey, "valuefunction-group")
+
+				stateUpdates <- states
+				msg = fastview.Message{}
+				So(conn.ReadJSON(&msg), ShouldBeNil)
+				So(msg.Type, ShouldEqual, fastview.MessageEle)
+				So(msg.Ops, ShouldNotBeEmpty)
+			})
+
+			Convey("Then a resync command is answered with the full state", func() {
+				So(conn.WriteJSON(fastview.Command{Cmd: CmdResync}), ShouldBeNil)
+				// The first full state is that of connecting.
+				fullStates := 0
+				So(conn.SetReadDeadline(time.Now().Add(5*time.Second)), ShouldBeNil)
+				for fullStates < 2 {
+					msg := fastview.Message{}
+					So(conn.ReadJSON(&msg), ShouldBeNil)
+					if msg.Type == fastview.MessageFullState {
+						fullStates++
+					}
+				}
+			})
+		})
+
+		Convey("When a client requests an unknown schema version", func() {
+			_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?v=9", http.Header{"Origin": {ts.URL}})
+
+			Convey("Then the upgrade is rejected", func() {
+				So(err, ShouldEqual, websocket.ErrBadHandshake)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestServerSnapshot(t *testing.T) {
+	Convey("Given a server", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		get := func(query string) (*http.Response, string) {
+			resp, err := http.Get(ts.URL + "/snapshot.svg" + query)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return resp, string(body)
+		}
+
+		Convey("Then the snapshot is a standalone svg of a polygon per adjacent four cells", func() {
+			resp, body := get("?angle=45&colormap=viridis")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "image/svg+xml")
+			So(xml.Unmarshal([]byte(body), &struct{}{}), ShouldBeNil)
+			So(body, ShouldNotContainSubstring, "WebSocket")
+			numPolygons := (len(states) - 1) * (len(states[0]) - 1)
+			So(strings.Count(body, "<polygon "), ShouldEqual, numPolygons)
+			// Polygons adjoining only walls, or any finish cell, have fixed fills rather than the colormap's.
+			So(strings.Count(body, `fill="rgb(`)+strings.Count(body, `fill="gray"`)+strings.Count(body, `fill="gold"`), ShouldEqual, numPolygons)
+			So(body, ShouldContainSubstring, `fill="gold"`)
+			So(body, ShouldContainSubstring, `transform="scale(`)
+		})
+
+		Convey("Then invalid params are rejected", func() {
+			resp, _ := get("?angle=50")
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			resp, _ = get("?colormap=plasma")
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			resp, _ = get("?format=png")
+			So(resp.StatusCode, ShouldEqual, http.StatusNotImplemented)
+		})
+
+		Convey("Then concurrent snapshots succeed while the values are updated", func() {
+			updated := make(chan struct{})
+			go func() {
+				defer close(updated)
+				for i := 0; i < 100; i++ {
+					grid_world.Visit(states, func(s *grid_world.State) { s.Value.AtomicSet(float64(-i)) })
+				}
+			}()
+
+			statuses := make(chan int, 4)
+			for i := 0; i < cap(statuses); i++ {
+				go func() {
+					resp, err := http.Get(ts.URL + "/snapshot.svg")
+					if err != nil {
+						statuses <- 0
+						return
+					}
+					resp.Body.Close()
+					statuses <- resp.StatusCode
+				}()
+			}
+			for i := 0; i < cap(statuses); i++ {
+				So(<-statuses, ShouldEqual, http.StatusOK)
+			}
+			<-updated
+		})
+	})
+}
+
+// fakeController counts the commands it receives.
+type fakeController struct {
+	resets   int
+	resetErr error
+	holds    int
+	steps    int
+	stepErr  error
+}
+
+func (fc *fakeController) Pause()                         {}
+func (fc *fakeController) Resume()                        {}
+func (fc *fakeController) SetParam(string, float64) error { return nil }
+func (fc *fakeController) Reset() error {
+	fc.resets++
+	return fc.resetErr
+}
+func (fc *fakeController) Hold(fn func()) {
+	fc.holds++
+	fn()
+}
+func (fc *fakeController) Step() (reinforcement.Metrics, error) {
+	fc.steps++
+	return reinforcement.Metrics{EpisodeCount: fc.steps}, fc.stepErr
+}
+
+func TestServerValue(t *testing.T) {
+	Convey("Given a server whose states have distinct values", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		grid_world.Visit(states, func(s *grid_world.State) {
+			s.Value.AtomicSet(float64(s.X) + float64(s.Y)/10 + float64(s.VX)/100 + float64(s.VY)/1000)
+		})
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		get := func(query string) (*http.Response, string) {
+			resp, err := http.Get(ts.URL + "/value" + query)
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return resp, string(body)
+		}
+
+		Convey("Then the value of the state of the passed position and velocities is returned", func() {
+			resp, body := get("?x=1&y=2&vx=-1&vy=3")
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+			var value stateValue
+			So(json.Unmarshal([]byte(body), &value), ShouldBeNil)
+			So(value.Value, ShouldEqual, states[1][2][grid_world.VelIndex(states, -1)][grid_world.VelIndex(states, 3)].Value.AtomicRead())
+			So(value.Value, ShouldAlmostEqual, 1.2-0.01+0.003)
+			So(value.CellType, ShouldEqual, "o")
+		})
+
+		Convey("Then the extreme velocities are in range", func() {
+			max := grid_world.DefaultKinematics.MaxVelocity
+			resp, body := get(fmt.Sprintf("?x=0&y=0&vx=%d&vy=%d", -max, max))
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(body, ShouldContainSubstring, `"cellType":"W"`)
+		})
+
+		Convey("Then missing, malformed, and out of range params are bad requests", func() {
+			max := grid_world.DefaultKinematics.MaxVelocity
+			for _, query := range []string{
+				"?y=2&vx=0&vy=1",
+				"?x=a&y=2&vx=0&vy=1",
+				"?x=-1&y=2&vx=0&vy=1",
+				fmt.Sprintf("?x=%d&y=2&vx=0&vy=1", len(states)),
+				fmt.Sprintf("?x=1&y=%d&vx=0&vy=1", len(states[0])),
+				fmt.Sprintf("?x=1&y=2&vx=%d&vy=1", max+1),
+				fmt.Sprintf("?x=1&y=2&vx=0&vy=%d", -max-1),
+			} {
+				resp, _ := get(query)
+				So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+			}
+		})
+	})
+}
+
+func TestServerGrid(t *testing.T) {
+	Convey("Given a server of the debug track", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		Convey("When its grid is requested", func() {
+			resp, err := http.Get(ts.URL + "/grid")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+			var layout gridLayout
+			So(json.NewDecoder(resp.Body).Decode(&layout), ShouldBeNil)
+
+			Convey("Then its dimensions are those of the state grid", func() {
+				So(layout.Width, ShouldEqual, len(grid_world.DebugTrack[0]))
+				So(layout.Height, ShouldEqual, len(grid_world.DebugTrack))
+				So(layout.Velocities, ShouldEqual, grid_world.DefaultKinematics.NumVelocities())
+				So(layout.MaxVelocity, ShouldEqual, grid_world.DefaultKinematics.MaxVelocity)
+			})
+
+			Convey("Then its cells are indexed [x][y], with y increasing upward from the bottom row", func() {
+				So(len(layout.Cells), ShouldEqual, layout.Width)
+				track := grid_world.DebugTrack
+				for x := range layout.Cells {
+					So(len(layout.Cells[x]), ShouldEqual, layout.Height)
+					for y := range layout.Cells[x] {
+						So(layout.Cells[x][y], ShouldEqual, string(track[len(track)-1-y][x]))
+					}
+				}
+			})
+		})
+	})
+}
+
+func TestServerReset(t *testing.T) {
+	Convey("Given a server with a training controller", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		controller := &fakeController{}
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, controller)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		Convey("Then posting to /reset resets training", func() {
+			resp, err := http.Post(ts.URL+"/reset", "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(controller.resets, ShouldEqual, 1)
+		})
+
+		Convey("Then failed resets are reported", func() {
+			controller.resetErr = fmt.Errorf("stopped")
+			resp, err := http.Post(ts.URL+"/reset", "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusInternalServerError)
+		})
+
+		Convey("Then other methods are not allowed", func() {
+			resp, err := http.Get(ts.URL + "/reset")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+			So(controller.resets, ShouldEqual, 0)
+		})
+
+		Convey("Then snapshots are converted while training is held", func() {
+			resp, err := http.Get(ts.URL + "/snapshot.svg")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(controller.holds, ShouldEqual, 1)
+		})
+
+		Convey("Then the reset command resets training", func() {
+			commands := make(chan fastview.Command, 1)
+			commands <- fastview.Command{Cmd: CmdReset}
+			close(commands)
+			srv.handleCommands(commands, make(chan struct{}, 1))
+			So(controller.resets, ShouldEqual, 1)
+		})
+
+		Convey("Then the step command steps training and refreshes the views", func() {
+			commands := make(chan fastview.Command, 1)
+			commands <- fastview.Command{Cmd: CmdStep}
+			close(commands)
+			srv.handleCommands(commands, make(chan struct{}, 1))
+			So(controller.steps, ShouldEqual, 1)
+			// The server has no state updates, so the views only publish per the refresh.
+			deadline := time.Now().Add(5 * time.Second)
+			for !hasLatest(srv.hub, "1-1-value-text") && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(hasLatest(srv.hub, "1-1-value-text"), ShouldBeTrue)
+		})
+
+		Convey("Then failed steps do not refresh the views", func() {
+			controller.stepErr = fmt.Errorf("running")
+			commands := make(chan fastview.Command, 1)
+			commands <- fastview.Command{Cmd: CmdStep}
+			close(commands)
+			srv.handleCommands(commands, make(chan struct{}, 1))
+			So(controller.steps, ShouldEqual, 1)
+			time.Sleep(5 * DefaultBatchWindow)
+			So(hasLatest(srv.hub, "1-1-value-text"), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a server without a training controller", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		Convey("Then /reset is not found", func() {
+			resp, err := http.Post(ts.URL+"/reset", "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func TestServerAuth(t *testing.T) {
+	Convey("Given a server configured with an auth token", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		controller := &fakeController{}
+		srv, err := NewServer(ctx, "", states, nil, nil, nil, controller, WithAuthToken("secret"))
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+		wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+		Convey("Then upgrades passing the token are accepted", func() {
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL+"?v=1&token=secret", http.Header{"Origin": {ts.URL}})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusSwitchingProtocols)
+		})
+
+		for _, query := range []string{"", "?token=", "?token=secre", "?token=secrets"} {
+			Convey(fmt.Sprintf("Then upgrades with query %q are unauthorized", query), func() {
+				_, resp, err := websocket.DefaultDialer.Dial(wsURL+query, http.Header{"Origin": {ts.URL}})
+				So(err, ShouldEqual, websocket.ErrBadHandshake)
+				So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			})
+		}
+
+		Convey("Then /reset requires the token", func() {
+			resp, err := http.Post(ts.URL+"/reset?token=wrong", "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(controller.resets, ShouldEqual, 0)
+
+			resp, err = http.Post(ts.URL+"/reset?token=secret", "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusNoContent)
+			So(controller.resets, ShouldEqual, 1)
+		})
+
+		Convey("Then the page does not disclose the token, and read-only endpoints remain open", func() {
+			resp, err := http.Get(ts.URL)
+			So(err, ShouldBeNil)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(string(body), ShouldNotContainSubstring, "secret")
+
+			resp, err = http.Get(ts.URL + "/grid")
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	Convey("Given an empty state grid", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		Convey("Then NewServer returns an error rather than exiting", func() {
+			srv, err := NewServer(ctx, "", [][][][]grid_world.State{}, nil, nil, nil, nil)
+			So(srv, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestPublishConfig(t *testing.T) {
+	Convey("Given a state grid", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+
+		Convey("When no publish config is passed", func() {
+			srv, err := NewServer(ctx, "", states, nil, nil, nil, nil)
+
+			Convey("Then the defaults are used", func() {
+				So(err, ShouldBeNil)
+				So(srv.publish, ShouldResemble, PublishConfig{
+					BatchWindow: DefaultBatchWindow,
+					Resolution:  DefaultPublishResolution,
+				})
+			})
+		})
+
+		Convey("When a partial publish config is passed", func() {
+			srv, err := NewServer(ctx, "", states, nil, nil, nil, nil,
+				WithPublishConfig(PublishConfig{Resolution: time.Second}))
+
+			Convey("Then its zero fields are defaulted", func() {
+				So(err, ShouldBeNil)
+				So(srv.publish, ShouldResemble, PublishConfig{
+					BatchWindow: DefaultBatchWindow,
+					Resolution:  time.Second,
+				})
+			})
+		})
+
+		Convey("When the publish resolution is shorter than the batch window", func() {
+			srv, err := NewServer(ctx, "", states, nil, nil, nil, nil,
+				WithPublishConfig(PublishConfig{BatchWindow: time.Second, Resolution: time.Millisecond}))
+
+			Convey("Then NewServer returns an error", func() {
+				So(srv, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a publish interval is negative", func() {
+			srv, err := NewServer(ctx, "", states, nil, nil, nil, nil,
+				WithPublishConfig(PublishConfig{BatchWindow: -time.Second}))
+
+			Convey("Then NewServer returns an error", func() {
+				So(srv, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestServerTelemetry(t *testing.T) {
+	Convey("Given a server receiving training metrics", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		grid_world.Visit(states, func(s *grid_world.State) { s.Value.AtomicSet(-2) })
+		states[1][1][0][0].Value.AtomicSet(3)
+		metrics := make(chan reinforcement.Metrics)
+		srv, err := NewServer(ctx, "", states, nil, metrics, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		metrics <- reinforcement.Metrics{EpisodeCount: 42, EpisodesGenerated: 45, EpisodeBacklog: 3, EpisodesPerSecond: 1000}
+		// The metrics are recorded before they are forwarded to the views, hence upon the next send.
+		metrics <- reinforcement.Metrics{EpisodeCount: 42, EpisodesGenerated: 45, EpisodeBacklog: 3, EpisodesPerSecond: 1000}
+
+		scrape := func() string {
+			resp, err := http.Get(ts.URL + "/metrics")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			body, _ := io.ReadAll(resp.Body)
+			return string(body)
+		}
+
+		Convey("Then /metrics exposes the training metrics", func() {
+			body := scrape()
+			So(body, ShouldContainSubstring, "tabular_episodes_processed_total 42")
+			So(body, ShouldContainSubstring, "tabular_episodes_generated_total 45")
+			So(body, ShouldContainSubstring, "tabular_episode_backlog 3")
+			So(body, ShouldContainSubstring, "tabular_episodes_per_second 1000")
+		})
+
+		Convey("Then /metrics exposes the state value stats", func() {
+			body := scrape()
+			So(body, ShouldContainSubstring, "tabular_state_value_max 3")
+			So(body, ShouldContainSubstring, "tabular_state_value_mean -1.9")
+		})
+
+		Convey("Then /metrics exposes the goroutine count", func() {
+			So(scrape(), ShouldContainSubstring, "go_goroutines ")
+		})
+
+		Convey("Then /metrics exposes the number of connected clients", func() {
+			So(scrape(), ShouldContainSubstring, "tabular_websocket_clients 0")
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", http.Header{"Origin": {ts.URL}})
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			deadline := time.Now().Add(5 * time.Second)
+			for srv.connected.Load() == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(scrape(), ShouldContainSubstring, "tabular_websocket_clients 1")
+		})
+
+		Convey("Then /metrics exposes the number of dropped frames", func() {
+			So(scrape(), ShouldContainSubstring, "tabular_websocket_frames_dropped_total 0")
+			srv.dropped.Add(2)
+			So(scrape(), ShouldContainSubstring, "tabular_websocket_frames_dropped_total 2")
+		})
+	})
+}
+
+// serverRoutines polls, up to the timeout, for the goroutines other than the caller's that run
+// code of the server, its views, or channerics to return, returning the stacks of those remaining.
+func serverRoutines(timeout time.Duration) (routines []string) {
+	deadline := time.Now().Add(timeout)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n")
+		routines = nil
+		// The first stack is the caller's.
+		for _, stack := range stacks[1:] {
+			if strings.Contains(stack, "tabular/server") || strings.Contains(stack, "niceyeti/channerics") {
+				routines = append(routines, stack)
+			}
+		}
+		if len(routines) == 0 || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerTeardown(t *testing.T) {
+	Convey("Given a server streaming state updates to a connected client", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		stateUpdates := make(chan [][][][]grid_world.State)
+		producers := make(chan struct{})
+		go func() {
+			defer close(producers)
+			for {
+				select {
+				case stateUpdates <- states:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		srv, err := NewServer(ctx, "", states, stateUpdates, nil, nil, nil)
+		So(err, ShouldBeNil)
+		ts := httptest.NewServer(srv.handler())
+		defer ts.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?v=1", http.Header{"Origin": {ts.URL}})
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		So(conn.ReadJSON(&fastview.Message{}), ShouldBeNil)
+		// The client reads until the server closes the websocket, replying to its close frame.
+		readErr := make(chan error, 1)
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					readErr <- err
+					return
+				}
+			}
+		}()
+
+		Convey("When the server's context is cancelled", func() {
+			cancel()
+			<-producers
+
+			Convey("Then the client's websocket is closed normally", func() {
+				select {
+				case err := <-readErr:
+					So(websocket.IsCloseError(err, websocket.CloseNormalClosure), ShouldBeTrue)
+				case <-time.After(5 * time.Second):
+					t.Fatal("the websocket was not closed")
+				}
+			})
+
+			Convey("Then the client's handler returns, and no routine of the server, hub, views or client remains", func() {
+				<-readErr
+				clientsClosed := make(chan struct{})
+				go func() {
+					srv.clients.Wait()
+					close(clientsClosed)
+				}()
+				select {
+				case <-clientsClosed:
+				case <-time.After(5 * time.Second):
+					t.Fatal("the client's handler did not return")
+				}
+				So(serverRoutines(5*time.Second), ShouldBeEmpty)
+			})
+		})
+	})
+}
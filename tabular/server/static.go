@@ -0,0 +1,52 @@
+package server
+
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+// staticFiles are the client assets, such as the page's websocket bootstrap script, served
+// under /static/ such that they may be edited, linted, and tested as plain files.
+//
+//go:embed static
+var staticFiles embed.FS
+
+// staticETags are the content hashes of the static files, by path, e.g. "static/bootstrap.js".
+var staticETags = hashFiles(staticFiles)
+
+// hashFiles returns a quoted ETag of the content hash of every file of the passed fs, by path.
+func hashFiles(fsys fs.FS) map[string]string {
+	etags := map[string]string{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		etags[path] = fmt.Sprintf(`"%x"`, sha256.Sum256(content))
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return etags
+}
+
+// serveStatic serves the static files. Embedded files have no modification time, so each is
+// served with the ETag of its content, and browsers are directed to revalidate their cached
+// copies, which are then only refetched once they change.
+func serveStatic() http.Handler {
+	files := http.FileServer(http.FS(staticFiles))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag, ok := staticETags[r.URL.Path[1:]]; ok {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,119 @@
+// bootstrap.js connects the page to the server's websocket, applying the ele-updates it pushes
+// to the page's views, and sends client commands. The websocket url is that of the data-websocket
+// attribute of this script's tag, which the server sets per the address by which it was reached.
+
+// The websocket message schema version understood by this script; see fastview.SchemaVersion.
+const schemaVersion = 1;
+// The websocket url, per this script's tag, requesting the schema version.
+const wsURL = new URL(document.currentScript.dataset.websocket);
+wsURL.searchParams.set("v", schemaVersion);
+// The auth token of the page's url, if any, which the server requires of the websocket when configured.
+const token = new URLSearchParams(window.location.search).get("token");
+if (token) {
+	wsURL.searchParams.set("token", token);
+}
+// The websocket, replaced upon each reconnection.
+let ws;
+// The delay before reconnecting, doubled per failed attempt up to the max, in ms.
+const minReconnectDelay = 500;
+const maxReconnectDelay = 10000;
+let reconnectDelay = minReconnectDelay;
+
+// Connect the websocket, reconnecting with exponential backoff whenever it closes,
+// e.g. when the server restarts, such that the page stays live. The server sends
+// the full current state to each new connection, which resyncs the page.
+function connect() {
+	ws = new WebSocket(wsURL);
+	ws.onopen = function (event) {
+		console.log("Web socket opened")
+		reconnectDelay = minReconnectDelay;
+	};
+
+	// Listen for errors. A close event follows, upon which reconnection is scheduled.
+	ws.onerror = function (event) {
+		console.log('WebSocket error: ', event);
+	};
+
+	ws.onclose = function (event) {
+		console.log("Web socket closed, reconnecting in " + reconnectDelay + "ms");
+		setTimeout(connect, reconnectDelay);
+		reconnectDelay = Math.min(2 * reconnectDelay, maxReconnectDelay);
+	};
+
+	// The meat: when the server pushes messages, dispatch them per their type.
+	ws.onmessage = function (event) {
+		const msg = JSON.parse(event.data);
+		// Version 0 messages are bare ele-updates, sent by servers predating the versioned schema.
+		if (Array.isArray(msg)) {
+			applyEleUpdates(msg);
+			return;
+		}
+		if (msg.version > schemaVersion) {
+			console.log("Unsupported message version, ignored: ", msg.version);
+			return;
+		}
+		switch (msg.type) {
+		case "ele":
+		case "fullstate":
+			applyEleUpdates(msg.ops || []);
+			break;
+		default:
+			console.log("Unknown message type, ignored: ", msg.type);
+		}
+	}
+}
+
+// Apply ele-updates to the page: find each ele by id and set its attributes or text content.
+function applyEleUpdates(updates) {
+	// FUTURE: scope the updates per view. Not really needed now, just grab them by id from doc level.
+	for (const update of updates) {
+		const ele = document.getElementById(update.EleId)
+		for (const op of update.Ops) {
+			if (op.Key === "textContent") {
+				ele.textContent = op.Value;
+			} else {
+				ele.setAttribute(op.Key, op.Value)
+			}
+		}
+	}
+}
+
+connect();
+
+// Send a message to the server, if connected; messages are dropped while reconnecting.
+function send(msg) {
+	if (ws.readyState !== WebSocket.OPEN) {
+		console.log("Web socket not open, dropped: ", msg);
+		return;
+	}
+	ws.send(JSON.stringify(msg));
+}
+
+// Send a command to the server, e.g. to pause or resume training.
+function sendCommand(cmd) {
+	send({ cmd: cmd });
+}
+
+// Set the value function's view angle, in degrees.
+function sendAngle() {
+	const val = parseFloat(document.getElementById("view-angle").value);
+	send({ cmd: "setAngle", val: val });
+}
+
+// Inspect the velocity substates of the cell at the passed grid coordinates.
+function inspectCell(x, y) {
+	send({ cmd: "inspect", x: x, y: y });
+}
+
+// Set the value function's colormap by name.
+function sendColorMap() {
+	const key = document.getElementById("color-map").value;
+	send({ cmd: "setColorMap", key: key });
+}
+
+// Set a hyper-parameter during training. Invalid values are ignored by the server.
+function sendParam() {
+	const key = document.getElementById("param-key").value;
+	const val = parseFloat(document.getElementById("param-val").value);
+	send({ cmd: "setParam", key: key, val: val });
+}
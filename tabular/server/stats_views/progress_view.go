@@ -0,0 +1,76 @@
+package stats_views
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+
+	"tabular/reinforcement"
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// ProgressView is a HUD of training progress: episodes processed, epsilon, eta, the episode
+// rate and backlog, and the quality of the greedy policy. It depends only on training metrics, not the
+// grid, so works for any track.
+type ProgressView struct {
+	id      string
+	updates <-chan []fastview.EleUpdate
+}
+
+var progressRows = []statsRow{
+	{eleId: "progress-episodes", label: "Episodes"},
+	{eleId: "progress-epsilon", label: "Epsilon"},
+	{eleId: "progress-eta", label: "Eta"},
+	{eleId: "progress-rate", label: "Episodes/sec"},
+	{eleId: "progress-backlog", label: "Episode backlog"},
+	{eleId: "progress-policy-steps", label: "Policy steps"},
+	{eleId: "progress-policy-fails", label: "Policy fail rate"},
+	{eleId: "progress-policy-changes", label: "Policy changes"},
+	{eleId: "progress-value-error", label: "Value error (RMS)"},
+}
+
+// NewProgressView returns a view of the training metrics published on the passed chan.
+// The episode rate is the mean over each publication interval.
+func NewProgressView(
+	done <-chan struct{},
+	metricsUpdates <-chan reinforcement.Metrics,
+) (pv *ProgressView) {
+	pv = &ProgressView{id: "progress"}
+	pv.updates = channerics.Convert(done, metricsUpdates, pv.onUpdate)
+	return
+}
+
+func (pv *ProgressView) Updates() <-chan []fastview.EleUpdate {
+	return pv.updates
+}
+
+// onUpdate maps the passed metrics to ele-updates of the progress rows, in the same order.
+func (pv *ProgressView) onUpdate(metrics reinforcement.Metrics) []fastview.EleUpdate {
+	// The value error is only computed against a reference.
+	valueError := "n/a"
+	if !math.IsNaN(metrics.ValueError) {
+		valueError = fmt.Sprintf("%.3f", metrics.ValueError)
+	}
+	return []fastview.EleUpdate{
+		textUpdate(progressRows[0].eleId, fmt.Sprintf("%d", metrics.EpisodeCount)),
+		textUpdate(progressRows[1].eleId, fmt.Sprintf("%.4f", metrics.Epsilon)),
+		textUpdate(progressRows[2].eleId, fmt.Sprintf("%.4f", metrics.Eta)),
+		textUpdate(progressRows[3].eleId, fmt.Sprintf("%.1f", metrics.EpisodesPerSecond)),
+		textUpdate(progressRows[4].eleId, fmt.Sprintf("%d", metrics.EpisodeBacklog)),
+		textUpdate(progressRows[5].eleId, fmt.Sprintf("%.1f", metrics.PolicySteps)),
+		textUpdate(progressRows[6].eleId, fmt.Sprintf("%.2f", metrics.PolicyFailRate)),
+		textUpdate(progressRows[7].eleId, fmt.Sprintf("%d", metrics.PolicyChanges)),
+		textUpdate(progressRows[8].eleId, valueError),
+	}
+}
+
+// Parse returns a table of labeled progress values, populated by updates.
+func (pv *ProgressView) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = pv.id
+	err = parseStatsTable(parent, name, progressRows)
+	return
+}
@@ -0,0 +1,52 @@
+package stats_views
+
+import (
+	"html/template"
+
+	"tabular/server/fastview"
+)
+
+// statsRow is a labeled text element in a stats table, whose content is set by ele-updates.
+type statsRow struct {
+	eleId string
+	label string
+}
+
+// parseStatsTable defines a template of the passed name containing a two-column table
+// of labels and their values. Values are placeholders until the first update.
+func parseStatsTable(
+	parent *template.Template,
+	name string,
+	rows []statsRow,
+) (err error) {
+	var tableRows string
+	for _, row := range rows {
+		tableRows += `
+				<tr>
+					<td>` + template.HTMLEscapeString(row.label) + `</td>
+					<td id="` + row.eleId + `">-</td>
+				</tr>`
+	}
+
+	_, err = parent.Parse(
+		`{{ define "` + name + `" }}
+		<div style="padding:10px; font-family:monospace;">
+			<table id="` + name + `">` + tableRows + `
+			</table>
+		</div>
+		{{ end }}`)
+	return
+}
+
+// textUpdate returns an ele-update setting the text content of the passed element.
+func textUpdate(eleId, text string) fastview.EleUpdate {
+	return fastview.EleUpdate{
+		EleId: eleId,
+		Ops: []fastview.Op{
+			{
+				Key:   "textContent",
+				Value: text,
+			},
+		},
+	}
+}
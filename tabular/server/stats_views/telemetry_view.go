@@ -0,0 +1,143 @@
+// stats_views contains views of training and runtime statistics, which are independent of the grid.
+package stats_views
+
+import (
+	"fmt"
+	"html/template"
+	"runtime"
+	"time"
+
+	"tabular/server/fastview"
+
+	channerics "github.com/niceyeti/channerics/channels"
+)
+
+// TelemetryView displays golang runtime telemetry: goroutines, memory, and gc stats.
+type TelemetryView struct {
+	id      string
+	updates <-chan []fastview.EleUpdate
+}
+
+// telemetryField is a labeled runtime statistic.
+type telemetryField struct {
+	id    string
+	label string
+	get   func(*runtime.MemStats) string
+}
+
+var telemetryFields = []telemetryField{
+	{
+		id:    "goroutines",
+		label: "Goroutines",
+		get:   func(_ *runtime.MemStats) string { return fmt.Sprintf("%d", runtime.NumGoroutine()) },
+	},
+	{
+		id:    "heapalloc",
+		label: "Heap alloc",
+		get:   func(ms *runtime.MemStats) string { return formatBytes(ms.HeapAlloc) },
+	},
+	{
+		id:    "sys",
+		label: "Sys",
+		get:   func(ms *runtime.MemStats) string { return formatBytes(ms.Sys) },
+	},
+	{
+		id:    "numgc",
+		label: "GC cycles",
+		get:   func(ms *runtime.MemStats) string { return fmt.Sprintf("%d", ms.NumGC) },
+	},
+	{
+		id:    "gcpause",
+		label: "GC pause total",
+		get:   func(ms *runtime.MemStats) string { return time.Duration(ms.PauseTotalNs).String() },
+	},
+	{
+		id:    "gclastpause",
+		label: "GC last pause",
+		get: func(ms *runtime.MemStats) string {
+			return time.Duration(ms.PauseNs[(ms.NumGC+255)%256]).String()
+		},
+	},
+}
+
+// NewTelemetryView returns a view that samples runtime telemetry at the passed rate.
+// Telemetry is independent of the model, hence the input chan is merely drained;
+// it must be read since the view-builder broadcasts models to all of its views.
+// The rate should be much longer than the root view's batching window.
+func NewTelemetryView[T any](
+	done <-chan struct{},
+	input <-chan T,
+	rate time.Duration,
+) (tv *TelemetryView) {
+	tv = &TelemetryView{id: "telemetry"}
+
+	updates := make(chan []fastview.EleUpdate)
+	tv.updates = updates
+	go func() {
+		defer close(updates)
+
+		ticker := channerics.NewTicker(done, rate)
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-input:
+				if !ok {
+					input = nil
+				}
+			case <-ticker:
+				select {
+				case updates <- tv.sample():
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return
+}
+
+func (tv *TelemetryView) Updates() <-chan []fastview.EleUpdate {
+	return tv.updates
+}
+
+// sample reads the current runtime telemetry and returns the ele-updates to display it.
+func (tv *TelemetryView) sample() (ops []fastview.EleUpdate) {
+	ms := &runtime.MemStats{}
+	runtime.ReadMemStats(ms)
+	for _, field := range telemetryFields {
+		ops = append(ops, textUpdate(tv.eleId(field.id), field.get(ms)))
+	}
+	return
+}
+
+func (tv *TelemetryView) eleId(fieldId string) string {
+	return tv.id + "-" + fieldId
+}
+
+// Parse returns a table of labeled telemetry values, populated by updates.
+func (tv *TelemetryView) Parse(
+	parent *template.Template,
+) (name string, err error) {
+	name = tv.id
+	rows := make([]statsRow, len(telemetryFields))
+	for i, field := range telemetryFields {
+		rows[i] = statsRow{eleId: tv.eleId(field.id), label: field.label}
+	}
+	err = parseStatsTable(parent, name, rows)
+	return
+}
+
+// formatBytes returns a human readable byte count.
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"math"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"tabular/grid_world"
+	"tabular/reinforcement"
+)
+
+// telemetry exports training and server internals in the Prometheus format, such that the app
+// may be scraped like any other service; it complements the page's in-browser progress view.
+// Training metrics are those of the latest reinforcement.Metrics received, and the state value
+// stats, client count, and dropped frame count are read upon each scrape.
+type telemetry struct {
+	server *Server
+
+	mu     sync.Mutex
+	latest reinforcement.Metrics
+
+	episodesProcessed *prometheus.Desc
+	episodesGenerated *prometheus.Desc
+	episodeBacklog    *prometheus.Desc
+	episodeRate       *prometheus.Desc
+	meanValue         *prometheus.Desc
+	maxValue          *prometheus.Desc
+	clients           *prometheus.Desc
+	droppedFrames     *prometheus.Desc
+}
+
+func newTelemetry(server *Server) *telemetry {
+	return &telemetry{
+		server: server,
+		episodesProcessed: prometheus.NewDesc("tabular_episodes_processed_total",
+			"The number of episodes processed by the estimator.", nil, nil),
+		episodesGenerated: prometheus.NewDesc("tabular_episodes_generated_total",
+			"The number of episodes generated by the agents.", nil, nil),
+		episodeBacklog: prometheus.NewDesc("tabular_episode_backlog",
+			"The number of episodes generated but not yet processed.", nil, nil),
+		episodeRate: prometheus.NewDesc("tabular_episodes_per_second",
+			"The mean episode rate over the last metrics interval.", nil, nil),
+		meanValue: prometheus.NewDesc("tabular_state_value_mean",
+			"The mean of the state values.", nil, nil),
+		maxValue: prometheus.NewDesc("tabular_state_value_max",
+			"The max of the state values.", nil, nil),
+		clients: prometheus.NewDesc("tabular_websocket_clients",
+			"The number of connected websocket clients.", nil, nil),
+		droppedFrames: prometheus.NewDesc("tabular_websocket_frames_dropped_total",
+			"The number of updates dropped because a client's websocket was congested.", nil, nil),
+	}
+}
+
+// registry returns a registry of the telemetry and the Go runtime's metrics, e.g. go_goroutines.
+func (t *telemetry) registry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(t, collectors.NewGoCollector())
+	return registry
+}
+
+// observe records each of the passed metrics as the latest, and forwards it to the returned
+// chan, which is closed once the passed chan is or done is.
+func (t *telemetry) observe(
+	done <-chan struct{},
+	metricsUpdates <-chan reinforcement.Metrics,
+) <-chan reinforcement.Metrics {
+	forwarded := make(chan reinforcement.Metrics)
+	go func() {
+		defer close(forwarded)
+		for {
+			select {
+			case <-done:
+				return
+			case metrics, ok := <-metricsUpdates:
+				if !ok {
+					return
+				}
+				t.mu.Lock()
+				t.latest = metrics
+				t.mu.Unlock()
+
+				select {
+				case forwarded <- metrics:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return forwarded
+}
+
+// Describe implements prometheus.Collector.
+func (t *telemetry) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		t.episodesProcessed, t.episodesGenerated, t.episodeBacklog, t.episodeRate,
+		t.meanValue, t.maxValue, t.clients, t.droppedFrames,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (t *telemetry) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	latest := t.latest
+	t.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(t.episodesProcessed, prometheus.CounterValue, float64(latest.EpisodeCount))
+	ch <- prometheus.MustNewConstMetric(t.episodesGenerated, prometheus.CounterValue, float64(latest.EpisodesGenerated))
+	ch <- prometheus.MustNewConstMetric(t.episodeBacklog, prometheus.GaugeValue, float64(latest.EpisodeBacklog))
+	ch <- prometheus.MustNewConstMetric(t.episodeRate, prometheus.GaugeValue, latest.EpisodesPerSecond)
+
+	mean, max := valueStats(t.server.states)
+	ch <- prometheus.MustNewConstMetric(t.meanValue, prometheus.GaugeValue, mean)
+	ch <- prometheus.MustNewConstMetric(t.maxValue, prometheus.GaugeValue, max)
+	ch <- prometheus.MustNewConstMetric(t.clients, prometheus.GaugeValue, float64(t.server.connected.Load()))
+	ch <- prometheus.MustNewConstMetric(t.droppedFrames, prometheus.CounterValue, float64(t.server.dropped.Load()))
+}
+
+// valueStats returns the mean and max of the passed state values, read atomically, hence
+// without racing training.
+func valueStats(states [][][][]grid_world.State) (mean, max float64) {
+	max = math.Inf(-1)
+	var n int
+	grid_world.Visit(states, func(state *grid_world.State) {
+		val := state.Value.AtomicRead()
+		mean += val
+		max = math.Max(max, val)
+		n++
+	})
+	if n == 0 {
+		return 0, 0
+	}
+	return mean / float64(n), max
+}
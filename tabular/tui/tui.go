@@ -0,0 +1,177 @@
+// tui renders training progress to a terminal, as an alternative to the web server's views, e.g.
+// for running headless over ssh. The value grid is drawn as a heatmap of each position's max
+// state value, overlaid with the policy's direction at each position per grid_world.MaxDir.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/nsf/termbox-go"
+
+	"tabular/grid_world"
+	"tabular/server/cell_views"
+)
+
+// Each grid position is drawn as a policy rune followed by a space, both shaded by its value,
+// such that cells are roughly square in most terminal fonts.
+const cellWidth = 2
+
+// cell is a single terminal cell.
+type cell struct {
+	ch     rune
+	fg, bg termbox.Attribute
+}
+
+// Run draws the passed states to the terminal, and redraws them upon each of the passed state
+// updates, at most once per refresh interval. Run returns once ctx is cancelled or the user
+// quits with q, Esc, or Ctrl-C; the terminal is restored before it does. Like the server, Run
+// is the sole consumer of the state updates, which it receives until it returns.
+func Run(
+	ctx context.Context,
+	states [][][][]grid_world.State,
+	stateUpdates <-chan [][][][]grid_world.State,
+	refresh time.Duration,
+) error {
+	if err := termbox.Init(); err != nil {
+		return fmt.Errorf("init terminal: %w", err)
+	}
+	defer termbox.Close()
+	termbox.SetOutputMode(termbox.Output256)
+
+	events := pollEvents()
+	defer func() {
+		// Interrupt blocks until PollEvent receives it, so the events are drained meanwhile.
+		go termbox.Interrupt()
+		for range events {
+		}
+	}()
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	updated, dirty := time.Now(), true
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-stateUpdates:
+			if !ok {
+				stateUpdates = nil
+				break
+			}
+			states, updated, dirty = update, time.Now(), true
+		case ev := <-events:
+			switch {
+			case ev.Type == termbox.EventError:
+				return fmt.Errorf("terminal: %w", ev.Err)
+			case ev.Type == termbox.EventResize:
+				dirty = true
+			case ev.Type == termbox.EventKey &&
+				(ev.Ch == 'q' || ev.Key == termbox.KeyEsc || ev.Key == termbox.KeyCtrlC):
+				return nil
+			}
+		case <-ticker.C:
+			if !dirty {
+				break
+			}
+			if err := draw(states, updated); err != nil {
+				return err
+			}
+			dirty = false
+		}
+	}
+}
+
+// pollEvents returns the terminal's events, until PollEvent is interrupted.
+func pollEvents() <-chan termbox.Event {
+	events := make(chan termbox.Event)
+	go func() {
+		defer close(events)
+		for {
+			ev := termbox.PollEvent()
+			if ev.Type == termbox.EventInterrupt {
+				return
+			}
+			events <- ev
+		}
+	}()
+	return events
+}
+
+// draw renders a frame of the states, followed by a status line, and flushes it to the terminal.
+func draw(states [][][][]grid_world.State, updated time.Time) error {
+	if err := termbox.Clear(termbox.ColorDefault, termbox.ColorDefault); err != nil {
+		return err
+	}
+
+	rows, min, max := frame(states)
+	for y, row := range rows {
+		for x, c := range row {
+			termbox.SetCell(x, y, c.ch, c.fg, c.bg)
+		}
+	}
+
+	status := fmt.Sprintf("min %.2f  max %.2f  updated %s  q: quit",
+		min, max, updated.Format("15:04:05"))
+	for x, ch := range status {
+		termbox.SetCell(x, len(rows)+1, ch, termbox.ColorDefault, termbox.ColorDefault)
+	}
+	return termbox.Flush()
+}
+
+// frame returns the terminal cells of the states by row, top first, and the min and max of
+// their positions' max values, by which they are shaded. Walls are blank, finish cells show
+// their cell type, and all other cells show their policy direction.
+func frame(states [][][][]grid_world.State) (rows [][]cell, min, max float64) {
+	cells := cell_views.Convert(states)
+	min, max = math.Inf(1), math.Inf(-1)
+	for x := range cells {
+		for y := range cells[x] {
+			if states[x][y][0][0].CellType != grid_world.WALL {
+				min = math.Min(min, cells[x][y].Max)
+				max = math.Max(max, cells[x][y].Max)
+			}
+		}
+	}
+
+	rows = make([][]cell, len(states[0]))
+	for i := range rows {
+		rows[i] = make([]cell, cellWidth*len(states))
+	}
+	for x := range cells {
+		for y, c := range cells[x] {
+			// Cells are already oriented with the top of the track at row zero, as in svg.
+			row := rows[c.Y][cellWidth*x : cellWidth*(x+1)]
+			cellType := states[x][y][0][0].CellType
+			switch cellType {
+			case grid_world.WALL:
+				row[0] = cell{ch: ' ', fg: termbox.ColorDefault, bg: termbox.ColorDefault}
+			case grid_world.FINISH, grid_world.FINISH_TIER_1, grid_world.FINISH_TIER_2, grid_world.FINISH_TIER_3:
+				row[0] = cell{ch: cellType, fg: termbox.ColorWhite | termbox.AttrBold, bg: shade(c.Max, min, max)}
+			default:
+				// The direction is selected apart from the cell's max value, so during training
+				// they may momentarily reflect different updates; each is read atomically, and
+				// the next frame reconciles them.
+				dir := grid_world.MaxDir(grid_world.MaxVelState(states[x][y]))
+				row[0] = cell{ch: dir, fg: termbox.ColorWhite | termbox.AttrBold, bg: shade(c.Max, min, max)}
+			}
+			row[1] = cell{ch: ' ', fg: row[0].fg, bg: row[0].bg}
+		}
+	}
+	return
+}
+
+// shade returns the 256-color attribute of the passed value, blended from red at the min, the
+// most costly, to blue at the max; the midpoint if all values are equal.
+func shade(val, min, max float64) termbox.Attribute {
+	frac := 0.5
+	if max > min {
+		frac = math.Max(0, math.Min(1, (val-min)/(max-min)))
+	}
+	// The 6x6x6 color cube begins at color 16, and Output256 attributes are offset by one,
+	// since zero is the default color.
+	red, blue := int(math.Round(5*(1-frac))), int(math.Round(5*frac))
+	return termbox.Attribute(16 + 36*red + blue + 1)
+}
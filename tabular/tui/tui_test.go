@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"testing"
+
+	"tabular/grid_world"
+
+	"github.com/nsf/termbox-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFrame(t *testing.T) {
+	Convey("Given the debug track's states", t, func() {
+		states := grid_world.Convert(grid_world.DebugTrack, grid_world.DefaultKinematics)
+		grid_world.Visit(states, func(s *grid_world.State) { s.Value.AtomicSet(-10) })
+		// The best substate of the cell at (1,1) heads right.
+		var best *grid_world.State
+		grid_world.Visit(states[1:2], func(s *grid_world.State) {
+			if s.Y == 1 && s.VX == 2 && s.VY == 0 {
+				best = s
+			}
+		})
+		best.Value.AtomicSet(5)
+
+		Convey("When a frame is rendered", func() {
+			rows, min, max := frame(states)
+
+			Convey("Then it spans the track, two terminal cells per position", func() {
+				So(len(rows), ShouldEqual, len(states[0]))
+				for _, row := range rows {
+					So(len(row), ShouldEqual, cellWidth*len(states))
+				}
+			})
+
+			Convey("Then the top row of the frame is the top row of the track", func() {
+				top := len(states[0]) - 1
+				for x := range states {
+					if states[x][top][0][0].CellType == grid_world.WALL {
+						So(rows[0][cellWidth*x].ch, ShouldEqual, ' ')
+						So(rows[0][cellWidth*x].bg, ShouldEqual, termbox.ColorDefault)
+					}
+				}
+			})
+
+			Convey("Then each position shows its policy direction, shaded by its max value", func() {
+				row := rows[len(states[0])-1-1]
+				So(row[cellWidth*1].ch, ShouldEqual, grid_world.MaxDir(best))
+				So(row[cellWidth*1].ch, ShouldEqual, '>')
+				So(row[cellWidth*1].bg, ShouldEqual, shade(5, min, max))
+				So(row[cellWidth*1+1].bg, ShouldEqual, row[cellWidth*1].bg)
+				So(min, ShouldEqual, -10)
+				So(max, ShouldEqual, 5)
+			})
+		})
+	})
+}
+
+func TestShade(t *testing.T) {
+	Convey("Given a range of values", t, func() {
+		Convey("Then the min is red, the max is blue, and equal values are shaded alike", func() {
+			So(shade(-10, -10, 5), ShouldEqual, termbox.Attribute(16+36*5+1))
+			So(shade(5, -10, 5), ShouldEqual, termbox.Attribute(16+5+1))
+			So(shade(1, 1, 1), ShouldEqual, shade(-1, -1, -1))
+		})
+	})
+}